@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+	log "github.com/sirupsen/logrus"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply PLAN_FILE",
+	Short: "Apply a saved plan",
+	Long: `Applies a plan created by 'secretsync plan'.
+
+Before writing anything, apply recomputes the configuration hash and each
+target's bundle ID, source, merge store and destination hashes. If any of
+them differ from the plan, apply refuses to run and lists what changed.
+
+Apply then runs exactly the plan: each target checks its planned hashes
+again right before it writes. A merge only writes the planned bundle over
+the planned merge store bundle, and a sync only writes when the bundle it
+read and the AWS secrets it would touch are as planned. A target that finds
+a change fails without writing anything. Only a change made between a
+target's final check and its own writes goes undetected.
+
+Examples:
+  secretsync plan --config config.yaml --out plan.bin
+  secretsync apply --config config.yaml plan.bin`,
+	Args: cobra.ExactArgs(1),
+	RunE: runApply,
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	l := log.WithFields(log.Fields{
+		"action": "runApply",
+		"plan":   args[0],
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	plan, err := pipeline.ReadPlan(args[0])
+	if err != nil {
+		return err
+	}
+
+	p, err := pipeline.NewFromFileWithContext(ctx, cfgFile, overlayFiles...)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		l.Warn("Received shutdown signal")
+		cancel()
+	}()
+
+	results, err := p.Apply(ctx, plan, pipeline.Options{ContinueOnError: true})
+	var driftErr *pipeline.PlanDriftError
+	if errors.As(err, &driftErr) {
+		fmt.Println("❌ Plan is stale, nothing was applied:")
+		for _, d := range driftErr.Drift {
+			if d.Target == "" {
+				fmt.Printf("   - %s changed\n", d.Kind)
+			} else {
+				fmt.Printf("   - %s: %s changed\n", d.Target, d.Kind)
+			}
+		}
+		return err
+	}
+
+	printResults(results)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if !r.Success {
+			return fmt.Errorf("apply completed with errors")
+		}
+	}
+	return nil
+}
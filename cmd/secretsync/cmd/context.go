@@ -40,7 +40,7 @@ func runContext(cmd *cobra.Command, args []string) error {
 	// Try to load config for AWS settings
 	var awsConfig *pipeline.AWSConfig
 	if cfgFile != "" {
-		cfg, err := pipeline.LoadConfig(cfgFile)
+		cfg, err := pipeline.LoadConfig(cfgFile, overlayFiles...)
 		if err != nil {
 			return fmt.Errorf("failed to load config file '%s': %w", cfgFile, err)
 		}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/controller"
+	"github.com/jbcom/secretsync/pkg/observability"
+	"github.com/spf13/cobra"
+	log "github.com/sirupsen/logrus"
+)
+
+var controllerCmd = &cobra.Command{
+	Use:   "controller",
+	Short: "Reconcile SecretSync resources in a Kubernetes cluster",
+	Long: `Watches SecretSync custom resources (secretsync.jbcom.dev/v1alpha1) and
+copies the secrets under each resource's Vault source path to its AWS Secrets
+Manager and Vault destinations, applying its filters and transforms.
+
+Every resource is reconciled when it changes and every --resync period. The
+outcome is written to its status: Synced, DryRun, Suspended or "Failed: ...".
+With syncDelete, secrets removed from the source are deleted from the
+destinations: AWS secrets only when tagged as written for this resource,
+Vault secrets only under a non-empty destination path.
+
+status.hash is an HMAC of the spec and the desired secrets, keyed with the
+contents of --hash-key-file. Without it a random key is used, and every
+resource is written again after a restart.
+
+In a cluster the pod's service account is used. Out of cluster, pass
+--kube-api with --kube-token-file and --kube-ca.
+
+Examples:
+  secretsync controller --namespace secrets --resync 5m`,
+	RunE: runController,
+}
+
+var (
+	controllerNamespace string
+	controllerResync    time.Duration
+	controllerListen    string
+	controllerKubeAPI   string
+	controllerKubeToken string
+	controllerKubeCA    string
+	controllerHashKey   string
+)
+
+func init() {
+	rootCmd.AddCommand(controllerCmd)
+	controllerCmd.Flags().StringVar(&controllerNamespace, "namespace", "", "namespace to watch (default: all namespaces)")
+	controllerCmd.Flags().DurationVar(&controllerResync, "resync", controller.DefaultResyncPeriod, "how often unchanged resources are reconciled")
+	controllerCmd.Flags().StringVar(&controllerListen, "listen", ":8080", "address of the health and metrics server")
+	controllerCmd.Flags().StringVar(&controllerKubeAPI, "kube-api", "", "Kubernetes API server URL (default: in-cluster)")
+	controllerCmd.Flags().StringVar(&controllerKubeToken, "kube-token-file", "", "bearer token file for --kube-api")
+	controllerCmd.Flags().StringVar(&controllerKubeCA, "kube-ca", "", "CA bundle of the --kube-api server")
+	controllerCmd.Flags().StringVar(&controllerHashKey, "hash-key-file", "", "file with the key of the status.hash HMAC (default: random per process)")
+}
+
+func runController(cmd *cobra.Command, args []string) error {
+	l := log.WithFields(log.Fields{
+		"action": "runController",
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var kube *controller.KubeClient
+	var err error
+	if controllerKubeAPI != "" {
+		kube, err = controller.NewKubeClient(controllerKubeAPI, controllerKubeToken, controllerKubeCA)
+	} else {
+		kube, err = controller.InClusterClient()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	var hashKey []byte
+	if controllerHashKey != "" {
+		data, err := os.ReadFile(controllerHashKey)
+		if err != nil {
+			return fmt.Errorf("failed to read hash key file: %w", err)
+		}
+		if hashKey = []byte(strings.TrimSpace(string(data))); len(hashKey) == 0 {
+			return fmt.Errorf("hash key file %s is empty", controllerHashKey)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", observability.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+	server := &http.Server{
+		Addr:              controllerListen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		l.WithField("address", controllerListen).Info("Starting server")
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			l.WithError(err).Error("Server failed")
+		}
+	}()
+	defer server.Close()
+
+	return controller.New(kube, controller.Options{
+		Namespace:    controllerNamespace,
+		ResyncPeriod: controllerResync,
+		HashKey:      hashKey,
+	}).Run(ctx)
+}
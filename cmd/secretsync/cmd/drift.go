@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var driftCmd = &cobra.Command{
+	Use:   "drift",
+	Short: "Detect secrets changed by hand in target accounts",
+	Long: `Compares each target's AWS Secrets Manager secrets with the target's
+current bundle in the merge store, after the target's filters and transforms,
+and reports secrets that drifted:
+
+  modified  value edited out of band (the changed keys are listed)
+  deleted   in the bundle but missing from the account
+  added     tagged as managed for the target but not in the bundle
+  unmanaged untagged, named under the target's secret_prefix or name
+            template prefix, and not in the bundle (e.g. created by hand)
+
+Only secrets in the bundle, secrets carrying the target's ownership tags and
+untagged secrets under the target's naming prefix are compared; other
+secrets in the account are ignored. Values are never shown.
+
+Exit codes: 0 = no drift, 1 = drift detected, 2 = a target could not be checked.
+
+In the serve daemon, serve.drift_interval runs the same check on an interval
+and publishes secretsync_drift_secrets{target,kind} gauges.
+
+Examples:
+  secretsync drift --config config.yaml
+  secretsync drift --config config.yaml --targets Serverless_Prod --output json`,
+	RunE: runDrift,
+}
+
+var (
+	driftTargets string
+	driftOutput  string
+)
+
+func init() {
+	rootCmd.AddCommand(driftCmd)
+	driftCmd.Flags().StringVar(&driftTargets, "targets", "", "comma-separated list of targets (default: all)")
+	driftCmd.Flags().StringVarP(&driftOutput, "output", "o", "human", "output format: human, json")
+}
+
+func runDrift(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	p, err := pipeline.NewFromFileWithContext(ctx, cfgFile, overlayFiles...)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+
+	var targetList []string
+	if driftTargets != "" {
+		for _, t := range strings.Split(driftTargets, ",") {
+			targetList = append(targetList, strings.TrimSpace(t))
+		}
+	}
+
+	report, err := p.DetectDrift(ctx, targetList)
+	if err != nil {
+		return err
+	}
+
+	if driftOutput == "json" {
+		out, err := report.JSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Print(formatDriftText(report))
+	}
+
+	if code := report.ExitCode(); code != 0 {
+		shutdownTracing()
+		os.Exit(code)
+	}
+	return nil
+}
+
+// formatDriftText formats a drift report for the terminal
+func formatDriftText(report *pipeline.DriftReport) string {
+	var sb strings.Builder
+	for _, t := range report.Targets {
+		switch {
+		case t.Error != "":
+			sb.WriteString(fmt.Sprintf("✗ %s: check failed: %s\n", t.Target, t.Error))
+			continue
+		case len(t.Secrets) == 0:
+			sb.WriteString(fmt.Sprintf("✓ %s: no drift (%d secrets)\n", t.Target, t.Checked))
+		default:
+			counts := make([]string, 0, len(pipeline.DriftKinds))
+			for _, kind := range pipeline.DriftKinds {
+				if n := t.Count(kind); n > 0 {
+					counts = append(counts, fmt.Sprintf("%d %s", n, kind))
+				}
+			}
+			sb.WriteString(fmt.Sprintf("! %s: %s (%d secrets)\n", t.Target, strings.Join(counts, ", "), t.Checked))
+		}
+		for _, s := range t.Secrets {
+			sb.WriteString(fmt.Sprintf("    %-9s %s\n", s.Kind, s.Name))
+			for _, keys := range []struct {
+				label string
+				keys  []string
+			}{{"+ keys", s.KeysAdded}, {"- keys", s.KeysRemoved}, {"~ keys", s.KeysModified}} {
+				if len(keys.keys) > 0 {
+					sb.WriteString(fmt.Sprintf("              %s: %s\n", keys.label, strings.Join(keys.keys, ", ")))
+				}
+			}
+		}
+		for _, name := range t.Unreadable {
+			sb.WriteString(fmt.Sprintf("    %-9s %s (could not be read; not compared)\n", "skipped", name))
+		}
+	}
+	return sb.String()
+}
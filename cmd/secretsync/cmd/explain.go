@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Explain where a merged secret key came from",
+	Long: `Shows which import supplied a key of a target's merged bundle, and every
+value it overrode. Provenance is recorded by the merge phase next to the
+bundle in the merge store. Values are always redacted: only their type
+and size are shown.
+
+For targets that inherit from another target, the source is the parent
+target; run explain against the parent to follow the chain.
+
+Examples:
+  secretsync explain --config config.yaml --target Serverless_Prod --secret app/config --key DB_PASSWORD
+
+  # All keys of a secret, as JSON
+  secretsync explain --config config.yaml --target Serverless_Prod --secret app/config --output json`,
+	RunE: runExplain,
+}
+
+var (
+	explainTarget string
+	explainSecret string
+	explainKey    string
+	explainOutput string
+)
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+	explainCmd.Flags().StringVar(&explainTarget, "target", "", "target whose bundle to explain (required)")
+	explainCmd.Flags().StringVar(&explainSecret, "secret", "", "secret path within the bundle (required)")
+	explainCmd.Flags().StringVar(&explainKey, "key", "", "key within the secret (default: all keys)")
+	explainCmd.Flags().StringVarP(&explainOutput, "output", "o", "human", "output format: human, json")
+	_ = explainCmd.MarkFlagRequired("target")
+	_ = explainCmd.MarkFlagRequired("secret")
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	p, err := pipeline.NewFromFileWithContext(ctx, cfgFile, overlayFiles...)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+
+	prov, err := p.ReadProvenance(ctx, explainTarget)
+	if err != nil {
+		return err
+	}
+
+	keys, ok := prov.Secrets[explainSecret]
+	if !ok {
+		return fmt.Errorf("secret %q not found in bundle %s of target %q", explainSecret, prov.BundleID, explainTarget)
+	}
+
+	selected := make(map[string]*pipeline.KeyProvenance)
+	if explainKey != "" {
+		kp, ok := prov.Lookup(explainSecret, explainKey)
+		if !ok {
+			return fmt.Errorf("key %q not found in secret %q", explainKey, explainSecret)
+		}
+		selected[explainKey] = kp
+	} else {
+		selected = keys
+	}
+
+	if explainOutput == "json" {
+		out, err := json.MarshalIndent(map[string]interface{}{
+			"target":    prov.Target,
+			"bundle_id": prov.BundleID,
+			"merged_at": prov.CreatedAt,
+			"secret":    explainSecret,
+			"keys":      selected,
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	fmt.Printf("Target:  %s (bundle %s, merged %s)\n", prov.Target, prov.BundleID, prov.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	fmt.Printf("Secret:  %s\n", explainSecret)
+
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		kp := selected[name]
+		fmt.Printf("\nKey: %s\n", name)
+		fmt.Printf("  ✅ winner:     %s\n", formatOrigin(kp.Winner))
+		for _, o := range kp.Merged {
+			fmt.Printf("  ➕ merged:     %s\n", formatOrigin(o))
+		}
+		for _, o := range kp.Overridden {
+			fmt.Printf("  ❌ overridden: %s\n", formatOrigin(o))
+		}
+	}
+
+	return nil
+}
+
+func formatOrigin(o pipeline.ValueOrigin) string {
+	version := ""
+	if o.Version > 0 {
+		version = fmt.Sprintf(" v%d", o.Version)
+	}
+	return fmt.Sprintf("%s (%s%s) %s", o.Source, o.Path, version, o.Value)
+}
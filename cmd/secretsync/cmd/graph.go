@@ -19,6 +19,7 @@ The graph shows:
 - Targets with their imports
 - Inheritance relationships (target → target)
 - Execution order (by dependency level)
+- The file each target is defined in (with --config includes and --overlay)
 
 Examples:
   vss graph --config config.yaml
@@ -35,7 +36,7 @@ func init() {
 
 func runGraph(cmd *cobra.Command, args []string) error {
 	// Load config
-	cfg, err := pipeline.LoadConfig(cfgFile)
+	cfg, err := pipeline.LoadConfig(cfgFile, overlayFiles...)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
@@ -84,7 +85,11 @@ func printTextGraph(cfg *pipeline.Config, graph *pipeline.Graph) {
 			// Categorize imports
 			var sources, inherited []string
 			for _, imp := range target.Imports {
-				if _, isTarget := cfg.Targets[imp]; isTarget {
+				sel, err := pipeline.ParseImport(imp)
+				if err != nil {
+					sel = pipeline.ImportSelector{Source: imp}
+				}
+				if _, isTarget := cfg.Targets[sel.Source]; isTarget {
 					inherited = append(inherited, imp)
 				} else {
 					sources = append(sources, imp)
@@ -92,6 +97,9 @@ func printTextGraph(cfg *pipeline.Config, graph *pipeline.Graph) {
 			}
 
 			fmt.Printf("   ├── %s (account: %s)\n", name, target.AccountID)
+			if origin := targetOrigin(cfg, name); origin != "" {
+				fmt.Printf("   │   └── defined in: %s\n", origin)
+			}
 			if len(sources) > 0 {
 				fmt.Printf("   │   └── sources: %v\n", sources)
 			}
@@ -184,7 +192,7 @@ func printDotGraph(cfg *pipeline.Config, graph *pipeline.Graph) {
 	fmt.Println("    style=dashed;")
 	fmt.Println("    color=green;")
 	for name, target := range cfg.Targets {
-		fmt.Printf("    \"%s\" [label=\"%s\\n%s\", color=green];\n", name, name, target.AccountID)
+		fmt.Printf("    \"%s\" [label=\"%s\\n%s\", tooltip=\"%s\", color=green];\n", name, name, target.AccountID, targetOrigin(cfg, name))
 	}
 	fmt.Println("  }")
 	fmt.Println()
@@ -193,13 +201,31 @@ func printDotGraph(cfg *pipeline.Config, graph *pipeline.Graph) {
 	fmt.Println("  // Dependencies")
 	for name, target := range cfg.Targets {
 		for _, imp := range target.Imports {
+			sel, err := pipeline.ParseImport(imp)
+			if err != nil {
+				sel = pipeline.ImportSelector{Source: imp}
+			}
 			style := "solid"
-			if _, isTarget := cfg.Targets[imp]; isTarget {
+			if _, isTarget := cfg.Targets[sel.Source]; isTarget {
 				style = "bold" // Inheritance edge
 			}
+			if sel.HasSelector() {
+				fmt.Printf("  \"%s\" -> \"%s\" [style=%s, label=\"%s\"];\n", sel.Source, name, style, sel.Suffix())
+				continue
+			}
 			fmt.Printf("  \"%s\" -> \"%s\" [style=%s];\n", imp, name, style)
 		}
 	}
 
 	fmt.Println("}")
 }
+
+// targetOrigin describes where a target is defined and the overlays that changed it
+func targetOrigin(cfg *pipeline.Config, name string) string {
+	loc := cfg.Origins.Targets[name]
+	origin := loc.String()
+	if len(loc.Overlays) > 0 {
+		origin += fmt.Sprintf(" (overridden by %s)", strings.Join(loc.Overlays, ", "))
+	}
+	return origin
+}
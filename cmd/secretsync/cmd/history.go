@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show recent pipeline runs from the run history",
+	Long: `Lists runs recorded in the run history configured under "history:" in the
+pipeline configuration, newest first. Every run (including dry runs) records
+its operation, targets, per-target results, diff summary, configuration hash
+and the identity that ran it.
+
+When several history sinks are configured, the first of jsonl, s3 and vault
+is read unless --sink selects another.
+
+Examples:
+  secretsync history --config config.yaml
+
+  # Last 5 runs that touched a target, as JSON
+  secretsync history --config config.yaml --target Serverless_Prod --limit 5 --output json`,
+	RunE: runHistory,
+}
+
+var (
+	historyLimit  int
+	historyTarget string
+	historySink   string
+	historyOutput string
+)
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().IntVar(&historyLimit, "limit", 20, "maximum number of runs to show (0 for all)")
+	historyCmd.Flags().StringVar(&historyTarget, "target", "", "only show runs that included this target")
+	historyCmd.Flags().StringVar(&historySink, "sink", "", "history sink to read: jsonl, s3, vault")
+	historyCmd.Flags().StringVarP(&historyOutput, "output", "o", "human", "output format: human, json")
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	cfg, err := pipeline.LoadConfig(cfgFile, overlayFiles...)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	sinks, err := pipeline.NewHistorySinks(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if len(sinks) == 0 {
+		return fmt.Errorf("no run history configured (see history: in the pipeline configuration)")
+	}
+
+	sink := sinks[0]
+	if historySink != "" {
+		sink = nil
+		for _, s := range sinks {
+			if strings.HasPrefix(s.Name(), historySink+":") {
+				sink = s
+				break
+			}
+		}
+		if sink == nil {
+			return fmt.Errorf("history sink %q is not configured", historySink)
+		}
+	}
+
+	var filter func(pipeline.RunRecord) bool
+	if historyTarget != "" {
+		filter = func(r pipeline.RunRecord) bool { return r.HasTarget(historyTarget) }
+	}
+
+	records, err := sink.List(ctx, historyLimit, filter)
+	if err != nil {
+		return fmt.Errorf("failed to read history from %s: %w", sink.Name(), err)
+	}
+
+	if historyOutput == "json" {
+		out, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No runs recorded")
+		return nil
+	}
+
+	for _, r := range records {
+		status := "✅"
+		if !r.Success {
+			status = "❌"
+		}
+		mode := string(r.Operation)
+		if r.DryRun {
+			mode += " (dry run)"
+		}
+		fmt.Printf("%s %s  %s  %s  by %s\n", status, r.FinishedAt.Format("2006-01-02 15:04:05 MST"), r.ID, mode, r.Caller)
+		fmt.Printf("   targets: %s  config: %.12s\n", strings.Join(r.Targets, ", "), r.ConfigHash)
+		if r.DiffSummary != nil {
+			s := r.DiffSummary
+			fmt.Printf("   changes: +%d ~%d -%d (%d unchanged)\n", s.Added, s.Modified, s.Removed, s.Unchanged)
+		}
+		if r.Error != "" {
+			fmt.Printf("   error: %s\n", r.Error)
+		}
+		for _, res := range r.Results {
+			if res.Error != "" {
+				fmt.Printf("   %s [%s]: %s\n", res.Target, res.Phase, res.Error)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import existing AWS Secrets Manager secrets into Vault",
+	Long: `Reads every Secrets Manager secret of one or more accounts and writes it to
+a Vault KV2 mount under <mount>/<account name>, so the pipeline can take over
+secrets that were managed by hand. Accounts are read with the same role as
+sync targets (Control Tower execution role or custom_role_pattern from
+--config), and the configuration's Vault connection is used for writing.
+
+Secret names are used as Vault paths unless a --map rule matches: rules are
+from=to prefixes, tried in order, and the first match replaces the prefix
+("prod/=" strips "prod/", "legacy/app/=app/" renames it).
+
+Writes use check-and-set: new secrets are only created if they do not exist,
+and secrets that exist with different values are reported as conflicts and
+left alone unless --overwrite is set. Secrets that are not JSON objects are
+skipped. Values are never shown. --dry-run reads Vault and reports what would
+be written without writing.
+
+A starter configuration is written to --starter-config with a source and a
+target per account. Running the pipeline with it produces a zero-sum diff:
+every imported secret syncs back to its account under its current name.
+Vault secrets whose values differ from the account (conflicts, failed writes
+and secrets that already existed under <mount>/<account name>) are listed as
+excluded and left out of the target with filters.path.exclude; remove them
+from the filter once they are resolved.
+
+Examples:
+  secretsync import --config config.yaml --mount teams \
+    --account Analytics_Prod=123456789012 --account Analytics_Dev=210987654321/eu-west-1 \
+    --map prod/= --dry-run
+  secretsync import --config config.yaml --mount teams --account Analytics_Prod=123456789012`,
+	RunE: runImport,
+}
+
+var (
+	importAccounts      []string
+	importMount         string
+	importMappings      []string
+	importOverwrite     bool
+	importDryRun        bool
+	importStarterConfig string
+	importOutput        string
+)
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().StringArrayVar(&importAccounts, "account", nil, "account to import as name=account_id[/region] (repeatable)")
+	importCmd.Flags().StringVar(&importMount, "mount", "", "Vault KV2 mount to write secrets to")
+	importCmd.Flags().StringArrayVar(&importMappings, "map", nil, "path mapping rule from=to, replacing an AWS name prefix (repeatable, first match wins)")
+	importCmd.Flags().BoolVar(&importOverwrite, "overwrite", false, "update Vault secrets that exist with different values")
+	importCmd.Flags().BoolVar(&importDryRun, "dry-run", false, "report what would be written without writing to Vault")
+	importCmd.Flags().StringVar(&importStarterConfig, "starter-config", "secretsync-import.yaml", "file to write the starter pipeline configuration to (empty to skip)")
+	importCmd.Flags().StringVarP(&importOutput, "output", "o", "human", "output format: human, json")
+
+	importCmd.MarkFlagRequired("account")
+	importCmd.MarkFlagRequired("mount")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	opts := pipeline.ImportOptions{
+		Mount:     importMount,
+		Overwrite: importOverwrite,
+		DryRun:    importDryRun,
+	}
+	for _, spec := range importAccounts {
+		acct, err := pipeline.ParseImportAccount(spec)
+		if err != nil {
+			return err
+		}
+		opts.Accounts = append(opts.Accounts, acct)
+	}
+	for _, spec := range importMappings {
+		m, err := pipeline.ParsePathMapping(spec)
+		if err != nil {
+			return err
+		}
+		opts.Mappings = append(opts.Mappings, m)
+	}
+
+	p, err := pipeline.NewFromFileWithContext(ctx, cfgFile, overlayFiles...)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+
+	report, err := p.Import(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if importOutput == "json" {
+		out, err := report.JSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		fmt.Print(formatImportText(report))
+	}
+
+	if importStarterConfig != "" && len(report.Config.Targets) > 0 {
+		if err := writeStarterConfig(importStarterConfig, report.Config); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Starter configuration written to %s\n", importStarterConfig)
+	}
+
+	if report.Failed() {
+		return fmt.Errorf("some secrets could not be imported")
+	}
+	return nil
+}
+
+// writeStarterConfig writes the starter configuration of an import
+func writeStarterConfig(path string, cfg *pipeline.Config) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	header := `# Pipeline configuration generated by: secretsync import
+#
+# Syncing with this configuration reproduces the imported secrets in their
+# accounts unchanged. Review before use:
+# - Add Vault authentication
+# - Replace the per-account sources with shared sources as secrets are consolidated
+
+`
+	if err := os.WriteFile(path, []byte(header+string(data)), 0600); err != nil {
+		return fmt.Errorf("failed to write starter config: %w", err)
+	}
+	return nil
+}
+
+// formatImportText formats an import report for the terminal
+func formatImportText(report *pipeline.ImportReport) string {
+	var sb strings.Builder
+	if report.DryRun {
+		sb.WriteString("[DRY-RUN] Nothing was written to Vault\n")
+	}
+	for _, a := range report.Accounts {
+		if a.Error != "" {
+			sb.WriteString(fmt.Sprintf("✗ %s (%s): %s\n", a.Account.Name, a.Account.AccountID, a.Error))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%s (%s) → %s: %d create, %d update, %d unchanged, %d conflict, %d skipped\n",
+			a.Account.Name, a.Account.AccountID, a.BasePath,
+			a.Count(pipeline.ImportCreate), a.Count(pipeline.ImportUpdate), a.Count(pipeline.ImportUnchanged),
+			a.Count(pipeline.ImportConflict), a.Count(pipeline.ImportSkipped)))
+		for _, s := range a.Secrets {
+			line := fmt.Sprintf("    %-9s %s", s.Action, s.Name)
+			if s.VaultPath != "" && s.VaultPath != s.Name {
+				line += " → " + s.VaultPath
+			}
+			switch {
+			case s.Error != "":
+				line += ": " + s.Error
+			case s.Reason != "":
+				line += " (" + s.Reason + ")"
+			case s.Action == pipeline.ImportConflict:
+				line += " (exists in Vault with different values; use --overwrite)"
+			}
+			sb.WriteString(line + "\n")
+		}
+		for _, vaultPath := range a.Excluded {
+			sb.WriteString(fmt.Sprintf("    %-9s %s (differs from the account; not synced by the starter config)\n", "excluded", vaultPath))
+		}
+	}
+	return sb.String()
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -19,6 +20,8 @@ var (
 	outputFile      string
 	vaultAddr       string
 	vaultMergeMount string
+	manifestPaths   []string
+	migrateReport   string
 )
 
 var migrateCmd = &cobra.Command{
@@ -28,26 +31,41 @@ var migrateCmd = &cobra.Command{
 
 Supported sources:
   - terraform-secretsmanager: Terraform-based AWS Secrets Manager pipeline
+  - external-secrets: External Secrets Operator manifests (ExternalSecret,
+    SecretStore, ClusterSecretStore)
+  - vault-secrets-operator: Vault Secrets Operator manifests (VaultStaticSecret,
+    VaultAuth, VaultConnection)
+
+Manifests are read from the files and directories given with --manifests.
+Stores and mounts become sources; each ExternalSecret or VaultStaticSecret
+becomes a target named <namespace>-<secret> with import selectors and key
+transforms. Targets have no account_id; set one for each. Anything that
+cannot be mapped is listed in the migration report (--report, default
+stdout) instead of being dropped.
 
 Example:
   vss migrate --from terraform-secretsmanager \
               --targets config/targets.yaml \
               --secrets config/secrets.yaml \
               --accounts config/accounts.yaml \
-              --output config.yaml`,
+              --output config.yaml
+
+  vss migrate --from external-secrets --manifests k8s/ --output config.yaml`,
 	RunE: runMigrate,
 }
 
 func init() {
 	rootCmd.AddCommand(migrateCmd)
 
-	migrateCmd.Flags().StringVar(&migrateFrom, "from", "", "Source format to migrate from (terraform-secretsmanager)")
+	migrateCmd.Flags().StringVar(&migrateFrom, "from", "", "Source format to migrate from (terraform-secretsmanager, external-secrets, vault-secrets-operator)")
 	migrateCmd.Flags().StringVar(&targetsFile, "targets", "", "Path to targets configuration file")
 	migrateCmd.Flags().StringVar(&secretsFile, "secrets", "", "Path to secrets configuration file")
 	migrateCmd.Flags().StringVar(&accountsFile, "accounts", "", "Path to accounts configuration file")
 	migrateCmd.Flags().StringVar(&outputFile, "output", "pipeline-config.yaml", "Output file path")
 	migrateCmd.Flags().StringVar(&vaultAddr, "vault-addr", "", "Vault address (or set VAULT_ADDR)")
 	migrateCmd.Flags().StringVar(&vaultMergeMount, "vault-merge-mount", "secret/merged", "Vault mount for merged secrets")
+	migrateCmd.Flags().StringArrayVar(&manifestPaths, "manifests", nil, "Kubernetes manifest file or directory (repeatable)")
+	migrateCmd.Flags().StringVar(&migrateReport, "report", "", "File to write the report of unmapped manifest fields to (default stdout)")
 
 	migrateCmd.MarkFlagRequired("from")
 }
@@ -56,6 +74,8 @@ func runMigrate(cmd *cobra.Command, args []string) error {
 	switch migrateFrom {
 	case "terraform-secretsmanager":
 		return migrateTerraformSecretManager()
+	case pipeline.ManifestsExternalSecrets, pipeline.ManifestsVaultSecretsOperator:
+		return migrateManifests(migrateFrom)
 	default:
 		return fmt.Errorf("unsupported migration source: %s", migrateFrom)
 	}
@@ -242,6 +262,99 @@ func migrateTerraformSecretManager() error {
 	return nil
 }
 
+func migrateManifests(from string) error {
+	if len(manifestPaths) == 0 {
+		return fmt.Errorf("--manifests is required for %s migration", from)
+	}
+
+	migration, err := pipeline.MigrateManifests(manifestPaths, from)
+	if err != nil {
+		return err
+	}
+	if len(migration.Targets) == 0 {
+		printMigrationReport(os.Stderr, migration.Issues)
+		return fmt.Errorf("no manifests could be converted")
+	}
+
+	addr := migration.VaultAddress
+	if addr == "" || vaultAddr != "" {
+		addr = getVaultAddr()
+	}
+	cfg := &pipeline.Config{
+		Vault: pipeline.VaultConfig{
+			Address:   addr,
+			Namespace: migration.VaultNamespace,
+		},
+		MergeStore: pipeline.MergeStoreConfig{
+			Vault: &pipeline.MergeStoreVault{
+				Mount: vaultMergeMount,
+			},
+		},
+		Sources: migration.Sources,
+		Targets: migration.Targets,
+		AWS: pipeline.AWSConfig{
+			Region: "us-east-1",
+		},
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	header := fmt.Sprintf(`# Pipeline configuration migrated from %s manifests
+# Generated by: vss migrate --from %s
+#
+# Review and adjust as needed:
+# - Set account_id (and region) for every target
+# - Add Vault authentication
+# - Resolve the entries of the migration report
+
+`, from, from)
+	if err := os.WriteFile(outputFile, []byte(header+string(data)), 0600); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	if migrateReport != "" {
+		f, err := os.Create(migrateReport)
+		if err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+		printMigrationReport(f, migration.Issues)
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	} else {
+		printMigrationReport(os.Stdout, migration.Issues)
+		fmt.Println()
+	}
+
+	fmt.Printf("✅ Migration complete!\n")
+	fmt.Printf("   Output: %s\n", outputFile)
+	fmt.Printf("   Sources: %d\n", len(cfg.Sources))
+	fmt.Printf("   Targets: %d\n", len(cfg.Targets))
+	fmt.Printf("   Unmapped: %d\n", len(migration.Issues))
+	fmt.Println()
+	fmt.Println("Next steps:")
+	fmt.Printf("   1. Review the generated config: %s\n", outputFile)
+	fmt.Println("   2. Set account_id for each target and add Vault authentication")
+	fmt.Println("   3. Validate: vss validate --config " + outputFile)
+	fmt.Println("   4. Dry run: vss pipeline --config " + outputFile + " --dry-run")
+
+	return nil
+}
+
+// printMigrationReport writes the manifest fields that could not be mapped
+func printMigrationReport(w io.Writer, issues []pipeline.MigrationIssue) {
+	if len(issues) == 0 {
+		fmt.Fprintln(w, "Migration report: every manifest was mapped")
+		return
+	}
+	fmt.Fprintf(w, "Migration report: %d items could not be mapped exactly\n", len(issues))
+	for _, issue := range issues {
+		fmt.Fprintf(w, "  - %s\n", issue)
+	}
+}
+
 func loadTerraformTargets(path string) (*TerraformTargetsFile, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -24,6 +24,8 @@ var (
 	outputFormat    string
 	computeDiff     bool
 	exitCodeMode    bool
+	maskValues      bool
+	showValues      bool
 )
 
 // pipelineCmd runs the full merge-then-sync pipeline
@@ -60,6 +62,9 @@ Examples:
   # GitHub Actions compatible output
   secretsync pipeline --config config.yaml --dry-run --output github
 
+  # Markdown report in the job summary and a sticky pull request comment
+  secretsync pipeline --config config.yaml --dry-run --step-summary --pr-comment
+
   # Specific targets only
   secretsync pipeline --config config.yaml --targets "Serverless_Stg,Serverless_Prod"
 
@@ -67,7 +72,13 @@ Examples:
   secretsync pipeline --config config.yaml --merge-only
 
   # Compute diff even when applying changes (for audit trail)
-  secretsync pipeline --config config.yaml --diff`,
+  secretsync pipeline --config config.yaml --diff
+
+  # Tell rotations from typos: first/last two characters, length and entropy
+  secretsync pipeline --config config.yaml --dry-run --mask-values
+
+  # Print changed values in full (local terminals only, never with --output github)
+  secretsync pipeline --config config.yaml --dry-run --show-values`,
 	RunE: runPipeline,
 }
 
@@ -81,9 +92,11 @@ func init() {
 	pipelineCmd.Flags().BoolVar(&discoverTargets, "discover", false, "enable dynamic target discovery from AWS Organizations/Identity Center")
 	
 	// Diff and output options
-	pipelineCmd.Flags().StringVarP(&outputFormat, "output", "o", "human", "output format: human, json, github, compact")
+	pipelineCmd.Flags().StringVarP(&outputFormat, "output", "o", "human", "output format: human, json, github, compact, markdown")
 	pipelineCmd.Flags().BoolVar(&computeDiff, "diff", false, "compute and show diff even when not in dry-run mode")
 	pipelineCmd.Flags().BoolVar(&exitCodeMode, "exit-code", false, "use exit codes: 0=no changes, 1=changes, 2=errors (useful for CI/CD)")
+	pipelineCmd.Flags().BoolVar(&maskValues, "mask-values", false, "describe changed values in the diff: first/last two characters, length and entropy class")
+	pipelineCmd.Flags().BoolVar(&showValues, "show-values", false, "print changed values in full in the diff (refused for GitHub and Markdown output and in GitHub Actions)")
 }
 
 func runPipeline(cmd *cobra.Command, args []string) error {
@@ -102,9 +115,9 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	if discoverTargets {
 		// Use context-aware constructor for dynamic target discovery
 		l.Info("Dynamic target discovery enabled")
-		p, err = pipeline.NewFromFileWithContext(ctx, cfgFile)
+		p, err = pipeline.NewFromFileWithContext(ctx, cfgFile, overlayFiles...)
 	} else {
-		p, err = pipeline.NewFromFile(cfgFile)
+		p, err = pipeline.NewFromFile(cfgFile, overlayFiles...)
 	}
 	if err != nil {
 		return fmt.Errorf("failed to create pipeline: %w", err)
@@ -138,6 +151,10 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 
 	// Parse output format
 	format := parseOutputFormat(outputFormat)
+	values, err := diffValueMode(format, maskValues, showValues)
+	if err != nil {
+		return err
+	}
 
 	// Run options
 	opts := pipeline.Options{
@@ -146,7 +163,8 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 		DryRun:          dryRun,
 		ContinueOnError: true,
 		OutputFormat:    format,
-		ComputeDiff:     computeDiff || dryRun,
+		ComputeDiff:     computeDiff || dryRun || stepSummary || prComment,
+		DiffValues:      values,
 	}
 
 	l.WithFields(log.Fields{
@@ -166,6 +184,7 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 		if diffOutput != "" {
 			fmt.Println(diffOutput)
 		}
+		publishMarkdownReport(ctx, d)
 	} else {
 		// Fall back to traditional results format
 		printResults(results)
@@ -175,6 +194,7 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	if exitCodeMode {
 		exitCode := p.ExitCode()
 		if exitCode != 0 {
+			shutdownTracing()
 			os.Exit(exitCode)
 		}
 		return nil
@@ -195,6 +215,28 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// diffValueMode returns how much of changed values the diff reveals.
+// Shown values are refused where output is shared: GitHub annotations,
+// Markdown reports and anywhere in GitHub Actions, whose logs other users
+// can read.
+func diffValueMode(format diff.OutputFormat, mask, show bool) (diff.ValueMode, error) {
+	switch {
+	case mask && show:
+		return diff.ValuesHidden, fmt.Errorf("--mask-values and --show-values are mutually exclusive")
+	case show && (format == diff.OutputFormatGitHub || format == diff.OutputFormatMarkdown):
+		return diff.ValuesHidden, fmt.Errorf("--show-values is not allowed with --output %s", format)
+	case show && (stepSummary || prComment):
+		return diff.ValuesHidden, fmt.Errorf("--show-values is not allowed with --step-summary or --pr-comment")
+	case show && os.Getenv("GITHUB_ACTIONS") == "true":
+		return diff.ValuesHidden, fmt.Errorf("--show-values is not allowed in GitHub Actions; use --mask-values")
+	case show:
+		return diff.ValuesShown, nil
+	case mask:
+		return diff.ValuesMasked, nil
+	}
+	return diff.ValuesHidden, nil
+}
+
 // parseOutputFormat converts string to OutputFormat
 func parseOutputFormat(s string) diff.OutputFormat {
 	switch strings.ToLower(s) {
@@ -204,6 +246,8 @@ func parseOutputFormat(s string) diff.OutputFormat {
 		return diff.OutputFormatGitHub
 	case "compact":
 		return diff.OutputFormatCompact
+	case "markdown":
+		return diff.OutputFormatMarkdown
 	default:
 		return diff.OutputFormatHuman
 	}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Save a dry run for later apply",
+	Long: `Performs a dry run and saves it as a plan file for 'secretsync apply'.
+
+The plan records each target's bundle ID and content hashes of its sources
+(the merged imports, or the stored bundle for --sync-only), the bundle in
+the merge store and the AWS secrets the sync would touch. Apply refuses to
+run if any of them, or the configuration, changed since the plan was made.
+
+The plan file contains hashes and the redacted diff, never secret values.
+Targets that inherit from other targets are planned against the bundles
+their parents will be merged to, so a plan always includes those parents.
+
+The plan file is set with --out (two dashes; a Terraform-style -out is
+parsed as -o and rejected).
+
+Examples:
+  secretsync plan --config config.yaml --out plan.bin
+  secretsync plan --config config.yaml --targets Serverless_Prod --out prod.plan
+  secretsync apply --config config.yaml plan.bin`,
+	Args: cobra.NoArgs,
+	RunE: runPlan,
+}
+
+var (
+	planOut       string
+	planTargets   string
+	planMergeOnly bool
+	planSyncOnly  bool
+	planOutput    string
+)
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+	planCmd.Flags().StringVar(&planOut, "out", "plan.bin", "path to write the plan file")
+	planCmd.Flags().StringVar(&planTargets, "targets", "", "comma-separated list of targets (default: all)")
+	planCmd.Flags().BoolVar(&planMergeOnly, "merge-only", false, "only plan the merge phase")
+	planCmd.Flags().BoolVar(&planSyncOnly, "sync-only", false, "only plan the sync phase")
+	planCmd.Flags().StringVarP(&planOutput, "output", "o", "human", "diff output format: human, json, github, compact, markdown")
+}
+
+func runPlan(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	p, err := pipeline.NewFromFileWithContext(ctx, cfgFile, overlayFiles...)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+
+	var targetList []string
+	if planTargets != "" {
+		for _, t := range strings.Split(planTargets, ",") {
+			targetList = append(targetList, strings.TrimSpace(t))
+		}
+	}
+
+	op := pipeline.OperationPipeline
+	if planMergeOnly {
+		op = pipeline.OperationMerge
+	} else if planSyncOnly {
+		op = pipeline.OperationSync
+	}
+
+	plan, err := p.Plan(ctx, pipeline.Options{
+		Operation:       op,
+		Targets:         targetList,
+		ContinueOnError: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	if plan.Diff != nil {
+		fmt.Println(diff.FormatDiff(plan.Diff, parseOutputFormat(planOutput)))
+	}
+
+	if err := pipeline.WritePlan(planOut, plan); err != nil {
+		return err
+	}
+	fmt.Printf("Plan for %d targets saved to %s\n", len(plan.Targets), planOut)
+	fmt.Printf("Run 'secretsync apply --config %s %s' to apply it.\n", cfgFile, planOut)
+	return nil
+}
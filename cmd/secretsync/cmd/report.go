@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	stepSummary  bool
+	prComment    bool
+	prNumber     int
+	githubAPIURL string
+)
+
+func init() {
+	pipelineCmd.Flags().BoolVar(&stepSummary, "step-summary", false, "append a Markdown diff report to $GITHUB_STEP_SUMMARY")
+	pipelineCmd.Flags().BoolVar(&prComment, "pr-comment", false, "post the Markdown diff report as a sticky pull request comment (uses $GITHUB_TOKEN)")
+	pipelineCmd.Flags().IntVar(&prNumber, "pr-number", 0, "pull request to comment on (default from $GITHUB_EVENT_PATH)")
+	pipelineCmd.Flags().StringVar(&githubAPIURL, "github-api-url", "", "GitHub REST API base URL (default $GITHUB_API_URL or "+diff.DefaultGitHubAPIURL+")")
+}
+
+// publishMarkdownReport writes the diff's Markdown report to the step
+// summary and the pull request, as requested by flags. Failures are logged:
+// the report is published after the run and never changes its outcome.
+func publishMarkdownReport(ctx context.Context, d *diff.PipelineDiff) {
+	l := log.WithFields(log.Fields{
+		"action": "publishMarkdownReport",
+	})
+	if d == nil || (!stepSummary && !prComment) {
+		return
+	}
+	report := diff.FormatDiff(d, diff.OutputFormatMarkdown)
+
+	if stepSummary {
+		if path := os.Getenv("GITHUB_STEP_SUMMARY"); path == "" {
+			l.Warn("--step-summary set but GITHUB_STEP_SUMMARY is not; skipping step summary")
+		} else if err := diff.AppendStepSummary(path, report); err != nil {
+			l.WithError(err).Warn("Failed to write step summary")
+		}
+	}
+
+	if prComment {
+		commenter, err := newPRCommenter()
+		if err != nil {
+			l.WithError(err).Warn("Cannot post pull request comment")
+			return
+		}
+		if commenter == nil {
+			l.Info("Not running for a pull request; skipping pull request comment")
+			return
+		}
+		url, err := commenter.Upsert(ctx, report)
+		if err != nil {
+			l.WithError(err).Warn("Failed to post pull request comment")
+			return
+		}
+		l.WithField("comment", url).Info("Posted diff report to pull request")
+	}
+}
+
+// newPRCommenter configures the sticky comment from flags and the GitHub
+// Actions environment. Returns nil if there is no pull request to comment on.
+func newPRCommenter() (*diff.PRCommenter, error) {
+	number := prNumber
+	if number == 0 {
+		if path := os.Getenv("GITHUB_EVENT_PATH"); path != "" {
+			n, err := diff.PullRequestFromEvent(path)
+			if err != nil {
+				return nil, err
+			}
+			number = n
+		}
+	}
+	if number == 0 {
+		return nil, nil
+	}
+
+	baseURL := githubAPIURL
+	if baseURL == "" {
+		baseURL = os.Getenv("GITHUB_API_URL")
+	}
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("GITHUB_TOKEN is not set")
+	}
+	return &diff.PRCommenter{
+		BaseURL:     baseURL,
+		Token:       token,
+		Repository:  os.Getenv("GITHUB_REPOSITORY"),
+		PullRequest: number,
+	}, nil
+}
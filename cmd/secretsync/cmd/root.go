@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/jbcom/secretsync/pkg/observability"
 	"github.com/spf13/cobra"
@@ -12,11 +14,16 @@ import (
 )
 
 var (
-	cfgFile     string
-	logLevel    string
-	logFormat   string
-	metricsAddr string
-	metricsPort int
+	cfgFile      string
+	overlayFiles []string
+	logLevel     string
+	logFormat    string
+	metricsAddr  string
+	metricsPort  int
+
+	otlpEndpoint     string
+	traceSampleRatio float64
+	shutdownTracer   func(context.Context) error
 )
 
 // Build information set via ldflags at build time
@@ -66,30 +73,61 @@ Examples:
 			log.SetFormatter(&log.JSONFormatter{})
 		}
 
-		// Start metrics server if enabled
-		if metricsPort > 0 {
+		// Start metrics server if enabled (serve and controller have /metrics on their own server)
+		if metricsPort > 0 && cmd != serveCmd && cmd != controllerCmd {
 			go startMetricsServer()
 		}
+
+		// Export traces when an OTLP endpoint is configured
+		if otlpEndpoint != "" || observability.TracingConfiguredFromEnv() {
+			shutdown, err := observability.InitTracing(cmd.Context(), observability.TracingConfig{
+				Endpoint:       otlpEndpoint,
+				SampleRatio:    traceSampleRatio,
+				ServiceVersion: Version,
+			})
+			if err != nil {
+				log.WithError(err).Warn("Tracing disabled")
+			} else {
+				shutdownTracer = shutdown
+			}
+		}
 	},
 }
 
 // Execute runs the root command
 func Execute() {
 	err := rootCmd.Execute()
+	shutdownTracing()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// shutdownTracing flushes pending spans to the OTLP endpoint
+func shutdownTracing() {
+	if shutdownTracer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracer(ctx); err != nil {
+		log.WithError(err).Warn("Failed to flush traces")
+	}
+	shutdownTracer = nil
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "config.yaml", "config file path")
+	rootCmd.PersistentFlags().StringArrayVar(&overlayFiles, "overlay", nil, "overlay file applied on top of the config, overriding its fields (repeatable, applied in order)")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "log format (text, json)")
 	rootCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "0.0.0.0", "metrics server address")
 	rootCmd.PersistentFlags().IntVar(&metricsPort, "metrics-port", 0, "metrics server port (0 = disabled)")
+	rootCmd.PersistentFlags().StringVar(&otlpEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint for traces (default from OTEL_EXPORTER_OTLP_ENDPOINT)")
+	rootCmd.PersistentFlags().Float64Var(&traceSampleRatio, "trace-sample-ratio", 1, "fraction of traces to sample (0-1]")
 
 	// Bind to viper
 	viper.BindPFlag("log.level", rootCmd.PersistentFlags().Lookup("log-level"))
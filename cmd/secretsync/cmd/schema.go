@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the pipeline configuration",
+	Long: `Prints a JSON Schema (draft 2020-12) of the pipeline configuration file,
+generated from the configuration types of this version of secretsync.
+
+Editors with JSON Schema support for YAML (e.g. the YAML language server used
+by VS Code and Neovim) use it to complete keys and flag unknown ones. Reference
+the schema from the first line of a config file:
+
+  # yaml-language-server: $schema=./secretsync.schema.json
+
+Examples:
+  secretsync schema > secretsync.schema.json
+  secretsync schema --output secretsync.schema.json`,
+	RunE: runSchema,
+}
+
+var schemaOutput string
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+	schemaCmd.Flags().StringVarP(&schemaOutput, "output", "o", "", "write the schema to this file instead of stdout")
+}
+
+func runSchema(cmd *cobra.Command, args []string) error {
+	schema, err := pipeline.ConfigJSONSchema()
+	if err != nil {
+		return fmt.Errorf("failed to generate schema: %w", err)
+	}
+	schema = append(schema, '\n')
+
+	if schemaOutput == "" {
+		_, err = os.Stdout.Write(schema)
+		return err
+	}
+	if err := os.WriteFile(schemaOutput, schema, 0644); err != nil {
+		return fmt.Errorf("failed to write schema: %w", err)
+	}
+	return nil
+}
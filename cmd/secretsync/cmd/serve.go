@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/daemon"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/spf13/cobra"
+	log "github.com/sirupsen/logrus"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the pipeline on a schedule as a long-running daemon",
+	Long: `Loads the configuration once and runs the pipeline on the schedules
+configured under "serve:", so each run skips the startup and discovery cost
+of a one-shot command. Runs execute one at a time.
+
+The HTTP server on --listen serves:
+  /metrics   Prometheus metrics
+  /healthz   liveness
+  /readyz    readiness (fails while shutting down)
+  /runs      recent runs, newest first (/runs/{id} for one run)
+  /drift     last drift check (with serve.drift_interval)
+
+POST /runs queues a run (trigger API). It is enabled by --api-token-file
+(bearer tokens, one per line; SECRETSYNC_API_TOKEN is also accepted) and/or
+--client-ca (mTLS, requires --tls-cert and --tls-key):
+  curl -H "Authorization: Bearer $TOKEN" -d '{"targets": ["Serverless_Prod"], "dry_run": true}' \
+    https://secretsync:8080/runs
+Overlapping requests for targets of a run that is still queued join that run.
+Poll the returned /runs/{id} for its results and diff. When the trigger API is
+enabled, GET /runs and /drift require the same credentials; /metrics, /healthz
+and /readyz stay open.
+
+On SIGTERM or SIGINT no new runs or targets are started, and targets that are
+already running finish before the process exits (bounded by
+--shutdown-timeout).
+
+Example configuration:
+  serve:
+    interval: 1h        # default schedule
+    jitter: 5m          # random delay added to each run
+    operation: pipeline
+    targets:
+      Serverless_Prod: 15m
+
+Examples:
+  secretsync serve --config config.yaml --listen :8080`,
+	RunE: runServe,
+}
+
+var (
+	serveListen          string
+	serveShutdownTimeout time.Duration
+	serveParallelism     int
+	serveContinueOnError bool
+	serveComputeDiff     bool
+	serveTokenFile       string
+	serveTLSCert         string
+	serveTLSKey          string
+	serveClientCA        string
+	serveClientNames     []string
+)
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	serveCmd.Flags().StringVar(&serveListen, "listen", ":8080", "address of the health, runs and metrics server")
+	serveCmd.Flags().DurationVar(&serveShutdownTimeout, "shutdown-timeout", 5*time.Minute, "how long shutdown waits for running targets")
+	serveCmd.Flags().IntVar(&serveParallelism, "parallelism", 0, "targets processed in parallel (default: pipeline.merge.parallel)")
+	serveCmd.Flags().BoolVar(&serveContinueOnError, "continue-on-error", false, "continue with other targets when one fails")
+	serveCmd.Flags().BoolVar(&serveComputeDiff, "diff", false, "compute the diff of every run")
+	serveCmd.Flags().StringVar(&serveTokenFile, "api-token-file", "", "file of bearer tokens accepted by the trigger API, one per line")
+	serveCmd.Flags().StringVar(&serveTLSCert, "tls-cert", "", "TLS certificate file")
+	serveCmd.Flags().StringVar(&serveTLSKey, "tls-key", "", "TLS private key file")
+	serveCmd.Flags().StringVar(&serveClientCA, "client-ca", "", "CA bundle for trigger API client certificates (mTLS)")
+	serveCmd.Flags().StringSliceVar(&serveClientNames, "client-name", nil, "allowed client certificate names (CN or DNS SAN; default: any verified)")
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	l := log.WithFields(log.Fields{
+		"action": "runServe",
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	p, err := pipeline.NewFromFileWithContext(ctx, cfgFile, overlayFiles...)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %w", err)
+	}
+
+	auth, err := serveAPIAuth()
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := serveTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	d, err := daemon.New(p, p.Config(), daemon.Options{
+		RunOptions: pipeline.Options{
+			Parallelism:     serveParallelism,
+			ContinueOnError: serveContinueOnError,
+			ComputeDiff:     serveComputeDiff,
+		},
+		ShutdownTimeout: serveShutdownTimeout,
+		Auth:            auth,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid serve configuration: %w", err)
+	}
+
+	server := &http.Server{
+		Addr:              serveListen,
+		Handler:           d.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		TLSConfig:         tlsConfig,
+	}
+	serverErr := make(chan error, 1)
+	go func() {
+		l.WithFields(log.Fields{
+			"address":     serveListen,
+			"tls":         tlsConfig != nil,
+			"trigger_api": auth.Tokens != nil || auth.ClientCerts,
+		}).Info("Starting server")
+		var err error
+		if tlsConfig != nil {
+			err = server.ListenAndServeTLS(serveTLSCert, serveTLSKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+			stop()
+		}
+	}()
+
+	if err := d.Run(ctx); err != nil {
+		return err
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		l.WithError(err).Warn("Server shutdown failed")
+	}
+
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("server error: %w", err)
+	default:
+	}
+	return nil
+}
+
+// serveAPIAuth reads the trigger API credentials
+func serveAPIAuth() (daemon.APIAuth, error) {
+	auth := daemon.APIAuth{
+		ClientCerts: serveClientCA != "",
+		ClientNames: serveClientNames,
+	}
+	if token := os.Getenv("SECRETSYNC_API_TOKEN"); token != "" {
+		auth.Tokens = append(auth.Tokens, token)
+	}
+	if serveTokenFile != "" {
+		data, err := os.ReadFile(serveTokenFile)
+		if err != nil {
+			return auth, fmt.Errorf("failed to read API token file: %w", err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if token := strings.TrimSpace(line); token != "" && !strings.HasPrefix(token, "#") {
+				auth.Tokens = append(auth.Tokens, token)
+			}
+		}
+		if len(auth.Tokens) == 0 {
+			return auth, fmt.Errorf("API token file %s contains no tokens", serveTokenFile)
+		}
+	}
+	return auth, nil
+}
+
+// serveTLSConfig returns the server TLS configuration, or nil for plain HTTP.
+// Client certificates are verified when presented but not required, so
+// probes and /metrics keep working without one.
+func serveTLSConfig() (*tls.Config, error) {
+	if (serveTLSCert == "") != (serveTLSKey == "") {
+		return nil, fmt.Errorf("--tls-cert and --tls-key must be set together")
+	}
+	if serveTLSCert == "" {
+		if serveClientCA != "" {
+			return nil, fmt.Errorf("--client-ca requires --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if serveClientCA != "" {
+		pem, err := os.ReadFile(serveClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", serveClientCA)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return cfg, nil
+}
@@ -2,7 +2,11 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/jbcom/secretsync/pkg/pipeline"
 	"github.com/spf13/cobra"
@@ -16,22 +20,42 @@ var validateCmd = &cobra.Command{
 
 Checks:
 - YAML syntax
+- Unknown fields (misspelled keys, with suggestions)
+- Included files (names defined in more than one file)
 - Required fields
 - Target references (sources exist)
 - Dependency graph (no cycles)
+- Policy rules (policies.rules)
 - AWS execution context (optional)
 
+The summary lists the file each target is defined in and the --overlay
+files that changed it.
+
+Policy findings are printed as text, or written as JSON or SARIF with
+--policy-format. Findings at or above policies.fail_on fail validation.
+Key rules need merged bundles and are only checked with --check-bundles,
+which performs a dry-run merge.
+
 Examples:
   vss validate --config config.yaml
-  vss validate --config config.yaml --check-aws`,
+  vss validate --config config.yaml --check-aws
+  vss validate --config config.yaml --check-aws --check-bundles --policy-format sarif --policy-output policy.sarif`,
 	RunE: runValidate,
 }
 
-var checkAWS bool
+var (
+	checkAWS     bool
+	checkBundles bool
+	policyFormat string
+	policyOutput string
+)
 
 func init() {
 	rootCmd.AddCommand(validateCmd)
 	validateCmd.Flags().BoolVar(&checkAWS, "check-aws", false, "also validate AWS credentials and access")
+	validateCmd.Flags().BoolVar(&checkBundles, "check-bundles", false, "check policy key rules against merged bundles (dry-run merge)")
+	validateCmd.Flags().StringVar(&policyFormat, "policy-format", "text", "policy findings format (text, json, sarif)")
+	validateCmd.Flags().StringVar(&policyOutput, "policy-output", "", "write policy findings to this file instead of stdout")
 }
 
 func runValidate(cmd *cobra.Command, args []string) error {
@@ -42,8 +66,16 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	fmt.Printf("Validating configuration: %s\n\n", cfgFile)
 
 	// Load config
-	cfg, err := pipeline.LoadConfig(cfgFile)
+	cfg, err := pipeline.LoadConfigStrict(cfgFile, overlayFiles...)
 	if err != nil {
+		var unknownErr *pipeline.UnknownFieldsError
+		if errors.As(err, &unknownErr) {
+			fmt.Printf("❌ Config has %d unknown field(s):\n", len(unknownErr.Fields))
+			for _, f := range unknownErr.Fields {
+				fmt.Printf("   %s\n", f)
+			}
+			return err
+		}
 		fmt.Printf("❌ Config load failed: %v\n", err)
 		return err
 	}
@@ -73,6 +105,21 @@ func runValidate(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  AWS Region: %s\n", cfg.AWS.Region)
 	fmt.Printf("  Control Tower: %v\n", cfg.AWS.ControlTower.Enabled)
 
+	if len(cfg.Origins.Files) > 1 {
+		fmt.Printf("  Config Files: %s\n", strings.Join(cfg.Origins.Files, ", "))
+	}
+
+	// Print where each target is defined
+	names := make([]string, 0, len(cfg.Targets))
+	for name := range cfg.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("\nTargets:\n")
+	for _, name := range names {
+		fmt.Printf("  %s: %s\n", name, targetOrigin(cfg, name))
+	}
+
 	// Print dependency levels
 	levels := graph.GroupByLevel()
 	fmt.Printf("\nDependency Levels:\n")
@@ -80,10 +127,12 @@ func runValidate(cmd *cobra.Command, args []string) error {
 		fmt.Printf("  Level %d: %v\n", i, level)
 	}
 
+	ctx := context.Background()
+
 	// Check AWS if requested
+	var accountTags pipeline.AccountTagsFunc
 	if checkAWS {
 		fmt.Println("\nValidating AWS access...")
-		ctx := context.Background()
 
 		awsCtx, err := pipeline.NewAWSExecutionContext(ctx, &cfg.AWS)
 		if err != nil {
@@ -93,9 +142,98 @@ func runValidate(cmd *cobra.Command, args []string) error {
 
 		fmt.Println("✅ AWS credentials valid")
 		fmt.Printf("\n%s", awsCtx.Summary())
+		accountTags = awsCtx.GetAccountTags
+	}
+
+	// Check policy rules
+	if len(cfg.Policies.Rules) > 0 {
+		if err := validatePolicies(ctx, cfg, accountTags); err != nil {
+			return err
+		}
 	}
 
 	l.Info("Validation completed successfully")
 	fmt.Println("\n✅ All validations passed")
 	return nil
 }
+
+// validatePolicies checks the policy rules and prints or writes the findings
+func validatePolicies(ctx context.Context, cfg *pipeline.Config, accountTags pipeline.AccountTagsFunc) error {
+	fmt.Printf("\nChecking %d policy rules...\n", len(cfg.Policies.Rules))
+
+	var report *pipeline.PolicyReport
+	if checkBundles {
+		// A dry-run merge checks the import rules and every merged bundle
+		p, err := pipeline.NewWithContext(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		opts := pipeline.DefaultOptions()
+		opts.Operation = pipeline.OperationMerge
+		opts.DryRun = true
+		opts.ContinueOnError = true
+		if _, err := p.Run(ctx, opts); err != nil {
+			log.WithError(err).Debug("Dry-run merge failed")
+		}
+		report = p.PolicyReport()
+	} else {
+		var err error
+		report, err = cfg.CheckPolicies(ctx, nil, accountTags)
+		if err != nil {
+			return err
+		}
+	}
+
+	var out []byte
+	var err error
+	switch policyFormat {
+	case "text":
+		out = []byte(formatPolicyText(report))
+	case "json":
+		out, err = report.JSON()
+	case "sarif":
+		out, err = report.SARIF(cfgFile, Version)
+	default:
+		return fmt.Errorf("unknown policy format %q (use text, json or sarif)", policyFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	if policyOutput != "" {
+		if err := os.WriteFile(policyOutput, out, 0644); err != nil {
+			return fmt.Errorf("failed to write policy findings: %w", err)
+		}
+		fmt.Printf("Policy findings written to %s\n", policyOutput)
+	} else {
+		fmt.Println(string(out))
+	}
+
+	if err := report.Err(); err != nil {
+		fmt.Printf("❌ %d blocking policy findings\n", len(report.Blocking()))
+		return err
+	}
+	fmt.Printf("✅ Policy checks passed (%d findings)\n", len(report.Findings))
+	return nil
+}
+
+// formatPolicyText formats policy findings one per line
+func formatPolicyText(report *pipeline.PolicyReport) string {
+	if len(report.Findings) == 0 {
+		return "No policy findings"
+	}
+	icons := map[string]string{
+		pipeline.PolicySeverityError:   "❌",
+		pipeline.PolicySeverityWarning: "⚠️ ",
+		pipeline.PolicySeverityNote:    "ℹ️ ",
+	}
+	lines := make([]string, 0, len(report.Findings))
+	for _, f := range report.Findings {
+		blocking := ""
+		if f.Blocking {
+			blocking = " (blocking)"
+		}
+		lines = append(lines, fmt.Sprintf("%s [%s] %s%s", icons[f.Severity], f.Severity, f.String(), blocking))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -12,12 +12,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/sony/gobreaker/v2"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// maxStateChanges bounds the state changes kept for span events
+const maxStateChanges = 16
+
 // Config holds circuit breaker configuration
 type Config struct {
 	// Name is the circuit breaker name (for logging and metrics)
@@ -61,6 +67,20 @@ func DefaultConfig(name string) *Config {
 type CircuitBreaker struct {
 	cb     *gobreaker.CircuitBreaker[any]
 	config *Config
+
+	// Recent state changes, recorded as events on the spans of the calls
+	// during which they happened
+	changesMu sync.Mutex
+	changes   []stateChange
+	changeSeq uint64
+}
+
+// stateChange is a circuit breaker state transition
+type stateChange struct {
+	seq  uint64
+	from gobreaker.State
+	to   gobreaker.State
+	at   time.Time
 }
 
 // New creates a new circuit breaker with the given configuration
@@ -85,6 +105,8 @@ func New(cfg *Config) *CircuitBreaker {
 		}
 	}
 
+	cb := &CircuitBreaker{config: cfg}
+
 	settings := gobreaker.Settings{
 		Name:        cfg.Name,
 		MaxRequests: cfg.MaxRequests,
@@ -92,6 +114,8 @@ func New(cfg *Config) *CircuitBreaker {
 		Timeout:     cfg.Timeout,
 		ReadyToTrip: cfg.ReadyToTrip,
 		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			cb.recordStateChange(from, to)
+
 			l := log.WithFields(log.Fields{
 				"circuitBreaker": name,
 				"fromState":      from.String(),
@@ -111,14 +135,57 @@ func New(cfg *Config) *CircuitBreaker {
 		},
 	}
 
-	cb := &CircuitBreaker{
-		cb:     gobreaker.NewCircuitBreaker[any](settings),
-		config: cfg,
-	}
+	cb.cb = gobreaker.NewCircuitBreaker[any](settings)
 
 	return cb
 }
 
+// recordStateChange keeps a state change for span events
+func (cb *CircuitBreaker) recordStateChange(from, to gobreaker.State) {
+	cb.changesMu.Lock()
+	defer cb.changesMu.Unlock()
+	cb.changeSeq++
+	cb.changes = append(cb.changes, stateChange{seq: cb.changeSeq, from: from, to: to, at: time.Now()})
+	if len(cb.changes) > maxStateChanges {
+		cb.changes = cb.changes[len(cb.changes)-maxStateChanges:]
+	}
+}
+
+// stateChangesSince returns the sequence of the latest state change and the
+// changes after seq
+func (cb *CircuitBreaker) stateChangesSince(seq uint64) (uint64, []stateChange) {
+	cb.changesMu.Lock()
+	defer cb.changesMu.Unlock()
+	var changes []stateChange
+	for _, c := range cb.changes {
+		if c.seq > seq {
+			changes = append(changes, c)
+		}
+	}
+	return cb.changeSeq, changes
+}
+
+// addSpanEvents records state changes and rejections on the span in ctx
+func (cb *CircuitBreaker) addSpanEvents(ctx context.Context, changes []stateChange, err error) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	for _, c := range changes {
+		span.AddEvent("circuit_breaker.state_change", trace.WithTimestamp(c.at), trace.WithAttributes(
+			attribute.String("circuit_breaker.name", cb.config.Name),
+			attribute.String("circuit_breaker.from", c.from.String()),
+			attribute.String("circuit_breaker.to", c.to.String()),
+		))
+	}
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		span.AddEvent("circuit_breaker.rejected", trace.WithAttributes(
+			attribute.String("circuit_breaker.name", cb.config.Name),
+			attribute.String("circuit_breaker.state", cb.cb.State().String()),
+		))
+	}
+}
+
 // Execute wraps a function call with circuit breaker pattern
 func (cb *CircuitBreaker) Execute(ctx context.Context, fn func(context.Context) (any, error)) (any, error) {
 	// Check context before executing
@@ -128,9 +195,13 @@ func (cb *CircuitBreaker) Execute(ctx context.Context, fn func(context.Context)
 	default:
 	}
 
+	seq, _ := cb.stateChangesSince(^uint64(0))
 	result, err := cb.cb.Execute(func() (any, error) {
 		return fn(ctx)
 	})
+	if _, changes := cb.stateChangesSince(seq); len(changes) > 0 || err != nil {
+		cb.addSpanEvents(ctx, changes, err)
+	}
 
 	if err != nil {
 		// Log errors for observability
@@ -9,6 +9,9 @@ import (
 	"github.com/sony/gobreaker/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestNew(t *testing.T) {
@@ -283,3 +286,41 @@ func TestCounts(t *testing.T) {
 	assert.Equal(t, uint32(2), counts.TotalFailures)
 	assert.Equal(t, uint32(2), counts.ConsecutiveFailures)
 }
+
+func TestCircuitBreaker_SpanEvents(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+
+	cb := New(&Config{
+		Name:    "span-test",
+		Timeout: time.Hour,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= 1
+		},
+	})
+	failing := func(ctx context.Context) (any, error) { return nil, errors.New("boom") }
+
+	ctx, span := tracer.Start(context.Background(), "trip")
+	_, err := cb.Execute(ctx, failing)
+	require.Error(t, err)
+	span.End()
+
+	ctx, span = tracer.Start(context.Background(), "rejected")
+	_, err = cb.Execute(ctx, failing)
+	require.ErrorIs(t, err, gobreaker.ErrOpenState)
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	events := spans[0].Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "circuit_breaker.state_change", events[0].Name)
+	assert.Contains(t, events[0].Attributes, attribute.String("circuit_breaker.from", "closed"))
+	assert.Contains(t, events[0].Attributes, attribute.String("circuit_breaker.to", "open"))
+
+	events = spans[1].Events()
+	require.Len(t, events, 1)
+	assert.Equal(t, "circuit_breaker.rejected", events[0].Name)
+	assert.Contains(t, events[0].Attributes, attribute.String("circuit_breaker.name", "span-test"))
+}
@@ -51,8 +51,9 @@ type AwsClient struct {
 
 	client *secretsmanager.Client `yaml:"-" json:"-"`
 
-	accountSecretArns map[string]string `yaml:"-" json:"-"`
-	arnMu             sync.RWMutex      `yaml:"-" json:"-"` // Protects accountSecretArns
+	accountSecretArns map[string]string            `yaml:"-" json:"-"`
+	accountSecretTags map[string]map[string]string `yaml:"-" json:"-"`
+	arnMu             sync.RWMutex                 `yaml:"-" json:"-"` // Protects accountSecretArns and accountSecretTags
 
 	// Cache fields for ListSecrets optimization
 	cachedSecrets []string     `yaml:"-" json:"-"`
@@ -96,6 +97,16 @@ func (in *AwsClient) DeepCopyInto(out *AwsClient) {
 			out.accountSecretArns[key] = val
 		}
 	}
+	if in.accountSecretTags != nil {
+		out.accountSecretTags = make(map[string]map[string]string, len(in.accountSecretTags))
+		for key, tags := range in.accountSecretTags {
+			copied := make(map[string]string, len(tags))
+			for k, v := range tags {
+				copied[k] = v
+			}
+			out.accountSecretTags[key] = copied
+		}
+	}
 	in.arnMu.RUnlock()
 
 	// Copy cachedSecrets with lock protection
@@ -196,7 +207,7 @@ func (c *AwsClient) CreateClientWithEndpoint(ctx context.Context, endpoint strin
 		Timeout: 30 * time.Second,
 	}
 
-	awscfg, err := config.LoadDefaultConfig(ctx, config.WithHTTPClient(httpClient))
+	awscfg, err := config.LoadDefaultConfig(ctx, config.WithHTTPClient(httpClient), config.WithAPIOptions(observability.AWSAPIOptions))
 	if err != nil {
 		l.Debugf("error: %v", err)
 		return err
@@ -369,6 +380,58 @@ func (c *AwsClient) updateSecret(ctx context.Context, name string, secret []byte
 	return nil
 }
 
+// tagSecret applies the client tags to an existing secret if any are missing.
+// The tags recorded by ListSecrets are checked first, so secrets that already
+// carry them cost no API call; applied tags are recorded for later lookups.
+func (c *AwsClient) tagSecret(ctx context.Context, name, arn string) error {
+	c.arnMu.RLock()
+	existing := c.accountSecretTags[name]
+	c.arnMu.RUnlock()
+
+	var tags []types.Tag
+	for k, v := range c.Tags {
+		if current, ok := existing[k]; ok && current == v {
+			continue
+		}
+		tags = append(tags, types.Tag{
+			Key:   aws.String(k),
+			Value: aws.String(v),
+		})
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	// Ensure circuit breaker is initialized
+	c.ensureBreaker()
+
+	// Wrap AWS API call with circuit breaker
+	_, err := circuitbreaker.ExecuteTyped(c.breaker, ctx, func(ctx context.Context) (*secretsmanager.TagResourceOutput, error) {
+		return c.client.TagResource(ctx, &secretsmanager.TagResourceInput{
+			SecretId: &arn,
+			Tags:     tags,
+		})
+	})
+	if err != nil {
+		return circuitbreaker.WrapError(err, c.breaker.Name(), c.breaker.State())
+	}
+
+	c.arnMu.Lock()
+	if c.accountSecretTags == nil {
+		c.accountSecretTags = make(map[string]map[string]string)
+	}
+	updated := make(map[string]string, len(existing)+len(tags))
+	for k, v := range c.accountSecretTags[name] {
+		updated[k] = v
+	}
+	for _, tag := range tags {
+		updated[*tag.Key] = *tag.Value
+	}
+	c.accountSecretTags[name] = updated
+	c.arnMu.Unlock()
+	return nil
+}
+
 func (g *AwsClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, path string, secrets []byte) ([]byte, error) {
 	startTime := time.Now()
 	status := "error"
@@ -420,6 +483,11 @@ func (g *AwsClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pat
 					l.WithError(err).Debug("Error comparing secrets")
 				} else if equal {
 					l.Debug("Secret unchanged, skipping update")
+					// Secrets already in sync when tagging was configured still
+					// need the ownership tags, or delete_orphans never finds them
+					if err := g.tagSecret(ctx, path, arn); err != nil {
+						l.WithError(err).Warn("Failed to tag secret")
+					}
 					operation = "skip"
 					status = "success"
 					return nil, nil
@@ -432,6 +500,11 @@ func (g *AwsClient) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, pat
 			l.WithError(err).Error("Failed to update secret")
 			return nil, err
 		}
+
+		// Secrets created before tagging was configured get tagged on update
+		if err := g.tagSecret(ctx, path, arn); err != nil {
+			l.WithError(err).Warn("Failed to tag secret")
+		}
 	} else {
 		err := g.createSecret(ctx, path, secrets)
 		if err != nil {
@@ -563,6 +636,7 @@ func (g *AwsClient) ListSecrets(ctx context.Context, p string) ([]string, error)
 	secretsList := []string{}
 	var nextToken *string
 	arnMap := make(map[string]string)
+	tagMap := make(map[string]map[string]string)
 	pageCount := 0
 	for {
 		params := &secretsmanager.ListSecretsInput{
@@ -602,6 +676,13 @@ func (g *AwsClient) ListSecrets(ctx context.Context, p string) ([]string, error)
 			}
 
 			arnMap[secretName] = *secret.ARN
+			if len(secret.Tags) > 0 {
+				tags := make(map[string]string, len(secret.Tags))
+				for _, tag := range secret.Tags {
+					tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+				}
+				tagMap[secretName] = tags
+			}
 			secretsList = append(secretsList, secretName)
 		}
 		if resp.NextToken == nil {
@@ -615,6 +696,7 @@ func (g *AwsClient) ListSecrets(ctx context.Context, p string) ([]string, error)
 
 	g.arnMu.Lock()
 	g.accountSecretArns = arnMap
+	g.accountSecretTags = tagMap
 	g.arnMu.Unlock()
 
 	// Update cache if TTL is configured
@@ -631,6 +713,22 @@ func (g *AwsClient) ListSecrets(ctx context.Context, p string) ([]string, error)
 	return secretsList, nil
 }
 
+// GetSecretTags returns the tags recorded for a secret by the last ListSecrets call.
+// Returns nil if the secret is unknown or has no tags.
+func (g *AwsClient) GetSecretTags(name string) map[string]string {
+	g.arnMu.RLock()
+	defer g.arnMu.RUnlock()
+	tags, ok := g.accountSecretTags[name]
+	if !ok {
+		return nil
+	}
+	copied := make(map[string]string, len(tags))
+	for k, v := range tags {
+		copied[k] = v
+	}
+	return copied
+}
+
 // isSecretEmpty checks if a secret has empty or null value with circuit breaker
 func (g *AwsClient) isSecretEmpty(ctx context.Context, arn string) (bool, error) {
 	// Ensure circuit breaker is initialized
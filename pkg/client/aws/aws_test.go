@@ -326,6 +326,37 @@ func TestAwsClient_WriteSecret(t *testing.T) {
 		_ = client.DeleteSecret(ctx, secretName)
 	})
 
+	t.Run("Unchanged secret gets ownership tags with LocalStack", func(t *testing.T) {
+		skipIfNoLocalStack(t)
+		ctx := context.Background()
+		secretName := "test-secret-unchanged-tags"
+		secretValue := []byte(`{"key":"value"}`)
+
+		// Written before tagging was configured
+		untagged := &AwsClient{Name: "test", Region: "us-east-1", accountSecretArns: map[string]string{}}
+		require.NoError(t, untagged.CreateClientWithEndpoint(ctx, getTestEndpoint()))
+		_, err := untagged.WriteSecret(ctx, metav1.ObjectMeta{Name: "test"}, secretName, secretValue)
+		require.NoError(t, err)
+		defer func() { _ = untagged.DeleteSecret(ctx, secretName) }()
+
+		client := &AwsClient{
+			Name:          "test",
+			Region:        "us-east-1",
+			SkipUnchanged: true,
+			Tags:          map[string]string{"secretsync:managed-by": "secretsync"},
+		}
+		require.NoError(t, client.CreateClientWithEndpoint(ctx, getTestEndpoint()))
+		_, err = client.ListSecrets(ctx, "")
+		require.NoError(t, err)
+		_, err = client.WriteSecret(ctx, metav1.ObjectMeta{Name: "test"}, secretName, secretValue)
+		require.NoError(t, err)
+
+		client.ClearCache()
+		_, err = client.ListSecrets(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, "secretsync", client.GetSecretTags(secretName)["secretsync:managed-by"])
+	})
+
 	t.Run("Write secret validation - no client", func(t *testing.T) {
 		// This test validates the struct setup without requiring LocalStack
 		client := &AwsClient{
@@ -340,6 +371,16 @@ func TestAwsClient_WriteSecret(t *testing.T) {
 	})
 }
 
+func TestAwsClient_TagSecretAlreadyTagged(t *testing.T) {
+	// Tags recorded by ListSecrets are checked first: no API call is made
+	// (the client is nil) when the secret already carries them
+	client := &AwsClient{
+		Tags:              map[string]string{"secretsync:managed-by": "secretsync"},
+		accountSecretTags: map[string]map[string]string{"app/db": {"secretsync:managed-by": "secretsync", "team": "data"}},
+	}
+	assert.NoError(t, client.tagSecret(context.Background(), "app/db", "arn:aws:secretsmanager:us-east-1:111111111111:secret:app/db"))
+}
+
 func TestAwsClient_Close(t *testing.T) {
 	client := &AwsClient{
 		Name: "test",
@@ -0,0 +1,36 @@
+package vault
+
+// DeepCopyInto and DeepCopy are written by hand, not generated: VaultClient
+// holds a sync.Once that must not be copied, so the generated "*out = *in"
+// does not apply. Fields added to VaultClient must be added here as well;
+// TestVaultClientDeepCopyFields fails until they are.
+
+// DeepCopyInto copies the receiver into out. in must be non-nil.
+// breakerOnce stays zero-valued in out, so ensureBreaker still works on the
+// copy, and the circuit breaker and API clients are shared with in.
+func (in *VaultClient) DeepCopyInto(out *VaultClient) {
+	out.Path = in.Path
+	out.Address = in.Address
+	out.CIDR = in.CIDR
+	out.AuthMethod = in.AuthMethod
+	out.Namespace = in.Namespace
+	out.TTL = in.TTL
+	out.Merge = in.Merge
+	out.Role = in.Role
+	out.MaxTraversalDepth = in.MaxTraversalDepth
+	out.MaxSecretsPerMount = in.MaxSecretsPerMount
+	out.QueueCompactionThreshold = in.QueueCompactionThreshold
+	out.Client = in.Client
+	out.logicalClient = in.logicalClient
+	out.breaker = in.breaker
+}
+
+// DeepCopy copies the receiver into a new VaultClient.
+func (in *VaultClient) DeepCopy() *VaultClient {
+	if in == nil {
+		return nil
+	}
+	out := new(VaultClient)
+	in.DeepCopyInto(out)
+	return out
+}
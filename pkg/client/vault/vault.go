@@ -17,6 +17,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/hashicorp/vault/api"
+	"go.opentelemetry.io/otel/attribute"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -58,21 +59,6 @@ type VaultClient struct {
 	breakerOnce   sync.Once                      `yaml:"-" json:"-"`
 }
 
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *VaultClient) DeepCopyInto(out *VaultClient) {
-	*out = *in
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VaultClient.
-func (in *VaultClient) DeepCopy() *VaultClient {
-	if in == nil {
-		return nil
-	}
-	out := new(VaultClient)
-	in.DeepCopyInto(out)
-	return out
-}
-
 func (c *VaultClient) Validate() error {
 	l := log.WithFields(log.Fields{
 		"action": "Validate",
@@ -252,6 +238,13 @@ func insertSliceString(a []string, index int, value string) []string {
 
 // GetKVSecret retrieves a kv secret from vault with circuit breaker
 func (vc *VaultClient) GetKVSecretOnce(ctx context.Context, s string) (map[string]interface{}, error) {
+	data, _, err := vc.GetKVSecretVersionOnce(ctx, s)
+	return data, err
+}
+
+// GetKVSecretVersionOnce retrieves a kv secret and its KV v2 version with circuit breaker.
+// The version is 0 if Vault did not return secret metadata.
+func (vc *VaultClient) GetKVSecretVersionOnce(ctx context.Context, s string) (map[string]interface{}, int, error) {
 	startTime := time.Now()
 	status := "error"
 	defer func() {
@@ -267,12 +260,12 @@ func (vc *VaultClient) GetKVSecretOnce(ctx context.Context, s string) (map[strin
 	var secrets map[string]interface{}
 	if s == "" {
 		observability.RecordError(observability.VaultErrors, "get_secret", "invalid_path")
-		return secrets, errors.New("secret path required")
+		return secrets, 0, errors.New("secret path required")
 	}
 	ss := strings.Split(s, "/")
 	if len(ss) < 2 {
 		observability.RecordError(observability.VaultErrors, "get_secret", "invalid_path")
-		return secrets, errors.New("secret path must be in kv/path/to/secret format")
+		return secrets, 0, errors.New("secret path must be in kv/path/to/secret format")
 	}
 	ss = insertSliceString(ss, 1, "data")
 	//log.Debugf("headers_sent=%+v", vc.Client.Headers())
@@ -280,40 +273,58 @@ func (vc *VaultClient) GetKVSecretOnce(ctx context.Context, s string) (map[strin
 	s = strings.Join(ss, "/")
 	if c == nil {
 		observability.RecordError(observability.VaultErrors, "get_secret", "not_initialized")
-		return secrets, errors.New("vault client not initialized")
+		return secrets, 0, errors.New("vault client not initialized")
 	}
 	
 	// Ensure circuit breaker is initialized
 	vc.ensureBreaker()
 	
 	// Wrap Vault API call with circuit breaker
-	result, err := circuitbreaker.ExecuteTyped(vc.breaker, ctx, func(ctx context.Context) (*api.Secret, error) {
+	result, err := vc.call(ctx, "read", s, func(ctx context.Context) (*api.Secret, error) {
 		return c.ReadWithContext(ctx, s)
 	})
 	if err != nil {
 		observability.RecordError(observability.VaultErrors, "get_secret", "api_error")
-		return secrets, circuitbreaker.WrapError(err, vc.breaker.Name(), vc.breaker.State())
+		return secrets, 0, circuitbreaker.WrapError(err, vc.breaker.Name(), vc.breaker.State())
 	}
 	
 	secret := result
 	if secret == nil || secret.Data == nil {
 		observability.RecordError(observability.VaultErrors, "get_secret", "not_found")
-		return nil, errors.New("secret not found: " + s)
+		return nil, 0, errors.New("secret not found: " + s)
 	}
 	l.Tracef("secret=%+v", secret)
 	if secret.Data["data"] == nil {
 		observability.RecordError(observability.VaultErrors, "get_secret", "no_data")
-		return nil, errors.New("secret data not found: " + s)
+		return nil, 0, errors.New("secret data not found: " + s)
 	}
 
 	// Type-safe extraction to prevent runtime panics
 	data, ok := secret.Data["data"].(map[string]interface{})
 	if !ok {
 		observability.RecordError(observability.VaultErrors, "get_secret", "invalid_type")
-		return nil, fmt.Errorf("unexpected data type in Vault response: got %T, expected map[string]interface{}", secret.Data["data"])
+		return nil, 0, fmt.Errorf("unexpected data type in Vault response: got %T, expected map[string]interface{}", secret.Data["data"])
 	}
 	status = "success"
-	return data, nil
+	return data, kvSecretVersion(secret), nil
+}
+
+// kvSecretVersion extracts the version from a KV v2 read response
+func kvSecretVersion(secret *api.Secret) int {
+	metadata, ok := secret.Data["metadata"].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	switch v := metadata["version"].(type) {
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
 }
 
 // GetKVSecret will login and retry secret access on failure
@@ -445,7 +456,7 @@ func (vc *VaultClient) WriteSecretOnce(ctx context.Context, p string, s map[stri
 	vc.ensureBreaker()
 	
 	// Wrap Vault API call with circuit breaker
-	_, err := circuitbreaker.ExecuteTyped(vc.breaker, ctx, func(ctx context.Context) (*api.Secret, error) {
+	_, err := vc.call(ctx, "write", p, func(ctx context.Context) (*api.Secret, error) {
 		return vc.Client.Logical().WriteWithContext(ctx, p, vd)
 	})
 	if err != nil {
@@ -473,7 +484,7 @@ func (vc *VaultClient) WriteSecretWithLatestCAS(ctx context.Context, p string, s
 	// Ensure circuit breaker is initialized
 	vc.ensureBreaker()
 	
-	metadata, err := circuitbreaker.ExecuteTyped(vc.breaker, ctx, func(ctx context.Context) (*api.Secret, error) {
+	metadata, err := vc.call(ctx, "read", metadataPathStr, func(ctx context.Context) (*api.Secret, error) {
 		return vc.Client.Logical().ReadWithContext(ctx, metadataPathStr)
 	})
 	if err != nil {
@@ -537,7 +548,7 @@ func (vc *VaultClient) DeleteSecret(ctx context.Context, p string) error {
 	vc.ensureBreaker()
 	
 	// Wrap Vault API call with circuit breaker
-	_, err := circuitbreaker.ExecuteTyped(vc.breaker, ctx, func(ctx context.Context) (*api.Secret, error) {
+	_, err := vc.call(ctx, "delete", p, func(ctx context.Context) (*api.Secret, error) {
 		return vc.Client.Logical().DeleteWithContext(ctx, p)
 	})
 	if err != nil {
@@ -801,6 +812,18 @@ func (vc *VaultClient) ensureBreaker() {
 	})
 }
 
+// call runs a Vault API call through the circuit breaker in a span named
+// vault.<operation>
+func (vc *VaultClient) call(ctx context.Context, operation, path string, fn func(context.Context) (*api.Secret, error)) (*api.Secret, error) {
+	ctx, span := observability.StartSpan(ctx, "vault."+operation,
+		attribute.String("vault.address", vc.Address),
+		attribute.String("vault.path", path),
+	)
+	result, err := circuitbreaker.ExecuteTyped(vc.breaker, ctx, fn)
+	observability.EndSpan(span, err)
+	return result, err
+}
+
 // listPathContents performs the actual Vault LIST operation with circuit breaker
 func (vc *VaultClient) listPathContents(ctx context.Context, metadataPath string) ([]string, error) {
 	logical := vc.getLogicalClient()
@@ -812,7 +835,7 @@ func (vc *VaultClient) listPathContents(ctx context.Context, metadataPath string
 	vc.ensureBreaker()
 	
 	// Wrap Vault API call with circuit breaker
-	result, err := circuitbreaker.ExecuteTyped(vc.breaker, ctx, func(ctx context.Context) (*api.Secret, error) {
+	result, err := vc.call(ctx, "list", metadataPath, func(ctx context.Context) (*api.Secret, error) {
 		return logical.ListWithContext(ctx, metadataPath)
 	})
 	if err != nil {
@@ -3,6 +3,7 @@ package vault
 import (
 	"context"
 	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -279,6 +280,40 @@ func TestVaultClient_GetKVSecretOnce(t *testing.T) {
 	}
 }
 
+func TestKVSecretVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret *api.Secret
+		want   int
+	}{
+		{
+			name: "json.Number version",
+			secret: &api.Secret{Data: map[string]interface{}{
+				"metadata": map[string]interface{}{"version": json.Number("7")},
+			}},
+			want: 7,
+		},
+		{
+			name: "float64 version",
+			secret: &api.Secret{Data: map[string]interface{}{
+				"metadata": map[string]interface{}{"version": float64(3)},
+			}},
+			want: 3,
+		},
+		{
+			name:   "no metadata",
+			secret: &api.Secret{Data: map[string]interface{}{"data": map[string]interface{}{}}},
+			want:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, kvSecretVersion(tt.secret))
+		})
+	}
+}
+
 func TestVaultClient_SetDefaults(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1020,3 +1055,38 @@ func TestVaultClient_getMetadataPath_PathValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestVaultClientDeepCopy(t *testing.T) {
+	in := &VaultClient{Address: "https://vault:8200", Path: "kv/app", Role: "sync", Merge: true, MaxTraversalDepth: 5}
+	in.ensureBreaker()
+
+	out := in.DeepCopy()
+	if out.Address != in.Address || out.Path != in.Path || out.Role != in.Role || !out.Merge || out.MaxTraversalDepth != 5 {
+		t.Errorf("DeepCopy() = %+v, fields not copied", out)
+	}
+	if out.breaker != in.breaker {
+		t.Errorf("DeepCopy() did not keep the circuit breaker")
+	}
+	out.ensureBreaker()
+	if out.breaker != in.breaker {
+		t.Errorf("ensureBreaker() replaced the copied circuit breaker")
+	}
+}
+
+// TestVaultClientDeepCopyFields fails when a field is added to VaultClient
+// without being copied by the hand-written DeepCopyInto
+func TestVaultClientDeepCopyFields(t *testing.T) {
+	copied := map[string]bool{
+		"Path": true, "Address": true, "CIDR": true, "AuthMethod": true, "Namespace": true,
+		"TTL": true, "Merge": true, "Role": true, "MaxTraversalDepth": true,
+		"MaxSecretsPerMount": true, "QueueCompactionThreshold": true, "Client": true,
+		"logicalClient": true, "breaker": true,
+		"breakerOnce": true, // Deliberately left zero-valued
+	}
+	typ := reflect.TypeOf(VaultClient{})
+	for i := 0; i < typ.NumField(); i++ {
+		if name := typ.Field(i).Name; !copied[name] {
+			t.Errorf("VaultClient.%s is not handled by DeepCopyInto (deepcopy.go)", name)
+		}
+	}
+}
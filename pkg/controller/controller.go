@@ -0,0 +1,596 @@
+// Package controller reconciles SecretSync custom resources.
+//
+// Each SecretSync copies the secrets under a Vault source path to its
+// destinations (AWS Secrets Manager or Vault), applying the resource's filters
+// and transforms. The controller lists and watches SecretSync objects through
+// the Kubernetes API, reconciles them on every change and periodically, and
+// reports the outcome in the object's status.
+package controller
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/pkg/client/aws"
+	"github.com/jbcom/secretsync/pkg/client/vault"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	log "github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Status values reported in SecretSyncStatus.Status. Failures are reported as
+// "Failed: <reason>".
+const (
+	StatusSynced    = "Synced"
+	StatusDryRun    = "DryRun"
+	StatusSuspended = "Suspended"
+	StatusFailed    = "Failed"
+)
+
+// DefaultResyncPeriod is how often every SecretSync is reconciled without changes
+const DefaultResyncPeriod = 10 * time.Minute
+
+// EventType is the type of a watch event
+type EventType string
+
+const (
+	EventAdded    EventType = "ADDED"
+	EventModified EventType = "MODIFIED"
+	EventDeleted  EventType = "DELETED"
+	EventBookmark EventType = "BOOKMARK"
+	// EventError ends a watch; the controller lists again
+	EventError EventType = "ERROR"
+)
+
+// Event is a change to a SecretSync object
+type Event struct {
+	Type   EventType
+	Object *v1alpha1.SecretSync
+}
+
+// Client reads and updates SecretSync objects; KubeClient implements it
+type Client interface {
+	// List returns the SecretSync objects in a namespace ("" for all)
+	List(ctx context.Context, namespace string) (*v1alpha1.SecretSyncList, error)
+	// UpdateStatus writes the status subresource of an object
+	UpdateStatus(ctx context.Context, ss *v1alpha1.SecretSync) error
+	// Watch streams changes after resourceVersion until the channel is closed
+	Watch(ctx context.Context, namespace, resourceVersion string) (<-chan Event, error)
+}
+
+// SyncClient is a secret store used as a source or destination.
+// *vault.VaultClient and *aws.AwsClient implement it.
+type SyncClient interface {
+	Init(ctx context.Context) error
+	GetPath() string
+	ListSecrets(ctx context.Context, path string) ([]string, error)
+	GetSecret(ctx context.Context, path string) ([]byte, error)
+	WriteSecret(ctx context.Context, meta metav1.ObjectMeta, path string, secret []byte) ([]byte, error)
+	DeleteSecret(ctx context.Context, path string) error
+}
+
+// taggedClient is a destination whose secrets carry ownership tags (AWS)
+type taggedClient interface {
+	GetSecretTags(name string) map[string]string
+}
+
+// ClientFactory creates the source and destination clients of a SecretSync.
+// owner identifies the object in ownership tags.
+type ClientFactory func(spec *v1alpha1.SecretSyncSpec, owner string) (SyncClient, []SyncClient, error)
+
+// Options configures the controller
+type Options struct {
+	// Namespace limits the controller to one namespace ("" for all)
+	Namespace string
+	// ResyncPeriod is how often unchanged objects are reconciled (default 10m)
+	ResyncPeriod time.Duration
+	// Clients creates the secret store clients (default: Vault and AWS clients from the spec)
+	Clients ClientFactory
+	// HashKey keys the HMAC kept in status.hash, so the status cannot be used
+	// to guess secret values (default: a random key per process, which makes
+	// every object be written again after a restart)
+	HashKey []byte
+}
+
+// Controller reconciles SecretSync objects
+type Controller struct {
+	kube Client
+	opts Options
+
+	mu       sync.Mutex
+	observed map[string]int64 // generation last reconciled, by namespace/name
+}
+
+// New creates a controller
+func New(kube Client, opts Options) *Controller {
+	if opts.ResyncPeriod <= 0 {
+		opts.ResyncPeriod = DefaultResyncPeriod
+	}
+	if opts.Clients == nil {
+		opts.Clients = NewClients
+	}
+	if len(opts.HashKey) == 0 {
+		opts.HashKey = make([]byte, 32)
+		rand.Read(opts.HashKey)
+	}
+	return &Controller{
+		kube:     kube,
+		opts:     opts,
+		observed: make(map[string]int64),
+	}
+}
+
+// NewClients creates the Vault source and the destination clients of a spec.
+// AWS destinations tag the secrets they write with the ownership tags used by
+// the pipeline, with the target "k8s:<namespace>/<name>".
+func NewClients(spec *v1alpha1.SecretSyncSpec, owner string) (SyncClient, []SyncClient, error) {
+	if spec.Source == nil {
+		return nil, nil, fmt.Errorf("spec.source is required")
+	}
+	source, err := vault.NewClient(spec.Source)
+	if err != nil {
+		return nil, nil, fmt.Errorf("source: %w", err)
+	}
+
+	var dests []SyncClient
+	for i, d := range spec.Dest {
+		switch {
+		case d == nil:
+			return nil, nil, fmt.Errorf("dest[%d] is empty", i)
+		case d.AWS != nil:
+			cfg := d.AWS.DeepCopy()
+			if cfg.Tags == nil {
+				cfg.Tags = make(map[string]string)
+			}
+			cfg.Tags[pipeline.TagManagedBy] = pipeline.ManagedByValue
+			cfg.Tags[pipeline.TagTarget] = owner
+			c, err := aws.NewClient(cfg)
+			if err != nil {
+				return nil, nil, fmt.Errorf("dest[%d]: %w", i, err)
+			}
+			dests = append(dests, c)
+		case d.Vault != nil:
+			c, err := vault.NewClient(d.Vault)
+			if err != nil {
+				return nil, nil, fmt.Errorf("dest[%d]: %w", i, err)
+			}
+			dests = append(dests, c)
+		case d.IdentityCenter != nil:
+			return nil, nil, fmt.Errorf("dest[%d]: awsIdentityCenter destinations are not supported by the controller", i)
+		default:
+			return nil, nil, fmt.Errorf("dest[%d] has no store configured", i)
+		}
+	}
+	if len(dests) == 0 {
+		return nil, nil, fmt.Errorf("spec.dest is required")
+	}
+	return source, dests, nil
+}
+
+// Run reconciles every object, then watches for changes until ctx is done.
+// Objects are listed and reconciled again every ResyncPeriod and whenever the
+// watch ends.
+func (c *Controller) Run(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"action":    "Controller.Run",
+		"namespace": c.opts.Namespace,
+	})
+	l.Info("Starting controller")
+
+	backoff := time.Second
+	for {
+		err := c.listAndWatch(ctx)
+		if ctx.Err() != nil {
+			l.Info("Controller stopped")
+			return nil
+		}
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+
+		l.WithError(err).WithField("retry_in", backoff).Warn("Watch failed, listing again")
+		select {
+		case <-ctx.Done():
+			l.Info("Controller stopped")
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, time.Minute)
+	}
+}
+
+// listAndWatch reconciles every object, then handles watch events until the
+// watch ends or the resync period elapses
+func (c *Controller) listAndWatch(ctx context.Context) error {
+	list, err := c.kube.List(ctx, c.opts.Namespace)
+	if err != nil {
+		return fmt.Errorf("failed to list SecretSyncs: %w", err)
+	}
+	for i := range list.Items {
+		c.reconcileAndUpdate(ctx, &list.Items[i])
+	}
+
+	// Cancelling the watch context closes the stream when a resync ends it
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, err := c.kube.Watch(watchCtx, c.opts.Namespace, list.ResourceVersion)
+	if err != nil {
+		return fmt.Errorf("failed to watch SecretSyncs: %w", err)
+	}
+
+	resync := time.NewTimer(c.opts.ResyncPeriod)
+	defer resync.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-resync.C:
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if err := c.handleEvent(ctx, ev); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleEvent reconciles added and changed objects. Changes that leave the
+// generation unchanged, such as the controller's own status updates, are
+// skipped; they are picked up by the next resync.
+func (c *Controller) handleEvent(ctx context.Context, ev Event) error {
+	switch ev.Type {
+	case EventError:
+		return errors.New("watch returned an error event")
+	case EventDeleted:
+		if ev.Object != nil {
+			c.mu.Lock()
+			delete(c.observed, key(ev.Object))
+			c.mu.Unlock()
+		}
+		return nil
+	case EventAdded, EventModified:
+		if ev.Object == nil {
+			return nil
+		}
+		c.mu.Lock()
+		generation, seen := c.observed[key(ev.Object)]
+		c.mu.Unlock()
+		if seen && generation == ev.Object.Generation {
+			return nil
+		}
+		c.reconcileAndUpdate(ctx, ev.Object)
+	}
+	return nil
+}
+
+// reconcileAndUpdate reconciles an object and writes its status if it changed
+func (c *Controller) reconcileAndUpdate(ctx context.Context, ss *v1alpha1.SecretSync) {
+	l := log.WithFields(log.Fields{
+		"action":     "Controller.reconcile",
+		"secretsync": key(ss),
+	})
+
+	status, err := c.Reconcile(ctx, ss)
+	if err != nil {
+		l.WithError(err).Error("Reconcile failed")
+	}
+
+	c.mu.Lock()
+	c.observed[key(ss)] = ss.Generation
+	c.mu.Unlock()
+
+	if status == ss.Status {
+		return
+	}
+	l.WithField("status", status.Status).Info("Updating status")
+	updated := ss.DeepCopy()
+	updated.Status = status
+	if err := c.kube.UpdateStatus(ctx, updated); err != nil {
+		l.WithError(err).Error("Failed to update status")
+	}
+
+	if len(ss.Spec.Notifications) > 0 && (status.Status == StatusSynced || err != nil) {
+		c.notify(ctx, updated, err)
+	}
+}
+
+// notify sends the object's notifications after a sync or a new failure.
+// Notifications are best effort: failures are logged.
+func (c *Controller) notify(ctx context.Context, ss *v1alpha1.SecretSync, syncErr error) {
+	l := log.WithFields(log.Fields{
+		"action":     "Controller.notify",
+		"secretsync": key(ss),
+	})
+
+	n := &pipeline.Notifier{Channels: ss.Spec.Notifications}
+	if ss.Spec.NotificationsTemplate != nil {
+		n.Template = *ss.Spec.NotificationsTemplate
+	}
+	if ss.Spec.Source != nil {
+		source, err := vault.NewClient(ss.Spec.Source)
+		if err != nil {
+			l.WithError(err).Warn("Failed to create notification secret client")
+		} else {
+			n.Secrets = pipeline.NewVaultNotificationSecrets(source)
+		}
+	}
+
+	if err := n.Notify(ctx, pipeline.SecretSyncNotification(ss, syncErr)); err != nil {
+		l.WithError(err).Warn("Failed to send notifications")
+	}
+}
+
+// Reconcile syncs one object and returns its new status. Failures are
+// returned as the error and as a "Failed: ..." status. Secret values never
+// appear in the status or the error.
+func (c *Controller) Reconcile(ctx context.Context, ss *v1alpha1.SecretSync) (v1alpha1.SecretSyncStatus, error) {
+	status := ss.Status
+	if boolValue(ss.Spec.Suspend) {
+		status.Status = StatusSuspended
+		return status, nil
+	}
+
+	if err := c.reconcile(ctx, ss, &status); err != nil {
+		status.Status = fmt.Sprintf("%s: %v", StatusFailed, err)
+		return status, err
+	}
+	return status, nil
+}
+
+func (c *Controller) reconcile(ctx context.Context, ss *v1alpha1.SecretSync, status *v1alpha1.SecretSyncStatus) error {
+	l := log.WithFields(log.Fields{
+		"action":     "Controller.reconcile",
+		"secretsync": key(ss),
+	})
+
+	source, dests, err := c.opts.Clients(&ss.Spec, Owner(ss))
+	if err != nil {
+		return err
+	}
+	if err := source.Init(ctx); err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+
+	secrets, err := readSource(ctx, source)
+	if err != nil {
+		return err
+	}
+	secrets, err = pipeline.ApplyTransforms(filters(ss.Spec.Filters), transforms(ss.Spec.Transforms), secrets)
+	if err != nil {
+		return fmt.Errorf("transforms: %w", err)
+	}
+
+	hash, err := desiredHash(c.opts.HashKey, &ss.Spec, secrets)
+	if err != nil {
+		return err
+	}
+
+	if boolValue(ss.Spec.DryRun) {
+		l.WithField("secrets", len(secrets)).Info("Dry run, not writing")
+		status.Status = StatusDryRun
+		status.Hash = hash
+		return nil
+	}
+
+	if status.Status == StatusSynced && status.Hash == hash && time.Since(status.LastSyncTime.Time) < c.opts.ResyncPeriod {
+		l.Debug("Unchanged since last sync")
+		return nil
+	}
+
+	for i, dest := range dests {
+		if err := dest.Init(ctx); err != nil {
+			return fmt.Errorf("dest[%d]: %w", i, err)
+		}
+		written, err := writeDest(ctx, ss, dest, secrets)
+		if err != nil {
+			return fmt.Errorf("dest[%d]: %w", i, err)
+		}
+		if boolValue(ss.Spec.SyncDelete) {
+			if err := deleteUnmanaged(ctx, ss, dest, written); err != nil {
+				return fmt.Errorf("dest[%d]: %w", i, err)
+			}
+		}
+	}
+
+	l.WithFields(log.Fields{
+		"secrets":      len(secrets),
+		"destinations": len(dests),
+	}).Info("Synced")
+	status.Status = StatusSynced
+	status.Hash = hash
+	status.LastSyncTime = metav1.Now()
+	status.SyncDestinations = len(dests)
+	return nil
+}
+
+// readSource reads every secret under the source path, keyed by the path
+// relative to it. A source path that is itself a secret is keyed by "".
+func readSource(ctx context.Context, source SyncClient) (map[string]map[string]interface{}, error) {
+	base := strings.Trim(source.GetPath(), "/")
+	paths, err := source.ListSecrets(ctx, base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source %s: %w", base, err)
+	}
+
+	rel := make([]string, 0, len(paths))
+	for _, p := range paths {
+		rel = append(rel, strings.TrimPrefix(strings.TrimPrefix(strings.Trim(p, "/"), base), "/"))
+	}
+	if len(rel) == 0 {
+		rel = []string{""}
+	}
+
+	secrets := make(map[string]map[string]interface{}, len(rel))
+	for _, r := range rel {
+		data, err := source.GetSecret(ctx, path.Join(base, r))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source %s: %w", path.Join(base, r), err)
+		}
+		if data == nil {
+			continue
+		}
+		var secret map[string]interface{}
+		if err := json.Unmarshal(data, &secret); err != nil {
+			return nil, fmt.Errorf("source %s is not a JSON object", path.Join(base, r))
+		}
+		secrets[r] = secret
+	}
+	return secrets, nil
+}
+
+// writeDest writes the secrets under the destination path and returns the
+// written destination paths
+func writeDest(ctx context.Context, ss *v1alpha1.SecretSync, dest SyncClient, secrets map[string]map[string]interface{}) (map[string]bool, error) {
+	names := make([]string, 0, len(secrets))
+	for r := range secrets {
+		names = append(names, r)
+	}
+	sort.Strings(names)
+
+	written := make(map[string]bool, len(names))
+	for _, r := range names {
+		data, err := json.Marshal(secrets[r])
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", r, err)
+		}
+		p := destPath(dest, r)
+		if _, err := dest.WriteSecret(ctx, ss.ObjectMeta, p, data); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", p, err)
+		}
+		written[p] = true
+	}
+	return written, nil
+}
+
+// deleteUnmanaged deletes destination secrets that are no longer in the
+// source. AWS secrets are only deleted when they carry this object's
+// ownership tags; Vault secrets only under a non-empty destination path.
+func deleteUnmanaged(ctx context.Context, ss *v1alpha1.SecretSync, dest SyncClient, written map[string]bool) error {
+	l := log.WithFields(log.Fields{
+		"action":     "Controller.deleteUnmanaged",
+		"secretsync": key(ss),
+	})
+
+	base := strings.Trim(dest.GetPath(), "/")
+	tagged, isTagged := dest.(taggedClient)
+	listPath := base
+	if isTagged {
+		listPath = ""
+	} else if base == "" {
+		return fmt.Errorf("syncDelete requires a destination path")
+	}
+
+	existing, err := dest.ListSecrets(ctx, listPath)
+	if err != nil {
+		return fmt.Errorf("failed to list destination: %w", err)
+	}
+	sort.Strings(existing)
+
+	owner := Owner(ss)
+	for _, name := range existing {
+		if written[name] {
+			continue
+		}
+		if isTagged {
+			tags := tagged.GetSecretTags(name)
+			if tags[pipeline.TagManagedBy] != pipeline.ManagedByValue || tags[pipeline.TagTarget] != owner {
+				continue
+			}
+		}
+		l.WithField("path", name).Info("Deleting secret removed from source")
+		if err := dest.DeleteSecret(ctx, name); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// destPath maps a source-relative path to a destination path
+func destPath(dest SyncClient, rel string) string {
+	base := strings.Trim(dest.GetPath(), "/")
+	if rel == "" {
+		return base
+	}
+	if base == "" {
+		return rel
+	}
+	return base + "/" + rel
+}
+
+// desiredHash digests the spec and the desired secrets so unchanged objects
+// are not written again. The digest is an HMAC under key: a plain hash of the
+// secrets would let anyone who can read the status test guesses of their values.
+func desiredHash(key []byte, spec *v1alpha1.SecretSyncSpec, secrets map[string]map[string]interface{}) (string, error) {
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode spec: %w", err)
+	}
+	secretsJSON, err := json.Marshal(secrets)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode secrets: %w", err)
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write(specJSON)
+	h.Write([]byte{0})
+	h.Write(secretsJSON)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// filters converts the CRD filters to pipeline target filters
+func filters(f *v1alpha1.FilterConfig) *pipeline.TargetFilters {
+	if f == nil {
+		return nil
+	}
+	out := &pipeline.TargetFilters{}
+	if f.Regex != nil {
+		out.Regex = &pipeline.PatternFilter{Include: f.Regex.Include, Exclude: f.Regex.Exclude}
+	}
+	if f.Path != nil {
+		out.Path = &pipeline.PatternFilter{Include: f.Path.Include, Exclude: f.Path.Exclude}
+	}
+	return out
+}
+
+// transforms converts the CRD transforms to pipeline target transforms
+func transforms(t *v1alpha1.TransformSpec) *pipeline.TargetTransforms {
+	if t == nil {
+		return nil
+	}
+	out := &pipeline.TargetTransforms{Include: t.Include, Exclude: t.Exclude}
+	for _, r := range t.Rename {
+		out.Rename = append(out.Rename, pipeline.RenameTransform{From: r.From, To: r.To})
+	}
+	if t.Template != nil {
+		out.Template = *t.Template
+	}
+	return out
+}
+
+// Owner is the ownership tag target of an object's AWS secrets
+func Owner(ss *v1alpha1.SecretSync) string {
+	return "k8s:" + key(ss)
+}
+
+func key(ss *v1alpha1.SecretSync) string {
+	return ss.Namespace + "/" + ss.Name
+}
+
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
@@ -0,0 +1,451 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeStore is an in-memory secret store
+type fakeStore struct {
+	mu      sync.Mutex
+	path    string
+	secrets map[string][]byte
+	tags    map[string]map[string]string // non-nil makes the store tagged like AWS
+	writes  int
+	deletes []string
+}
+
+func (s *fakeStore) Init(ctx context.Context) error { return nil }
+func (s *fakeStore) GetPath() string                { return s.path }
+
+func (s *fakeStore) ListSecrets(ctx context.Context, p string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for name := range s.secrets {
+		if p == "" || strings.HasPrefix(name, p+"/") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *fakeStore) GetSecret(ctx context.Context, p string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.secrets[p]
+	if !ok {
+		return nil, fmt.Errorf("secret %s not found", p)
+	}
+	return data, nil
+}
+
+func (s *fakeStore) WriteSecret(ctx context.Context, meta metav1.ObjectMeta, p string, data []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.secrets[p] = data
+	s.writes++
+	return data, nil
+}
+
+func (s *fakeStore) DeleteSecret(ctx context.Context, p string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.secrets, p)
+	s.deletes = append(s.deletes, p)
+	return nil
+}
+
+// taggedStore is a fakeStore with AWS-style ownership tags
+type taggedStore struct{ *fakeStore }
+
+func (s taggedStore) GetSecretTags(name string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tags[name]
+}
+
+// fakeKube is an in-memory Kubernetes API
+type fakeKube struct {
+	mu      sync.Mutex
+	items   []v1alpha1.SecretSync
+	updates []v1alpha1.SecretSync
+	events  chan Event
+}
+
+func (k *fakeKube) List(ctx context.Context, namespace string) (*v1alpha1.SecretSyncList, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	list := &v1alpha1.SecretSyncList{}
+	list.ResourceVersion = "1"
+	for _, ss := range k.items {
+		list.Items = append(list.Items, *ss.DeepCopy())
+	}
+	return list, nil
+}
+
+func (k *fakeKube) UpdateStatus(ctx context.Context, ss *v1alpha1.SecretSync) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.updates = append(k.updates, *ss.DeepCopy())
+	for i := range k.items {
+		if k.items[i].Name == ss.Name && k.items[i].Namespace == ss.Namespace {
+			k.items[i].Status = ss.Status
+		}
+	}
+	return nil
+}
+
+func (k *fakeKube) Watch(ctx context.Context, namespace, resourceVersion string) (<-chan Event, error) {
+	return k.events, nil
+}
+
+func (k *fakeKube) statusUpdates() []v1alpha1.SecretSync {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return append([]v1alpha1.SecretSync(nil), k.updates...)
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func newSecretSync() *v1alpha1.SecretSync {
+	return &v1alpha1.SecretSync{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "api", Generation: 1},
+		Spec: v1alpha1.SecretSyncSpec{
+			Dest: []*v1alpha1.StoreConfig{{}},
+		},
+	}
+}
+
+func newSource() *fakeStore {
+	return &fakeStore{
+		path: "kv/apps/api",
+		secrets: map[string][]byte{
+			"kv/apps/api/db":    []byte(`{"user":"app","password":"s3cret"}`),
+			"kv/apps/api/redis": []byte(`{"url":"redis://cache"}`),
+		},
+	}
+}
+
+func clients(source SyncClient, dests ...SyncClient) ClientFactory {
+	return func(spec *v1alpha1.SecretSyncSpec, owner string) (SyncClient, []SyncClient, error) {
+		return source, dests, nil
+	}
+}
+
+func TestReconcile_Sync(t *testing.T) {
+	source := newSource()
+	dest := &fakeStore{path: "secret/api", secrets: map[string][]byte{}}
+	c := New(&fakeKube{}, Options{Clients: clients(source, dest)})
+
+	status, err := c.Reconcile(context.Background(), newSecretSync())
+	require.NoError(t, err)
+
+	assert.Equal(t, StatusSynced, status.Status)
+	assert.Equal(t, 1, status.SyncDestinations)
+	assert.NotEmpty(t, status.Hash)
+	assert.False(t, status.LastSyncTime.IsZero())
+	assert.JSONEq(t, `{"user":"app","password":"s3cret"}`, string(dest.secrets["secret/api/db"]))
+	assert.JSONEq(t, `{"url":"redis://cache"}`, string(dest.secrets["secret/api/redis"]))
+}
+
+func TestReconcile_SingleSecretSource(t *testing.T) {
+	source := &fakeStore{path: "kv/apps/api", secrets: map[string][]byte{
+		"kv/apps/api": []byte(`{"token":"abc"}`),
+	}}
+	dest := &fakeStore{path: "api-token", secrets: map[string][]byte{}}
+	c := New(&fakeKube{}, Options{Clients: clients(source, dest)})
+
+	_, err := c.Reconcile(context.Background(), newSecretSync())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"token":"abc"}`, string(dest.secrets["api-token"]))
+}
+
+func TestReconcile_FiltersAndTransforms(t *testing.T) {
+	source := newSource()
+	dest := &fakeStore{path: "secret/api", secrets: map[string][]byte{}}
+	c := New(&fakeKube{}, Options{Clients: clients(source, dest)})
+
+	ss := newSecretSync()
+	ss.Spec.Filters = &v1alpha1.FilterConfig{Path: &v1alpha1.PathFilterConfig{Include: []string{"db"}}}
+	ss.Spec.Transforms = &v1alpha1.TransformSpec{Rename: []v1alpha1.RenameTransform{{From: "user", To: "username"}}}
+
+	_, err := c.Reconcile(context.Background(), ss)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"username":"app","password":"s3cret"}`, string(dest.secrets["secret/api/db"]))
+	assert.NotContains(t, dest.secrets, "secret/api/redis")
+}
+
+func TestReconcile_SuspendAndDryRun(t *testing.T) {
+	source := newSource()
+	dest := &fakeStore{path: "secret/api", secrets: map[string][]byte{}}
+	c := New(&fakeKube{}, Options{Clients: clients(source, dest)})
+
+	ss := newSecretSync()
+	ss.Spec.Suspend = boolPtr(true)
+	status, err := c.Reconcile(context.Background(), ss)
+	require.NoError(t, err)
+	assert.Equal(t, StatusSuspended, status.Status)
+
+	ss.Spec.Suspend = nil
+	ss.Spec.DryRun = boolPtr(true)
+	status, err = c.Reconcile(context.Background(), ss)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDryRun, status.Status)
+	assert.NotEmpty(t, status.Hash)
+	assert.Zero(t, dest.writes)
+}
+
+func TestReconcile_SkipsUnchanged(t *testing.T) {
+	source := newSource()
+	dest := &fakeStore{path: "secret/api", secrets: map[string][]byte{}}
+	c := New(&fakeKube{}, Options{Clients: clients(source, dest)})
+
+	ss := newSecretSync()
+	status, err := c.Reconcile(context.Background(), ss)
+	require.NoError(t, err)
+	assert.Equal(t, 2, dest.writes)
+
+	ss.Status = status
+	again, err := c.Reconcile(context.Background(), ss)
+	require.NoError(t, err)
+	assert.Equal(t, status, again)
+	assert.Equal(t, 2, dest.writes, "unchanged object must not be written again")
+
+	source.secrets["kv/apps/api/db"] = []byte(`{"user":"app","password":"rotated"}`)
+	changed, err := c.Reconcile(context.Background(), ss)
+	require.NoError(t, err)
+	assert.NotEqual(t, status.Hash, changed.Hash)
+	assert.Equal(t, 4, dest.writes)
+}
+
+func TestDesiredHash_Keyed(t *testing.T) {
+	spec := &newSecretSync().Spec
+	secrets := map[string]map[string]interface{}{"db": {"password": "s3cret"}}
+
+	hash, err := desiredHash([]byte("key"), spec, secrets)
+	require.NoError(t, err)
+	same, err := desiredHash([]byte("key"), spec, secrets)
+	require.NoError(t, err)
+	assert.Equal(t, hash, same)
+
+	// Without the key the hash cannot be recomputed from guessed values
+	other, err := desiredHash([]byte("other"), spec, secrets)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+
+	// Controllers without a configured key use different random keys
+	a := New(&fakeKube{}, Options{})
+	b := New(&fakeKube{}, Options{})
+	assert.Len(t, a.opts.HashKey, 32)
+	assert.NotEqual(t, a.opts.HashKey, b.opts.HashKey)
+}
+
+func TestReconcile_SyncDeleteOnlyOwned(t *testing.T) {
+	source := newSource()
+	ss := newSecretSync()
+	ss.Spec.SyncDelete = boolPtr(true)
+	owned := map[string]string{pipeline.TagManagedBy: pipeline.ManagedByValue, pipeline.TagTarget: Owner(ss)}
+
+	dest := taggedStore{&fakeStore{
+		path: "api",
+		secrets: map[string][]byte{
+			"api/old":     []byte(`{}`),
+			"api/manual":  []byte(`{}`),
+			"api/foreign": []byte(`{}`),
+		},
+		tags: map[string]map[string]string{
+			"api/old":     owned,
+			"api/foreign": {pipeline.TagManagedBy: pipeline.ManagedByValue, pipeline.TagTarget: "k8s:other/api"},
+		},
+	}}
+	c := New(&fakeKube{}, Options{Clients: clients(source, dest)})
+
+	_, err := c.Reconcile(context.Background(), ss)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api/old"}, dest.deletes)
+	assert.Contains(t, dest.secrets, "api/manual")
+	assert.Contains(t, dest.secrets, "api/foreign")
+	assert.Contains(t, dest.secrets, "api/db")
+}
+
+func TestReconcile_SyncDeleteVaultPath(t *testing.T) {
+	source := newSource()
+	ss := newSecretSync()
+	ss.Spec.SyncDelete = boolPtr(true)
+
+	dest := &fakeStore{path: "secret/api", secrets: map[string][]byte{
+		"secret/api/old":   []byte(`{}`),
+		"secret/other/old": []byte(`{}`),
+	}}
+	c := New(&fakeKube{}, Options{Clients: clients(source, dest)})
+
+	_, err := c.Reconcile(context.Background(), ss)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"secret/api/old"}, dest.deletes)
+
+	dest.path = ""
+	ss.Status = v1alpha1.SecretSyncStatus{}
+	status, err := c.Reconcile(context.Background(), ss)
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(status.Status, StatusFailed+": "))
+}
+
+func TestReconcile_FailureHidesValues(t *testing.T) {
+	source := &fakeStore{path: "kv/apps/api", secrets: map[string][]byte{
+		"kv/apps/api/db": []byte(`not json s3cret`),
+	}}
+	dest := &fakeStore{path: "secret/api", secrets: map[string][]byte{}}
+	c := New(&fakeKube{}, Options{Clients: clients(source, dest)})
+
+	status, err := c.Reconcile(context.Background(), newSecretSync())
+	require.Error(t, err)
+	assert.True(t, strings.HasPrefix(status.Status, StatusFailed+": "))
+	assert.NotContains(t, status.Status, "s3cret")
+}
+
+func TestControllerRun(t *testing.T) {
+	source := newSource()
+	dest := &fakeStore{path: "secret/api", secrets: map[string][]byte{}}
+	kube := &fakeKube{items: []v1alpha1.SecretSync{*newSecretSync()}, events: make(chan Event)}
+	c := New(kube, Options{Clients: clients(source, dest)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return len(kube.statusUpdates()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, StatusSynced, kube.statusUpdates()[0].Status.Status)
+
+	// The controller's own status update does not change the generation and is skipped
+	updated := kube.statusUpdates()[0]
+	kube.events <- Event{Type: EventModified, Object: &updated}
+
+	// A spec change is reconciled
+	changed := updated.DeepCopy()
+	changed.Generation = 2
+	changed.Spec.DryRun = boolPtr(true)
+	kube.events <- Event{Type: EventModified, Object: changed}
+
+	require.Eventually(t, func() bool { return len(kube.statusUpdates()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, StatusDryRun, kube.statusUpdates()[1].Status.Status)
+	assert.Equal(t, 2, dest.writes)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestKubeClient(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("sa-token\n"), 0600))
+
+	var statusBody v1alpha1.SecretSync
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sa-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Query().Get("watch") == "1":
+			assert.Equal(t, "7", r.URL.Query().Get("resourceVersion"))
+			fmt.Fprintln(w, `{"type":"MODIFIED","object":{"metadata":{"namespace":"apps","name":"api","generation":2}}}`)
+			fmt.Fprintln(w, `{"type":"ERROR","object":{"kind":"Status","code":410}}`)
+		case r.Method == http.MethodGet:
+			assert.Equal(t, "/apis/secretsync.jbcom.dev/v1alpha1/namespaces/apps/secretsyncs", r.URL.Path)
+			fmt.Fprint(w, `{"metadata":{"resourceVersion":"7"},"items":[{"metadata":{"namespace":"apps","name":"api"}}]}`)
+		case r.Method == http.MethodPut:
+			assert.Equal(t, "/apis/secretsync.jbcom.dev/v1alpha1/namespaces/apps/secretsyncs/api/status", r.URL.Path)
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&statusBody))
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	k, err := NewKubeClient(server.URL, tokenFile, "")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	list, err := k.List(ctx, "apps")
+	require.NoError(t, err)
+	assert.Equal(t, "7", list.ResourceVersion)
+	require.Len(t, list.Items, 1)
+
+	ss := list.Items[0]
+	ss.Status.Status = StatusSynced
+	require.NoError(t, k.UpdateStatus(ctx, &ss))
+	assert.Equal(t, "SecretSync", statusBody.Kind)
+	assert.Equal(t, StatusSynced, statusBody.Status.Status)
+
+	events, err := k.Watch(ctx, "apps", list.ResourceVersion)
+	require.NoError(t, err)
+	var got []Event
+	for ev := range events {
+		got = append(got, ev)
+	}
+	require.Len(t, got, 2)
+	assert.Equal(t, EventModified, got[0].Type)
+	assert.Equal(t, int64(2), got[0].Object.Generation)
+	assert.Equal(t, EventError, got[1].Type)
+
+	bad, err := NewKubeClient(server.URL, "", "")
+	require.NoError(t, err)
+	_, err = bad.List(ctx, "")
+	assert.ErrorContains(t, err, "401")
+}
+
+func TestControllerNotifications(t *testing.T) {
+	var mu sync.Mutex
+	var got []pipeline.NotificationData
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data pipeline.NotificationData
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&data))
+		mu.Lock()
+		got = append(got, data)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	source := newSource()
+	dest := &fakeStore{path: "secret/api", secrets: map[string][]byte{}}
+	kube := &fakeKube{}
+	c := New(kube, Options{Clients: clients(source, dest)})
+
+	ss := newSecretSync()
+	ss.Spec.Notifications = []*v1alpha1.NotificationSpec{{Webhook: &v1alpha1.WebhookNotification{URL: hook.URL}}}
+
+	c.reconcileAndUpdate(context.Background(), ss)
+	require.Len(t, got, 1)
+	assert.Equal(t, v1alpha1.NotificationEventSyncSuccess, got[0].Event)
+	assert.Equal(t, "SecretSync apps/api synced to 1 destinations", got[0].Message)
+	assert.Equal(t, "api", got[0].Resource.Name)
+	assert.Equal(t, "apps", got[0].Resource.Namespace)
+	assert.Equal(t, 1, got[0].Resource.SyncDestinations)
+
+	// An unchanged resync sends nothing
+	ss.Status = kube.statusUpdates()[0].Status
+	c.reconcileAndUpdate(context.Background(), ss)
+	assert.Len(t, got, 1)
+
+	source.secrets["kv/apps/api/db"] = []byte(`not json`)
+	c.reconcileAndUpdate(context.Background(), ss)
+	require.Len(t, got, 2)
+	assert.Equal(t, v1alpha1.NotificationEventSyncFailure, got[1].Event)
+	assert.NotEmpty(t, got[1].Resource.Error)
+}
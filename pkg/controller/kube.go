@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	log "github.com/sirupsen/logrus"
+)
+
+// In-cluster service account files
+const (
+	serviceAccountDir   = "/var/run/secrets/kubernetes.io/serviceaccount"
+	serviceAccountToken = serviceAccountDir + "/token"
+	serviceAccountCA    = serviceAccountDir + "/ca.crt"
+)
+
+// KubeClient is a minimal client of the SecretSync resources of the
+// Kubernetes API
+type KubeClient struct {
+	// Host is the API server URL
+	Host string
+	// TokenFile holds the bearer token; it is read on every request so
+	// rotated service account tokens are picked up
+	TokenFile string
+
+	http *http.Client
+}
+
+// NewKubeClient creates a client for an API server. caFile, when set, is the
+// CA bundle that verifies the server certificate.
+func NewKubeClient(host, tokenFile, caFile string) (*KubeClient, error) {
+	if host == "" {
+		return nil, fmt.Errorf("kubernetes API host is required")
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubernetes CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in kubernetes CA %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return &KubeClient{
+		Host:      strings.TrimSuffix(host, "/"),
+		TokenFile: tokenFile,
+		http:      &http.Client{Transport: transport},
+	}, nil
+}
+
+// InClusterClient creates a client from the pod's service account
+func InClusterClient() (*KubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+	return NewKubeClient("https://"+net.JoinHostPort(host, port), serviceAccountToken, serviceAccountCA)
+}
+
+// resourcePath returns the API path of the secretsyncs collection
+func resourcePath(namespace string) string {
+	p := "/apis/" + v1alpha1.SchemeGroupVersion.String()
+	if namespace != "" {
+		p += "/namespaces/" + url.PathEscape(namespace)
+	}
+	return p + "/secretsyncs"
+}
+
+// do sends an authenticated request
+func (k *KubeClient) do(ctx context.Context, method, p string, query url.Values, body []byte) (*http.Response, error) {
+	u := k.Host + p
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if k.TokenFile != "" {
+		token, err := os.ReadFile(k.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubernetes token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return k.http.Do(req)
+}
+
+// checkResponse returns an error for non-2xx responses, closing the body
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var status struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(msg, &status) == nil && status.Message != "" {
+		return fmt.Errorf("kubernetes API returned %s: %s", resp.Status, status.Message)
+	}
+	return fmt.Errorf("kubernetes API returned %s", resp.Status)
+}
+
+// List returns the SecretSync objects in a namespace ("" for all)
+func (k *KubeClient) List(ctx context.Context, namespace string) (*v1alpha1.SecretSyncList, error) {
+	resp, err := k.do(ctx, http.MethodGet, resourcePath(namespace), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var list v1alpha1.SecretSyncList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode SecretSync list: %w", err)
+	}
+	return &list, nil
+}
+
+// UpdateStatus writes the status subresource of an object
+func (k *KubeClient) UpdateStatus(ctx context.Context, ss *v1alpha1.SecretSync) error {
+	obj := ss.DeepCopy()
+	obj.APIVersion = v1alpha1.SchemeGroupVersion.String()
+	obj.Kind = "SecretSync"
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	resp, err := k.do(ctx, http.MethodPut, resourcePath(ss.Namespace)+"/"+url.PathEscape(ss.Name)+"/status", nil, body)
+	if err != nil {
+		return err
+	}
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Watch streams changes after resourceVersion. The channel is closed when
+// the server ends the watch; an expired resourceVersion is delivered as an
+// EventError.
+func (k *KubeClient) Watch(ctx context.Context, namespace, resourceVersion string) (<-chan Event, error) {
+	query := url.Values{
+		"watch":               {"1"},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {fmt.Sprint(int((30 * time.Minute).Seconds()))},
+	}
+	if resourceVersion != "" {
+		query.Set("resourceVersion", resourceVersion)
+	}
+	resp, err := k.do(ctx, http.MethodGet, resourcePath(namespace), query, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+
+		dec := json.NewDecoder(resp.Body)
+		for {
+			var raw struct {
+				Type   EventType       `json:"type"`
+				Object json.RawMessage `json:"object"`
+			}
+			if err := dec.Decode(&raw); err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					log.WithError(err).Debug("Watch stream ended")
+				}
+				return
+			}
+
+			ev := Event{Type: raw.Type}
+			if raw.Type != EventError {
+				ev.Object = &v1alpha1.SecretSync{}
+				if err := json.Unmarshal(raw.Object, ev.Object); err != nil {
+					log.WithError(err).Warn("Failed to decode watch event")
+					continue
+				}
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
@@ -0,0 +1,218 @@
+package daemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	log "github.com/sirupsen/logrus"
+)
+
+// APIAuth configures authentication of the trigger API.
+// The API is disabled unless at least one method is configured.
+type APIAuth struct {
+	// Tokens are the accepted bearer tokens
+	Tokens []string
+	// ClientCerts accepts requests with a TLS client certificate verified
+	// against the server's client CA
+	ClientCerts bool
+	// ClientNames, when set, restricts client certificates to these names
+	// (subject common name or DNS SAN)
+	ClientNames []string
+}
+
+// enabled reports whether any authentication method is configured
+func (a APIAuth) enabled() bool {
+	return len(a.Tokens) > 0 || a.ClientCerts
+}
+
+// authenticate returns the identity of an authorized request, or an error
+func (a APIAuth) authenticate(r *http.Request) (string, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		for i, accepted := range a.Tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(accepted)) == 1 {
+				return fmt.Sprintf("token#%d", i+1), nil
+			}
+		}
+		return "", fmt.Errorf("invalid bearer token")
+	}
+
+	if a.ClientCerts && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		cert := r.TLS.VerifiedChains[0][0]
+		if len(a.ClientNames) == 0 {
+			return "cert:" + cert.Subject.CommonName, nil
+		}
+		names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+		for _, allowed := range a.ClientNames {
+			for _, name := range names {
+				if name == allowed {
+					return "cert:" + name, nil
+				}
+			}
+		}
+		return "", fmt.Errorf("client certificate %q is not allowed", cert.Subject.CommonName)
+	}
+
+	return "", fmt.Errorf("missing credentials")
+}
+
+// authorized wraps a read-only handler so it requires the same
+// authentication as the trigger API when authentication is configured.
+// Without authentication the handler is served as is.
+func (d *Daemon) authorized(h http.HandlerFunc) http.HandlerFunc {
+	if !d.opts.Auth.enabled() {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := d.opts.Auth.authenticate(r); err != nil {
+			log.WithFields(log.Fields{
+				"action": "Daemon.authorized",
+				"remote": r.RemoteAddr,
+				"path":   r.URL.Path,
+			}).WithError(err).Warn("Rejected request")
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// TriggerRequest is the body of POST /runs. Fields mirror pipeline.Options.
+type TriggerRequest struct {
+	Operation       pipeline.Operation `json:"operation"` // default pipeline
+	Targets         []string           `json:"targets"`   // default all targets
+	DryRun          bool               `json:"dry_run"`
+	ComputeDiff     bool               `json:"compute_diff"`
+	ContinueOnError bool               `json:"continue_on_error"`
+}
+
+// TriggerResponse is the reply to POST /runs
+type TriggerResponse struct {
+	ID           string   `json:"id"`
+	State        RunState `json:"state"`
+	Deduplicated bool     `json:"deduplicated"` // an overlapping queued run absorbed the request
+	URL          string   `json:"url"`
+}
+
+// triggerOptions validates a trigger request and returns the run options
+func (d *Daemon) triggerOptions(req TriggerRequest) (pipeline.Options, error) {
+	opts := d.opts.RunOptions
+	switch req.Operation {
+	case "":
+		opts.Operation = pipeline.OperationPipeline
+	case pipeline.OperationMerge, pipeline.OperationSync, pipeline.OperationPipeline:
+		opts.Operation = req.Operation
+	default:
+		return opts, fmt.Errorf("operation %q must be merge, sync or pipeline", req.Operation)
+	}
+
+	for _, t := range req.Targets {
+		if !d.targets[t] {
+			return opts, fmt.Errorf("unknown target %q", t)
+		}
+	}
+	if len(req.Targets) > 0 {
+		opts.Targets = normalizeTargets(req.Targets)
+	}
+	opts.DryRun = req.DryRun
+	opts.ComputeDiff = opts.ComputeDiff || req.ComputeDiff
+	opts.ContinueOnError = opts.ContinueOnError || req.ContinueOnError
+	return opts, nil
+}
+
+// handleTrigger serves POST /runs
+func (d *Daemon) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	l := log.WithFields(log.Fields{
+		"action": "Daemon.handleTrigger",
+		"remote": r.RemoteAddr,
+	})
+
+	if !d.opts.Auth.enabled() {
+		http.Error(w, "trigger API is disabled", http.StatusNotFound)
+		return
+	}
+	caller, err := d.opts.Auth.authenticate(r)
+	if err != nil {
+		l.WithError(err).Warn("Rejected trigger request")
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !d.ready.Load() {
+		http.Error(w, "not accepting runs", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req TriggerRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	opts, err := d.triggerOptions(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	run, deduplicated := d.enqueue("api:"+caller, opts)
+	l.WithFields(log.Fields{
+		"caller":       caller,
+		"run_id":       run.ID,
+		"operation":    opts.Operation,
+		"targets":      opts.Targets,
+		"deduplicated": deduplicated,
+	}).Info("Run triggered")
+
+	url := "/runs/" + run.ID
+	w.Header().Set("Location", url)
+	writeJSON(w, http.StatusAccepted, TriggerResponse{
+		ID:           run.ID,
+		State:        RunQueued,
+		Deduplicated: deduplicated,
+		URL:          url,
+	})
+}
+
+// normalizeTargets returns sorted, unique target names
+func normalizeTargets(targets []string) []string {
+	seen := make(map[string]bool, len(targets))
+	out := make([]string, 0, len(targets))
+	for _, t := range targets {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// overlaps reports whether two target lists share a target (nil means all)
+func overlaps(a, b []string) bool {
+	if a == nil || b == nil {
+		return true
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unionTargets combines two target lists (nil means all)
+func unionTargets(a, b []string) []string {
+	if a == nil || b == nil {
+		return nil
+	}
+	return normalizeTargets(append(append([]string{}, a...), b...))
+}
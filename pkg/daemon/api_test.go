@@ -0,0 +1,200 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIAuth(t *testing.T) {
+	auth := APIAuth{Tokens: []string{"first", "second"}}
+
+	req := httptest.NewRequest(http.MethodPost, "/runs", nil)
+	_, err := auth.authenticate(req)
+	assert.Error(t, err, "no credentials")
+
+	req.Header.Set("Authorization", "Bearer second")
+	caller, err := auth.authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "token#2", caller, "the token itself is never used as the identity")
+
+	req.Header.Set("Authorization", "Bearer wrong")
+	_, err = auth.authenticate(req)
+	assert.Error(t, err)
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "vending"}, DNSNames: []string{"vending.internal"}}
+	req = httptest.NewRequest(http.MethodPost, "/runs", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+	_, err = auth.authenticate(req)
+	assert.Error(t, err, "client certificates not enabled")
+
+	auth = APIAuth{ClientCerts: true}
+	caller, err = auth.authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "cert:vending", caller)
+
+	auth.ClientNames = []string{"vending.internal"}
+	caller, err = auth.authenticate(req)
+	require.NoError(t, err)
+	assert.Equal(t, "cert:vending.internal", caller)
+
+	auth.ClientNames = []string{"other"}
+	_, err = auth.authenticate(req)
+	assert.Error(t, err)
+
+	req.TLS = &tls.ConnectionState{}
+	_, err = auth.authenticate(req)
+	assert.Error(t, err, "unverified connection")
+}
+
+func TestTriggerAPI(t *testing.T) {
+	runner := &fakeRunner{}
+	d, err := New(runner, testConfig(pipeline.ServeConfig{Interval: "1h"}), Options{
+		Auth: APIAuth{Tokens: []string{"s3cret"}},
+	})
+	require.NoError(t, err)
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+
+	post := func(token, body string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/runs", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := post("", `{}`)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = post("s3cret", `{}`)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "not accepting runs before Run")
+	resp.Body.Close()
+
+	// Hold the scheduled run so triggered runs stay queued
+	runner.started = make(chan struct{}, 1)
+	runner.release = make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+	<-runner.started
+	runner.started = nil
+
+	for _, body := range []string{`{"targets": ["Nope"]}`, `{"operation": "delete"}`, `{"target": "Prod"}`, `not json`} {
+		resp = post("s3cret", body)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+		resp.Body.Close()
+	}
+
+	resp = post("s3cret", `{"operation": "sync", "targets": ["Prod"], "dry_run": true, "compute_diff": true}`)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var first TriggerResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&first))
+	resp.Body.Close()
+	assert.Equal(t, "/runs/"+first.ID, resp.Header.Get("Location"))
+	assert.False(t, first.Deduplicated)
+
+	resp = post("s3cret", `{"operation": "sync", "targets": ["Stg", "Prod"], "dry_run": true, "compute_diff": true}`)
+	var second TriggerResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&second))
+	resp.Body.Close()
+	assert.True(t, second.Deduplicated)
+	assert.Equal(t, first.ID, second.ID)
+
+	close(runner.release)
+	require.Eventually(t, func() bool {
+		run, _ := d.runs.get(first.ID)
+		return run.State == RunSucceeded
+	}, time.Second, 5*time.Millisecond)
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL+first.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	var run Run
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&run))
+	resp.Body.Close()
+	assert.Equal(t, "api:token#1", run.Trigger)
+	assert.Equal(t, []string{"Prod", "Stg"}, run.Targets)
+	assert.True(t, run.DryRun)
+	require.NotNil(t, run.Diff)
+	assert.Len(t, run.Results, 1)
+
+	runner.mu.Lock()
+	last := runner.calls[len(runner.calls)-1]
+	runner.mu.Unlock()
+	assert.Equal(t, pipeline.OperationSync, last.Operation)
+	assert.Equal(t, []string{"Prod", "Stg"}, last.Targets)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestTriggerAPI_Disabled(t *testing.T) {
+	d, err := New(&fakeRunner{}, testConfig(pipeline.ServeConfig{}), Options{})
+	require.NoError(t, err)
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/runs", "application/json", bytes.NewBufferString(`{}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestReadAPI_Auth(t *testing.T) {
+	get := func(srv *httptest.Server, path, token string) int {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	d, err := New(&fakeRunner{}, testConfig(pipeline.ServeConfig{}), Options{Auth: APIAuth{Tokens: []string{"s3cret"}}})
+	require.NoError(t, err)
+	d.ready.Store(true)
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+
+	for _, path := range []string{"/runs", "/runs/missing", "/drift"} {
+		assert.Equal(t, http.StatusUnauthorized, get(srv, path, ""), path)
+		assert.Equal(t, http.StatusUnauthorized, get(srv, path, "wrong"), path)
+	}
+	assert.Equal(t, http.StatusOK, get(srv, "/runs", "s3cret"))
+	assert.Equal(t, http.StatusNotFound, get(srv, "/runs/missing", "s3cret"))
+	assert.Equal(t, http.StatusNotFound, get(srv, "/drift", "s3cret"))
+	for _, path := range []string{"/healthz", "/readyz", "/metrics"} {
+		assert.Equal(t, http.StatusOK, get(srv, path, ""), path)
+	}
+
+	// Without authentication the read routes stay open
+	d, err = New(&fakeRunner{}, testConfig(pipeline.ServeConfig{}), Options{})
+	require.NoError(t, err)
+	open := httptest.NewServer(d.Handler())
+	defer open.Close()
+	assert.Equal(t, http.StatusOK, get(open, "/runs", ""))
+	assert.Equal(t, http.StatusNotFound, get(open, "/runs/missing", ""))
+	assert.Equal(t, http.StatusNotFound, get(open, "/drift", ""))
+}
@@ -0,0 +1,513 @@
+// Package daemon runs the pipeline as a long-running service.
+//
+// The daemon loads the configuration once and runs the pipeline on the
+// schedules configured under "serve:" and on authenticated trigger requests.
+// Runs are executed one at a time by a single worker. With
+// serve.drift_interval, targets are also checked for drift between runs.
+// Health, readiness, run status, drift, the trigger API and metrics are
+// served on one HTTP handler.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	reqctx "github.com/jbcom/secretsync/pkg/context"
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/observability"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	log "github.com/sirupsen/logrus"
+)
+
+// Runner executes pipeline runs; *pipeline.Pipeline implements it
+type Runner interface {
+	Run(ctx context.Context, opts pipeline.Options) ([]pipeline.Result, error)
+	Diff() *diff.PipelineDiff
+}
+
+// DriftDetector checks targets for drift; *pipeline.Pipeline implements it
+type DriftDetector interface {
+	DetectDrift(ctx context.Context, targets []string) (*pipeline.DriftReport, error)
+}
+
+// Options configures the daemon
+type Options struct {
+	// RunOptions are the base options of every run (parallelism, continue on error, diff)
+	RunOptions pipeline.Options
+	// ShutdownTimeout bounds how long shutdown waits for the in-flight run
+	// before cancelling it (default 5m)
+	ShutdownTimeout time.Duration
+	// MaxRuns is the number of runs kept for /runs (default 100)
+	MaxRuns int
+	// Auth enables the trigger API (POST /runs)
+	Auth APIAuth
+}
+
+// Daemon schedules and executes pipeline runs
+type Daemon struct {
+	runner    Runner
+	schedules []pipeline.Schedule
+	targets   map[string]bool
+	opts      Options
+
+	runs *runStore
+
+	queueMu sync.Mutex
+	queue   []*Run
+	wake    chan struct{}
+
+	// Drift checks, when enabled; execMu keeps them from overlapping runs
+	drift         DriftDetector
+	driftInterval time.Duration
+	driftJitter   time.Duration
+	lastDrift     atomic.Pointer[pipeline.DriftReport]
+	execMu        sync.Mutex
+
+	ready    atomic.Bool
+	stopping chan struct{}
+}
+
+// New creates a daemon for a pipeline configuration
+func New(runner Runner, cfg *pipeline.Config, opts Options) (*Daemon, error) {
+	schedules, err := cfg.Serve.Schedules()
+	if err != nil {
+		return nil, err
+	}
+
+	// The default schedule covers every target without its own schedule
+	if len(schedules[0].Exclude) > 0 {
+		excluded := make(map[string]bool, len(schedules[0].Exclude))
+		for _, name := range schedules[0].Exclude {
+			excluded[name] = true
+		}
+		targets := []string{}
+		for name := range cfg.Targets {
+			if !excluded[name] {
+				targets = append(targets, name)
+			}
+		}
+		sort.Strings(targets)
+		schedules[0].Targets = targets
+	}
+
+	targets := make(map[string]bool, len(cfg.Targets))
+	for name := range cfg.Targets {
+		targets[name] = true
+	}
+
+	driftInterval, err := cfg.Serve.DriftCheckInterval()
+	if err != nil {
+		return nil, err
+	}
+	var drift DriftDetector
+	if driftInterval > 0 {
+		var ok bool
+		if drift, ok = runner.(DriftDetector); !ok {
+			return nil, fmt.Errorf("serve.drift_interval: runner does not support drift checks")
+		}
+	}
+
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = 5 * time.Minute
+	}
+	if opts.MaxRuns <= 0 {
+		opts.MaxRuns = 100
+	}
+
+	return &Daemon{
+		runner:        runner,
+		schedules:     schedules,
+		targets:       targets,
+		opts:          opts,
+		runs:          newRunStore(opts.MaxRuns),
+		wake:          make(chan struct{}, 1),
+		drift:         drift,
+		driftInterval: driftInterval,
+		driftJitter:   schedules[0].Jitter,
+		stopping:      make(chan struct{}),
+	}, nil
+}
+
+// Run starts the schedules and the worker and blocks until ctx is done.
+// On shutdown no new runs or targets are started; the in-flight run finishes
+// its running targets, or is cancelled after ShutdownTimeout.
+func (d *Daemon) Run(ctx context.Context) error {
+	l := log.WithFields(log.Fields{
+		"action": "Daemon.Run",
+	})
+
+	// Runs are not cancelled with ctx so in-flight targets can finish
+	runCtx, cancelRuns := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRuns()
+
+	var wg sync.WaitGroup
+	for _, s := range d.schedules {
+		if s.Targets != nil && len(s.Targets) == 0 {
+			continue
+		}
+		l.WithFields(log.Fields{
+			"schedule": s.Name,
+			"interval": s.Interval,
+			"jitter":   s.Jitter,
+			"targets":  s.Targets,
+		}).Info("Starting schedule")
+		wg.Add(1)
+		go func(s pipeline.Schedule) {
+			defer wg.Done()
+			d.schedule(s)
+		}(s)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.work(runCtx)
+	}()
+
+	if d.drift != nil {
+		l.WithField("interval", d.driftInterval).Info("Starting drift checks")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.checkDriftLoop(runCtx)
+		}()
+	}
+
+	d.ready.Store(true)
+	<-ctx.Done()
+
+	l.Info("Shutting down, waiting for in-flight run")
+	d.ready.Store(false)
+	close(d.stopping)
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(d.opts.ShutdownTimeout):
+		l.WithField("timeout", d.opts.ShutdownTimeout).Warn("Shutdown timeout reached, cancelling in-flight run")
+		cancelRuns()
+		<-done
+	}
+
+	l.Info("Daemon stopped")
+	return nil
+}
+
+// schedule enqueues a run of s on its interval until the daemon stops.
+// The first run starts after the jitter alone.
+func (d *Daemon) schedule(s pipeline.Schedule) {
+	delay := jitter(s.Jitter)
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-d.stopping:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		opts := d.opts.RunOptions
+		opts.Operation = s.Operation
+		opts.Targets = s.Targets
+		d.enqueue("schedule:"+s.Name, opts)
+
+		delay = s.Interval + jitter(s.Jitter)
+	}
+}
+
+// jitter returns a random duration in [0, max)
+func jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	return rand.N(max)
+}
+
+// enqueue queues a run. A queued run with the same operation and flags whose
+// targets overlap absorbs the request instead: its targets become the union of
+// both. Running runs are never extended, since they may have read their
+// sources already. Returns the run and whether the request was deduplicated.
+func (d *Daemon) enqueue(trigger string, opts pipeline.Options) (*Run, bool) {
+	d.queueMu.Lock()
+	defer d.queueMu.Unlock()
+
+	for _, queued := range d.queue {
+		q := queued.opts
+		if q.Operation != opts.Operation || q.DryRun != opts.DryRun || q.ComputeDiff != opts.ComputeDiff ||
+			q.ContinueOnError != opts.ContinueOnError || !overlaps(q.Targets, opts.Targets) {
+			continue
+		}
+		d.runs.update(queued.ID, func(r *Run) {
+			r.opts.Targets = unionTargets(r.opts.Targets, opts.Targets)
+			r.Targets = r.opts.Targets
+			r.Deduplicated++
+		})
+		log.WithFields(log.Fields{
+			"action":  "Daemon.enqueue",
+			"trigger": trigger,
+			"run_id":  queued.ID,
+		}).Debug("Request merged into queued run")
+		return queued, true
+	}
+
+	r := &Run{
+		ID:        reqctx.NewRequestContext().RequestID,
+		Trigger:   trigger,
+		Operation: opts.Operation,
+		Targets:   opts.Targets,
+		DryRun:    opts.DryRun,
+		State:     RunQueued,
+		QueuedAt:  time.Now().UTC(),
+		opts:      opts,
+	}
+	d.runs.add(r)
+	d.queue = append(d.queue, r)
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return r, false
+}
+
+// work executes queued runs one at a time until the daemon stops,
+// then cancels the runs still queued
+func (d *Daemon) work(ctx context.Context) {
+	for {
+		select {
+		case <-d.stopping:
+			d.cancelQueued()
+			return
+		default:
+		}
+
+		d.queueMu.Lock()
+		var next *Run
+		if len(d.queue) > 0 {
+			next = d.queue[0]
+			d.queue = d.queue[1:]
+		}
+		d.queueMu.Unlock()
+
+		if next == nil {
+			select {
+			case <-d.stopping:
+			case <-d.wake:
+			}
+			continue
+		}
+		d.execute(ctx, next)
+	}
+}
+
+// execute runs one queued run and records its outcome
+func (d *Daemon) execute(ctx context.Context, r *Run) {
+	l := log.WithFields(log.Fields{
+		"action":  "Daemon.execute",
+		"run_id":  r.ID,
+		"trigger": r.Trigger,
+	})
+	d.execMu.Lock()
+	defer d.execMu.Unlock()
+
+	started := time.Now().UTC()
+	d.runs.update(r.ID, func(r *Run) {
+		r.State = RunRunning
+		r.StartedAt = &started
+	})
+	l.Info("Starting run")
+
+	// The run ID becomes the pipeline's request ID
+	ctx = reqctx.WithRequestContext(ctx, &reqctx.RequestContext{RequestID: r.ID, StartTime: started})
+	var opts pipeline.Options
+	d.runs.update(r.ID, func(r *Run) {
+		opts = r.opts
+	})
+	opts.Stop = d.stopping
+	results, err := d.runner.Run(ctx, opts)
+
+	finished := time.Now().UTC()
+	state := RunSucceeded
+	if err != nil {
+		state = RunFailed
+	}
+	records := pipeline.RecordResults(results)
+	var runDiff *diff.PipelineDiff
+	if opts.DryRun || opts.ComputeDiff {
+		runDiff = d.runner.Diff()
+	}
+	for _, rr := range records {
+		if !rr.Success {
+			state = RunFailed
+		}
+	}
+
+	d.runs.update(r.ID, func(r *Run) {
+		r.State = state
+		r.FinishedAt = &finished
+		r.Results = records
+		r.Diff = runDiff
+		if err != nil {
+			r.Error = err.Error()
+		}
+	})
+
+	entry := l.WithFields(log.Fields{
+		"state":       state,
+		"duration_ms": finished.Sub(started).Milliseconds(),
+	})
+	if err != nil {
+		entry.WithError(err).Error("Run failed")
+	} else {
+		entry.Info("Run completed")
+	}
+}
+
+// checkDriftLoop checks every target for drift on the drift interval until
+// the daemon stops. The first check starts after the jitter alone.
+func (d *Daemon) checkDriftLoop(ctx context.Context) {
+	delay := jitter(d.driftJitter)
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-d.stopping:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		d.checkDrift(ctx)
+		delay = d.driftInterval + jitter(d.driftJitter)
+	}
+}
+
+// checkDrift checks every target for drift and publishes the
+// secretsync_drift_secrets gauges. Targets that could not be checked keep
+// their previous values.
+func (d *Daemon) checkDrift(ctx context.Context) {
+	l := log.WithFields(log.Fields{
+		"action": "Daemon.checkDrift",
+	})
+	d.execMu.Lock()
+	defer d.execMu.Unlock()
+
+	report, err := d.drift.DetectDrift(ctx, nil)
+	if err != nil {
+		l.WithError(err).Error("Drift check failed")
+		return
+	}
+	d.lastDrift.Store(report)
+
+	var drifted []string
+	for _, t := range report.Targets {
+		if t.Error != "" {
+			continue
+		}
+		for _, kind := range pipeline.DriftKinds {
+			observability.DriftSecrets.WithLabelValues(t.Target, string(kind)).Set(float64(t.Count(kind)))
+		}
+		if len(t.Secrets) > 0 {
+			drifted = append(drifted, t.Target)
+		}
+	}
+	if len(drifted) > 0 {
+		l.WithField("targets", strings.Join(drifted, ",")).Warn("Drift detected")
+	} else {
+		l.Debug("No drift detected")
+	}
+}
+
+// cancelQueued marks every queued run cancelled
+func (d *Daemon) cancelQueued() {
+	d.queueMu.Lock()
+	queued := d.queue
+	d.queue = nil
+	d.queueMu.Unlock()
+
+	for _, r := range queued {
+		d.runs.update(r.ID, func(r *Run) {
+			r.State = RunCancelled
+		})
+	}
+}
+
+// Handler serves /metrics, /healthz, /readyz, /runs, /drift and, when Auth
+// is configured, the trigger API on POST /runs. With Auth configured, /runs
+// and /drift require authentication; /metrics, /healthz and /readyz stay open.
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", observability.Handler())
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !d.ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+
+	mux.HandleFunc("GET /runs", d.authorized(func(w http.ResponseWriter, r *http.Request) {
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+		writeJSON(w, http.StatusOK, d.runs.list(limit))
+	}))
+
+	mux.HandleFunc("POST /runs", d.handleTrigger)
+
+	mux.HandleFunc("GET /drift", d.authorized(func(w http.ResponseWriter, r *http.Request) {
+		report := d.lastDrift.Load()
+		if report == nil {
+			http.Error(w, "no drift check has completed", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	}))
+
+	mux.HandleFunc("GET /runs/{id}", d.authorized(func(w http.ResponseWriter, r *http.Request) {
+		run, ok := d.runs.get(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "run not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, run)
+	}))
+
+	return mux
+}
+
+// writeJSON writes v as an indented JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.WithError(err).Debug("Failed to write response")
+	}
+}
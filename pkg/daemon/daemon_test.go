@@ -0,0 +1,330 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	reqctx "github.com/jbcom/secretsync/pkg/context"
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/observability"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRunner records runs and optionally blocks until released
+type fakeRunner struct {
+	mu      sync.Mutex
+	calls   []pipeline.Options
+	ids     []string
+	started chan struct{}
+	release chan struct{}
+	err     error
+}
+
+func (f *fakeRunner) Run(ctx context.Context, opts pipeline.Options) ([]pipeline.Result, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, opts)
+	f.ids = append(f.ids, reqctx.GetRequestID(ctx))
+	f.mu.Unlock()
+
+	if f.started != nil {
+		f.started <- struct{}{}
+	}
+	if f.release != nil {
+		<-f.release
+	}
+	return []pipeline.Result{{Target: "Stg", Phase: "merge", Success: f.err == nil, Error: f.err}}, f.err
+}
+
+func (f *fakeRunner) Diff() *diff.PipelineDiff {
+	return &diff.PipelineDiff{DryRun: true}
+}
+
+func (f *fakeRunner) callCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+func testConfig(serve pipeline.ServeConfig) *pipeline.Config {
+	return &pipeline.Config{
+		Targets: map[string]pipeline.Target{"Stg": {}, "Prod": {}, "Dev": {}},
+		Serve:   serve,
+	}
+}
+
+func TestNew_Schedules(t *testing.T) {
+	d, err := New(&fakeRunner{}, testConfig(pipeline.ServeConfig{
+		Interval: "30m",
+		Targets:  map[string]string{"Prod": "5m"},
+	}), Options{})
+	require.NoError(t, err)
+
+	require.Len(t, d.schedules, 2)
+	assert.Equal(t, "default", d.schedules[0].Name)
+	assert.Equal(t, 30*time.Minute, d.schedules[0].Interval)
+	assert.Equal(t, []string{"Dev", "Stg"}, d.schedules[0].Targets, "overridden targets leave the default schedule")
+	assert.Equal(t, []string{"Prod"}, d.schedules[1].Targets)
+	assert.Equal(t, 5*time.Minute, d.schedules[1].Interval)
+
+	_, err = New(&fakeRunner{}, testConfig(pipeline.ServeConfig{Interval: "often"}), Options{})
+	assert.Error(t, err)
+}
+
+func TestDaemon_ScheduledRuns(t *testing.T) {
+	runner := &fakeRunner{}
+	d, err := New(runner, testConfig(pipeline.ServeConfig{Interval: "10ms"}), Options{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+
+	require.Eventually(t, func() bool { return runner.callCount() >= 2 }, 2*time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	runner.mu.Lock()
+	assert.Equal(t, pipeline.OperationPipeline, runner.calls[0].Operation)
+	assert.Nil(t, runner.calls[0].Targets, "default schedule without overrides runs all targets")
+	firstID := runner.ids[0]
+	runner.mu.Unlock()
+
+	run, ok := d.runs.get(firstID)
+	require.True(t, ok, "the run ID is the pipeline request ID")
+	assert.Equal(t, RunSucceeded, run.State)
+	assert.Equal(t, "schedule:default", run.Trigger)
+	require.Len(t, run.Results, 1)
+	assert.NotNil(t, run.FinishedAt)
+}
+
+func TestDaemon_GracefulShutdown(t *testing.T) {
+	runner := &fakeRunner{started: make(chan struct{}, 1), release: make(chan struct{})}
+	d, err := New(runner, testConfig(pipeline.ServeConfig{Interval: "1h"}), Options{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+
+	<-runner.started
+	queued, _ := d.enqueue("schedule:other", pipeline.Options{Operation: pipeline.OperationSync})
+	cancel()
+
+	select {
+	case <-done:
+		t.Fatal("daemon stopped before the in-flight run finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	runner.mu.Lock()
+	stop := runner.calls[0].Stop
+	runner.mu.Unlock()
+	select {
+	case <-stop:
+	default:
+		t.Fatal("in-flight run was not told to stop starting targets")
+	}
+
+	close(runner.release)
+	require.NoError(t, <-done)
+
+	assert.Equal(t, 1, runner.callCount(), "queued runs are not started after shutdown")
+	run, _ := d.runs.get(queued.ID)
+	assert.Equal(t, RunCancelled, run.State)
+}
+
+func TestDaemon_ShutdownTimeout(t *testing.T) {
+	runner := &fakeRunner{started: make(chan struct{}, 1), release: make(chan struct{})}
+	d, err := New(runner, testConfig(pipeline.ServeConfig{}), Options{ShutdownTimeout: 10 * time.Millisecond})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+	<-runner.started
+	cancel()
+
+	// The fake ignores ctx; release it once the timeout has fired
+	time.Sleep(30 * time.Millisecond)
+	close(runner.release)
+	require.NoError(t, <-done)
+}
+
+func TestDaemon_EnqueueDedupe(t *testing.T) {
+	d, err := New(&fakeRunner{}, testConfig(pipeline.ServeConfig{}), Options{})
+	require.NoError(t, err)
+
+	syncOpts := pipeline.Options{Operation: pipeline.OperationSync}
+	withTargets := func(opts pipeline.Options, targets ...string) pipeline.Options {
+		opts.Targets = targets
+		return opts
+	}
+
+	first, dedup := d.enqueue("api:a", withTargets(syncOpts, "Prod"))
+	assert.False(t, dedup)
+
+	// Overlapping targets merge into the queued run
+	second, dedup := d.enqueue("api:b", withTargets(syncOpts, "Prod", "Stg"))
+	assert.True(t, dedup)
+	assert.Equal(t, first.ID, second.ID)
+
+	// Disjoint targets, or different flags, queue a new run
+	other, dedup := d.enqueue("api:a", withTargets(syncOpts, "Dev"))
+	assert.False(t, dedup)
+	assert.NotEqual(t, first.ID, other.ID)
+	dry := syncOpts
+	dry.DryRun = true
+	_, dedup = d.enqueue("api:a", withTargets(dry, "Prod"))
+	assert.False(t, dedup)
+
+	// All targets overlaps everything and absorbs the targets
+	all, dedup := d.enqueue("schedule:default", pipeline.Options{Operation: pipeline.OperationPipeline})
+	assert.False(t, dedup)
+	_, dedup = d.enqueue("api:a", withTargets(pipeline.Options{Operation: pipeline.OperationPipeline}, "Prod"))
+	assert.True(t, dedup)
+
+	run, _ := d.runs.get(first.ID)
+	assert.Equal(t, []string{"Prod", "Stg"}, run.Targets)
+	assert.Equal(t, 1, run.Deduplicated)
+	run, _ = d.runs.get(all.ID)
+	assert.Nil(t, run.Targets)
+	assert.Len(t, d.queue, 4)
+}
+
+func TestDaemon_Handler(t *testing.T) {
+	runner := &fakeRunner{err: errors.New("vault sealed")}
+	d, err := New(runner, testConfig(pipeline.ServeConfig{}), Options{})
+	require.NoError(t, err)
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+
+	get := func(path string) *http.Response {
+		resp, err := http.Get(srv.URL + path)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := get("/healthz")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode, "not ready before Run")
+	resp.Body.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+	require.Eventually(t, func() bool { return runner.callCount() == 1 }, time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool {
+		runs := d.runs.list(1)
+		return len(runs) == 1 && runs[0].State == RunFailed
+	}, time.Second, 5*time.Millisecond)
+
+	resp = get("/readyz")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = get("/runs")
+	var runs []Run
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&runs))
+	resp.Body.Close()
+	require.Len(t, runs, 1)
+	assert.Equal(t, "vault sealed", runs[0].Error)
+
+	resp = get("/runs/" + runs[0].ID)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = get("/runs/missing")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp.Body.Close()
+
+	resp = get("/metrics")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+// fakeDriftRunner also detects drift
+type fakeDriftRunner struct {
+	fakeRunner
+	checks atomic.Int32
+}
+
+func (f *fakeDriftRunner) DetectDrift(ctx context.Context, targets []string) (*pipeline.DriftReport, error) {
+	f.checks.Add(1)
+	return &pipeline.DriftReport{Targets: []pipeline.TargetDrift{
+		{Target: "Prod", Checked: 3, Secrets: []pipeline.DriftedSecret{
+			{Name: "app/db", Kind: pipeline.DriftModified},
+			{Name: "app/api", Kind: pipeline.DriftModified},
+			{Name: "app/old", Kind: pipeline.DriftAdded},
+		}},
+		{Target: "Stg", Checked: 2},
+		{Target: "Dev", Error: "access denied"},
+	}}, nil
+}
+
+func TestDaemon_DriftChecks(t *testing.T) {
+	_, err := New(&fakeRunner{}, testConfig(pipeline.ServeConfig{DriftInterval: "1m"}), Options{})
+	assert.ErrorContains(t, err, "serve.drift_interval")
+
+	runner := &fakeDriftRunner{}
+	d, err := New(runner, testConfig(pipeline.ServeConfig{Interval: "1h", DriftInterval: "10ms"}), Options{})
+	require.NoError(t, err)
+	srv := httptest.NewServer(d.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/drift")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode, "no drift check yet")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- d.Run(ctx) }()
+	require.Eventually(t, func() bool { return runner.checks.Load() >= 2 }, 2*time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(observability.DriftSecrets.WithLabelValues("Prod", "modified")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(observability.DriftSecrets.WithLabelValues("Prod", "added")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(observability.DriftSecrets.WithLabelValues("Prod", "deleted")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(observability.DriftSecrets.WithLabelValues("Stg", "modified")))
+
+	resp, err = http.Get(srv.URL + "/drift")
+	require.NoError(t, err)
+	var report pipeline.DriftReport
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	require.Len(t, report.Targets, 3)
+	assert.Equal(t, "access denied", report.Targets[2].Error)
+}
+
+func TestRunStore_Evicts(t *testing.T) {
+	s := newRunStore(2)
+	s.add(&Run{ID: "running", State: RunRunning})
+	s.add(&Run{ID: "a", State: RunSucceeded})
+	s.add(&Run{ID: "b", State: RunFailed})
+
+	_, ok := s.get("running")
+	assert.True(t, ok, "active runs are never evicted")
+	_, ok = s.get("a")
+	assert.False(t, ok)
+
+	runs := s.list(0)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "b", runs[0].ID)
+}
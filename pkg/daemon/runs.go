@@ -0,0 +1,109 @@
+package daemon
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/pipeline"
+)
+
+// RunState is the lifecycle state of a daemon run
+type RunState string
+
+const (
+	// RunQueued runs wait for the worker
+	RunQueued RunState = "queued"
+	// RunRunning runs are executing Pipeline.Run
+	RunRunning RunState = "running"
+	// RunSucceeded runs completed with every target successful
+	RunSucceeded RunState = "succeeded"
+	// RunFailed runs completed with an error or a failed target
+	RunFailed RunState = "failed"
+	// RunCancelled runs were still queued when the daemon shut down
+	RunCancelled RunState = "cancelled"
+)
+
+// Run is a pipeline run queued or executed by the daemon
+type Run struct {
+	ID         string                  `json:"id"`
+	Trigger    string                  `json:"trigger"` // schedule:<name> or api:<caller>
+	Operation  pipeline.Operation      `json:"operation"`
+	Targets    []string                `json:"targets,omitempty"` // empty means all targets
+	DryRun     bool                    `json:"dry_run"`
+	State      RunState                `json:"state"`
+	QueuedAt   time.Time               `json:"queued_at"`
+	StartedAt  *time.Time              `json:"started_at,omitempty"`
+	FinishedAt *time.Time              `json:"finished_at,omitempty"`
+	Error      string                  `json:"error,omitempty"`
+	Results    []pipeline.RecordResult `json:"results,omitempty"`
+	Diff       *diff.PipelineDiff      `json:"diff,omitempty"` // when dry run or compute_diff
+
+	// Deduplicated counts requests merged into this run while it was queued
+	Deduplicated int `json:"deduplicated,omitempty"`
+
+	opts pipeline.Options
+}
+
+// runStore keeps the most recent runs for the /runs endpoint
+type runStore struct {
+	mu    sync.Mutex
+	max   int
+	runs  map[string]*Run
+	order []string // oldest first
+}
+
+func newRunStore(max int) *runStore {
+	return &runStore{max: max, runs: make(map[string]*Run)}
+}
+
+// add stores a run, evicting the oldest finished runs beyond the limit
+func (s *runStore) add(r *Run) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs[r.ID] = r
+	s.order = append(s.order, r.ID)
+
+	for i := 0; len(s.order) > s.max && i < len(s.order); {
+		old := s.runs[s.order[i]]
+		if old.State == RunQueued || old.State == RunRunning {
+			i++
+			continue
+		}
+		delete(s.runs, old.ID)
+		s.order = append(s.order[:i], s.order[i+1:]...)
+	}
+}
+
+// update changes a run under the store's lock
+func (s *runStore) update(id string, fn func(*Run)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.runs[id]; ok {
+		fn(r)
+	}
+}
+
+// get returns a copy of a run
+func (s *runStore) get(id string) (Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.runs[id]
+	if !ok {
+		return Run{}, false
+	}
+	return *r, true
+}
+
+// list returns copies of up to limit runs, newest first
+func (s *runStore) list(limit int) []Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make([]Run, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0 && (limit <= 0 || len(runs) < limit); i-- {
+		runs = append(runs, *s.runs[s.order[i]])
+	}
+	return runs
+}
@@ -32,6 +32,9 @@ type SecretChange struct {
 	KeysAdded    []string `json:"keys_added,omitempty"`
 	KeysRemoved  []string `json:"keys_removed,omitempty"`
 	KeysModified []string `json:"keys_modified,omitempty"`
+
+	// Per-key types, and masked or shown values when requested
+	KeyChanges []KeyChange `json:"key_changes,omitempty"`
 	
 	// Current and desired states (values redacted by default)
 	CurrentKeys []string `json:"current_keys,omitempty"`
@@ -42,11 +45,23 @@ type SecretChange struct {
 	DesiredHash string `json:"desired_hash,omitempty"`
 }
 
+// KeyOverride records a scalar key that a later import overrode during merge
+type KeyOverride struct {
+	Path       string `json:"path"`             // Secret path within the bundle
+	Key        string `json:"key"`              // Key within the secret
+	Source     string `json:"source"`           // Import whose value won
+	Overridden string `json:"overridden"`       // Import whose value was replaced
+	Origin     string `json:"origin,omitempty"` // Original source, when the overridden value came through an inherited target
+	Protected  bool   `json:"protected,omitempty"`
+}
+
 // TargetDiff represents all changes for a single target
 type TargetDiff struct {
-	Target  string          `json:"target"`
-	Changes []SecretChange  `json:"changes"`
-	Summary ChangeSummary   `json:"summary"`
+	Target    string          `json:"target"`
+	Sources   []string        `json:"sources,omitempty"` // Merge sources in priority order, including import selectors
+	Changes   []SecretChange  `json:"changes"`
+	Summary   ChangeSummary   `json:"summary"`
+	Overrides []KeyOverride   `json:"overrides,omitempty"` // Scalar keys overridden during merge
 }
 
 // ChangeSummary provides statistics about changes
@@ -74,6 +89,7 @@ type PipelineDiff struct {
 	Summary     ChangeSummary `json:"summary"`
 	DryRun      bool          `json:"dry_run"`
 	ConfigPath  string        `json:"config_path,omitempty"`
+	Values      ValueMode     `json:"values,omitempty"` // How much of changed values the diff reveals
 }
 
 // IsZeroSum returns true if the entire pipeline has no changes
@@ -104,6 +120,15 @@ func (p *PipelineDiff) AddTargetDiff(td TargetDiff) {
 
 // DiffSecrets compares two secret maps and returns the changes
 func DiffSecrets(current, desired map[string]interface{}) []SecretChange {
+	return DiffSecretsWithOptions(current, desired, DiffOptions{})
+}
+
+// DiffSecretsWithOptions compares two secret maps and returns the changes.
+// Modified secrets always describe the type of each changed key; values are
+// masked or shown per opts.Values. Added and removed secrets describe their
+// keys only when values are masked or shown.
+func DiffSecretsWithOptions(current, desired map[string]interface{}, opts DiffOptions) []SecretChange {
+	describe := opts.Values == ValuesMasked || opts.Values == ValuesShown
 	var changes []SecretChange
 	seen := make(map[string]bool)
 
@@ -118,6 +143,7 @@ func DiffSecrets(current, desired map[string]interface{}) []SecretChange {
 				Path:        path,
 				ChangeType:  ChangeTypeAdded,
 				DesiredKeys: getMapKeys(desiredVal),
+				KeyChanges:  describeKeys(describe, nil, desiredVal, opts.Values),
 			})
 			continue
 		}
@@ -139,6 +165,7 @@ func DiffSecrets(current, desired map[string]interface{}) []SecretChange {
 				DesiredKeys: getMapKeys(desiredVal),
 			}
 			change.KeysAdded, change.KeysRemoved, change.KeysModified = diffMapKeys(currentVal, desiredVal)
+			change.KeyChanges = keyChanges(currentVal, desiredVal, opts.Values)
 			changes = append(changes, change)
 		}
 	}
@@ -150,6 +177,7 @@ func DiffSecrets(current, desired map[string]interface{}) []SecretChange {
 				Path:        path,
 				ChangeType:  ChangeTypeRemoved,
 				CurrentKeys: getMapKeys(currentVal),
+				KeyChanges:  describeKeys(describe, currentVal, nil, opts.Values),
 			})
 		}
 	}
@@ -162,6 +190,14 @@ func DiffSecrets(current, desired map[string]interface{}) []SecretChange {
 	return changes
 }
 
+// describeKeys returns the key changes of an added or removed secret, if describe is set
+func describeKeys(describe bool, current, desired interface{}, mode ValueMode) []KeyChange {
+	if !describe {
+		return nil
+	}
+	return keyChanges(current, desired, mode)
+}
+
 // ComputeSummary calculates summary statistics from changes
 func ComputeSummary(changes []SecretChange) ChangeSummary {
 	var summary ChangeSummary
@@ -233,10 +269,11 @@ func diffMapKeys(current, desired interface{}) (added, removed, modified []strin
 type OutputFormat string
 
 const (
-	OutputFormatHuman   OutputFormat = "human"
-	OutputFormatJSON    OutputFormat = "json"
-	OutputFormatGitHub  OutputFormat = "github"  // GitHub Actions annotations
-	OutputFormatCompact OutputFormat = "compact" // One-line summary
+	OutputFormatHuman    OutputFormat = "human"
+	OutputFormatJSON     OutputFormat = "json"
+	OutputFormatGitHub   OutputFormat = "github"   // GitHub Actions annotations
+	OutputFormatCompact  OutputFormat = "compact"  // One-line summary
+	OutputFormatMarkdown OutputFormat = "markdown" // PR comments and step summaries
 )
 
 // FormatDiff formats the pipeline diff according to the specified format
@@ -248,6 +285,8 @@ func FormatDiff(diff *PipelineDiff, format OutputFormat) string {
 		return formatGitHub(diff)
 	case OutputFormatCompact:
 		return formatCompact(diff)
+	case OutputFormatMarkdown:
+		return formatMarkdown(diff)
 	default:
 		return formatHuman(diff)
 	}
@@ -281,6 +320,7 @@ func formatHuman(diff *PipelineDiff) string {
 
 	if diff.IsZeroSum() {
 		sb.WriteString("✅ ZERO-SUM: No changes detected\n")
+		writeOverridesHuman(&sb, diff)
 		return sb.String()
 	}
 
@@ -293,6 +333,9 @@ func formatHuman(diff *PipelineDiff) string {
 		}
 
 		sb.WriteString(fmt.Sprintf("Target: %s\n", td.Target))
+		if len(td.Sources) > 0 {
+			sb.WriteString(fmt.Sprintf("Sources: %s\n", strings.Join(td.Sources, ", ")))
+		}
 		sb.WriteString(strings.Repeat("-", 40) + "\n")
 
 		for _, c := range td.Changes {
@@ -306,8 +349,10 @@ func formatHuman(diff *PipelineDiff) string {
 				if len(c.DesiredKeys) > 0 {
 					sb.WriteString(fmt.Sprintf("    keys: %v\n", c.DesiredKeys))
 				}
+				writeKeyChangesHuman(&sb, c.KeyChanges)
 			case ChangeTypeRemoved:
 				sb.WriteString(fmt.Sprintf("  - %s (removed)\n", c.Path))
+				writeKeyChangesHuman(&sb, c.KeyChanges)
 			case ChangeTypeModified:
 				sb.WriteString(fmt.Sprintf("  ~ %s (modified)\n", c.Path))
 				if len(c.KeysAdded) > 0 {
@@ -319,14 +364,95 @@ func formatHuman(diff *PipelineDiff) string {
 				if len(c.KeysModified) > 0 {
 					sb.WriteString(fmt.Sprintf("    ~ keys: %v\n", c.KeysModified))
 				}
+				writeKeyChangesHuman(&sb, c.KeyChanges)
 			}
 		}
 		sb.WriteString("\n")
 	}
 
+	writeOverridesHuman(&sb, diff)
 	return sb.String()
 }
 
+// writeKeyChangesHuman lists key changes that carry more than the key name:
+// a type change or a value description
+func writeKeyChangesHuman(sb *strings.Builder, changes []KeyChange) {
+	for _, kc := range changes {
+		if line := describeKeyChange(kc, true); line != "" {
+			sb.WriteString(fmt.Sprintf("      %s\n", line))
+		}
+	}
+}
+
+// describeKeyChange formats a key change as "key: <current> → <desired>",
+// or returns "" if there is nothing to say beyond the key name.
+// Values are only included if reveal is set and the diff shows them.
+func describeKeyChange(kc KeyChange, reveal bool) string {
+	side := func(typ string, v *ValueInfo) string {
+		if v == nil {
+			return typ
+		}
+		return typ + " " + v.format(reveal)
+	}
+
+	switch kc.ChangeType {
+	case ChangeTypeAdded:
+		if kc.Desired == nil {
+			return ""
+		}
+		return fmt.Sprintf("+ %s: %s", kc.Key, side(kc.DesiredType, kc.Desired))
+	case ChangeTypeRemoved:
+		if kc.Current == nil {
+			return ""
+		}
+		return fmt.Sprintf("- %s: %s", kc.Key, side(kc.CurrentType, kc.Current))
+	}
+
+	if kc.Current == nil && kc.Desired == nil {
+		if !kc.TypeChanged {
+			return ""
+		}
+		return fmt.Sprintf("~ %s: type %s → %s", kc.Key, kc.CurrentType, kc.DesiredType)
+	}
+	if kc.TypeChanged {
+		return fmt.Sprintf("~ %s: type changed, %s → %s", kc.Key, side(kc.CurrentType, kc.Current), side(kc.DesiredType, kc.Desired))
+	}
+	return fmt.Sprintf("~ %s: %s → %s", kc.Key, kc.Current.format(reveal), kc.Desired.format(reveal))
+}
+
+// writeOverridesHuman lists keys overridden during merge, per target
+func writeOverridesHuman(sb *strings.Builder, diff *PipelineDiff) {
+	header := false
+	for _, td := range diff.Targets {
+		if len(td.Overrides) == 0 {
+			continue
+		}
+		if !header {
+			sb.WriteString("\nKey Overrides\n")
+			sb.WriteString("=============\n")
+			header = true
+		}
+		sb.WriteString(fmt.Sprintf("Target: %s\n", td.Target))
+		for _, o := range td.Overrides {
+			marker := "~"
+			suffix := ""
+			if o.Protected {
+				marker = "!"
+				suffix = " [PROTECTED]"
+			}
+			sb.WriteString(fmt.Sprintf("  %s %s %s: %s overrides %s%s\n", marker, o.Path, o.Key, o.Source, overriddenLabel(o), suffix))
+		}
+	}
+}
+
+// overriddenLabel names the overridden import, with its original source if inherited
+func overriddenLabel(o KeyOverride) string {
+	if o.Origin != "" && o.Origin != o.Overridden {
+		return fmt.Sprintf("%s (from %s)", o.Overridden, o.Origin)
+	}
+	return o.Overridden
+}
+
 func formatGitHub(diff *PipelineDiff) string {
 	var sb strings.Builder
 
@@ -354,6 +480,9 @@ func formatGitHub(diff *PipelineDiff) string {
 
 		sb.WriteString(fmt.Sprintf("::group::Target: %s (%d changes)\n", td.Target,
 			td.Summary.Added+td.Summary.Removed+td.Summary.Modified))
+		if len(td.Sources) > 0 {
+			sb.WriteString(fmt.Sprintf("Sources: %s\n", strings.Join(td.Sources, ", ")))
+		}
 
 		for _, c := range td.Changes {
 			switch c.ChangeType {
@@ -364,11 +493,28 @@ func formatGitHub(diff *PipelineDiff) string {
 			case ChangeTypeModified:
 				sb.WriteString(fmt.Sprintf("::notice::~ %s (modified)\n", c.Path))
 			}
+			// Annotations are visible to anyone who can read the run, so
+			// values are never revealed here
+			for _, kc := range c.KeyChanges {
+				if line := describeKeyChange(kc, false); line != "" {
+					sb.WriteString(fmt.Sprintf("  %s %s\n", c.Path, line))
+				}
+			}
 		}
 
 		sb.WriteString("::endgroup::\n")
 	}
 
+	for _, td := range diff.Targets {
+		for _, o := range td.Overrides {
+			level := "notice"
+			if o.Protected {
+				level = "warning"
+			}
+			sb.WriteString(fmt.Sprintf("::%s::%s: %s %s from %s overridden by %s\n", level, td.Target, o.Path, o.Key, overriddenLabel(o), o.Source))
+		}
+	}
+
 	return sb.String()
 }
 
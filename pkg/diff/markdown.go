@@ -0,0 +1,129 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// formatMarkdown renders the diff as a Markdown report for pull request
+// comments and the GitHub step summary: summary tables followed by a
+// collapsible section per changed target. Values are never revealed, since
+// the report is visible to anyone who can read the pull request or run.
+func formatMarkdown(diff *PipelineDiff) string {
+	var sb strings.Builder
+
+	title := "SecretSync diff"
+	if diff.DryRun {
+		title += " (dry run)"
+	}
+	sb.WriteString(fmt.Sprintf("### %s\n\n", title))
+
+	if diff.IsZeroSum() {
+		sb.WriteString(fmt.Sprintf("✅ **Zero-sum**: no changes detected across %d secrets\n\n", diff.Summary.Total))
+	} else {
+		sb.WriteString(fmt.Sprintf("⚠️ **%d changes detected** (%d added, %d removed, %d modified)\n\n",
+			changeCount(diff.Summary), diff.Summary.Added, diff.Summary.Removed, diff.Summary.Modified))
+	}
+
+	sb.WriteString("| Target | Added | Removed | Modified | Unchanged | Total |\n")
+	sb.WriteString("|--------|------:|--------:|---------:|----------:|------:|\n")
+	for _, td := range diff.Targets {
+		writeSummaryRow(&sb, mdCode(td.Target), td.Summary)
+	}
+	writeSummaryRow(&sb, "**All targets**", diff.Summary)
+	sb.WriteString("\n")
+
+	for _, td := range diff.Targets {
+		if !td.Summary.HasChanges() {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("<details>\n<summary><b>%s</b>: +%d −%d ~%d</summary>\n\n",
+			htmlEscape(td.Target), td.Summary.Added, td.Summary.Removed, td.Summary.Modified))
+		if len(td.Sources) > 0 {
+			sources := make([]string, len(td.Sources))
+			for i, s := range td.Sources {
+				sources[i] = mdCode(s)
+			}
+			sb.WriteString(fmt.Sprintf("Sources: %s\n\n", strings.Join(sources, ", ")))
+		}
+
+		sb.WriteString("| | Secret | Keys |\n")
+		sb.WriteString("|---|--------|------|\n")
+		var details []string
+		for _, c := range td.Changes {
+			var marker, keys string
+			switch c.ChangeType {
+			case ChangeTypeAdded:
+				marker, keys = "➕ added", mdKeys("", c.DesiredKeys)
+			case ChangeTypeRemoved:
+				marker, keys = "➖ removed", mdKeys("", c.CurrentKeys)
+			case ChangeTypeModified:
+				marker = "✏️ modified"
+				var parts []string
+				for _, k := range []string{mdKeys("+", c.KeysAdded), mdKeys("−", c.KeysRemoved), mdKeys("~", c.KeysModified)} {
+					if k != "" {
+						parts = append(parts, k)
+					}
+				}
+				keys = strings.Join(parts, " ")
+			default:
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s |\n", marker, mdCode(c.Path), keys))
+
+			for _, kc := range c.KeyChanges {
+				if line := describeKeyChange(kc, false); line != "" {
+					details = append(details, fmt.Sprintf("- %s %s", mdCode(c.Path), mdCode(line)))
+				}
+			}
+		}
+		if len(details) > 0 {
+			sb.WriteString("\n" + strings.Join(details, "\n") + "\n")
+		}
+
+		if len(td.Overrides) > 0 {
+			sb.WriteString("\n**Key overrides**\n\n")
+			for _, o := range td.Overrides {
+				protected := ""
+				if o.Protected {
+					protected = " **(protected)**"
+				}
+				sb.WriteString(fmt.Sprintf("- %s %s: %s overrides %s%s\n",
+					mdCode(o.Path), mdCode(o.Key), mdCode(o.Source), mdCode(overriddenLabel(o)), protected))
+			}
+		}
+		sb.WriteString("\n</details>\n\n")
+	}
+
+	return sb.String()
+}
+
+// writeSummaryRow writes a row of the summary table
+func writeSummaryRow(sb *strings.Builder, label string, s ChangeSummary) {
+	sb.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %d | %d |\n", label, s.Added, s.Removed, s.Modified, s.Unchanged, s.Total))
+}
+
+// changeCount returns the number of added, removed and modified secrets
+func changeCount(s ChangeSummary) int {
+	return s.Added + s.Removed + s.Modified
+}
+
+// mdKeys formats a list of keys as code spans with a leading marker
+func mdKeys(marker string, keys []string) string {
+	formatted := make([]string, len(keys))
+	for i, k := range keys {
+		formatted[i] = marker + mdCode(k)
+	}
+	return strings.Join(formatted, " ")
+}
+
+// mdCode formats text as a code span that is safe inside a table cell
+func mdCode(s string) string {
+	s = strings.NewReplacer("`", "'", "|", `\|`, "\n", " ").Replace(s)
+	return "`" + s + "`"
+}
+
+// htmlEscape escapes text for the HTML of a <summary> element
+func htmlEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
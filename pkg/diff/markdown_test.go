@@ -0,0 +1,59 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatDiff_Markdown(t *testing.T) {
+	current := map[string]interface{}{
+		"db":  map[string]interface{}{"port": 5432.0, "password": "correct-horse-battery"},
+		"old": map[string]interface{}{"KEY": "x"},
+	}
+	desired := map[string]interface{}{
+		"db":      map[string]interface{}{"port": "5432", "password": "correct-horse-battery!"},
+		"api|new": map[string]interface{}{"TOKEN": "sk_live_abc"},
+	}
+	changes := DiffSecretsWithOptions(current, desired, DiffOptions{Values: ValuesShown})
+	summary := ComputeSummary(changes)
+	d := &PipelineDiff{
+		DryRun: true,
+		Targets: []TargetDiff{
+			{Target: "Production", Sources: []string{"analytics"}, Changes: changes, Summary: summary,
+				Overrides: []KeyOverride{{Path: "db", Key: "port", Source: "prod", Overridden: "analytics", Protected: true}}},
+			{Target: "Staging", Summary: ChangeSummary{Unchanged: 3, Total: 3}},
+		},
+		Summary: ChangeSummary{Added: 1, Removed: 1, Modified: 1, Unchanged: 3, Total: 6},
+	}
+
+	output := FormatDiff(d, OutputFormatMarkdown)
+	for _, want := range []string{
+		"### SecretSync diff (dry run)",
+		"⚠️ **3 changes detected** (1 added, 1 removed, 1 modified)",
+		"| `Production` | 1 | 1 | 1 | 0 | 3 |",
+		"| **All targets** | 1 | 1 | 1 | 3 | 6 |",
+		"<summary><b>Production</b>: +1 −1 ~1</summary>",
+		"| ➕ added | `api\\|new` | `TOKEN` |",
+		"| ➖ removed | `old` | `KEY` |",
+		"| ✏️ modified | `db` | ~`password` ~`port` |",
+		"- `db` `~ port: type changed, number ****",
+		"- `db` `port`: `prod` overrides `analytics` **(protected)**",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "Staging</b>") {
+		t.Error("unchanged targets have no details section")
+	}
+	for _, secret := range []string{"correct-horse", "sk_live_abc", "5432\""} {
+		if strings.Contains(output, secret) {
+			t.Errorf("markdown must never contain values, found %q", secret)
+		}
+	}
+
+	d = &PipelineDiff{Summary: ChangeSummary{Unchanged: 2, Total: 2}}
+	if output := FormatDiff(d, OutputFormatMarkdown); !strings.Contains(output, "✅ **Zero-sum**: no changes detected across 2 secrets") {
+		t.Errorf("expected zero-sum message:\n%s", output)
+	}
+}
@@ -0,0 +1,202 @@
+package diff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultGitHubAPIURL is the GitHub REST API used when no base URL is set
+const DefaultGitHubAPIURL = "https://api.github.com"
+
+// DefaultCommentMarker identifies the sticky comment among a pull request's comments
+const DefaultCommentMarker = "<!-- secretsync-diff -->"
+
+// maxCommentLength is GitHub's limit on the length of a comment body
+const maxCommentLength = 65536
+
+// PRCommenter posts a diff report as a single sticky pull request comment,
+// updating the comment it posted before instead of adding another
+type PRCommenter struct {
+	BaseURL     string // GitHub REST API base URL (default DefaultGitHubAPIURL)
+	Token       string
+	Repository  string // owner/name
+	PullRequest int
+	Marker      string // Hidden marker identifying the comment (default DefaultCommentMarker)
+	HTTPClient  *http.Client
+}
+
+// issueComment is the part of a GitHub issue comment the commenter uses
+type issueComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+}
+
+// Upsert creates the sticky comment with the given body, or updates it if
+// it exists. Returns the comment's API URL.
+func (c *PRCommenter) Upsert(ctx context.Context, body string) (string, error) {
+	l := log.WithFields(log.Fields{
+		"action":      "PRCommenter.Upsert",
+		"repository":  c.Repository,
+		"pullRequest": c.PullRequest,
+	})
+
+	if c.Token == "" {
+		return "", fmt.Errorf("GitHub token is required")
+	}
+	if strings.Count(c.Repository, "/") != 1 {
+		return "", fmt.Errorf("repository %q must be owner/name", c.Repository)
+	}
+	if c.PullRequest <= 0 {
+		return "", fmt.Errorf("pull request number is required")
+	}
+
+	body = c.marker() + "\n" + body
+	if len(body) > maxCommentLength {
+		const note = "\n\n_Report truncated; see the workflow run for the full diff._\n"
+		body = strings.ToValidUTF8(body[:maxCommentLength-len(note)], "") + note
+	}
+
+	existing, err := c.findComment(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return "", err
+	}
+	var comment issueComment
+	if existing != nil {
+		l.WithField("commentID", existing.ID).Debug("Updating sticky comment")
+		err = c.do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/issues/comments/%d", c.Repository, existing.ID), payload, &comment)
+	} else {
+		l.Debug("Creating sticky comment")
+		err = c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", c.Repository, c.PullRequest), payload, &comment)
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/repos/%s/issues/comments/%d", c.baseURL(), c.Repository, comment.ID), nil
+}
+
+// findComment returns the pull request comment carrying the marker, or nil
+func (c *PRCommenter) findComment(ctx context.Context) (*issueComment, error) {
+	for page := 1; ; page++ {
+		var comments []issueComment
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=100&page=%d", c.Repository, c.PullRequest, page)
+		if err := c.do(ctx, http.MethodGet, path, nil, &comments); err != nil {
+			return nil, err
+		}
+		for i := range comments {
+			if strings.HasPrefix(comments[i].Body, c.marker()) {
+				return &comments[i], nil
+			}
+		}
+		if len(comments) < 100 {
+			return nil, nil
+		}
+	}
+}
+
+// do sends a request to the GitHub API and decodes the JSON response into out
+func (c *PRCommenter) do(ctx context.Context, method, path string, payload []byte, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL()+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("GitHub API %s %s: %w", method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GitHub API %s %s returned %s: %s", method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode GitHub API response: %w", err)
+	}
+	return nil
+}
+
+func (c *PRCommenter) baseURL() string {
+	if c.BaseURL == "" {
+		return DefaultGitHubAPIURL
+	}
+	return strings.TrimSuffix(c.BaseURL, "/")
+}
+
+func (c *PRCommenter) marker() string {
+	if c.Marker == "" {
+		return DefaultCommentMarker
+	}
+	return c.Marker
+}
+
+// PullRequestFromEvent returns the pull request number of a GitHub Actions
+// event payload ($GITHUB_EVENT_PATH), or 0 if the event is not for a pull request
+func PullRequestFromEvent(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read event payload: %w", err)
+	}
+	var event struct {
+		PullRequest *struct {
+			Number int `json:"number"`
+		} `json:"pull_request"`
+		Issue *struct {
+			Number      int             `json:"number"`
+			PullRequest json.RawMessage `json:"pull_request"`
+		} `json:"issue"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return 0, fmt.Errorf("failed to parse event payload: %w", err)
+	}
+	switch {
+	case event.PullRequest != nil:
+		return event.PullRequest.Number, nil
+	case event.Issue != nil && event.Issue.PullRequest != nil:
+		// Comments on pull requests arrive as issue events
+		return event.Issue.Number, nil
+	}
+	return 0, nil
+}
+
+// AppendStepSummary appends a Markdown report to the GitHub Actions step
+// summary file ($GITHUB_STEP_SUMMARY)
+func AppendStepSummary(path, markdown string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open step summary: %w", err)
+	}
+	if _, err := f.WriteString(markdown + "\n"); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write step summary: %w", err)
+	}
+	return f.Close()
+}
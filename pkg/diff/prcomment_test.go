@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGitHub serves the issue comments API for one pull request
+type fakeGitHub struct {
+	mu       sync.Mutex
+	comments []issueComment
+	requests []string
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	if r.Header.Get("Authorization") != "Bearer test-token" {
+		http.Error(w, `{"message": "Bad credentials"}`, http.StatusUnauthorized)
+		return
+	}
+
+	var payload struct {
+		Body string `json:"body"`
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/infra/issues/7/comments":
+		page := f.comments
+		if r.URL.Query().Get("page") != "1" {
+			page = nil
+		}
+		json.NewEncoder(w).Encode(page)
+	case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/infra/issues/7/comments":
+		json.NewDecoder(r.Body).Decode(&payload)
+		c := issueComment{ID: int64(100 + len(f.comments)), Body: payload.Body}
+		f.comments = append(f.comments, c)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(c)
+	case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/repos/acme/infra/issues/comments/"):
+		json.NewDecoder(r.Body).Decode(&payload)
+		for i := range f.comments {
+			if fmt.Sprintf("/repos/acme/infra/issues/comments/%d", f.comments[i].ID) == r.URL.Path {
+				f.comments[i].Body = payload.Body
+				json.NewEncoder(w).Encode(f.comments[i])
+				return
+			}
+		}
+		http.NotFound(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestPRCommenter_Upsert(t *testing.T) {
+	gh := &fakeGitHub{comments: []issueComment{{ID: 1, Body: "LGTM"}}}
+	server := httptest.NewServer(gh)
+	defer server.Close()
+
+	c := &PRCommenter{BaseURL: server.URL + "/", Token: "test-token", Repository: "acme/infra", PullRequest: 7}
+
+	url, err := c.Upsert(context.Background(), "first report")
+	if err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if url != server.URL+"/repos/acme/infra/issues/comments/101" {
+		t.Errorf("unexpected comment URL %q", url)
+	}
+
+	if _, err := c.Upsert(context.Background(), "second report"); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	if len(gh.comments) != 2 {
+		t.Fatalf("expected the sticky comment to be updated, got %d comments", len(gh.comments))
+	}
+	if gh.comments[1].Body != DefaultCommentMarker+"\nsecond report" {
+		t.Errorf("unexpected comment body %q", gh.comments[1].Body)
+	}
+	if gh.comments[0].Body != "LGTM" {
+		t.Error("other comments must not be touched")
+	}
+	if last := gh.requests[len(gh.requests)-1]; last != "PATCH /repos/acme/infra/issues/comments/101" {
+		t.Errorf("expected an update, got %s", last)
+	}
+
+	c.Token = "wrong"
+	if _, err := c.Upsert(context.Background(), "report"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("expected API error, got %v", err)
+	}
+	c.Token, c.Repository = "test-token", "infra"
+	if _, err := c.Upsert(context.Background(), "report"); err == nil {
+		t.Error("expected error for invalid repository")
+	}
+}
+
+func TestPRCommenter_Truncates(t *testing.T) {
+	gh := &fakeGitHub{}
+	server := httptest.NewServer(gh)
+	defer server.Close()
+
+	c := &PRCommenter{BaseURL: server.URL, Token: "test-token", Repository: "acme/infra", PullRequest: 7}
+	if _, err := c.Upsert(context.Background(), strings.Repeat("é", maxCommentLength)); err != nil {
+		t.Fatalf("Upsert failed: %v", err)
+	}
+	body := gh.comments[0].Body
+	if len(body) > maxCommentLength || !strings.HasSuffix(body, "_Report truncated; see the workflow run for the full diff._\n") {
+		t.Errorf("expected truncated body, got %d bytes", len(body))
+	}
+}
+
+func TestPullRequestFromEvent(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]int{
+		`{"pull_request": {"number": 42}}`:             42,
+		`{"issue": {"number": 9, "pull_request": {}}}`: 9,
+		`{"issue": {"number": 9}}`:                     0,
+		`{"ref": "refs/heads/main"}`:                   0,
+	}
+	for payload, want := range tests {
+		path := filepath.Join(dir, "event.json")
+		if err := os.WriteFile(path, []byte(payload), 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := PullRequestFromEvent(path)
+		if err != nil || got != want {
+			t.Errorf("PullRequestFromEvent(%s) = %d, %v; want %d", payload, got, err, want)
+		}
+	}
+	if _, err := PullRequestFromEvent(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected error for missing payload")
+	}
+}
+
+func TestAppendStepSummary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.md")
+	if err := AppendStepSummary(path, "one"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendStepSummary(path, "two"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "one\ntwo\n" {
+		t.Errorf("unexpected summary %q", data)
+	}
+}
@@ -0,0 +1,229 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/jbcom/secretsync/pkg/utils"
+)
+
+// ValueMode controls how much of each changed value a diff reveals
+type ValueMode string
+
+const (
+	// ValuesHidden reports key names and types only (default)
+	ValuesHidden ValueMode = ""
+	// ValuesMasked adds the first and last two characters, length and entropy class
+	ValuesMasked ValueMode = "masked"
+	// ValuesShown adds the values themselves. Never use where output is shared.
+	ValuesShown ValueMode = "show"
+)
+
+// DiffOptions configures DiffSecretsWithOptions
+type DiffOptions struct {
+	Values ValueMode
+}
+
+// EntropyClass roughly classifies how random a value is
+type EntropyClass string
+
+const (
+	EntropyEmpty  EntropyClass = "empty"
+	EntropyLow    EntropyClass = "low"
+	EntropyMedium EntropyClass = "medium"
+	EntropyHigh   EntropyClass = "high"
+)
+
+// Entropy class thresholds, in bits of Shannon entropy over the whole value
+const (
+	entropyMediumBits = 40
+	entropyHighBits   = 80
+)
+
+// maskMinLength is the shortest value whose first and last two characters are shown
+const maskMinLength = 8
+
+// KeyChange describes a single key of a changed secret. Secrets that are not
+// maps are described as the single key "<value>".
+type KeyChange struct {
+	Key         string     `json:"key"`
+	ChangeType  ChangeType `json:"change_type"`
+	CurrentType string     `json:"current_type,omitempty"`
+	DesiredType string     `json:"desired_type,omitempty"`
+	TypeChanged bool       `json:"type_changed"`
+
+	// Set when values are masked or shown
+	Current *ValueInfo `json:"current,omitempty"`
+	Desired *ValueInfo `json:"desired,omitempty"`
+}
+
+// ValueInfo describes a value without revealing it, unless values are shown
+type ValueInfo struct {
+	Masked  string       `json:"masked"`
+	Length  int          `json:"length"` // Characters, or items of objects and arrays
+	Entropy EntropyClass `json:"entropy,omitempty"`
+	Value   interface{}  `json:"value,omitempty"` // Only with ValuesShown
+}
+
+// keyChanges describes the keys of a changed secret. current or desired is
+// nil for added and removed secrets.
+func keyChanges(current, desired interface{}, mode ValueMode) []KeyChange {
+	currentMap, okCurrent := current.(map[string]interface{})
+	desiredMap, okDesired := desired.(map[string]interface{})
+	if (current != nil && !okCurrent) || (desired != nil && !okDesired) {
+		currentMap, desiredMap = wrapValue(current), wrapValue(desired)
+	}
+
+	keys := make([]string, 0, len(currentMap)+len(desiredMap))
+	for k := range desiredMap {
+		keys = append(keys, k)
+	}
+	for k := range currentMap {
+		if _, ok := desiredMap[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var changes []KeyChange
+	for _, k := range keys {
+		cv, inCurrent := currentMap[k]
+		dv, inDesired := desiredMap[k]
+		kc := KeyChange{Key: k}
+		switch {
+		case !inCurrent:
+			kc.ChangeType = ChangeTypeAdded
+		case !inDesired:
+			kc.ChangeType = ChangeTypeRemoved
+		case utils.DeepEqual(cv, dv):
+			continue
+		default:
+			kc.ChangeType = ChangeTypeModified
+		}
+		if inCurrent {
+			kc.CurrentType = valueType(cv)
+			kc.Current = describeValue(cv, mode)
+		}
+		if inDesired {
+			kc.DesiredType = valueType(dv)
+			kc.Desired = describeValue(dv, mode)
+		}
+		kc.TypeChanged = inCurrent && inDesired && kc.CurrentType != kc.DesiredType
+		changes = append(changes, kc)
+	}
+	return changes
+}
+
+// wrapValue treats a non-map secret as a map with the single key "<value>"
+func wrapValue(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	return map[string]interface{}{"<value>": v}
+}
+
+// valueType returns the JSON type name of a decoded value
+func valueType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case float32, float64, int, int32, int64, uint, uint32, uint64, json.Number:
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// describeValue describes a value for the given mode, or returns nil if values are hidden
+func describeValue(v interface{}, mode ValueMode) *ValueInfo {
+	if mode != ValuesMasked && mode != ValuesShown {
+		return nil
+	}
+
+	var info *ValueInfo
+	switch val := v.(type) {
+	case map[string]interface{}:
+		// Only the size of structured values is described
+		info = &ValueInfo{Masked: "{…}", Length: len(val)}
+	case []interface{}:
+		info = &ValueInfo{Masked: "[…]", Length: len(val)}
+	default:
+		text, ok := val.(string)
+		if !ok {
+			text = fmt.Sprint(val)
+		}
+		info = &ValueInfo{
+			Masked:  maskValue(text),
+			Length:  utf8.RuneCountInString(text),
+			Entropy: entropyClass(text),
+		}
+	}
+	if mode == ValuesShown {
+		info.Value = v
+	}
+	return info
+}
+
+// maskValue keeps the first and last two characters of values long enough
+// that this reveals little, and masks shorter values entirely
+func maskValue(s string) string {
+	runes := []rune(s)
+	if len(runes) < maskMinLength {
+		return "****"
+	}
+	return string(runes[:2]) + "****" + string(runes[len(runes)-2:])
+}
+
+// entropyClass classifies a value by its Shannon entropy over all characters
+func entropyClass(s string) EntropyClass {
+	n := utf8.RuneCountInString(s)
+	if n == 0 {
+		return EntropyEmpty
+	}
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var perChar float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		perChar -= p * math.Log2(p)
+	}
+	switch bits := perChar * float64(n); {
+	case bits >= entropyHighBits:
+		return EntropyHigh
+	case bits >= entropyMediumBits:
+		return EntropyMedium
+	default:
+		return EntropyLow
+	}
+}
+
+// String formats the description for diff output, never including the value
+func (v *ValueInfo) String() string {
+	return v.format(false)
+}
+
+// format formats the description, or the value itself if reveal is set and it is known
+func (v *ValueInfo) format(reveal bool) string {
+	if reveal && v.Value != nil {
+		data, err := json.Marshal(v.Value)
+		if err == nil {
+			return string(data)
+		}
+	}
+	if v.Entropy == "" {
+		return fmt.Sprintf("%s (%d items)", v.Masked, v.Length)
+	}
+	return fmt.Sprintf("%s (%d chars, %s entropy)", v.Masked, v.Length, v.Entropy)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ssoadmin"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/jbcom/secretsync/pkg/driver"
+	"github.com/jbcom/secretsync/pkg/observability"
 	log "github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -154,7 +155,7 @@ func (c *IdentityCenterClient) Init(ctx context.Context) error {
 	}
 
 	// Load AWS config
-	awscfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(c.Region))
+	awscfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(c.Region), config.WithAPIOptions(observability.AWSAPIOptions))
 	if err != nil {
 		return fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -169,6 +169,16 @@ Help:      "Total number of pipeline errors",
 []string{"phase", "error_type"},
 )
 
+// Drift metrics
+DriftSecrets = prometheus.NewGaugeVec(
+prometheus.GaugeOpts{
+Namespace: namespace,
+Name:      "drift_secrets",
+Help:      "Secrets that drifted from the merge store bundle at the last drift check",
+},
+[]string{"target", "kind"},
+)
+
 // S3 merge store metrics
 S3OperationDuration = prometheus.NewHistogramVec(
 prometheus.HistogramOpts{
@@ -218,6 +228,9 @@ Registry.MustRegister(PipelineTargetsProcessed)
 Registry.MustRegister(PipelineParallelWorkers)
 Registry.MustRegister(PipelineErrors)
 
+// Drift metrics
+Registry.MustRegister(DriftSecrets)
+
 // S3 metrics
 Registry.MustRegister(S3OperationDuration)
 Registry.MustRegister(S3ObjectSize)
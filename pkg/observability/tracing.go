@@ -0,0 +1,124 @@
+package observability
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	reqctx "github.com/jbcom/secretsync/pkg/context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the instrumentation scope of secretsync spans
+const TracerName = "github.com/jbcom/secretsync"
+
+// AttrRequestID is the span attribute holding the pipeline request ID
+const AttrRequestID = attribute.Key("secretsync.request_id")
+
+// TracingConfig configures OTLP trace export
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP endpoint URL (e.g. http://otel-collector:4318).
+	// Empty uses the OTEL_EXPORTER_OTLP_* environment variables.
+	Endpoint string
+	// SampleRatio is the fraction of new traces sampled (default 1)
+	SampleRatio float64
+	// ServiceName is the service.name resource attribute (default secretsync)
+	ServiceName string
+	// ServiceVersion is the service.version resource attribute
+	ServiceVersion string
+}
+
+// TracingConfiguredFromEnv reports whether an OTLP endpoint is set in the environment
+func TracingConfiguredFromEnv() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// InitTracing installs a global tracer provider that exports spans over
+// OTLP/HTTP. The returned function flushes and stops the exporter.
+// Without InitTracing every span is a no-op.
+func InitTracing(ctx context.Context, cfg TracingConfig) (func(context.Context) error, error) {
+	var opts []otlptracehttp.Option
+	if cfg.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "secretsync"
+	}
+	attrs := []attribute.KeyValue{attribute.String("service.name", serviceName)}
+	if cfg.ServiceVersion != "" {
+		attrs = append(attrs, attribute.String("service.version", cfg.ServiceVersion))
+	}
+	resource, err := sdkresource.Merge(sdkresource.Default(), sdkresource.NewSchemaless(attrs...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// StartSpan starts a span as a child of the span in ctx. The request ID of
+// ctx, when present, is added as the secretsync.request_id attribute.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if id := reqctx.GetRequestID(ctx); id != "" {
+		attrs = append(attrs, AttrRequestID.String(id))
+	}
+	return otel.Tracer(TracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan records err, if any, as the span's error status and ends it
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// AWSAPIOptions adds a span for every AWS API call made by a client.
+// Use with config.WithAPIOptions when loading the AWS configuration.
+var AWSAPIOptions = []func(*middleware.Stack) error{addAWSTracing}
+
+// addAWSTracing wraps each AWS operation, including its retries, in a span
+// named aws.<service>.<operation>, e.g. aws.SecretsManager.ListSecrets
+func addAWSTracing(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("SecretSyncTracing",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			service, operation := awsmiddleware.GetServiceID(ctx), awsmiddleware.GetOperationName(ctx)
+			ctx, span := StartSpan(ctx, "aws."+strings.ReplaceAll(service, " ", "")+"."+operation,
+				attribute.String("rpc.system", "aws-api"),
+				attribute.String("rpc.service", service),
+				attribute.String("rpc.method", operation),
+				attribute.String("cloud.region", awsmiddleware.GetRegion(ctx)),
+			)
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			EndSpan(span, err)
+			return out, metadata, err
+		}), middleware.After)
+}
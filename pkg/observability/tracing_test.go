@@ -0,0 +1,79 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	reqctx "github.com/jbcom/secretsync/pkg/context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a global tracer provider that records ended spans
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestStartSpan(t *testing.T) {
+	recorder := recordSpans(t)
+
+	rc := reqctx.NewRequestContext()
+	ctx := reqctx.WithRequestContext(context.Background(), rc)
+
+	ctx, span := StartSpan(ctx, "parent", attribute.String("secretsync.target", "Staging"))
+	_, childSpan := StartSpan(ctx, "child")
+	EndSpan(childSpan, errors.New("access denied"))
+	EndSpan(span, nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	child, parent := spans[0], spans[1]
+
+	assert.Equal(t, "child", child.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), child.Parent().SpanID())
+	assert.Equal(t, codes.Error, child.Status().Code)
+	assert.Contains(t, child.Attributes(), AttrRequestID.String(rc.RequestID))
+
+	assert.Equal(t, codes.Unset, parent.Status().Code)
+	assert.Contains(t, parent.Attributes(), attribute.String("secretsync.target", "Staging"))
+}
+
+func TestAWSAPIOptions(t *testing.T) {
+	recorder := recordSpans(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{"SecretList": []}`))
+	}))
+	defer server.Close()
+
+	client := secretsmanager.New(secretsmanager.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		APIOptions:   AWSAPIOptions,
+	})
+	_, err := client.ListSecrets(context.Background(), &secretsmanager.ListSecretsInput{})
+	require.NoError(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "aws.SecretsManager.ListSecrets", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("rpc.method", "ListSecrets"))
+	assert.Contains(t, spans[0].Attributes(), attribute.String("cloud.region", "us-east-1"))
+}
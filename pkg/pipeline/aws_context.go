@@ -34,6 +34,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/smithy-go"
 	"github.com/jbcom/secretsync/pkg/circuitbreaker"
+	"github.com/jbcom/secretsync/pkg/observability"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -94,7 +95,7 @@ func NewAWSExecutionContext(ctx context.Context, cfg *AWSConfig) (*AWSExecutionC
 	})
 
 	// Load base AWS config from environment (supports OIDC, instance profile, etc.)
-	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region), config.WithAPIOptions(observability.AWSAPIOptions))
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/client/aws"
+	reqctx "github.com/jbcom/secretsync/pkg/context"
+	log "github.com/sirupsen/logrus"
+)
+
+// awsSourcePath returns the deterministic source path for an AWS Secrets Manager source.
+// The path participates in bundle ID calculation, so it only depends on configuration.
+func awsSourcePath(src *AWSSource, defaultRegion string) string {
+	region := src.Region
+	if region == "" {
+		region = defaultRegion
+	}
+	sourcePath := fmt.Sprintf("aws://%s/%s", src.AccountID, region)
+	if src.Prefix != "" {
+		sourcePath = fmt.Sprintf("%s/%s", sourcePath, src.Prefix)
+	}
+	return sourcePath
+}
+
+// matchesAWSSource reports whether a secret belongs to an AWS source.
+// The secret name must equal the source prefix or continue it at a "/"
+// boundary, and the secret must carry every configured tag.
+func matchesAWSSource(src *AWSSource, secretName string, secretTags map[string]string) bool {
+	if !hasPathPrefix(secretName, src.Prefix) {
+		return false
+	}
+	for key, want := range src.Tags {
+		if got, ok := secretTags[key]; !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// hasPathPrefix reports whether name is prefix or lies under it: prefix
+// "analytics" matches "analytics" and "analytics/db" but not "analytics-legacy/db"
+func hasPathPrefix(name, prefix string) bool {
+	if prefix == "" || name == prefix {
+		return true
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return strings.HasPrefix(name, prefix)
+}
+
+// awsSourceRelPath returns the path of a secret relative to the source prefix.
+// A secret named exactly like the prefix keeps its base name.
+func awsSourceRelPath(src *AWSSource, secretName string) string {
+	relPath := strings.TrimPrefix(strings.TrimPrefix(secretName, src.Prefix), "/")
+	if relPath == "" {
+		relPath = path.Base(secretName)
+	}
+	return relPath
+}
+
+// getRoleARNForSource returns the role ARN for reading an AWS source.
+// No role is assumed when the source lives in the caller's own account.
+func (p *Pipeline) getRoleARNForSource(src *AWSSource) string {
+	if p.awsCtx != nil && p.awsCtx.CallerIdentity != nil && p.awsCtx.CallerIdentity.AccountID == src.AccountID {
+		return ""
+	}
+	return p.getRoleARNForAccount(src.AccountID)
+}
+
+// readAWSSource reads all secrets matching an AWS Secrets Manager source.
+// Returns secrets keyed by their path relative to the source prefix.
+// Secrets that are not JSON objects are skipped since they cannot be deep merged.
+func (p *Pipeline) readAWSSource(ctx context.Context, importName string, src *AWSSource) (map[string]interface{}, error) {
+	region := src.Region
+	if region == "" {
+		region = p.config.AWS.Region
+	}
+
+	l := log.WithFields(log.Fields{
+		"action":     "readAWSSource",
+		"source":     importName,
+		"accountID":  src.AccountID,
+		"region":     region,
+		"prefix":     src.Prefix,
+		"request_id": reqctx.GetRequestID(ctx),
+	})
+
+	client := &aws.AwsClient{
+		Name:    importName,
+		Region:  region,
+		RoleArn: p.getRoleARNForSource(src),
+	}
+	if err := client.CreateClient(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create AWS client for source %s: %w", importName, err)
+	}
+	defer client.Close()
+
+	secretNames, err := client.ListSecrets(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list AWS secrets for source %s: %w", importName, err)
+	}
+
+	secrets := make(map[string]interface{})
+	for _, secretName := range secretNames {
+		if !matchesAWSSource(src, secretName, client.GetSecretTags(secretName)) {
+			continue
+		}
+
+		secretData, err := client.GetSecret(ctx, secretName)
+		if err != nil {
+			l.WithError(err).WithField("secret", secretName).Warn("Failed to read secret")
+			continue
+		}
+
+		var data map[string]interface{}
+		if err := json.Unmarshal(secretData, &data); err != nil {
+			l.WithField("secret", secretName).Warn("Secret is not a JSON object, skipping")
+			continue
+		}
+		secrets[awsSourceRelPath(src, secretName)] = data
+	}
+
+	l.WithField("secretsCount", len(secrets)).Debug("Read AWS source")
+	return secrets, nil
+}
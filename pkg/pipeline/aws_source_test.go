@@ -0,0 +1,84 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAWSSourcePath(t *testing.T) {
+	tests := []struct {
+		name          string
+		src           *AWSSource
+		defaultRegion string
+		expected      string
+	}{
+		{
+			name:          "Explicit region",
+			src:           &AWSSource{AccountID: "111111111111", Region: "eu-west-1"},
+			defaultRegion: "us-east-1",
+			expected:      "aws://111111111111/eu-west-1",
+		},
+		{
+			name:          "Default region",
+			src:           &AWSSource{AccountID: "111111111111"},
+			defaultRegion: "us-east-1",
+			expected:      "aws://111111111111/us-east-1",
+		},
+		{
+			name:          "With prefix",
+			src:           &AWSSource{AccountID: "111111111111", Region: "us-west-2", Prefix: "analytics/"},
+			defaultRegion: "us-east-1",
+			expected:      "aws://111111111111/us-west-2/analytics/",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, awsSourcePath(tt.src, tt.defaultRegion))
+		})
+	}
+}
+
+func TestMatchesAWSSource(t *testing.T) {
+	src := &AWSSource{
+		Prefix: "analytics/",
+		Tags:   map[string]string{"team": "data"},
+	}
+
+	tests := []struct {
+		name       string
+		secretName string
+		tags       map[string]string
+		expected   bool
+	}{
+		{"Prefix and tags match", "analytics/db", map[string]string{"team": "data", "env": "prod"}, true},
+		{"Wrong prefix", "billing/db", map[string]string{"team": "data"}, false},
+		{"Missing tag", "analytics/db", nil, false},
+		{"Different tag value", "analytics/db", map[string]string{"team": "web"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchesAWSSource(src, tt.secretName, tt.tags))
+		})
+	}
+
+	// No prefix or tags matches everything
+	assert.True(t, matchesAWSSource(&AWSSource{}, "anything", nil))
+
+	// A prefix only matches whole path segments
+	noSlash := &AWSSource{Prefix: "analytics"}
+	assert.True(t, matchesAWSSource(noSlash, "analytics", nil))
+	assert.True(t, matchesAWSSource(noSlash, "analytics/db", nil))
+	assert.False(t, matchesAWSSource(noSlash, "analytics-legacy/db", nil))
+	assert.False(t, matchesAWSSource(noSlash, "analyticsX", nil))
+	assert.False(t, matchesAWSSource(src, "analytics-legacy/db", map[string]string{"team": "data"}))
+}
+
+func TestAWSSourceRelPath(t *testing.T) {
+	assert.Equal(t, "db/postgres", awsSourceRelPath(&AWSSource{Prefix: "analytics/"}, "analytics/db/postgres"))
+	assert.Equal(t, "db/postgres", awsSourceRelPath(&AWSSource{Prefix: "analytics"}, "analytics/db/postgres"))
+	assert.Equal(t, "api-key", awsSourceRelPath(&AWSSource{}, "api-key"))
+	assert.Equal(t, "token", awsSourceRelPath(&AWSSource{Prefix: "analytics/token"}, "analytics/token"))
+}
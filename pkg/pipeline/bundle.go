@@ -35,6 +35,14 @@ func TargetBundlePath(mount, targetName string, sources []string) string {
 	return fmt.Sprintf("%s/targets/%s/%s", mount, targetName, id)
 }
 
+// TargetProvenancePath returns the merge store path of the key-level provenance
+// for a target's bundle. It is kept outside the bundle so it is never synced.
+// Format: {mount}/provenance/{target_name}/{bundle_id}
+func TargetProvenancePath(mount, targetName string, sources []string) string {
+	id := BundleID(sources)
+	return fmt.Sprintf("%s/provenance/%s/%s", mount, targetName, id)
+}
+
 // MergeRequest represents a request to merge N sources into a bundle
 type MergeRequest struct {
 	// Sources in priority order (later sources override earlier on conflict)
@@ -3,6 +3,7 @@
 package pipeline
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"regexp"
@@ -29,19 +30,45 @@ import (
 //  1. Auto-detect Vault/AWS from environment
 //  2. Resolve sources/targets via fuzzy matching against AWS Organizations
 //  3. Configure merge store automatically
-func LoadConfig(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+//
+// Unknown keys are logged as warnings; use LoadConfigStrict to reject them.
+//
+// String values may reference secrets instead of holding them: ${env:NAME},
+// ${file:path}, ${ssm:/name} and ${vault:mount/path#key} (plus legacy ${NAME}
+// for environment variables) are resolved in every string field. Resolved
+// values are never logged.
+//
+// Configurations can be split into files: include lists files and globs whose
+// sources, targets and dynamic_targets are merged in (a name may only be
+// defined once), and overlays are applied in order on top, overriding fields
+// (nested mappings merge key by key, other values are replaced).
+func LoadConfig(path string, overlays ...string) (*Config, error) {
+	return loadConfig(path, overlays, false)
+}
+
+// LoadConfigStrict loads configuration like LoadConfig, but returns an
+// *UnknownFieldsError if any key does not match a configuration field
+func LoadConfigStrict(path string, overlays ...string) (*Config, error) {
+	return loadConfig(path, overlays, true)
+}
+
+func loadConfig(path string, overlays []string, strict bool) (*Config, error) {
+	cfg, unknown, err := readConfig(path, overlays)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil, err
 	}
-
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+	if len(unknown) > 0 {
+		if strict {
+			return nil, &UnknownFieldsError{Fields: unknown}
+		}
+		for _, f := range unknown {
+			log.WithFields(log.Fields{
+				"action": "LoadConfig",
+			}).Warn(f.String())
+		}
 	}
 
 	cfg.applyDefaults()
-	cfg.expandEnvVars()
 
 	// Auto-detect clients from environment and apply
 	cfg.AutoDetectAndConfigure()
@@ -64,25 +91,30 @@ func LoadConfig(path string) (*Config, error) {
 		cfg.Vault.Address = v.GetString("vault.address")
 	}
 
-	return &cfg, nil
+	// References are resolved last: SSM and Vault connect with the final AWS and Vault settings
+	refs := &configRefResolver{cfg: cfg}
+	if err := refs.resolveRefs(context.Background(), RefEnv, RefFile, RefSSM, RefVault); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
 }
 
-// LoadConfigWithoutAutoDetect loads config without auto-detection (for testing)
+// LoadConfigWithoutAutoDetect loads config without auto-detection (for testing).
+// Only env and file references are resolved.
 func LoadConfigWithoutAutoDetect(path string) (*Config, error) {
-	data, err := os.ReadFile(path)
+	cfg, _, err := readConfig(path, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
-	}
-
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+		return nil, err
 	}
 
 	cfg.applyDefaults()
-	cfg.expandEnvVars()
+	refs := &configRefResolver{cfg: cfg}
+	if err := refs.resolveRefs(context.Background(), RefEnv, RefFile); err != nil {
+		return nil, err
+	}
 
-	return &cfg, nil
+	return cfg, nil
 }
 
 // applyDefaults sets default values for unset fields
@@ -107,34 +139,6 @@ func (c *Config) applyDefaults() {
 	}
 }
 
-// expandEnvVars expands ${VAR} patterns in config values
-func (c *Config) expandEnvVars() {
-	envPattern := regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
-	const maxEnvValueLength = 10000
-
-	expand := func(s string) string {
-		return envPattern.ReplaceAllStringFunc(s, func(match string) string {
-			varName := match[2 : len(match)-1]
-			if val := os.Getenv(varName); val != "" {
-				if len(val) > maxEnvValueLength {
-					log.WithField("variable", varName).Warn("Environment variable value exceeds maximum length, keeping placeholder")
-					return match
-				}
-				return val
-			}
-			return match
-		})
-	}
-
-	if c.Vault.Auth.AppRole != nil {
-		c.Vault.Auth.AppRole.RoleID = expand(c.Vault.Auth.AppRole.RoleID)
-		c.Vault.Auth.AppRole.SecretID = expand(c.Vault.Auth.AppRole.SecretID)
-	}
-	if c.Vault.Auth.Token != nil {
-		c.Vault.Auth.Token.Token = expand(c.Vault.Auth.Token.Token)
-	}
-}
-
 // Validate validates the configuration with minimal requirements.
 // The system auto-detects and resolves most configuration via:
 // - AWS Organizations discovery for account resolution
@@ -157,10 +161,48 @@ func (c *Config) Validate() error {
 		if target.AccountID != "" && !isValidAWSAccountID(target.AccountID) {
 			return fmt.Errorf("target %q: invalid account_id format %q (must be 12 digits)", name, target.AccountID)
 		}
+		if err := validateSecretNaming(target.SecretPrefix, target.SecretNameTemplate); err != nil {
+			return fmt.Errorf("target %q: %w", name, err)
+		}
+		if _, err := newSecretTransformer(target); err != nil {
+			return fmt.Errorf("target %q: %w", name, err)
+		}
+		if _, err := newBundleMerger(target); err != nil {
+			return fmt.Errorf("target %q: %w", name, err)
+		}
+		for _, imp := range target.Imports {
+			if _, err := ParseImport(imp); err != nil {
+				return fmt.Errorf("target %q: %w", name, err)
+			}
+		}
 		// Note: imports are NOT validated here - they can be resolved dynamically
 		// via fuzzy matching against AWS Organizations or Vault mounts
 	}
 
+	if err := c.History.validate(c.MergeStore); err != nil {
+		return err
+	}
+
+	if err := c.Serve.validate(c.Targets); err != nil {
+		return err
+	}
+
+	if err := c.Notifications.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Policies.validate(); err != nil {
+		return err
+	}
+
+	if _, err := compileSecretSchemas(c); err != nil {
+		return err
+	}
+
+	if _, err := compileProtectedKeys(c.Pipeline.Merge); err != nil {
+		return fmt.Errorf("pipeline.merge: %w", err)
+	}
+
 	// Validate inheritance if targets reference each other
 	if err := c.ValidateTargetInheritance(); err != nil {
 		return err
@@ -175,6 +217,9 @@ func (c *Config) Validate() error {
 				return fmt.Errorf("dynamic_target %q: invalid name_matching.strategy %q (must be exact, fuzzy, or loose)", name, nm.Strategy)
 			}
 		}
+		if err := validateSecretNaming(dt.SecretPrefix, dt.SecretNameTemplate); err != nil {
+			return fmt.Errorf("dynamic_target %q: %w", name, err)
+		}
 		// Validate account_name_patterns regex if present
 		for i, pattern := range dt.AccountNamePatterns {
 			if pattern.Pattern != "" {
@@ -211,7 +256,8 @@ func (c *Config) AutoConfigure() {
 	// Auto-create source entries for any imports that don't exist
 	// These will be resolved later via fuzzy matching
 	for _, target := range c.Targets {
-		for _, imp := range target.Imports {
+		for _, spec := range target.Imports {
+			imp := importSourceName(spec)
 			// Skip if it's another target (inheritance)
 			if _, isTarget := c.Targets[imp]; isTarget {
 				continue
@@ -226,7 +272,8 @@ func (c *Config) AutoConfigure() {
 
 	// Same for dynamic targets
 	for _, dt := range c.DynamicTargets {
-		for _, imp := range dt.Imports {
+		for _, spec := range dt.Imports {
+			imp := importSourceName(spec)
 			if _, isTarget := c.Targets[imp]; isTarget {
 				continue
 			}
@@ -237,9 +284,14 @@ func (c *Config) AutoConfigure() {
 	}
 }
 
-// WriteConfig writes the configuration to a file
+// WriteConfig writes the configuration to a file. Values resolved from
+// references are written as the references.
 func (c *Config) WriteConfig(path string) error {
-	data, err := yaml.Marshal(c)
+	raw, err := c.withRawRefs(c)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(raw)
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
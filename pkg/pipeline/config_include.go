@@ -0,0 +1,313 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includableSections are the top-level keys an included file may set, with
+// the name of their entries in messages. Entries are merged by name.
+var includableSections = []struct {
+	key, kind string
+}{
+	{"sources", "source"},
+	{"targets", "target"},
+	{"dynamic_targets", "dynamic target"},
+}
+
+// ConfigLocation is where a source or target is defined
+type ConfigLocation struct {
+	File string
+	Line int
+	// Overlays are the overlay files that changed the definition, in order
+	Overlays []string
+}
+
+func (l ConfigLocation) String() string {
+	if l.File == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", l.File, l.Line)
+}
+
+// ConfigOrigins records the files a configuration was loaded from and
+// where each source and target is defined
+type ConfigOrigins struct {
+	Files          []string // The config file, its includes and overlays, in load order
+	Sources        map[string]ConfigLocation
+	Targets        map[string]ConfigLocation
+	DynamicTargets map[string]ConfigLocation
+}
+
+// section returns the locations of a top-level section's entries
+func (o *ConfigOrigins) section(key string) map[string]ConfigLocation {
+	var m *map[string]ConfigLocation
+	switch key {
+	case "sources":
+		m = &o.Sources
+	case "targets":
+		m = &o.Targets
+	default:
+		m = &o.DynamicTargets
+	}
+	if *m == nil {
+		*m = make(map[string]ConfigLocation)
+	}
+	return *m
+}
+
+// configLoader reads a configuration file with its includes and overlays
+// into a single YAML mapping
+type configLoader struct {
+	root       *yaml.Node
+	origins    ConfigOrigins
+	loaded     map[string]bool // Absolute paths of the files loaded so far
+	unknown    []UnknownField
+	duplicates []string
+}
+
+// readConfig reads a configuration file, merging in its includes and then
+// applying the overlays in order. Returns the keys that match no field.
+func readConfig(path string, overlays []string) (*Config, []UnknownField, error) {
+	l := &configLoader{loaded: make(map[string]bool)}
+	if err := l.load(path); err != nil {
+		return nil, nil, err
+	}
+	for _, overlay := range overlays {
+		if err := l.overlay(overlay); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(l.duplicates) > 0 {
+		return nil, nil, fmt.Errorf("duplicate names in config files:\n  %s", strings.Join(l.duplicates, "\n  "))
+	}
+
+	var cfg Config
+	if err := l.root.Decode(&cfg); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	cfg.Origins = l.origins
+	return &cfg, l.unknown, nil
+}
+
+// readConfigFile parses a configuration file to its top-level mapping
+func readConfigFile(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	anchorFileRefs(&doc, filepath.Dir(path))
+	// Decoding each file on its own reports type errors with the right file
+	var probe Config
+	if err := doc.Decode(&probe); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return doc.Content[0], nil
+}
+
+// load reads a file and the files it includes. The first file loaded is the
+// root configuration. Each file is loaded once, so include cycles and
+// overlapping globs are harmless.
+func (l *configLoader) load(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve config file %s: %w", path, err)
+	}
+	if l.loaded[abs] {
+		return nil
+	}
+	l.loaded[abs] = true
+
+	node, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	l.origins.Files = append(l.origins.Files, path)
+	l.check(node, path)
+
+	if l.root == nil {
+		l.root = node
+	} else {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if key.Value != "include" && !isIncludableSection(key.Value) {
+				return fmt.Errorf("%s:%d: included files may only set include, sources, targets and dynamic_targets, not %s (use an overlay)",
+					path, key.Line, key.Value)
+			}
+		}
+	}
+	for _, s := range includableSections {
+		l.addEntries(s.key, s.kind, mappingValue(node, s.key), path, node != l.root)
+	}
+
+	includeNode := mappingValue(node, "include")
+	if includeNode == nil {
+		return nil
+	}
+	var patterns []string
+	if err := includeNode.Decode(&patterns); err != nil {
+		return fmt.Errorf("%s:%d: include must be a list of files: %w", path, includeNode.Line, err)
+	}
+	for _, pattern := range patterns {
+		files, err := resolveInclude(path, pattern)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, includeNode.Line, err)
+		}
+		for _, file := range files {
+			if err := l.load(file); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// overlay applies an overlay file: nested mappings are merged key by key,
+// every other value replaces the value of the same key
+func (l *configLoader) overlay(path string) error {
+	node, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	l.origins.Files = append(l.origins.Files, path)
+	l.check(node, path)
+
+	if include := mappingValue(node, "include"); include != nil {
+		return fmt.Errorf("%s:%d: overlays cannot include files", path, include.Line)
+	}
+	for _, s := range includableSections {
+		entries := mappingValue(node, s.key)
+		if entries == nil || entries.Kind != yaml.MappingNode {
+			continue
+		}
+		locations := l.origins.section(s.key)
+		for i := 0; i+1 < len(entries.Content); i += 2 {
+			key := entries.Content[i]
+			if loc, ok := locations[key.Value]; ok {
+				loc.Overlays = append(loc.Overlays, path)
+				locations[key.Value] = loc
+			} else if key.Value != "<<" {
+				locations[key.Value] = ConfigLocation{File: path, Line: key.Line}
+			}
+		}
+	}
+	mergeConfigNodes(l.root, node)
+	return nil
+}
+
+// addEntries records where the entries of a section are defined and, for
+// included files, appends them to the root configuration. Names defined more
+// than once are recorded as duplicates.
+func (l *configLoader) addEntries(key, kind string, entries *yaml.Node, file string, merge bool) {
+	if entries == nil || entries.Kind != yaml.MappingNode {
+		return
+	}
+	locations := l.origins.section(key)
+	var dst *yaml.Node
+	if merge {
+		dst = mappingValue(l.root, key)
+		if dst == nil || dst.Kind != yaml.MappingNode {
+			dst = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingValue(l.root, key, dst)
+		}
+	}
+	for i := 0; i+1 < len(entries.Content); i += 2 {
+		name := entries.Content[i]
+		if name.Value != "<<" {
+			loc := ConfigLocation{File: file, Line: name.Line}
+			if prev, ok := locations[name.Value]; ok {
+				l.duplicates = append(l.duplicates, fmt.Sprintf("%s %q is defined in %s and %s", kind, name.Value, prev, loc))
+				continue
+			}
+			locations[name.Value] = loc
+		}
+		if merge {
+			dst.Content = append(dst.Content, name, entries.Content[i+1])
+		}
+	}
+}
+
+// check records the keys of a file that match no configuration field
+func (l *configLoader) check(node *yaml.Node, file string) {
+	var unknown []UnknownField
+	checkConfigNode(node, reflect.TypeOf(Config{}), "", &unknown)
+	for _, f := range unknown {
+		f.File = file
+		l.unknown = append(l.unknown, f)
+	}
+}
+
+func isIncludableSection(key string) bool {
+	for _, s := range includableSections {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveInclude returns the files of an include entry, relative to the
+// including file. Globs match in lexical order and may match nothing.
+func resolveInclude(from, pattern string) ([]string, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(from), pattern)
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}, nil
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include pattern %q: %w", pattern, err)
+	}
+	return files, nil
+}
+
+// mappingValue returns the value of a key in a mapping node, or nil
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets the value of a key in a mapping node
+func setMappingValue(m *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content[i+1] = value
+			return
+		}
+	}
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// mergeConfigNodes merges an overlay mapping into a base mapping
+func mergeConfigNodes(dst, src *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		existing := mappingValue(dst, key.Value)
+		switch {
+		case existing == nil:
+			dst.Content = append(dst.Content, key, value)
+		case existing.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode:
+			mergeConfigNodes(existing, value)
+		default:
+			setMappingValue(dst, key.Value, value)
+		}
+	}
+}
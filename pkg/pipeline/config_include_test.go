@@ -0,0 +1,191 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfigFiles writes files relative to a temp dir and returns the dir
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestLoadConfig_Include(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `include:
+  - sources.yaml
+  - targets/*.yaml
+merge_store:
+  vault: {mount: merged}
+targets:
+  Base:
+    imports: [analytics]
+`,
+		"sources.yaml": `sources:
+  analytics:
+    vault: {mount: analytics}
+`,
+		"targets/prod.yaml": `include: [../teams/*.yaml]
+targets:
+  Production:
+    account_id: "222222222222"
+    imports: [Base]
+`,
+		"targets/staging.yaml": `targets:
+  Staging: [Base]
+`,
+		"teams/payments.yaml": `sources:
+  payments:
+    vault: {mount: payments}
+dynamic_targets:
+  sandboxes:
+    imports: [payments]
+`,
+		// Overlapping globs and cycles load each file once
+		"teams/cycle.yaml": `include: [../config.yaml, ../targets/*.yaml]
+`,
+	})
+
+	cfg, err := LoadConfigWithoutAutoDetect(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, []string{"Base"}, cfg.Targets["Staging"].Imports)
+	assert.Equal(t, "222222222222", cfg.Targets["Production"].AccountID)
+	assert.Contains(t, cfg.Sources, "payments")
+	assert.Contains(t, cfg.DynamicTargets, "sandboxes")
+
+	rel := func(name string) string { return filepath.Join(dir, name) }
+	assert.Equal(t, []string{
+		rel("config.yaml"), rel("sources.yaml"), rel("targets/prod.yaml"),
+		rel("teams/cycle.yaml"), rel("targets/staging.yaml"), rel("teams/payments.yaml"),
+	}, cfg.Origins.Files)
+	assert.Equal(t, ConfigLocation{File: rel("config.yaml"), Line: 7}, cfg.Origins.Targets["Base"])
+	assert.Equal(t, ConfigLocation{File: rel("targets/prod.yaml"), Line: 3}, cfg.Origins.Targets["Production"])
+	assert.Equal(t, ConfigLocation{File: rel("targets/staging.yaml"), Line: 2}, cfg.Origins.Targets["Staging"])
+	assert.Equal(t, rel("teams/payments.yaml")+":2", cfg.Origins.Sources["payments"].String())
+}
+
+func TestLoadConfig_IncludeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr []string
+	}{
+		{
+			name: "duplicate names",
+			files: map[string]string{
+				"config.yaml": "include: [a.yaml, b.yaml]\ntargets:\n  Prod: [x]\n",
+				"a.yaml":      "targets:\n  Prod: [y]\nsources:\n  x: {}\n",
+				"b.yaml":      "sources:\n\n  x: {}\n",
+			},
+			wantErr: []string{
+				`target "Prod" is defined in $DIR/config.yaml:3 and $DIR/a.yaml:2`,
+				`source "x" is defined in $DIR/a.yaml:4 and $DIR/b.yaml:3`,
+			},
+		},
+		{
+			name: "settings in included file",
+			files: map[string]string{
+				"config.yaml": "include: [a.yaml]\n",
+				"a.yaml":      "targets: {}\npipeline:\n  dry_run: true\n",
+			},
+			wantErr: []string{"$DIR/a.yaml:2: included files may only set", "not pipeline (use an overlay)"},
+		},
+		{
+			name: "missing file",
+			files: map[string]string{
+				"config.yaml": "include: [missing.yaml]\n",
+			},
+			wantErr: []string{"failed to read config file"},
+		},
+		{
+			name: "type error",
+			files: map[string]string{
+				"config.yaml": "include: [a.yaml]\n",
+				"a.yaml":      "targets:\n  Prod:\n    imports: {a: b}\n",
+			},
+			wantErr: []string{"failed to parse config $DIR/a.yaml", "line 3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigFiles(t, tt.files)
+			_, err := LoadConfigWithoutAutoDetect(filepath.Join(dir, "config.yaml"))
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), strings.ReplaceAll(want, "$DIR", dir))
+			}
+		})
+	}
+}
+
+func TestLoadConfig_Overlays(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `sources:
+  analytics:
+    vault: {mount: analytics}
+targets:
+  Production:
+    account_id: "222222222222"
+    imports: [analytics]
+    region: us-east-1
+pipeline:
+  sync:
+    parallel: 4
+    delete_orphans: true
+`,
+		"prod.yaml": `targets:
+  Production:
+    account_id: "333333333333"
+    imports: [analytics, shared]
+  Extra: [Production]
+pipeline:
+  sync:
+    parallel: 8
+`,
+		"region.yaml": `targets:
+  Production:
+    region: eu-west-1
+    regoin: typo
+`,
+	})
+	path := filepath.Join(dir, "config.yaml")
+	prod, region := filepath.Join(dir, "prod.yaml"), filepath.Join(dir, "region.yaml")
+
+	cfg, err := LoadConfig(path, prod, region)
+	require.NoError(t, err)
+
+	target := cfg.Targets["Production"]
+	assert.Equal(t, "333333333333", target.AccountID)
+	assert.Equal(t, []string{"analytics", "shared"}, target.Imports, "lists are replaced")
+	assert.Equal(t, "eu-west-1", target.Region)
+	assert.Equal(t, 8, cfg.Pipeline.Sync.Parallel)
+	assert.True(t, cfg.Pipeline.Sync.DeleteOrphans, "nested mappings merge")
+
+	assert.Equal(t, ConfigLocation{File: path, Line: 5, Overlays: []string{prod, region}}, cfg.Origins.Targets["Production"])
+	assert.Equal(t, ConfigLocation{File: prod, Line: 5}, cfg.Origins.Targets["Extra"])
+	assert.Equal(t, []string{path, prod, region}, cfg.Origins.Files)
+
+	_, err = LoadConfigStrict(path, prod, region)
+	var unknownErr *UnknownFieldsError
+	require.ErrorAs(t, err, &unknownErr)
+	require.Len(t, unknownErr.Fields, 1)
+	assert.Equal(t, region+":4: unknown field targets.Production.regoin (did you mean region?)", unknownErr.Fields[0].String())
+
+	// The base config is unchanged without overlays
+	cfg, err = LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "222222222222", cfg.Targets["Production"].AccountID)
+}
@@ -0,0 +1,323 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/jbcom/secretsync/pkg/client/vault"
+	"github.com/jbcom/secretsync/pkg/observability"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Reference schemes in configuration values
+const (
+	RefEnv   = "env"   // ${env:NAME} environment variable
+	RefFile  = "file"  // ${file:path} file contents, relative to the config file that contains it
+	RefVault = "vault" // ${vault:mount/path#key} key of a Vault KV2 secret
+	RefSSM   = "ssm"   // ${ssm:/name} SSM parameter, decrypted
+)
+
+// configRefPattern matches ${scheme:ref} references and legacy ${VAR} environment variables
+var configRefPattern = regexp.MustCompile(`\$\{(?:(env|file|vault|ssm):([^}]+)|([A-Za-z_][A-Za-z0-9_]*))\}`)
+
+// maxEnvValueLength limits legacy ${VAR} values
+const maxEnvValueLength = 10000
+
+// kvSecretReader reads Vault KV2 secrets
+type kvSecretReader interface {
+	GetKVSecretOnce(ctx context.Context, path string) (map[string]interface{}, error)
+}
+
+// ssmParameterReader reads SSM parameters
+type ssmParameterReader interface {
+	GetSSMParameter(ctx context.Context, name string) (string, error)
+}
+
+// configRef is a configuration value that was resolved from references
+type configRef struct {
+	raw      string // The value as written, with its references
+	resolved string
+}
+
+// configRefResolver resolves references in configuration values. The Vault
+// and SSM readers are created on first use when not set, so configurations
+// without such references never connect to Vault or AWS.
+type configRefResolver struct {
+	cfg   *Config
+	vault kvSecretReader
+	ssm   ssmParameterReader
+}
+
+// resolveRefs replaces the references of the given schemes in every string
+// field of the configuration; references of other schemes are left as they
+// are. Each value is expanded once, so resolved values are never expanded
+// again. The aws and vault sections are resolved first because the SSM and
+// Vault clients connect with them. Resolved values are never logged, and the
+// values as written are kept for writing configurations (see withRawRefs).
+//
+// Legacy ${VAR} references are resolved with env and keep their placeholder
+// when the variable is unset; every other reference must resolve.
+func (r *configRefResolver) resolveRefs(ctx context.Context, schemes ...string) error {
+	enabled := make(map[string]bool)
+	for _, scheme := range schemes {
+		enabled[scheme] = true
+	}
+	expand := func(field, s string) (string, error) {
+		if !strings.Contains(s, "${") {
+			return s, nil
+		}
+		out, err := r.expand(ctx, enabled, field, s)
+		if err != nil || out == s {
+			return out, err
+		}
+		if r.cfg.refs == nil {
+			r.cfg.refs = make(map[string]configRef)
+		}
+		raw := s
+		if prev, ok := r.cfg.refs[field]; ok && prev.resolved == s {
+			raw = prev.raw // Resolved in two steps (env and file first)
+		}
+		r.cfg.refs[field] = configRef{raw: raw, resolved: out}
+		return out, nil
+	}
+
+	if err := walkConfigStrings(reflect.ValueOf(&r.cfg.AWS).Elem(), "aws", expand); err != nil {
+		return err
+	}
+	if err := walkConfigStrings(reflect.ValueOf(&r.cfg.Vault).Elem(), "vault", expand); err != nil {
+		return err
+	}
+	v := reflect.ValueOf(r.cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name, ok := yamlFieldName(v.Type().Field(i))
+		if !ok || name == "aws" || name == "vault" {
+			continue
+		}
+		if err := walkConfigStrings(v.Field(i), name, expand); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expand replaces the references of the enabled schemes in a value
+func (r *configRefResolver) expand(ctx context.Context, enabled map[string]bool, field, s string) (string, error) {
+	var firstErr error
+	out := configRefPattern.ReplaceAllStringFunc(s, func(match string) string {
+		m := configRefPattern.FindStringSubmatch(match)
+		scheme, ref := m[1], m[2]
+		if m[3] != "" {
+			if !enabled[RefEnv] {
+				return match
+			}
+			return expandLegacyEnv(m[3], match)
+		}
+		if !enabled[scheme] || firstErr != nil {
+			return match
+		}
+
+		value, err := r.resolve(ctx, field, scheme, ref)
+		if err != nil {
+			firstErr = fmt.Errorf("%s: failed to resolve ${%s:%s}: %w", field, scheme, ref, err)
+			return match
+		}
+		log.WithFields(log.Fields{
+			"action": "resolveConfigRefs",
+			"field":  field,
+			"ref":    scheme,
+		}).Debug("Resolved config reference")
+		return value
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return out, nil
+}
+
+// resolve returns the value of a single reference
+func (r *configRefResolver) resolve(ctx context.Context, field, scheme, ref string) (string, error) {
+	switch scheme {
+	case RefEnv:
+		value, ok := os.LookupEnv(ref)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", ref)
+		}
+		return value, nil
+
+	case RefFile:
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case RefSSM:
+		if field == "aws.region" {
+			return "", fmt.Errorf("the SSM client needs aws.region")
+		}
+		if r.ssm == nil {
+			awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(r.cfg.AWS.Region), config.WithAPIOptions(observability.AWSAPIOptions))
+			if err != nil {
+				return "", fmt.Errorf("failed to load AWS config: %w", err)
+			}
+			r.ssm = &AWSExecutionContext{Config: &r.cfg.AWS, BaseConfig: awsCfg}
+		}
+		return r.ssm.GetSSMParameter(ctx, ref)
+
+	case RefVault:
+		if strings.HasPrefix(field, "vault.") {
+			return "", fmt.Errorf("the Vault client needs the vault section")
+		}
+		path, key, ok := strings.Cut(ref, "#")
+		if !ok || path == "" || key == "" {
+			return "", fmt.Errorf("vault references must be mount/path#key")
+		}
+		if r.vault == nil {
+			client := &vault.VaultClient{
+				Address:   r.cfg.Vault.Address,
+				Namespace: r.cfg.Vault.Namespace,
+			}
+			if err := client.Init(ctx); err != nil {
+				return "", fmt.Errorf("failed to init vault client: %w", err)
+			}
+			r.vault = client
+		}
+		data, err := r.vault.GetKVSecretOnce(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		value, ok := data[key]
+		if !ok {
+			return "", fmt.Errorf("key %s not found", key)
+		}
+		s, ok := value.(string)
+		if !ok {
+			return "", fmt.Errorf("key %s is not a string", key)
+		}
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown reference scheme %s", scheme)
+}
+
+// expandLegacyEnv resolves a ${VAR} reference, keeping the placeholder if
+// the variable is unset, empty or too long
+func expandLegacyEnv(name, match string) string {
+	val := os.Getenv(name)
+	if val == "" {
+		return match
+	}
+	if len(val) > maxEnvValueLength {
+		log.WithField("variable", name).Warn("Environment variable value exceeds maximum length, keeping placeholder")
+		return match
+	}
+	return val
+}
+
+// walkConfigStrings calls fn for every string in a configuration value and
+// sets it to the result. Fields are named by their dotted YAML path; untyped
+// values (inline schemas) are not walked.
+func walkConfigStrings(v reflect.Value, path string, fn func(field, s string) (string, error)) error {
+	switch v.Kind() {
+	case reflect.String:
+		s, err := fn(path, v.String())
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return walkConfigStrings(v.Elem(), path, fn)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			name, ok := yamlFieldName(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+			fieldPath := path
+			if name != "" {
+				fieldPath = joinConfigPath(path, name)
+			}
+			if err := walkConfigStrings(v.Field(i), fieldPath, fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := walkConfigStrings(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() == reflect.Interface {
+			return nil
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			// Map values are not addressable: walk a copy and store it back
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(k))
+			if err := walkConfigStrings(elem, joinConfigPath(path, k.String()), fn); err != nil {
+				return err
+			}
+			v.SetMapIndex(k, elem)
+		}
+	}
+	return nil
+}
+
+// withRawRefs returns a copy of dst in which the fields resolved from
+// references in c are set back to the references as written, so configurations
+// derived from c can be written without the resolved values. A field is
+// restored only where dst still holds c's resolved value at the same path.
+func (c *Config) withRawRefs(dst *Config) (*Config, error) {
+	data, err := yaml.Marshal(dst)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	var out Config
+	if err := yaml.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	out.Origins = dst.Origins
+	if len(c.refs) == 0 {
+		return &out, nil
+	}
+
+	err = walkConfigStrings(reflect.ValueOf(&out).Elem(), "", func(field, s string) (string, error) {
+		if ref, ok := c.refs[field]; ok && ref.resolved == s {
+			return ref.raw, nil
+		}
+		return s, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// anchorFileRefs makes relative ${file:} references in a parsed config file
+// relative to the file's directory, like include
+func anchorFileRefs(node *yaml.Node, dir string) {
+	if node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "${file:") {
+		node.Value = configRefPattern.ReplaceAllStringFunc(node.Value, func(match string) string {
+			m := configRefPattern.FindStringSubmatch(match)
+			if m[1] != RefFile || filepath.IsAbs(m[2]) {
+				return match
+			}
+			return "${file:" + filepath.Join(dir, m[2]) + "}"
+		})
+	}
+	for _, child := range node.Content {
+		anchorFileRefs(child, dir)
+	}
+}
@@ -0,0 +1,239 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKVReader map[string]map[string]interface{}
+
+func (f fakeKVReader) GetKVSecretOnce(_ context.Context, path string) (map[string]interface{}, error) {
+	data, ok := f[path]
+	if !ok {
+		return nil, errors.New("secret not found: " + path)
+	}
+	return data, nil
+}
+
+type fakeSSMReader map[string]string
+
+func (f fakeSSMReader) GetSSMParameter(_ context.Context, name string) (string, error) {
+	value, ok := f[name]
+	if !ok {
+		return "", errors.New("parameter not found: " + name)
+	}
+	return value, nil
+}
+
+func TestResolveConfigRefs(t *testing.T) {
+	t.Setenv("SECRETSYNC_TEST_BUCKET", "history-bucket")
+	roleIDFile := filepath.Join(t.TempDir(), "role-id")
+	require.NoError(t, os.WriteFile(roleIDFile, []byte("role-from-file\n"), 0600))
+
+	slackURL := "https://hooks.slack.com/services/${vault:kv/notify#slack_path}"
+	cfg := &Config{
+		Vault: VaultConfig{
+			Address: "${ssm:/vault/address}",
+			Auth:    VaultAuthConfig{AppRole: &AppRoleAuth{RoleID: "${file:" + roleIDFile + "}", SecretID: "${SECRETSYNC_TEST_UNSET}"}},
+		},
+		AWS: AWSConfig{Organizations: OrganizationsConfig{OUs: map[string]OUConfig{
+			"prod": {Accounts: []string{"111111111111", "${ssm:/accounts/prod}"}},
+		}}},
+		Targets: map[string]Target{
+			"Prod": {AccountID: "${ssm:/accounts/prod}", Imports: []string{"analytics"}, SecretPrefix: "${ssm:/prefix}"},
+		},
+		History: HistoryConfig{S3: &HistoryS3{Bucket: "${env:SECRETSYNC_TEST_BUCKET}", KMSKeyID: "${ssm:/kms/history}"}},
+		Notifications: NotificationsConfig{Channels: []*v1alpha1.NotificationSpec{
+			{Slack: &v1alpha1.SlackNotification{URL: &slackURL}},
+		}},
+	}
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	log.SetLevel(log.DebugLevel)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetLevel(log.InfoLevel)
+	}()
+
+	r := &configRefResolver{
+		cfg: cfg,
+		ssm: fakeSSMReader{
+			"/vault/address": "https://vault.example.com",
+			"/accounts/prod": "222222222222",
+			"/kms/history":   "alias/history",
+			"/prefix":        "${env:SECRETSYNC_TEST_BUCKET}/",
+		},
+		vault: fakeKVReader{"kv/notify": {"slack_path": "T000/B000/XXXX"}},
+	}
+	require.NoError(t, r.resolveRefs(context.Background(), RefEnv, RefFile, RefSSM, RefVault))
+
+	assert.Equal(t, "https://vault.example.com", cfg.Vault.Address)
+	assert.Equal(t, "role-from-file", cfg.Vault.Auth.AppRole.RoleID)
+	assert.Equal(t, "${SECRETSYNC_TEST_UNSET}", cfg.Vault.Auth.AppRole.SecretID, "unset legacy variables keep their placeholder")
+	assert.Equal(t, []string{"111111111111", "222222222222"}, cfg.AWS.Organizations.OUs["prod"].Accounts)
+	assert.Equal(t, "222222222222", cfg.Targets["Prod"].AccountID)
+	assert.Equal(t, "${env:SECRETSYNC_TEST_BUCKET}/", cfg.Targets["Prod"].SecretPrefix, "resolved values are not expanded again")
+	assert.Equal(t, "history-bucket", cfg.History.S3.Bucket)
+	assert.Equal(t, "alias/history", cfg.History.S3.KMSKeyID)
+	assert.Equal(t, "https://hooks.slack.com/services/T000/B000/XXXX", *cfg.Notifications.Channels[0].Slack.URL)
+
+	assert.Contains(t, logs.String(), "notifications.channels[0].slack.url")
+	for _, value := range []string{"role-from-file", "222222222222", "alias/history", "T000/B000/XXXX"} {
+		assert.NotContains(t, logs.String(), value, "resolved values are never logged")
+	}
+}
+
+func TestResolveConfigRefs_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr string
+	}{
+		{
+			name:    "unset variable",
+			cfg:     Config{Vault: VaultConfig{Auth: VaultAuthConfig{Token: &TokenAuth{Token: "${env:SECRETSYNC_TEST_UNSET}"}}}},
+			wantErr: "vault.auth.token.token: failed to resolve ${env:SECRETSYNC_TEST_UNSET}: environment variable SECRETSYNC_TEST_UNSET is not set",
+		},
+		{
+			name:    "missing file",
+			cfg:     Config{MergeStore: MergeStoreConfig{S3: &MergeStoreS3{KMSKeyID: "${file:/nonexistent/kms}"}}},
+			wantErr: "merge_store.s3.kms_key_id: failed to resolve ${file:/nonexistent/kms}",
+		},
+		{
+			name:    "missing parameter",
+			cfg:     Config{Targets: map[string]Target{"Prod": {AccountID: "${ssm:/missing}"}}},
+			wantErr: "targets.Prod.account_id: failed to resolve ${ssm:/missing}: parameter not found",
+		},
+		{
+			name:    "ssm in aws.region",
+			cfg:     Config{AWS: AWSConfig{Region: "${ssm:/region}"}},
+			wantErr: "aws.region: failed to resolve ${ssm:/region}: the SSM client needs aws.region",
+		},
+		{
+			name:    "vault in vault section",
+			cfg:     Config{Vault: VaultConfig{Namespace: "${vault:kv/ns#name}"}},
+			wantErr: "vault.namespace: failed to resolve ${vault:kv/ns#name}: the Vault client needs the vault section",
+		},
+		{
+			name:    "vault without key",
+			cfg:     Config{History: HistoryConfig{Vault: &HistoryVault{Mount: "${vault:kv/notify}"}}},
+			wantErr: "vault references must be mount/path#key",
+		},
+		{
+			name:    "missing vault key",
+			cfg:     Config{History: HistoryConfig{Vault: &HistoryVault{Mount: "${vault:kv/notify#mount}"}}},
+			wantErr: "key mount not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &configRefResolver{
+				cfg:   &tt.cfg,
+				ssm:   fakeSSMReader{"/region": "us-east-1"},
+				vault: fakeKVReader{"kv/notify": {"slack_path": "secret-value"}},
+			}
+			err := r.resolveRefs(context.Background(), RefEnv, RefFile, RefSSM, RefVault)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+			assert.NotContains(t, err.Error(), "secret-value")
+		})
+	}
+}
+
+func TestLoadConfig_FileRefs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "webhook"), []byte("https://example.com/hook\n"), 0600))
+	t.Setenv("SECRETSYNC_TEST_ACCOUNT", "333333333333")
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+targets:
+  Prod:
+    account_id: ${env:SECRETSYNC_TEST_ACCOUNT}
+notifications:
+  channels:
+    - webhook:
+        url: ${file:`+filepath.Join(dir, "webhook")+`}
+`), 0644))
+
+	cfg, err := LoadConfigWithoutAutoDetect(path)
+	require.NoError(t, err)
+	assert.Equal(t, "333333333333", cfg.Targets["Prod"].AccountID)
+	assert.Equal(t, "https://example.com/hook", cfg.Notifications.Channels[0].Webhook.URL)
+}
+
+func TestLoadConfig_RelativeFileRefs(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `include: [teams/payments.yaml]
+notifications:
+  channels:
+    - webhook:
+        url: ${file:webhook}
+`,
+		"webhook":       "https://example.com/hook\n",
+		"teams/account": "444444444444\n",
+		"teams/payments.yaml": `targets:
+  Payments:
+    account_id: ${file:account}
+`,
+	})
+
+	cfg, err := LoadConfigWithoutAutoDetect(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/hook", cfg.Notifications.Channels[0].Webhook.URL)
+	assert.Equal(t, "444444444444", cfg.Targets["Payments"].AccountID, "relative to the including file's directory")
+}
+
+func TestConfig_WithRawRefs(t *testing.T) {
+	t.Setenv("SECRETSYNC_TEST_KMS", "alias/merge-store")
+	t.Setenv("SECRETSYNC_TEST_ROLE", "SecretSyncRole")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+vault:
+  address: https://vault.example.com
+aws:
+  region: us-east-1
+  execution_context:
+    custom_role_pattern: arn:aws:iam::{{.AccountID}}:role/${env:SECRETSYNC_TEST_ROLE}
+merge_store:
+  s3:
+    bucket: merged
+    kms_key_id: ${env:SECRETSYNC_TEST_KMS}
+targets:
+  Prod:
+    account_id: "111111111111"
+    imports: [analytics]
+`), 0644))
+
+	cfg, err := LoadConfigWithoutAutoDetect(path)
+	require.NoError(t, err)
+	require.Equal(t, "alias/merge-store", cfg.MergeStore.S3.KMSKeyID)
+
+	p := &Pipeline{config: cfg}
+	starter, err := p.starterConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "${env:SECRETSYNC_TEST_KMS}", starter.MergeStore.S3.KMSKeyID)
+	assert.Equal(t, "https://vault.example.com", starter.Vault.Address)
+	assert.NotNil(t, starter.Sources)
+	assert.NotNil(t, starter.Targets)
+	assert.Equal(t, "alias/merge-store", cfg.MergeStore.S3.KMSKeyID, "the loaded config is unchanged")
+
+	out := filepath.Join(t.TempDir(), "out.yaml")
+	require.NoError(t, cfg.WriteConfig(out))
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "${env:SECRETSYNC_TEST_KMS}")
+	assert.Contains(t, string(data), "${env:SECRETSYNC_TEST_ROLE}")
+	assert.NotContains(t, string(data), "alias/merge-store")
+	assert.NotContains(t, string(data), "SecretSyncRole")
+}
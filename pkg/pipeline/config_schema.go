@@ -0,0 +1,360 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// configSchemaEnums lists the allowed values of string fields, by struct type and YAML key
+var configSchemaEnums = map[reflect.Type]map[string][]string{
+	reflect.TypeOf(Target{}): {
+		"merge_strategy": mergeStrategyNames(),
+	},
+	reflect.TypeOf(MergeStrategyOverride{}): {
+		"strategy": mergeStrategyNames(),
+	},
+	reflect.TypeOf(MergeSettings{}): {
+		"on_protected_override": {ProtectedOverrideWarn, ProtectedOverrideFail},
+	},
+	reflect.TypeOf(NameMatchingConfig{}): {
+		"strategy": {"exact", "fuzzy", "loose"},
+	},
+	reflect.TypeOf(PoliciesConfig{}): {
+		"fail_on": {PolicySeverityError, PolicySeverityWarning, PolicySeverityNote, PolicyFailOnNever},
+	},
+	reflect.TypeOf(PolicyRule{}): {
+		"severity": {PolicySeverityError, PolicySeverityWarning, PolicySeverityNote},
+	},
+}
+
+// configSchemaTypeEnums lists the allowed values of string types
+var configSchemaTypeEnums = map[reflect.Type][]string{
+	reflect.TypeOf(ExecutionContextType("")): {
+		string(ExecutionContextManagement), string(ExecutionContextDelegated), string(ExecutionContextHub),
+	},
+	reflect.TypeOf(Operation("")): {
+		string(OperationMerge), string(OperationSync), string(OperationPipeline),
+	},
+	reflect.TypeOf(v1alpha1.NotificationEvent("")): {
+		string(v1alpha1.NotificationEventSyncSuccess), string(v1alpha1.NotificationEventSyncFailure),
+	},
+}
+
+func mergeStrategyNames() []string {
+	return []string{
+		string(utils.MergeStrategyDeep), string(utils.MergeStrategyListReplace), string(utils.MergeStrategyListUnion),
+		string(utils.MergeStrategyMapReplace), string(utils.MergeStrategyFirstWins),
+	}
+}
+
+// yamlField is a struct field as yaml.v3 decodes it
+type yamlField struct {
+	Name string
+	Type reflect.Type
+}
+
+// yamlFieldName returns the key yaml.v3 decodes a struct field from: the
+// yaml tag name or the lowercased field name. Skipped fields (unexported or
+// tagged "-") return false; inline structs return an empty name.
+func yamlFieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	tag := f.Tag.Get("yaml")
+	if tag == "-" {
+		return "", false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if strings.Contains(","+opts+",", ",inline,") {
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			return "", true
+		}
+	}
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name, true
+}
+
+// yamlFields returns the fields yaml.v3 decodes into a struct type, keyed by
+// YAML key, with inline structs flattened
+func yamlFields(t reflect.Type) map[string]yamlField {
+	fields := make(map[string]yamlField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := yamlFieldName(f)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			for k, v := range yamlFields(ft) {
+				fields[k] = v
+			}
+			continue
+		}
+		fields[name] = yamlField{Name: name, Type: f.Type}
+	}
+	return fields
+}
+
+// ConfigJSONSchema returns a JSON Schema (draft 2020-12) of the pipeline
+// configuration file, generated from Config. Editors that support JSON
+// Schema for YAML use it to complete and check configuration files.
+func ConfigJSONSchema() ([]byte, error) {
+	g := &configSchemaGenerator{defs: make(map[string]interface{})}
+	schema := g.object(reflect.TypeOf(Config{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "secretsync pipeline configuration"
+	schema["$defs"] = g.defs
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// configSchemaGenerator builds JSON Schemas from Go types. Named structs are
+// added to defs once and referenced.
+type configSchemaGenerator struct {
+	defs map[string]interface{}
+}
+
+func (g *configSchemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	if enum, ok := configSchemaTypeEnums[t]; ok {
+		return map[string]interface{}{"type": "string", "enum": enum}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return map[string]interface{}{"anyOf": []interface{}{g.schema(t.Elem()), map[string]interface{}{"type": "null"}}}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = nil // reserve the name for recursive types
+			g.defs[name] = g.definition(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + name}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": g.schema(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": g.schema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// definition returns the schema of a named struct
+func (g *configSchemaGenerator) definition(t reflect.Type) map[string]interface{} {
+	object := g.object(t)
+	if t == reflect.TypeOf(Target{}) {
+		// Target.UnmarshalYAML accepts a list of imports as shorthand
+		return map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				object,
+			},
+		}
+	}
+	return object
+}
+
+// object returns the schema of a struct's fields
+func (g *configSchemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	for name, f := range yamlFields(t) {
+		if enum, ok := configSchemaEnums[t][name]; ok {
+			properties[name] = map[string]interface{}{"type": "string", "enum": enum}
+			continue
+		}
+		properties[name] = g.schema(f.Type)
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// UnknownField is a configuration key that does not match any field
+type UnknownField struct {
+	File       string // Set when loaded from a file
+	Path       string // Dotted path of the key, e.g. pipeline.sync.delete_orphan
+	Key        string
+	Line       int
+	Column     int
+	Suggestion string // Closest known key, if any
+}
+
+func (f UnknownField) String() string {
+	s := fmt.Sprintf("line %d: unknown field %s", f.Line, f.Path)
+	if f.File != "" {
+		s = fmt.Sprintf("%s:%d: unknown field %s", f.File, f.Line, f.Path)
+	}
+	if f.Suggestion != "" {
+		s += fmt.Sprintf(" (did you mean %s?)", f.Suggestion)
+	}
+	return s
+}
+
+// UnknownFieldsError is returned by strict loading when the configuration
+// has keys that do not match any field
+type UnknownFieldsError struct {
+	Fields []UnknownField
+}
+
+func (e *UnknownFieldsError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.String()
+	}
+	return fmt.Sprintf("%d unknown config field(s): %s", len(e.Fields), strings.Join(msgs, "; "))
+}
+
+// CheckConfigFields returns the keys of a YAML configuration that do not
+// match any Config field, in document order. yaml.Unmarshal ignores them,
+// so a misspelled key silently falls back to its default.
+func CheckConfigFields(data []byte) ([]UnknownField, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	var unknown []UnknownField
+	checkConfigNode(&doc, reflect.TypeOf(Config{}), "", &unknown)
+	return unknown, nil
+}
+
+// checkConfigNode checks the keys of a node against the type it decodes into
+func checkConfigNode(n *yaml.Node, t reflect.Type, path string, unknown *[]UnknownField) {
+	for n.Kind == yaml.DocumentNode || n.Kind == yaml.AliasNode {
+		if n.Kind == yaml.AliasNode {
+			n = n.Alias
+		} else if len(n.Content) > 0 {
+			n = n.Content[0]
+		} else {
+			return
+		}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Value == "<<" && key.Tag == "!!merge" {
+				checkMergeNode(value, t, path, unknown)
+				continue
+			}
+			f, ok := fields[key.Value]
+			if !ok {
+				*unknown = append(*unknown, UnknownField{
+					Path:       joinConfigPath(path, key.Value),
+					Key:        key.Value,
+					Line:       key.Line,
+					Column:     key.Column,
+					Suggestion: suggestConfigField(key.Value, fields),
+				})
+				continue
+			}
+			checkConfigNode(value, f.Type, joinConfigPath(path, key.Value), unknown)
+		}
+	case t.Kind() == reflect.Map && n.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			checkConfigNode(n.Content[i+1], t.Elem(), joinConfigPath(path, n.Content[i].Value), unknown)
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && n.Kind == yaml.SequenceNode:
+		for i, item := range n.Content {
+			checkConfigNode(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i), unknown)
+		}
+	}
+}
+
+// checkMergeNode checks the mappings merged into a mapping with "<<"
+func checkMergeNode(n *yaml.Node, t reflect.Type, path string, unknown *[]UnknownField) {
+	if n.Kind == yaml.SequenceNode {
+		for _, item := range n.Content {
+			checkConfigNode(item, t, path, unknown)
+		}
+		return
+	}
+	checkConfigNode(n, t, path, unknown)
+}
+
+func joinConfigPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// suggestConfigField returns the known key closest to an unknown one: a key
+// that only differs in case, underscores or dashes, otherwise the key with
+// the smallest edit distance if it is close enough to be a typo
+func suggestConfigField(key string, fields map[string]yamlField) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	normalize := strings.NewReplacer("_", "", "-", "")
+	for _, name := range names {
+		if strings.EqualFold(normalize.Replace(name), normalize.Replace(key)) {
+			return name
+		}
+	}
+
+	maxDistance := len(key) / 3
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	best, bestDistance := "", maxDistance+1
+	for _, name := range names {
+		if d := editDistance(strings.ToLower(key), name); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const configSchemaTestConfig = `
+vault:
+  address: https://vault.example.com
+sources:
+  analytics:
+    vault: {mount: analytics}
+merge_store:
+  vault: {mount: merged}
+targets:
+  Staging:
+    account_id: "111111111111"
+    imports: [analytics]
+    merge_strategy: list_union
+  Production: [Staging]
+pipeline:
+  sync:
+    delete_orphans: true
+notifications:
+  channels:
+    - slack:
+        url: https://hooks.slack.com/x
+        events: [failure]
+`
+
+// compileConfigSchema compiles the generated config schema
+func compileConfigSchema(t *testing.T) *jsonschema.Schema {
+	data, err := ConfigJSONSchema()
+	require.NoError(t, err)
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	require.NoError(t, err)
+
+	c := jsonschema.NewCompiler()
+	require.NoError(t, c.AddResource("config.json", doc))
+	schema, err := c.Compile("config.json")
+	require.NoError(t, err)
+	return schema
+}
+
+// validateAgainstSchema validates a YAML config against the compiled schema
+func validateAgainstSchema(t *testing.T, schema *jsonschema.Schema, config string) error {
+	var doc interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(config), &doc))
+	value, err := normalizeJSON(doc)
+	require.NoError(t, err)
+	return schema.Validate(value)
+}
+
+func TestConfigJSONSchema(t *testing.T) {
+	schema := compileConfigSchema(t)
+	assert.NoError(t, validateAgainstSchema(t, schema, configSchemaTestConfig))
+
+	for name, config := range map[string]string{
+		"misspelled key":   "pipeline:\n  sync:\n    delete_orphan: true\n",
+		"unknown strategy": "targets:\n  Prod:\n    merge_strategy: shallow\n",
+		"shorthand type":   "targets:\n  Prod: [1, {a: b}]\n",
+		"unknown event":    "notifications:\n  channels:\n    - webhook: {url: https://x, events: [done]}\n",
+	} {
+		assert.Error(t, validateAgainstSchema(t, schema, config), name)
+	}
+}
+
+func TestCheckConfigFields(t *testing.T) {
+	config := `
+log:
+  levl: debug
+targets:
+  Staging:
+    account_id: "111111111111"
+    secret_prefx: app/
+    secretPrefix: app/
+  Production: [Staging]
+defaults: &defaults
+  parallel: 4
+pipeline:
+  sync:
+    <<: *defaults
+    delete_orphan: true
+  merge:
+    <<: *defaults
+policies:
+  rules:
+    - name: no-dev
+      sevrity: warning
+      targetz: {names: ["*"]}
+`
+	unknown, err := CheckConfigFields([]byte(config))
+	require.NoError(t, err)
+
+	var got []string
+	for _, f := range unknown {
+		got = append(got, f.String())
+	}
+	assert.Equal(t, []string{
+		"line 3: unknown field log.levl (did you mean level?)",
+		"line 7: unknown field targets.Staging.secret_prefx (did you mean secret_prefix?)",
+		"line 8: unknown field targets.Staging.secretPrefix (did you mean secret_prefix?)",
+		"line 10: unknown field defaults",
+		"line 15: unknown field pipeline.sync.delete_orphan (did you mean delete_orphans?)",
+		"line 21: unknown field policies.rules[0].sevrity (did you mean severity?)",
+		"line 22: unknown field policies.rules[0].targetz (did you mean targets?)",
+	}, got)
+	assert.Equal(t, 5, unknown[4].Column)
+}
+
+func TestLoadConfigStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(configSchemaTestConfig+"        unknown_key: 1\n"), 0644))
+
+	_, err := LoadConfigStrict(path)
+	var unknownErr *UnknownFieldsError
+	require.ErrorAs(t, err, &unknownErr)
+	require.Len(t, unknownErr.Fields, 1)
+	assert.Equal(t, "notifications.channels[0].slack.unknown_key", unknownErr.Fields[0].Path)
+	assert.Empty(t, unknownErr.Fields[0].Suggestion)
+
+	// Non-strict loading only warns
+	cfg, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Staging"}, cfg.Targets["Production"].Imports)
+}
@@ -389,6 +389,11 @@ func TestGetSourcePath(t *testing.T) {
 
 	// Inherited target
 	assert.Equal(t, "merged-secrets/Stg", cfg.GetSourcePath("Stg"))
+
+	// AWS Secrets Manager source falls back to the global region
+	cfg.AWS.Region = "us-east-1"
+	cfg.Sources["legacy"] = Source{AWS: &AWSSource{AccountID: "333333333333", Prefix: "legacy/"}}
+	assert.Equal(t, "aws://333333333333/us-east-1/legacy/", cfg.GetSourcePath("legacy"))
 }
 
 func TestIsValidAWSAccountID(t *testing.T) {
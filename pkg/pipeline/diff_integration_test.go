@@ -50,7 +50,7 @@ func TestDiffIntegration_InitAndTracking(t *testing.T) {
 	require.NotNil(t, p)
 
 	// Initialize diff tracking
-	p.initDiff(true, "/tmp/test-config.yaml")
+	p.initDiff(true, "/tmp/test-config.yaml", diff.ValuesHidden)
 
 	// Verify diff tracking is initialized
 	assert.NotNil(t, p.pipelineDiff)
@@ -87,7 +87,7 @@ func TestDiffIntegration_AddTargetDiff(t *testing.T) {
 	require.NoError(t, err)
 
 	// Initialize diff tracking
-	p.initDiff(true, "")
+	p.initDiff(true, "", diff.ValuesHidden)
 
 	// Create a test target diff
 	targetDiff := diff.TargetDiff{
@@ -151,7 +151,7 @@ func TestDiffIntegration_MultipleTargets(t *testing.T) {
 	require.NoError(t, err)
 
 	// Initialize diff tracking
-	p.initDiff(false, "")
+	p.initDiff(false, "", diff.ValuesHidden)
 
 	// Add diffs for multiple targets
 	p.addTargetDiff(diff.TargetDiff{
@@ -210,7 +210,7 @@ func TestDiffIntegration_ZeroSum(t *testing.T) {
 	require.NoError(t, err)
 
 	// Initialize diff tracking
-	p.initDiff(true, "")
+	p.initDiff(true, "", diff.ValuesHidden)
 
 	// Add a zero-sum diff (only unchanged secrets)
 	p.addTargetDiff(diff.TargetDiff{
@@ -257,7 +257,7 @@ func TestDiffIntegration_ExitCodes(t *testing.T) {
 	t.Run("ExitCode 0 for zero-sum", func(t *testing.T) {
 		p, err := New(cfg)
 		require.NoError(t, err)
-		p.initDiff(true, "")
+		p.initDiff(true, "", diff.ValuesHidden)
 
 		p.addTargetDiff(diff.TargetDiff{
 			Target:  "target1",
@@ -271,7 +271,7 @@ func TestDiffIntegration_ExitCodes(t *testing.T) {
 	t.Run("ExitCode 1 for changes detected", func(t *testing.T) {
 		p, err := New(cfg)
 		require.NoError(t, err)
-		p.initDiff(true, "")
+		p.initDiff(true, "", diff.ValuesHidden)
 
 		p.addTargetDiff(diff.TargetDiff{
 			Target: "target1",
@@ -287,7 +287,7 @@ func TestDiffIntegration_ExitCodes(t *testing.T) {
 	t.Run("ExitCode 2 for errors", func(t *testing.T) {
 		p, err := New(cfg)
 		require.NoError(t, err)
-		p.initDiff(true, "")
+		p.initDiff(true, "", diff.ValuesHidden)
 
 		// Add a failed result
 		p.resultsMu.Lock()
@@ -9,15 +9,26 @@ import (
 )
 
 // initDiff initializes diff tracking for a run
-func (p *Pipeline) initDiff(dryRun bool, configPath string) {
+func (p *Pipeline) initDiff(dryRun bool, configPath string, values diff.ValueMode) {
 	p.diffMu.Lock()
 	defer p.diffMu.Unlock()
 	p.pipelineDiff = &diff.PipelineDiff{
 		DryRun:     dryRun,
 		ConfigPath: configPath,
+		Values:     values,
 	}
 }
 
+// diffOptions returns the options for diffing secrets in the current run
+func (p *Pipeline) diffOptions() diff.DiffOptions {
+	p.diffMu.Lock()
+	defer p.diffMu.Unlock()
+	if p.pipelineDiff == nil {
+		return diff.DiffOptions{}
+	}
+	return diff.DiffOptions{Values: p.pipelineDiff.Values}
+}
+
 // addTargetDiff adds a target diff to the pipeline diff
 func (p *Pipeline) addTargetDiff(td diff.TargetDiff) {
 	p.diffMu.Lock()
@@ -53,61 +64,83 @@ func (p *Pipeline) computeMergeDiff(ctx context.Context, targetName string, sour
 		}
 	}
 
-	// Fetch desired state from source paths
+	// Fetch desired state from source paths, merged the same way as mergeTarget
+	target := p.config.Targets[targetName]
+	merger, err := newBundleMerger(target)
+	if err != nil {
+		return nil, err
+	}
 	desiredSecrets := make(map[string]interface{})
-	for _, sourcePath := range sourcePaths {
-		sourceSecrets, err := p.fetchVaultSecrets(ctx, sourcePath)
+	for _, importSpec := range target.Imports {
+		sourcePath := p.config.GetSourcePath(importSpec)
+		selector, err := ParseImport(importSpec)
+		if err != nil {
+			l.WithError(err).Debug("Invalid import selector")
+			continue
+		}
+
+		var sourceSecrets map[string]interface{}
+		if src := p.config.Sources[selector.Source]; src.AWS != nil {
+			sourceSecrets, err = p.readAWSSource(ctx, selector.Source, src.AWS)
+		} else {
+			sourceSecrets, err = p.fetchVaultSecrets(ctx, p.config.GetSourcePath(selector.Source))
+		}
 		if err != nil {
 			l.WithError(err).WithField("sourcePath", sourcePath).Debug("Failed to fetch source secrets")
 			continue
 		}
-		for k, v := range sourceSecrets {
-			desiredSecrets[k] = v
+		for k, v := range selector.apply(sourceSecrets) {
+			merger.merge(desiredSecrets, k, v, ValueOrigin{Source: importSpec, Path: p.config.GetSourcePath(selector.Source) + "/" + k})
 		}
 	}
 
-	changes := diff.DiffSecrets(currentSecrets, desiredSecrets)
+	changes := diff.DiffSecretsWithOptions(currentSecrets, desiredSecrets, p.diffOptions())
 	summary := diff.ComputeSummary(changes)
 
+	// Protection failures are reported by mergeTarget; the diff only shows them
+	overrides, _ := p.checkOverrides(targetName, merger.conflicts)
+
 	targetDiff := &diff.TargetDiff{
-		Target:  targetName,
-		Changes: changes,
-		Summary: summary,
+		Target:    targetName,
+		Sources:   sourcePaths,
+		Changes:   changes,
+		Summary:   summary,
+		Overrides: overrides,
 	}
 
 	return targetDiff, nil
 }
 
-// computeSyncDiff computes the diff for a sync operation
-func (p *Pipeline) computeSyncDiff(ctx context.Context, targetName string, roleARN, region string) (*diff.TargetDiff, error) {
+// computeSyncDiff computes the diff for a sync operation.
+// Desired state is the bundle being synced, keyed by AWS secret name. Secrets
+// in the account that are not in the bundle only count as removed when they
+// are managed for this target and delete_orphans is enabled, matching what
+// syncTarget actually deletes.
+func (p *Pipeline) computeSyncDiff(ctx context.Context, targetName string, roleARN, region string, bundle map[string]map[string]interface{}) (*diff.TargetDiff, error) {
 	l := log.WithFields(log.Fields{
 		"action": "computeSyncDiff",
 		"target": targetName,
 	})
 
-	currentSecrets, err := p.fetchAWSSecrets(ctx, roleARN, region)
+	desiredSecrets := make(map[string]interface{}, len(bundle))
+	for secretPath, data := range bundle {
+		name, err := p.getAWSSecretName(targetName, secretPath)
+		if err != nil {
+			l.WithError(err).WithField("secret", secretPath).Debug("Failed to determine AWS secret name")
+			continue
+		}
+		desiredSecrets[name] = data
+	}
+
+	accountSecrets, accountTags, err := p.fetchAWSSecretsWithTags(ctx, roleARN, region, p.currentStateScope(targetName, desiredSecrets))
 	if err != nil {
 		l.WithError(err).Debug("Failed to fetch current AWS state")
-		currentSecrets = make(map[string]interface{})
+		accountSecrets = make(map[string]interface{})
 	}
 
-	var desiredSecrets map[string]interface{}
-	if p.config.MergeStore.Vault != nil {
-		mergePath := fmt.Sprintf("%s/%s", p.config.MergeStore.Vault.Mount, targetName)
-		desiredSecrets, err = p.fetchVaultSecrets(ctx, mergePath)
-		if err != nil {
-			l.WithError(err).Debug("Failed to fetch desired state from merge store")
-			desiredSecrets = make(map[string]interface{})
-		}
-	} else if p.s3Store != nil {
-		desiredSecrets, err = p.fetchS3MergeSecrets(ctx, targetName)
-		if err != nil {
-			l.WithError(err).Debug("Failed to fetch desired state from S3")
-			desiredSecrets = make(map[string]interface{})
-		}
-	}
+	currentSecrets := p.selectCurrentState(targetName, accountSecrets, accountTags, desiredSecrets)
 
-	changes := diff.DiffSecrets(currentSecrets, desiredSecrets)
+	changes := diff.DiffSecretsWithOptions(currentSecrets, desiredSecrets, p.diffOptions())
 	summary := diff.ComputeSummary(changes)
 
 	targetDiff := &diff.TargetDiff{
@@ -155,3 +188,27 @@ func (p *Pipeline) ExitCode() int {
 
 	return 0
 }
+
+// selectCurrentState returns the account secrets a sync of the target would
+// touch: those it writes and, with delete_orphans, the managed orphans it deletes
+func (p *Pipeline) selectCurrentState(targetName string, accountSecrets map[string]interface{}, accountTags map[string]map[string]string, desired map[string]interface{}) map[string]interface{} {
+	current := make(map[string]interface{})
+	inScope := p.currentStateScope(targetName, desired)
+	for name, data := range accountSecrets {
+		if inScope(name, accountTags[name]) {
+			current[name] = data
+		}
+	}
+	return current
+}
+
+// currentStateScope selects the account secrets selectCurrentState keeps, so
+// that only their values are read
+func (p *Pipeline) currentStateScope(targetName string, desired map[string]interface{}) secretScope {
+	return func(name string, tags map[string]string) bool {
+		if _, ok := desired[name]; ok {
+			return true
+		}
+		return p.config.Pipeline.Sync.DeleteOrphans && isManagedBy(tags, targetName)
+	}
+}
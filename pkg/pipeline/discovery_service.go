@@ -122,11 +122,12 @@ func (d *DiscoveryService) DiscoverTargets() (map[string]Target, error) {
 			}
 
 			discoveredTargets[targetName] = Target{
-				AccountID:    acct.ID,
-				Imports:      imports,
-				Region:       region,
-				SecretPrefix: dynamicTarget.SecretPrefix,
-				RoleARN:      roleARN,
+				AccountID:          acct.ID,
+				Imports:            imports,
+				Region:             region,
+				SecretPrefix:       dynamicTarget.SecretPrefix,
+				SecretNameTemplate: dynamicTarget.SecretNameTemplate,
+				RoleARN:            roleARN,
 			}
 
 			dtLog.WithFields(log.Fields{
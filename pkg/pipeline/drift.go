@@ -0,0 +1,252 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	log "github.com/sirupsen/logrus"
+)
+
+// DriftKind classifies how a target's AWS secrets drifted from its bundle
+type DriftKind string
+
+const (
+	// DriftModified is a secret whose value was edited out of band
+	DriftModified DriftKind = "modified"
+	// DriftDeleted is a bundle secret missing from the account
+	DriftDeleted DriftKind = "deleted"
+	// DriftAdded is a secret tagged for the target that is not in the bundle
+	DriftAdded DriftKind = "added"
+	// DriftUnmanaged is an untagged secret named like the target's secrets
+	// that is not in the bundle, e.g. one created by hand
+	DriftUnmanaged DriftKind = "unmanaged"
+)
+
+// DriftKinds lists every drift kind in report order
+var DriftKinds = []DriftKind{DriftModified, DriftDeleted, DriftAdded, DriftUnmanaged}
+
+// DriftedSecret is an AWS secret that no longer matches the merge store bundle.
+// Key changes describe what was changed in the account, relative to the bundle.
+type DriftedSecret struct {
+	Name         string    `json:"name"`
+	Kind         DriftKind `json:"kind"`
+	KeysAdded    []string  `json:"keys_added,omitempty"`
+	KeysRemoved  []string  `json:"keys_removed,omitempty"`
+	KeysModified []string  `json:"keys_modified,omitempty"`
+}
+
+// TargetDrift is the drift of one target
+type TargetDrift struct {
+	Target     string          `json:"target"`
+	AccountID  string          `json:"account_id,omitempty"`
+	Checked    int             `json:"checked"` // Bundle secrets compared
+	Secrets    []DriftedSecret `json:"secrets,omitempty"`
+	Unreadable []string        `json:"unreadable,omitempty"` // Listed but could not be read; not compared
+	Error      string          `json:"error,omitempty"`
+}
+
+// Count returns the number of drifted secrets of a kind
+func (t TargetDrift) Count(kind DriftKind) int {
+	n := 0
+	for _, s := range t.Secrets {
+		if s.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// DriftReport is the result of comparing targets' AWS secrets with their bundles
+type DriftReport struct {
+	CheckedAt time.Time     `json:"checked_at"`
+	Targets   []TargetDrift `json:"targets"`
+}
+
+// HasDrift reports whether any target drifted
+func (r *DriftReport) HasDrift() bool {
+	for _, t := range r.Targets {
+		if len(t.Secrets) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ExitCode returns 0 without drift, 1 on drift and 2 if a target could not be checked
+func (r *DriftReport) ExitCode() int {
+	for _, t := range r.Targets {
+		if t.Error != "" {
+			return 2
+		}
+	}
+	if r.HasDrift() {
+		return 1
+	}
+	return 0
+}
+
+// JSON returns the report as indented JSON
+func (r *DriftReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// DetectDrift compares each target's AWS secrets with its current merge
+// store bundle, after the target's filters and transforms. Only secrets in
+// the bundle, secrets carrying the target's ownership tags and untagged
+// secrets under the target's naming prefix are compared, and only their
+// values are read; other secrets in the account are neither read nor
+// reported. Targets default to all.
+func (p *Pipeline) DetectDrift(ctx context.Context, targets []string) (*DriftReport, error) {
+	if len(targets) == 0 {
+		for name := range p.config.Targets {
+			targets = append(targets, name)
+		}
+		sort.Strings(targets)
+	}
+	for _, name := range targets {
+		if _, ok := p.config.Targets[name]; !ok {
+			return nil, fmt.Errorf("unknown target %q", name)
+		}
+	}
+
+	report := &DriftReport{CheckedAt: time.Now().UTC()}
+	for _, name := range targets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		report.Targets = append(report.Targets, p.detectTargetDrift(ctx, name))
+	}
+	return report, nil
+}
+
+// detectTargetDrift compares one target's AWS secrets with its bundle
+func (p *Pipeline) detectTargetDrift(ctx context.Context, targetName string) TargetDrift {
+	l := log.WithFields(log.Fields{
+		"action": "detectTargetDrift",
+		"target": targetName,
+	})
+	target := p.config.Targets[targetName]
+	td := TargetDrift{Target: targetName, AccountID: target.AccountID}
+	fail := func(err error) TargetDrift {
+		l.WithError(err).Warn("Drift check failed")
+		td.Error = err.Error()
+		return td
+	}
+
+	bundlePath, err := p.GetBundlePath(targetName)
+	if err != nil {
+		return fail(fmt.Errorf("failed to get bundle path: %w", err))
+	}
+	secretsData, err := p.readBundleSecrets(ctx, targetName, bundlePath)
+	if err != nil {
+		return fail(fmt.Errorf("failed to read bundle: %w", err))
+	}
+	transformer, err := newSecretTransformer(target)
+	if err == nil {
+		secretsData, err = transformer.apply(secretsData)
+	}
+	if err != nil {
+		return fail(fmt.Errorf("failed to apply filters and transforms: %w", err))
+	}
+
+	bundle := make(map[string]interface{}, len(secretsData))
+	for secretPath, data := range secretsData {
+		name, err := p.getAWSSecretName(targetName, secretPath)
+		if err != nil {
+			return fail(err)
+		}
+		bundle[name] = data
+	}
+
+	region := target.Region
+	if region == "" {
+		region = p.config.AWS.Region
+	}
+	accountSecrets, accountTags, err := p.fetchAWSSecretsWithTags(ctx, p.getRoleARNForTarget(target), region, driftScope(targetName, p.secretNamePrefix(targetName), bundle))
+	if err != nil {
+		return fail(fmt.Errorf("failed to read AWS secrets: %w", err))
+	}
+
+	td.Secrets, td.Unreadable = compareDrift(targetName, p.secretNamePrefix(targetName), bundle, accountSecrets, accountTags)
+	td.Checked = len(bundle)
+	l.WithFields(log.Fields{
+		"checked": td.Checked,
+		"drifted": len(td.Secrets),
+	}).Debug("Drift check completed")
+	return td
+}
+
+// driftScope selects the account secrets compareDrift looks at, so that drift
+// checks only read the values of the target's secrets
+func driftScope(targetName, namePrefix string, bundle map[string]interface{}) secretScope {
+	return func(name string, tags map[string]string) bool {
+		_, ok := bundle[name]
+		return ok || isManagedBy(tags, targetName) || isUnmanagedCandidate(namePrefix, name, tags)
+	}
+}
+
+// isUnmanagedCandidate reports whether a secret without ownership tags is
+// named like the target's secrets
+func isUnmanagedCandidate(namePrefix, name string, tags map[string]string) bool {
+	return namePrefix != "" && strings.HasPrefix(name, namePrefix) && tags[TagManagedBy] != ManagedByValue
+}
+
+// compareDrift compares a target's bundle, keyed by AWS secret name, with
+// the secrets listed in its account. accountTags holds every listed secret;
+// secrets listed but missing from accountSecrets could not be read and are
+// returned as unreadable instead of deleted. Secrets without ownership tags
+// whose names start with namePrefix are reported as unmanaged; with an empty
+// prefix nothing distinguishes them from other secrets, so none are.
+func compareDrift(targetName, namePrefix string, bundle, accountSecrets map[string]interface{}, accountTags map[string]map[string]string) ([]DriftedSecret, []string) {
+	var unreadable []string
+	expected := make(map[string]interface{}, len(bundle))
+	for name, data := range bundle {
+		if _, listed := accountTags[name]; listed {
+			if _, read := accountSecrets[name]; !read {
+				unreadable = append(unreadable, name)
+				continue
+			}
+		}
+		expected[name] = data
+	}
+
+	actual := make(map[string]interface{})
+	unmanaged := make(map[string]bool)
+	for name, data := range accountSecrets {
+		if _, ok := expected[name]; ok || isManagedBy(accountTags[name], targetName) {
+			actual[name] = data
+			continue
+		}
+		if isUnmanagedCandidate(namePrefix, name, accountTags[name]) {
+			actual[name] = data
+			unmanaged[name] = true
+		}
+	}
+
+	var drifted []DriftedSecret
+	for _, c := range diff.DiffSecrets(expected, actual) {
+		s := DriftedSecret{Name: c.Path}
+		switch c.ChangeType {
+		case diff.ChangeTypeModified:
+			s.Kind = DriftModified
+			s.KeysAdded, s.KeysRemoved, s.KeysModified = c.KeysAdded, c.KeysRemoved, c.KeysModified
+		case diff.ChangeTypeRemoved:
+			s.Kind = DriftDeleted
+		case diff.ChangeTypeAdded:
+			s.Kind = DriftAdded
+			if unmanaged[c.Path] {
+				s.Kind = DriftUnmanaged
+			}
+		default:
+			continue
+		}
+		drifted = append(drifted, s)
+	}
+	sort.Strings(unreadable)
+	return drifted, unreadable
+}
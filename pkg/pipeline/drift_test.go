@@ -0,0 +1,103 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareDrift(t *testing.T) {
+	managed := map[string]string{TagManagedBy: ManagedByValue, TagTarget: "Production"}
+	bundle := map[string]interface{}{
+		"app/db":     map[string]interface{}{"host": "db", "password": "s3cret"},
+		"app/api":    map[string]interface{}{"token": "abc"},
+		"app/cache":  map[string]interface{}{"url": "redis://cache"},
+		"app/locked": map[string]interface{}{"key": "v"},
+		"app/same":   map[string]interface{}{"key": "v"},
+	}
+	account := map[string]interface{}{
+		"app/db":      map[string]interface{}{"host": "db", "password": "changed", "debug": "true"},
+		"app/api":     map[string]interface{}{"token": "abc"},
+		"app/same":    map[string]interface{}{"key": "v"},
+		"app/extra":   map[string]interface{}{"key": "v"},
+		"other/team":  map[string]interface{}{"key": "v"},
+		"app/staging": map[string]interface{}{"key": "v"},
+		"app/manual":  map[string]interface{}{"key": "v"},
+	}
+	tags := map[string]map[string]string{
+		"app/db":      managed,
+		"app/api":     managed,
+		"app/same":    nil, // Not tagged, but in the bundle
+		"app/extra":   managed,
+		"app/locked":  managed, // Listed but unreadable
+		"other/team":  nil,
+		"app/staging": {TagManagedBy: ManagedByValue, TagTarget: "Staging"},
+		"app/manual":  {"team": "web"}, // Created by hand under the target's prefix
+	}
+
+	drifted, unreadable := compareDrift("Production", "app/", bundle, account, tags)
+	assert.Equal(t, []DriftedSecret{
+		{Name: "app/cache", Kind: DriftDeleted},
+		{Name: "app/db", Kind: DriftModified, KeysAdded: []string{"debug"}, KeysModified: []string{"password"}},
+		{Name: "app/extra", Kind: DriftAdded},
+		{Name: "app/manual", Kind: DriftUnmanaged},
+	}, drifted)
+	assert.Equal(t, []string{"app/locked"}, unreadable)
+
+	// Without a naming prefix untagged secrets are never reported
+	drifted, _ = compareDrift("Production", "", bundle, account, tags)
+	assert.Zero(t, TargetDrift{Secrets: drifted}.Count(DriftUnmanaged))
+
+	drifted, unreadable = compareDrift("Production", "app/", bundle, bundle, nil)
+	assert.Empty(t, drifted)
+	assert.Empty(t, unreadable)
+}
+
+func TestDriftScope(t *testing.T) {
+	managed := map[string]string{TagManagedBy: ManagedByValue, TagTarget: "Production"}
+	bundle := map[string]interface{}{"app/db": map[string]interface{}{"host": "db"}}
+	inScope := driftScope("Production", "app/", bundle)
+
+	assert.True(t, inScope("app/db", nil), "bundle secrets are read")
+	assert.True(t, inScope("legacy/api", managed), "secrets managed for the target are read")
+	assert.True(t, inScope("app/manual", map[string]string{"team": "web"}), "untagged secrets under the prefix are read")
+	assert.False(t, inScope("other/team", nil))
+	assert.False(t, inScope("app/staging", map[string]string{TagManagedBy: ManagedByValue, TagTarget: "Staging"}))
+	assert.False(t, driftScope("Production", "", bundle)("app/manual", nil))
+}
+
+func TestDriftReport(t *testing.T) {
+	report := &DriftReport{Targets: []TargetDrift{
+		{Target: "Staging", Checked: 3},
+		{Target: "Production", Checked: 2, Secrets: []DriftedSecret{
+			{Name: "app/db", Kind: DriftModified},
+			{Name: "app/api", Kind: DriftModified},
+			{Name: "app/extra", Kind: DriftAdded},
+		}},
+	}}
+	assert.True(t, report.HasDrift())
+	assert.Equal(t, 1, report.ExitCode())
+	assert.Equal(t, 2, report.Targets[1].Count(DriftModified))
+	assert.Equal(t, 0, report.Targets[1].Count(DriftDeleted))
+
+	out, err := report.JSON()
+	require.NoError(t, err)
+	var decoded DriftReport
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	assert.Equal(t, report.Targets, decoded.Targets)
+
+	report.Targets[0].Error = "access denied"
+	assert.Equal(t, 2, report.ExitCode())
+
+	assert.Equal(t, 0, (&DriftReport{Targets: []TargetDrift{{Target: "Staging"}}}).ExitCode())
+}
+
+func TestDetectDrift_UnknownTarget(t *testing.T) {
+	p, err := New(policyTestConfig())
+	require.NoError(t, err)
+	_, err = p.DetectDrift(context.Background(), []string{"Missing"})
+	assert.ErrorContains(t, err, `unknown target "Missing"`)
+}
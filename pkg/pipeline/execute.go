@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -9,6 +10,7 @@ import (
 	reqctx "github.com/jbcom/secretsync/pkg/context"
 	"github.com/jbcom/secretsync/pkg/observability"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // runMerge executes only the merge phase
@@ -79,9 +81,21 @@ func (p *Pipeline) runPipeline(ctx context.Context, targets []string, opts Optio
 		return allResults, fmt.Errorf("merge phase failed: %w", mergeErr)
 	}
 
-	// Sync phase
+	// Sync phase. Targets whose merged secrets failed their schemas or whose
+	// imports could not all be read are not synced, so the previous bundle is
+	// not synced in their place.
 	l.Info("Phase 2: Sync")
-	syncResults, syncErr := p.executeSyncPhase(ctx, targets, opts)
+	syncTargets := targets
+	if failed := heldBackTargets(mergeResults); len(failed) > 0 {
+		l.WithField("targets", failed).Warn("Skipping sync of targets whose merge was incomplete")
+		syncTargets = nil
+		for _, t := range targets {
+			if !failed[t] {
+				syncTargets = append(syncTargets, t)
+			}
+		}
+	}
+	syncResults, syncErr := p.executeSyncPhase(ctx, syncTargets, opts)
 	allResults = append(allResults, syncResults...)
 
 	p.resultsMu.Lock()
@@ -125,9 +139,17 @@ func (p *Pipeline) executeMergePhase(ctx context.Context, targets []string, opts
 		}).Debug("Processing merge level")
 
 		// Execute level in parallel
-		levelResults := p.executeParallel(ctx, levelTargets, opts.Parallelism, func(target string) Result {
-			return p.mergeTarget(ctx, target, opts.DryRun)
+		levelCtx, span := observability.StartSpan(ctx, "pipeline.merge.level",
+			attribute.Int("secretsync.level", levelIdx),
+			attribute.StringSlice("secretsync.targets", levelTargets),
+		)
+		levelResults := p.executeParallel(levelCtx, levelTargets, opts.Parallelism, opts.Stop, func(target string) Result {
+			return traceTarget(levelCtx, "pipeline.merge_target", target, opts.DryRun, func(ctx context.Context) Result {
+				return p.mergeTarget(ctx, target, opts.DryRun)
+			})
 		})
+		span.SetAttributes(attribute.Int("secretsync.failed", countFailed(levelResults)))
+		observability.EndSpan(span, nil)
 
 		results = append(results, levelResults...)
 
@@ -151,8 +173,10 @@ func (p *Pipeline) executeMergePhase(ctx context.Context, targets []string, opts
 
 // executeSyncPhase runs sync operations (can be fully parallel)
 func (p *Pipeline) executeSyncPhase(ctx context.Context, targets []string, opts Options) ([]Result, error) {
-	results := p.executeParallel(ctx, targets, opts.Parallelism, func(target string) Result {
-		return p.syncTarget(ctx, target, opts.DryRun)
+	results := p.executeParallel(ctx, targets, opts.Parallelism, opts.Stop, func(target string) Result {
+		return traceTarget(ctx, "pipeline.sync_target", target, opts.DryRun, func(ctx context.Context) Result {
+			return p.syncTarget(ctx, target, opts.DryRun)
+		})
 	})
 
 	var lastErr error
@@ -172,8 +196,9 @@ func (p *Pipeline) executeSyncPhase(ctx context.Context, targets []string, opts
 	return results, lastErr
 }
 
-// executeParallel runs a function for each target with limited concurrency
-func (p *Pipeline) executeParallel(ctx context.Context, targets []string, maxParallel int, fn func(string) Result) []Result {
+// executeParallel runs a function for each target with limited concurrency.
+// Once stop is closed no further targets are started; running ones finish.
+func (p *Pipeline) executeParallel(ctx context.Context, targets []string, maxParallel int, stop <-chan struct{}, fn func(string) Result) []Result {
 	if maxParallel <= 0 {
 		maxParallel = 1
 	}
@@ -184,6 +209,13 @@ func (p *Pipeline) executeParallel(ctx context.Context, targets []string, maxPar
 
 	for i, target := range targets {
 		select {
+		case <-stop:
+			results[i] = Result{
+				Target:  target,
+				Success: false,
+				Error:   ErrStopped,
+			}
+			continue
 		case <-ctx.Done():
 			results[i] = Result{
 				Target:  target,
@@ -194,6 +226,19 @@ func (p *Pipeline) executeParallel(ctx context.Context, targets []string, maxPar
 		case sem <- struct{}{}:
 		}
 
+		// Stop may have been closed while waiting for a slot
+		select {
+		case <-stop:
+			<-sem
+			results[i] = Result{
+				Target:  target,
+				Success: false,
+				Error:   ErrStopped,
+			}
+			continue
+		default:
+		}
+
 		wg.Add(1)
 		observability.PipelineParallelWorkers.WithLabelValues("execute").Inc()
 
@@ -210,3 +255,42 @@ func (p *Pipeline) executeParallel(ctx context.Context, targets []string, maxPar
 	wg.Wait()
 	return results
 }
+
+// traceTarget runs fn for a single target in a span named name
+func traceTarget(ctx context.Context, name, target string, dryRun bool, fn func(context.Context) Result) Result {
+	ctx, span := observability.StartSpan(ctx, name,
+		attribute.String("secretsync.target", target),
+		attribute.Bool("secretsync.dry_run", dryRun),
+	)
+	result := fn(ctx)
+	observability.EndSpan(span, result.Error)
+	return result
+}
+
+// countFailed returns the number of unsuccessful results
+func countFailed(results []Result) int {
+	n := 0
+	for _, r := range results {
+		if !r.Success {
+			n++
+		}
+	}
+	return n
+}
+
+// heldBackTargets returns the targets whose merge failed schema validation
+// or could not read all of its imports
+func heldBackTargets(results []Result) map[string]bool {
+	var failed map[string]bool
+	for _, r := range results {
+		var schemaErr *SchemaValidationError
+		var sourceErr *SourceReadError
+		if errors.As(r.Error, &schemaErr) || errors.As(r.Error, &sourceErr) {
+			if failed == nil {
+				failed = make(map[string]bool)
+			}
+			failed[r.Target] = true
+		}
+	}
+	return failed
+}
@@ -60,6 +60,18 @@ func (p *Pipeline) fetchVaultSecrets(ctx context.Context, path string) (map[stri
 
 // fetchAWSSecrets fetches all secrets from AWS Secrets Manager
 func (p *Pipeline) fetchAWSSecrets(ctx context.Context, roleARN, region string) (map[string]interface{}, error) {
+	secrets, _, err := p.fetchAWSSecretsWithTags(ctx, roleARN, region, nil)
+	return secrets, err
+}
+
+// secretScope selects, from a listed secret's name and tags, the secrets
+// whose values a caller needs
+type secretScope func(name string, tags map[string]string) bool
+
+// fetchAWSSecretsWithTags fetches secrets from AWS Secrets Manager along with
+// their tags. Tags are returned for every listed secret, but values are only
+// read for secrets in scope (all of them when scope is nil).
+func (p *Pipeline) fetchAWSSecretsWithTags(ctx context.Context, roleARN, region string, scope secretScope) (map[string]interface{}, map[string]map[string]string, error) {
 	l := log.WithFields(log.Fields{
 		"action":  "fetchAWSSecrets",
 		"roleARN": roleARN,
@@ -74,17 +86,23 @@ func (p *Pipeline) fetchAWSSecrets(ctx context.Context, roleARN, region string)
 
 	if err := awsClient.Init(ctx); err != nil {
 		l.WithError(err).Debug("Failed to initialize AWS client")
-		return nil, err
+		return nil, nil, err
 	}
 
 	secretsList, err := awsClient.ListSecrets(ctx, "")
 	if err != nil {
 		l.WithError(err).Debug("Failed to list AWS secrets")
-		return map[string]interface{}{}, nil
+		return nil, nil, fmt.Errorf("failed to list AWS secrets: %w", err)
 	}
 
 	secrets := make(map[string]interface{})
+	tags := make(map[string]map[string]string)
 	for _, secretName := range secretsList {
+		tags[secretName] = awsClient.GetSecretTags(secretName)
+		if scope != nil && !scope(secretName, tags[secretName]) {
+			continue
+		}
+
 		secretData, err := awsClient.GetSecret(ctx, secretName)
 		if err != nil {
 			l.WithError(err).WithField("secretName", secretName).Debug("Failed to get secret")
@@ -99,7 +117,7 @@ func (p *Pipeline) fetchAWSSecrets(ctx context.Context, roleARN, region string)
 		secrets[secretName] = data
 	}
 
-	return secrets, nil
+	return secrets, tags, nil
 }
 
 // fetchS3MergeSecrets fetches all secrets from S3 merge store for a target
@@ -59,11 +59,18 @@ func BuildGraph(cfg *Config) (*Graph, error) {
 	// Build edges
 	for name, target := range cfg.Targets {
 		node := g.Nodes[name]
-		for _, imp := range target.Imports {
+		seen := make(map[string]bool)
+		for _, spec := range target.Imports {
+			imp := importSourceName(spec)
 			depNode, ok := g.Nodes[imp]
 			if !ok {
 				return nil, fmt.Errorf("target %q imports unknown source/target %q", name, imp)
 			}
+			// The same source may be imported with several selectors
+			if seen[imp] {
+				continue
+			}
+			seen[imp] = true
 			node.Deps = append(node.Deps, imp)
 			depNode.DependedBy = append(depNode.DependedBy, name)
 		}
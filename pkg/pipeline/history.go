@@ -0,0 +1,494 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/jbcom/secretsync/pkg/client/vault"
+	reqctx "github.com/jbcom/secretsync/pkg/context"
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/observability"
+	log "github.com/sirupsen/logrus"
+)
+
+// historyTimeFormat sorts lexically in time order; used in S3 keys and Vault paths
+const historyTimeFormat = "20060102T150405.000Z"
+
+// RunRecord is one entry of the run history and audit log
+type RunRecord struct {
+	ID          string              `json:"id"` // Request ID of the run
+	StartedAt   time.Time           `json:"started_at"`
+	FinishedAt  time.Time           `json:"finished_at"`
+	Operation   Operation           `json:"operation"`
+	DryRun      bool                `json:"dry_run"`
+	Targets     []string            `json:"targets"`
+	Success     bool                `json:"success"`
+	Error       string              `json:"error,omitempty"`
+	Results     []RecordResult      `json:"results"`
+	DiffSummary *diff.ChangeSummary `json:"diff_summary,omitempty"`
+	ConfigHash  string              `json:"config_hash"`
+	Caller      string              `json:"caller"` // AWS caller ARN, or the local user
+}
+
+// RecordResult is a Result in a form that serializes (error as string)
+type RecordResult struct {
+	Target      string              `json:"target"`
+	Phase       string              `json:"phase"`
+	Operation   string              `json:"operation,omitempty"`
+	Success     bool                `json:"success"`
+	Error       string              `json:"error,omitempty"`
+	DurationMS  int64               `json:"duration_ms"`
+	Details     ResultDetails       `json:"details"`
+	DiffSummary *diff.ChangeSummary `json:"diff_summary,omitempty"`
+}
+
+// HasTarget reports whether the run included a target
+func (r RunRecord) HasTarget(target string) bool {
+	for _, t := range r.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// HistorySink stores run records
+type HistorySink interface {
+	// Name identifies the sink in logs and errors
+	Name() string
+	// Write stores a record
+	Write(ctx context.Context, rec *RunRecord) error
+	// List returns up to limit records accepted by filter (nil accepts all), newest first
+	List(ctx context.Context, limit int, filter func(RunRecord) bool) ([]RunRecord, error)
+}
+
+// NewHistorySinks creates a sink for each configured history destination
+func NewHistorySinks(ctx context.Context, cfg *Config) ([]HistorySink, error) {
+	var sinks []HistorySink
+	h := cfg.History
+	if h.JSONL != nil {
+		sinks = append(sinks, &JSONLHistorySink{Path: h.JSONL.Path})
+	}
+	if h.S3 != nil {
+		s3Cfg := h.S3.withDefaults(cfg.MergeStore)
+		region := s3Cfg.Region
+		if region == "" {
+			region = cfg.AWS.Region
+		}
+		awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), config.WithAPIOptions(observability.AWSAPIOptions))
+		if err != nil {
+			return nil, fmt.Errorf("history.s3: failed to load AWS config: %w", err)
+		}
+		sinks = append(sinks, &S3HistorySink{
+			Bucket:   s3Cfg.Bucket,
+			Prefix:   s3Cfg.Prefix,
+			KMSKeyID: s3Cfg.KMSKeyID,
+			client:   s3.NewFromConfig(awsCfg),
+		})
+	}
+	if h.Vault != nil {
+		historyPath := h.Vault.Path
+		if historyPath == "" {
+			historyPath = "secretsync/history"
+		}
+		client := &vault.VaultClient{
+			Address:   cfg.Vault.Address,
+			Namespace: cfg.Vault.Namespace,
+		}
+		if err := client.Init(ctx); err != nil {
+			return nil, fmt.Errorf("history.vault: failed to init vault client: %w", err)
+		}
+		sinks = append(sinks, &VaultHistorySink{
+			BasePath: strings.Trim(h.Vault.Mount, "/") + "/" + strings.Trim(historyPath, "/"),
+			client:   client,
+		})
+	}
+	return sinks, nil
+}
+
+// withDefaults returns the S3 history settings with the bucket, prefix and
+// KMS key of the S3 merge store when no bucket is set, so records are
+// written to {merge store prefix}history/ in the merge store bucket
+func (h HistoryS3) withDefaults(store MergeStoreConfig) HistoryS3 {
+	if h.Bucket != "" || store.S3 == nil {
+		return h
+	}
+	h.Bucket = store.S3.Bucket
+	if h.Prefix == "" {
+		h.Prefix = store.S3.Prefix
+	}
+	if h.KMSKeyID == "" {
+		h.KMSKeyID = store.S3.KMSKeyID
+	}
+	return h
+}
+
+// validate checks the history configuration. The S3 sink defaults to the
+// bucket of the S3 merge store.
+func (h HistoryConfig) validate(store MergeStoreConfig) error {
+	if h.JSONL != nil && h.JSONL.Path == "" {
+		return fmt.Errorf("history.jsonl.path is required")
+	}
+	if h.S3 != nil && h.S3.withDefaults(store).Bucket == "" {
+		return fmt.Errorf("history.s3.bucket is required without an S3 merge store")
+	}
+	if h.Vault != nil && h.Vault.Mount == "" {
+		return fmt.Errorf("history.vault.mount is required")
+	}
+	return nil
+}
+
+// enabled reports whether any history sink is configured
+func (h HistoryConfig) enabled() bool {
+	return h.JSONL != nil || h.S3 != nil || h.Vault != nil
+}
+
+// newRunRecord builds the history record of a finished run
+func (p *Pipeline) newRunRecord(ctx context.Context, opts Options, targets []string, results []Result, runErr error) *RunRecord {
+	rec := &RunRecord{
+		ID:         reqctx.GetRequestID(ctx),
+		FinishedAt: time.Now().UTC(),
+		Operation:  opts.Operation,
+		DryRun:     opts.DryRun,
+		Targets:    targets,
+		Success:    runErr == nil,
+		ConfigHash: p.config.Hash(),
+		Caller:     p.callerIdentity(ctx),
+	}
+	if reqCtx := reqctx.FromContext(ctx); reqCtx != nil {
+		rec.StartedAt = reqCtx.StartTime.UTC()
+	}
+	if runErr != nil {
+		rec.Error = runErr.Error()
+	}
+
+	rec.Results = RecordResults(results)
+	for _, r := range rec.Results {
+		if !r.Success {
+			rec.Success = false
+		}
+	}
+
+	if d := p.Diff(); d != nil && (opts.DryRun || opts.ComputeDiff) {
+		summary := d.Summary
+		rec.DiffSummary = &summary
+	}
+	return rec
+}
+
+// RecordResults converts results to their serializable form
+func RecordResults(results []Result) []RecordResult {
+	records := make([]RecordResult, 0, len(results))
+	for _, r := range results {
+		rr := RecordResult{
+			Target:     r.Target,
+			Phase:      r.Phase,
+			Operation:  r.Operation,
+			Success:    r.Success,
+			DurationMS: r.Duration.Milliseconds(),
+			Details:    r.Details,
+		}
+		if r.Error != nil {
+			rr.Error = r.Error.Error()
+		}
+		if r.Diff != nil {
+			summary := r.Diff.Summary
+			rr.DiffSummary = &summary
+		}
+		records = append(records, rr)
+	}
+	return records
+}
+
+// recordRun writes a run record to every history sink.
+// History is best effort: failures are logged and never fail the run.
+func (p *Pipeline) recordRun(ctx context.Context, rec *RunRecord) {
+	l := log.WithFields(log.Fields{
+		"action":     "recordRun",
+		"request_id": rec.ID,
+	})
+
+	if p.historySinks == nil {
+		sinks, err := NewHistorySinks(ctx, p.config)
+		if err != nil {
+			l.WithError(err).Warn("Failed to initialize run history")
+			return
+		}
+		p.historySinks = sinks
+	}
+
+	for _, sink := range p.historySinks {
+		if err := sink.Write(ctx, rec); err != nil {
+			l.WithError(err).WithField("sink", sink.Name()).Warn("Failed to write run history")
+		}
+	}
+}
+
+// callerIdentity returns the AWS caller ARN, falling back to the local user
+func (p *Pipeline) callerIdentity(ctx context.Context) string {
+	if p.awsCtx != nil && p.awsCtx.CallerIdentity != nil {
+		return p.awsCtx.CallerIdentity.ARN
+	}
+	if p.caller != "" {
+		return p.caller
+	}
+
+	p.caller = "unknown"
+	if awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(p.config.AWS.Region), config.WithAPIOptions(observability.AWSAPIOptions)); err == nil {
+		stsCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if out, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(stsCtx, &sts.GetCallerIdentityInput{}); err == nil {
+			p.caller = aws.ToString(out.Arn)
+			return p.caller
+		}
+	}
+	if u, err := user.Current(); err == nil {
+		p.caller = "user:" + u.Username
+	}
+	return p.caller
+}
+
+// JSONLHistorySink appends records to a JSON Lines file
+type JSONLHistorySink struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Name implements HistorySink
+func (s *JSONLHistorySink) Name() string {
+	return "jsonl:" + s.Path
+}
+
+// Write implements HistorySink
+func (s *JSONLHistorySink) Write(ctx context.Context, rec *RunRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	return nil
+}
+
+// List implements HistorySink
+func (s *JSONLHistorySink) List(ctx context.Context, limit int, filter func(RunRecord) bool) ([]RunRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history file: %w", err)
+	}
+	defer f.Close()
+
+	var all []RunRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec RunRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid record: %w", s.Path, lineNum, err)
+		}
+		all = append(all, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history file: %w", err)
+	}
+
+	var records []RunRecord
+	for i := len(all) - 1; i >= 0 && (limit <= 0 || len(records) < limit); i-- {
+		if filter == nil || filter(all[i]) {
+			records = append(records, all[i])
+		}
+	}
+	return records, nil
+}
+
+// S3HistorySink writes one JSON object per record to S3
+type S3HistorySink struct {
+	Bucket   string
+	Prefix   string
+	KMSKeyID string
+
+	client *s3.Client
+}
+
+// Name implements HistorySink
+func (s *S3HistorySink) Name() string {
+	return fmt.Sprintf("s3://%s/%s", s.Bucket, s.historyPrefix())
+}
+
+// historyPrefix returns the key prefix of history objects
+func (s *S3HistorySink) historyPrefix() string {
+	prefix := s.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + "history/"
+}
+
+// Write implements HistorySink
+func (s *S3HistorySink) Write(ctx context.Context, rec *RunRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.historyPrefix() + historyRecordName(rec) + ".json"),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	}
+	if s.KMSKeyID != "" {
+		input.ServerSideEncryption = "aws:kms"
+		input.SSEKMSKeyId = aws.String(s.KMSKeyID)
+	} else {
+		input.ServerSideEncryption = "AES256"
+	}
+
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+	return nil
+}
+
+// List implements HistorySink
+func (s *S3HistorySink) List(ctx context.Context, limit int, filter func(RunRecord) bool) ([]RunRecord, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(s.historyPrefix()),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list history: %w", err)
+		}
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.ToString(obj.Key))
+		}
+	}
+	sort.Strings(keys)
+
+	var records []RunRecord
+	for i := len(keys) - 1; i >= 0 && (limit <= 0 || len(records) < limit); i-- {
+		output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(keys[i]),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", keys[i], err)
+		}
+		body, err := io.ReadAll(output.Body)
+		output.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", keys[i], err)
+		}
+		var rec RunRecord
+		if err := json.Unmarshal(body, &rec); err != nil {
+			return nil, fmt.Errorf("invalid record %s: %w", keys[i], err)
+		}
+		if filter == nil || filter(rec) {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// VaultHistorySink writes one KV2 secret per record under BasePath
+type VaultHistorySink struct {
+	BasePath string
+
+	client *vault.VaultClient
+}
+
+// Name implements HistorySink
+func (s *VaultHistorySink) Name() string {
+	return "vault:" + s.BasePath
+}
+
+// Write implements HistorySink
+func (s *VaultHistorySink) Write(ctx context.Context, rec *RunRecord) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("failed to convert record: %w", err)
+	}
+
+	recordPath := s.BasePath + "/" + historyRecordName(rec)
+	if _, err := s.client.WriteSecretOnce(ctx, recordPath, data, nil); err != nil {
+		return fmt.Errorf("failed to write %s: %w", recordPath, err)
+	}
+	return nil
+}
+
+// List implements HistorySink
+func (s *VaultHistorySink) List(ctx context.Context, limit int, filter func(RunRecord) bool) ([]RunRecord, error) {
+	paths, err := s.client.ListSecrets(ctx, s.BasePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list history: %w", err)
+	}
+	sort.Strings(paths)
+
+	var records []RunRecord
+	for i := len(paths) - 1; i >= 0 && (limit <= 0 || len(records) < limit); i-- {
+		data, err := s.client.GetKVSecretOnce(ctx, paths[i])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", paths[i], err)
+		}
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		var rec RunRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return nil, fmt.Errorf("invalid record %s: %w", paths[i], err)
+		}
+		if filter == nil || filter(rec) {
+			records = append(records, rec)
+		}
+	}
+	return records, nil
+}
+
+// historyRecordName returns a name for a record that sorts by finish time
+func historyRecordName(rec *RunRecord) string {
+	return rec.FinishedAt.UTC().Format(historyTimeFormat) + "-" + rec.ID
+}
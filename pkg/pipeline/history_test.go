@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	reqctx "github.com/jbcom/secretsync/pkg/context"
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLHistorySink(t *testing.T) {
+	ctx := context.Background()
+	sink := &JSONLHistorySink{Path: filepath.Join(t.TempDir(), "history.jsonl")}
+
+	records, err := sink.List(ctx, 10, nil)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	for i, targets := range [][]string{{"Stg"}, {"Stg", "Prod"}, {"Prod"}} {
+		require.NoError(t, sink.Write(ctx, &RunRecord{
+			ID:         string(rune('a' + i)),
+			FinishedAt: time.Date(2026, 1, 1, 0, i, 0, 0, time.UTC),
+			Operation:  OperationPipeline,
+			Targets:    targets,
+			Success:    true,
+		}))
+	}
+
+	info, err := os.Stat(sink.Path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	records, err = sink.List(ctx, 2, nil)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "c", records[0].ID, "newest first")
+	assert.Equal(t, "b", records[1].ID)
+
+	records, err = sink.List(ctx, 0, func(r RunRecord) bool { return r.HasTarget("Stg") })
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "b", records[0].ID)
+	assert.Equal(t, "a", records[1].ID)
+}
+
+func TestNewRunRecord(t *testing.T) {
+	cfg := &Config{Targets: map[string]Target{"Prod": {AccountID: "111111111111"}}}
+	p := &Pipeline{config: cfg, caller: "arn:aws:iam::111111111111:role/ci"}
+	p.initDiff(true, "", diff.ValuesHidden)
+
+	reqCtx := reqctx.NewRequestContext()
+	ctx := reqctx.WithRequestContext(context.Background(), reqCtx)
+
+	results := []Result{
+		{Target: "Prod", Phase: "merge", Success: true, Duration: 1500 * time.Millisecond,
+			Diff: &diff.TargetDiff{Summary: diff.ChangeSummary{Added: 2}}},
+		{Target: "Prod", Phase: "sync", Success: false, Error: errors.New("access denied")},
+	}
+	rec := p.newRunRecord(ctx, Options{Operation: OperationPipeline, DryRun: true}, []string{"Prod"}, results, nil)
+
+	assert.Equal(t, reqCtx.RequestID, rec.ID)
+	assert.Equal(t, reqCtx.StartTime.UTC(), rec.StartedAt)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/ci", rec.Caller)
+	assert.Equal(t, cfg.Hash(), rec.ConfigHash)
+	assert.True(t, rec.DryRun)
+	assert.False(t, rec.Success, "a failed target fails the record")
+	require.Len(t, rec.Results, 2)
+	assert.Equal(t, int64(1500), rec.Results[0].DurationMS)
+	assert.Equal(t, 2, rec.Results[0].DiffSummary.Added)
+	assert.Equal(t, "access denied", rec.Results[1].Error)
+	assert.NotNil(t, rec.DiffSummary)
+}
+
+func TestHistoryConfigValidate(t *testing.T) {
+	assert.NoError(t, HistoryConfig{}.validate(MergeStoreConfig{}))
+	assert.False(t, HistoryConfig{}.enabled())
+
+	assert.Error(t, HistoryConfig{JSONL: &HistoryJSONL{}}.validate(MergeStoreConfig{}))
+	assert.Error(t, HistoryConfig{S3: &HistoryS3{}}.validate(MergeStoreConfig{}))
+	assert.Error(t, HistoryConfig{Vault: &HistoryVault{}}.validate(MergeStoreConfig{}))
+
+	h := HistoryConfig{JSONL: &HistoryJSONL{Path: "history.jsonl"}}
+	assert.NoError(t, h.validate(MergeStoreConfig{}))
+	assert.True(t, h.enabled())
+
+	// The S3 sink defaults to the S3 merge store
+	store := MergeStoreConfig{S3: &MergeStoreS3{Bucket: "merged", Prefix: "bundles/", KMSKeyID: "alias/merge"}}
+	assert.NoError(t, HistoryConfig{S3: &HistoryS3{}}.validate(store))
+}
+
+func TestHistoryS3_WithDefaults(t *testing.T) {
+	store := MergeStoreConfig{S3: &MergeStoreS3{Bucket: "merged", Prefix: "bundles/", KMSKeyID: "alias/merge"}}
+
+	got := HistoryS3{}.withDefaults(store)
+	assert.Equal(t, HistoryS3{Bucket: "merged", Prefix: "bundles/", KMSKeyID: "alias/merge"}, got)
+	assert.Equal(t, "s3://merged/bundles/history/", (&S3HistorySink{Bucket: got.Bucket, Prefix: got.Prefix}).Name())
+
+	got = HistoryS3{Prefix: "audit/", Region: "eu-west-1"}.withDefaults(store)
+	assert.Equal(t, HistoryS3{Bucket: "merged", Prefix: "audit/", Region: "eu-west-1", KMSKeyID: "alias/merge"}, got)
+
+	explicit := HistoryS3{Bucket: "audit"}
+	assert.Equal(t, explicit, explicit.withDefaults(store), "an explicit bucket keeps its own settings")
+	assert.Equal(t, HistoryS3{}, HistoryS3{}.withDefaults(MergeStoreConfig{}))
+}
@@ -0,0 +1,522 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/client/aws"
+	"github.com/jbcom/secretsync/pkg/client/vault"
+	"github.com/jbcom/secretsync/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// ImportAccount is an AWS account whose Secrets Manager secrets are imported into Vault
+type ImportAccount struct {
+	Name      string `json:"name"` // Target name in the starter config
+	AccountID string `json:"account_id"`
+	Region    string `json:"region,omitempty"` // Default: aws.region
+}
+
+// PathMapping replaces a prefix of AWS secret names to form Vault paths
+type PathMapping struct {
+	From string `json:"from"` // AWS secret name prefix
+	To   string `json:"to"`   // Vault path prefix replacing From
+}
+
+// ImportOptions configures Import
+type ImportOptions struct {
+	Accounts []ImportAccount
+	// Mount is the Vault KV2 mount; each account is written under <mount>/<name>
+	Mount string
+	// Mappings are tried in order and the first matching prefix wins.
+	// Names matching no mapping are used as Vault paths as-is.
+	Mappings []PathMapping
+	// Overwrite updates Vault secrets that already exist with different values
+	Overwrite bool
+	DryRun    bool
+}
+
+// ImportAction is what Import does, or would do, with one AWS secret
+type ImportAction string
+
+const (
+	ImportCreate    ImportAction = "create"
+	ImportUpdate    ImportAction = "update"
+	ImportUnchanged ImportAction = "unchanged"
+	// ImportConflict is a Vault secret that exists with different values and is kept without Overwrite
+	ImportConflict ImportAction = "conflict"
+	ImportSkipped  ImportAction = "skipped"
+)
+
+// ImportedSecret is the outcome for one AWS secret. Values are never included.
+type ImportedSecret struct {
+	Name      string       `json:"name"`                 // AWS secret name
+	VaultPath string       `json:"vault_path,omitempty"` // Path relative to the account's Vault base path
+	Action    ImportAction `json:"action"`
+	Reason    string       `json:"reason,omitempty"` // Why the secret was skipped
+	Error     string       `json:"error,omitempty"`  // Vault read or write failure
+}
+
+// AccountImport is the outcome for one account
+type AccountImport struct {
+	Account  ImportAccount    `json:"account"`
+	BasePath string           `json:"base_path"` // Vault path the account's secrets are written under
+	Secrets  []ImportedSecret `json:"secrets,omitempty"`
+	// Excluded are Vault paths under BasePath whose values differ from the
+	// account (conflicts, failed writes and pre-existing secrets); the starter
+	// target excludes them so its first run does not change the account
+	Excluded []string `json:"excluded,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Count returns the number of secrets with an action
+func (a AccountImport) Count(action ImportAction) int {
+	n := 0
+	for _, s := range a.Secrets {
+		if s.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// ImportReport is the result of Import
+type ImportReport struct {
+	DryRun   bool            `json:"dry_run"`
+	Accounts []AccountImport `json:"accounts"`
+	// Config is a starter pipeline configuration that syncs the imported
+	// secrets back to their accounts under their current names
+	Config *Config `json:"-"`
+}
+
+// Failed reports whether any account or secret could not be imported
+func (r *ImportReport) Failed() bool {
+	for _, a := range r.Accounts {
+		if a.Error != "" {
+			return true
+		}
+		for _, s := range a.Secrets {
+			if s.Error != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// JSON returns the report as indented JSON
+func (r *ImportReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// importVault is the part of the Vault client Import uses
+type importVault interface {
+	GetKVSecretVersionOnce(ctx context.Context, path string) (map[string]interface{}, int, error)
+	WriteSecretOnce(ctx context.Context, path string, data map[string]interface{}, cas *int) (map[string]interface{}, error)
+	ListSecrets(ctx context.Context, path string) ([]string, error)
+}
+
+// ParseImportAccount parses an account flag: name=account_id[/region]
+func ParseImportAccount(spec string) (ImportAccount, error) {
+	name, id, ok := strings.Cut(spec, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return ImportAccount{}, fmt.Errorf("account %q must be name=account_id[/region]", spec)
+	}
+	acct := ImportAccount{Name: strings.TrimSpace(name)}
+	acct.AccountID, acct.Region, _ = strings.Cut(strings.TrimSpace(id), "/")
+	if !isValidAWSAccountID(acct.AccountID) {
+		return ImportAccount{}, fmt.Errorf("account %q: invalid account_id format %q (must be 12 digits)", spec, acct.AccountID)
+	}
+	if strings.ContainsAny(acct.Name, ":#/") {
+		return ImportAccount{}, fmt.Errorf("account %q: name must not contain ':', '#' or '/'", spec)
+	}
+	return acct, nil
+}
+
+// ParsePathMapping parses a mapping flag: from=to. An empty "to" strips the prefix.
+func ParsePathMapping(spec string) (PathMapping, error) {
+	from, to, ok := strings.Cut(spec, "=")
+	if !ok || from == "" {
+		return PathMapping{}, fmt.Errorf("mapping %q must be from=to", spec)
+	}
+	if !awsSecretNamePattern.MatchString(from) {
+		return PathMapping{}, fmt.Errorf("mapping %q: %q is not an AWS secret name prefix", spec, from)
+	}
+	if strings.HasPrefix(to, "/") || strings.Contains(to, "//") {
+		return PathMapping{}, fmt.Errorf("mapping %q: %q is not a Vault path prefix", spec, to)
+	}
+	return PathMapping{From: from, To: to}, nil
+}
+
+// mapSecretName returns the Vault path of an AWS secret name and the index
+// of the mapping applied, or -1 if the name is used as-is
+func mapSecretName(name string, mappings []PathMapping) (string, int) {
+	for i, m := range mappings {
+		if strings.HasPrefix(name, m.From) {
+			return m.To + strings.TrimPrefix(name, m.From), i
+		}
+	}
+	return name, -1
+}
+
+// validVaultPath reports whether a mapped path can be written below a Vault base path
+func validVaultPath(p string) bool {
+	if p == "" {
+		return false
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// Import reads the Secrets Manager secrets of each account and writes them to
+// Vault under <mount>/<account name>, using check-and-set so existing secrets
+// are never overwritten by accident. With DryRun nothing is written, but
+// Vault is still read so the report shows what would change. The report's
+// Config reproduces the accounts' current secrets with a zero-sum diff: its
+// targets exclude every Vault path whose values differ from the account.
+func (p *Pipeline) Import(ctx context.Context, opts ImportOptions) (*ImportReport, error) {
+	if opts.Mount == "" {
+		return nil, fmt.Errorf("a Vault mount is required")
+	}
+	if len(opts.Accounts) == 0 {
+		return nil, fmt.Errorf("at least one account is required")
+	}
+	seen := make(map[string]bool)
+	for _, acct := range opts.Accounts {
+		if seen[acct.Name] {
+			return nil, fmt.Errorf("account %q is listed more than once", acct.Name)
+		}
+		seen[acct.Name] = true
+	}
+
+	client := &vault.VaultClient{
+		Address:   p.config.Vault.Address,
+		Namespace: p.config.Vault.Namespace,
+	}
+	if err := client.Init(ctx); err != nil {
+		return nil, fmt.Errorf("failed to init vault client: %w", err)
+	}
+
+	starter, err := p.starterConfig()
+	if err != nil {
+		return nil, err
+	}
+	report := &ImportReport{DryRun: opts.DryRun, Config: starter}
+	for _, acct := range opts.Accounts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result, target := p.importAccount(ctx, client, acct, opts)
+		report.Accounts = append(report.Accounts, result)
+		if result.Error == "" {
+			report.Config.Sources[importedSourceName(acct.Name)] = Source{Vault: &VaultSource{Mount: result.BasePath}}
+			report.Config.Targets[acct.Name] = target
+		}
+	}
+	return report, nil
+}
+
+// importAccount imports one account and returns the starter config's target for it
+func (p *Pipeline) importAccount(ctx context.Context, client importVault, acct ImportAccount, opts ImportOptions) (AccountImport, Target) {
+	l := log.WithFields(log.Fields{
+		"action":    "importAccount",
+		"account":   acct.Name,
+		"accountID": acct.AccountID,
+		"dryRun":    opts.DryRun,
+	})
+	result := AccountImport{
+		Account:  acct,
+		BasePath: strings.TrimSuffix(opts.Mount, "/") + "/" + acct.Name,
+	}
+	target := Target{
+		AccountID: acct.AccountID,
+		Region:    acct.Region,
+		Imports:   []string{importedSourceName(acct.Name)},
+	}
+
+	region := acct.Region
+	if region == "" {
+		region = p.config.AWS.Region
+	}
+	secrets, unreadable, err := p.readImportAccount(ctx, target, region)
+	if err != nil {
+		l.WithError(err).Warn("Failed to read account")
+		result.Error = err.Error()
+		return result, target
+	}
+
+	// Secrets already under the base path are listed before anything is
+	// written, so the starter target can leave out the ones not imported
+	existing, err := client.ListSecrets(ctx, result.BasePath)
+	if err != nil {
+		l.WithError(err).Warn("Failed to list existing Vault secrets")
+		result.Error = fmt.Sprintf("failed to list Vault secrets under %s: %v", result.BasePath, err)
+		return result, target
+	}
+
+	result.Secrets, target.SecretPrefix, target.SecretNameTemplate = planImport(acct, region, secrets, opts.Mappings)
+	result.Secrets = append(result.Secrets, unreadable...)
+	sort.Slice(result.Secrets, func(i, j int) bool { return result.Secrets[i].Name < result.Secrets[j].Name })
+
+	for i := range result.Secrets {
+		s := &result.Secrets[i]
+		if s.Action == ImportSkipped {
+			continue
+		}
+		applyImportedSecret(ctx, client, result.BasePath, s, secrets[s.Name], opts)
+		if s.Error != "" {
+			l.WithField("secret", s.Name).WithField("error", s.Error).Warn("Failed to import secret")
+		}
+	}
+
+	result.Excluded = starterExclusions(result.BasePath, existing, result.Secrets)
+	if len(result.Excluded) > 0 {
+		exclude := make([]string, len(result.Excluded))
+		for i, vaultPath := range result.Excluded {
+			exclude[i] = escapePathGlob(vaultPath)
+		}
+		target.Filters = &TargetFilters{Path: &PatternFilter{Exclude: exclude}}
+	}
+
+	l.WithFields(log.Fields{
+		"created":  result.Count(ImportCreate),
+		"updated":  result.Count(ImportUpdate),
+		"skipped":  result.Count(ImportSkipped),
+		"excluded": len(result.Excluded),
+	}).Info("Account imported")
+	return result, target
+}
+
+// starterExclusions returns the Vault paths, relative to basePath, that the
+// starter target must not sync to keep its first run zero-sum: secrets left
+// in Vault with other values (conflicts and failed writes) and secrets that
+// were already under basePath but not imported. existing holds full paths.
+func starterExclusions(basePath string, existing []string, secrets []ImportedSecret) []string {
+	imported := make(map[string]bool, len(secrets))
+	excluded := make(map[string]bool)
+	for _, s := range secrets {
+		switch {
+		case s.VaultPath == "" || s.Action == ImportSkipped:
+		case s.Action == ImportConflict || s.Error != "":
+			excluded[s.VaultPath] = true
+		default:
+			imported[s.VaultPath] = true
+		}
+	}
+	for _, fullPath := range existing {
+		relPath := strings.TrimPrefix(strings.TrimPrefix(fullPath, basePath), "/")
+		if relPath != "" && !imported[relPath] {
+			excluded[relPath] = true
+		}
+	}
+
+	paths := make([]string, 0, len(excluded))
+	for relPath := range excluded {
+		paths = append(paths, relPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// escapePathGlob quotes the glob metacharacters of a path so a path filter
+// matches it literally
+func escapePathGlob(p string) string {
+	var sb strings.Builder
+	for _, r := range p {
+		if strings.ContainsRune(`*?[]\`, r) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// readImportAccount reads every secret of an account. Secrets that cannot be
+// read or are not JSON objects are returned as skipped.
+func (p *Pipeline) readImportAccount(ctx context.Context, target Target, region string) (map[string]map[string]interface{}, []ImportedSecret, error) {
+	awsClient := &aws.AwsClient{
+		RoleArn: p.getRoleARNForTarget(target),
+		Region:  region,
+		Name:    "import",
+	}
+	if err := awsClient.Init(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to init AWS client: %w", err)
+	}
+	names, err := awsClient.ListSecrets(ctx, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list AWS secrets: %w", err)
+	}
+
+	secrets := make(map[string]map[string]interface{}, len(names))
+	var skipped []ImportedSecret
+	for _, name := range names {
+		raw, err := awsClient.GetSecret(ctx, name)
+		if err != nil {
+			skipped = append(skipped, ImportedSecret{Name: name, Action: ImportSkipped, Reason: "failed to read secret"})
+			continue
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(raw, &data); err != nil || data == nil {
+			skipped = append(skipped, ImportedSecret{Name: name, Action: ImportSkipped, Reason: "not a JSON object"})
+			continue
+		}
+		secrets[name] = data
+	}
+	return secrets, skipped, nil
+}
+
+// planImport maps an account's secrets to Vault paths and derives the
+// starter target's secret naming that maps them back. Secrets whose names
+// the naming cannot reproduce, or that map to an invalid or shared Vault
+// path, are skipped so the starter config stays zero-sum. Returns the
+// planned secrets and the target's secret_prefix and secret_name_template.
+func planImport(acct ImportAccount, region string, secrets map[string]map[string]interface{}, mappings []PathMapping) ([]ImportedSecret, string, string) {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var planned []ImportedSecret
+	byPath := make(map[string]string)
+	used := make(map[int]bool)
+	for _, name := range names {
+		vaultPath, rule := mapSecretName(name, mappings)
+		s := ImportedSecret{Name: name, VaultPath: vaultPath}
+		switch other, taken := byPath[vaultPath]; {
+		case !validVaultPath(vaultPath):
+			s.Action, s.Reason = ImportSkipped, "mapped to an invalid Vault path"
+		case taken:
+			s.Action, s.Reason = ImportSkipped, fmt.Sprintf("maps to the same Vault path as %s", other)
+		default:
+			byPath[vaultPath] = name
+			used[rule] = true
+		}
+		planned = append(planned, s)
+	}
+
+	prefix, nameTemplate := reverseMappings(mappings, used)
+	for i := range planned {
+		s := &planned[i]
+		if s.Action == ImportSkipped {
+			continue
+		}
+		data := newSecretNameData(acct.Name, acct.AccountID, region, s.VaultPath)
+		if got, err := renderSecretName(prefix, nameTemplate, data); err != nil || got != s.Name {
+			s.Action, s.Reason = ImportSkipped, "path mappings are ambiguous for this name; the starter config could not sync it back"
+		}
+	}
+	return planned, prefix, nameTemplate
+}
+
+// reverseMappings returns a secret_prefix and secret_name_template that turn
+// Vault paths back into AWS names for the mappings in use. used holds the
+// indexes of applied mappings, and -1 if some names matched no mapping.
+func reverseMappings(mappings []PathMapping, used map[int]bool) (string, string) {
+	var rules []PathMapping
+	for i, m := range mappings {
+		if used[i] {
+			rules = append(rules, m)
+		}
+	}
+	switch {
+	case len(rules) == 0:
+		return "", ""
+	case len(rules) == 1 && rules[0].To == "" && !used[-1]:
+		// Every name had the same prefix stripped: restore it with secret_prefix
+		return rules[0].From, ""
+	}
+
+	// Longer Vault prefixes are tested first so nested prefixes map back correctly
+	sort.SliceStable(rules, func(i, j int) bool { return len(rules[i].To) > len(rules[j].To) })
+	var sb strings.Builder
+	fallback := "{{.Path}}"
+	for _, m := range rules {
+		if m.To == "" {
+			if !used[-1] {
+				fallback = m.From + "{{.Path}}"
+			}
+			continue
+		}
+		if sb.Len() == 0 {
+			sb.WriteString("{{if ")
+		} else {
+			sb.WriteString("{{else if ")
+		}
+		n := len(m.To)
+		sb.WriteString(fmt.Sprintf("and (ge (len .Path) %d) (eq (slice .Path 0 %d) %q)}}%s{{slice .Path %d}}", n, n, m.To, m.From, n))
+	}
+	if sb.Len() == 0 {
+		if fallback == "{{.Path}}" {
+			return "", ""
+		}
+		return "", fallback
+	}
+	sb.WriteString("{{else}}" + fallback + "{{end}}")
+	return "", sb.String()
+}
+
+// applyImportedSecret compares one secret with Vault and writes it unless
+// the report is a dry run. New secrets are written with cas=0 and existing
+// ones with their current version, so concurrent writers are never overwritten.
+func applyImportedSecret(ctx context.Context, client importVault, basePath string, s *ImportedSecret, data map[string]interface{}, opts ImportOptions) {
+	fullPath := basePath + "/" + s.VaultPath
+
+	existing, version, err := client.GetKVSecretVersionOnce(ctx, fullPath)
+	switch {
+	case err != nil && !isVaultNotFound(err):
+		s.Action, s.Error = ImportSkipped, fmt.Sprintf("failed to read Vault secret: %v", err)
+		return
+	case err != nil:
+		s.Action, version = ImportCreate, 0
+	case utils.DeepEqual(existing, data):
+		s.Action = ImportUnchanged
+		return
+	case !opts.Overwrite:
+		s.Action = ImportConflict
+		return
+	default:
+		s.Action = ImportUpdate
+	}
+
+	if opts.DryRun {
+		return
+	}
+	if _, err := client.WriteSecretOnce(ctx, fullPath, data, &version); err != nil {
+		s.Error = fmt.Sprintf("failed to write Vault secret: %v", err)
+	}
+}
+
+// isVaultNotFound reports whether a KV read failed because the secret does not exist
+func isVaultNotFound(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "secret not found") || strings.HasPrefix(msg, "secret data not found")
+}
+
+// importedSourceName returns the starter config's source name for an account
+func importedSourceName(accountName string) string {
+	return accountName + "-imported"
+}
+
+// starterConfig returns an empty configuration with the pipeline's Vault
+// connection, AWS settings and merge store. Vault credentials are left out,
+// and values resolved from references are kept as the references.
+func (p *Pipeline) starterConfig() (*Config, error) {
+	return p.config.withRawRefs(&Config{
+		Vault: VaultConfig{
+			Address:   p.config.Vault.Address,
+			Namespace: p.config.Vault.Namespace,
+		},
+		AWS:        p.config.AWS,
+		MergeStore: p.config.MergeStore,
+		Sources:    make(map[string]Source),
+		Targets:    make(map[string]Target),
+	})
+}
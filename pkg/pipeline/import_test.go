@@ -0,0 +1,230 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImportAccount(t *testing.T) {
+	acct, err := ParseImportAccount("Analytics_Prod=123456789012")
+	require.NoError(t, err)
+	assert.Equal(t, ImportAccount{Name: "Analytics_Prod", AccountID: "123456789012"}, acct)
+
+	acct, err = ParseImportAccount("Analytics_Dev=210987654321/eu-west-1")
+	require.NoError(t, err)
+	assert.Equal(t, ImportAccount{Name: "Analytics_Dev", AccountID: "210987654321", Region: "eu-west-1"}, acct)
+
+	for _, spec := range []string{"123456789012", "=123456789012", "Prod=1234", "a:b=123456789012"} {
+		_, err := ParseImportAccount(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestParsePathMapping(t *testing.T) {
+	m, err := ParsePathMapping("prod/=")
+	require.NoError(t, err)
+	assert.Equal(t, PathMapping{From: "prod/"}, m)
+
+	m, err = ParsePathMapping("legacy/app/=app/")
+	require.NoError(t, err)
+	assert.Equal(t, PathMapping{From: "legacy/app/", To: "app/"}, m)
+
+	for _, spec := range []string{"prod/", "=app/", "pr{od=app", "prod/=/app"} {
+		_, err := ParsePathMapping(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	acct := ImportAccount{Name: "Analytics_Prod", AccountID: "123456789012"}
+	secret := map[string]interface{}{"password": "x"}
+
+	tests := []struct {
+		name       string
+		secrets    []string
+		mappings   []PathMapping
+		wantPaths  map[string]string // AWS name -> Vault path; missing names are skipped
+		wantPrefix string
+	}{
+		{
+			name:      "no mappings",
+			secrets:   []string{"db", "api/stripe"},
+			wantPaths: map[string]string{"db": "db", "api/stripe": "api/stripe"},
+		},
+		{
+			name:       "stripped prefix",
+			secrets:    []string{"prod/db", "prod/api/stripe"},
+			mappings:   []PathMapping{{From: "prod/"}},
+			wantPaths:  map[string]string{"prod/db": "db", "prod/api/stripe": "api/stripe"},
+			wantPrefix: "prod/",
+		},
+		{
+			name:     "renamed prefixes and unmapped names",
+			secrets:  []string{"legacy/app/db", "legacy/app/config/main", "shared/db", "other"},
+			mappings: []PathMapping{{From: "legacy/app/", To: "app/"}, {From: "shared/", To: "common/shared/"}},
+			wantPaths: map[string]string{
+				"legacy/app/db":          "app/db",
+				"legacy/app/config/main": "app/config/main",
+				"shared/db":              "common/shared/db",
+				"other":                  "other",
+			},
+		},
+		{
+			name:      "ambiguous reverse mapping",
+			secrets:   []string{"prod/db", "db2"},
+			mappings:  []PathMapping{{From: "prod/"}},
+			wantPaths: map[string]string{"db2": "db2"},
+		},
+		{
+			name:      "shared and invalid Vault paths",
+			secrets:   []string{"a/db", "b/db", "a//x"},
+			mappings:  []PathMapping{{From: "a/", To: "app/"}, {From: "b/", To: "app/"}},
+			wantPaths: map[string]string{"a/db": "app/db"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secrets := make(map[string]map[string]interface{})
+			for _, name := range tt.secrets {
+				secrets[name] = secret
+			}
+			planned, prefix, nameTemplate := planImport(acct, "us-east-1", secrets, tt.mappings)
+			require.Len(t, planned, len(tt.secrets))
+			assert.Equal(t, tt.wantPrefix, prefix)
+
+			got := map[string]string{}
+			for _, s := range planned {
+				if s.Action == ImportSkipped {
+					assert.NotEmpty(t, s.Reason, s.Name)
+					continue
+				}
+				got[s.Name] = s.VaultPath
+
+				// The starter target's naming maps each Vault path back to its AWS name
+				name, err := renderSecretName(prefix, nameTemplate, newSecretNameData(acct.Name, acct.AccountID, "us-east-1", s.VaultPath))
+				require.NoError(t, err)
+				assert.Equal(t, s.Name, name)
+			}
+			assert.Equal(t, tt.wantPaths, got)
+			assert.NoError(t, validateSecretNaming(prefix, nameTemplate))
+		})
+	}
+}
+
+// fakeImportVault is an in-memory KV2 mount with check-and-set writes
+type fakeImportVault struct {
+	secrets  map[string]map[string]interface{}
+	versions map[string]int
+	writes   []string
+}
+
+func (f *fakeImportVault) GetKVSecretVersionOnce(_ context.Context, path string) (map[string]interface{}, int, error) {
+	data, ok := f.secrets[path]
+	if !ok {
+		return nil, 0, errors.New("secret not found: " + path)
+	}
+	return data, f.versions[path], nil
+}
+
+func (f *fakeImportVault) WriteSecretOnce(_ context.Context, path string, data map[string]interface{}, cas *int) (map[string]interface{}, error) {
+	if cas == nil || *cas != f.versions[path] {
+		return nil, errors.New("check-and-set parameter did not match the current version")
+	}
+	f.secrets[path] = data
+	f.versions[path]++
+	f.writes = append(f.writes, path)
+	return nil, nil
+}
+
+func (f *fakeImportVault) ListSecrets(_ context.Context, path string) ([]string, error) {
+	var paths []string
+	for p := range f.secrets {
+		if strings.HasPrefix(p, path+"/") {
+			paths = append(paths, p)
+		}
+	}
+	return paths, nil
+}
+
+func TestStarterExclusions(t *testing.T) {
+	existing := []string{"teams/Prod/same", "teams/Prod/changed", "teams/Prod/manual/odd*name", "teams/Prod/failed"}
+	secrets := []ImportedSecret{
+		{Name: "same", VaultPath: "same", Action: ImportUnchanged},
+		{Name: "new", VaultPath: "new", Action: ImportCreate},
+		{Name: "changed", VaultPath: "changed", Action: ImportConflict},
+		{Name: "failed", VaultPath: "failed", Action: ImportUpdate, Error: "failed to write Vault secret"},
+		{Name: "raced", VaultPath: "raced", Action: ImportCreate, Error: "check-and-set"},
+		{Name: "binary", VaultPath: "binary", Action: ImportSkipped, Reason: "not a JSON object"},
+	}
+
+	excluded := starterExclusions("teams/Prod", existing, secrets)
+	assert.Equal(t, []string{"changed", "failed", "manual/odd*name", "raced"}, excluded)
+
+	// The escaped patterns exclude exactly those paths from the starter target
+	exclude := make([]string, len(excluded))
+	for i, p := range excluded {
+		exclude[i] = escapePathGlob(p)
+	}
+	transformer, err := newSecretTransformer(Target{Filters: &TargetFilters{Path: &PatternFilter{Exclude: exclude}}})
+	require.NoError(t, err)
+	synced, err := transformer.apply(map[string]map[string]interface{}{
+		"same":            {"k": "v"},
+		"new":             {"k": "v"},
+		"changed":         {"k": "v"},
+		"manual/odd*name": {"k": "v"},
+		"manual/oddXname": {"k": "v"},
+	})
+	require.NoError(t, err)
+	assert.Len(t, synced, 3)
+	assert.Contains(t, synced, "manual/oddXname", "escaped globs match literally")
+	assert.NotContains(t, synced, "changed")
+}
+
+func TestApplyImportedSecret(t *testing.T) {
+	newVault := func() *fakeImportVault {
+		return &fakeImportVault{
+			secrets: map[string]map[string]interface{}{
+				"teams/Prod/same":    {"user": "admin"},
+				"teams/Prod/changed": {"user": "old"},
+			},
+			versions: map[string]int{"teams/Prod/same": 1, "teams/Prod/changed": 3},
+		}
+	}
+	apply := func(v *fakeImportVault, path string, data map[string]interface{}, opts ImportOptions) ImportedSecret {
+		s := ImportedSecret{Name: path, VaultPath: path}
+		applyImportedSecret(context.Background(), v, "teams/Prod", &s, data, opts)
+		return s
+	}
+
+	v := newVault()
+	assert.Equal(t, ImportCreate, apply(v, "new", map[string]interface{}{"k": "v"}, ImportOptions{}).Action)
+	assert.Equal(t, ImportUnchanged, apply(v, "same", map[string]interface{}{"user": "admin"}, ImportOptions{}).Action)
+	assert.Equal(t, ImportConflict, apply(v, "changed", map[string]interface{}{"user": "new"}, ImportOptions{}).Action)
+	assert.Equal(t, []string{"teams/Prod/new"}, v.writes)
+	assert.Equal(t, "old", v.secrets["teams/Prod/changed"]["user"], "conflicts are not overwritten")
+
+	s := apply(v, "changed", map[string]interface{}{"user": "new"}, ImportOptions{Overwrite: true})
+	assert.Equal(t, ImportUpdate, s.Action)
+	assert.Empty(t, s.Error, "written with the current version as cas")
+	assert.Equal(t, "new", v.secrets["teams/Prod/changed"]["user"])
+
+	v = newVault()
+	assert.Equal(t, ImportCreate, apply(v, "new", map[string]interface{}{"k": "v"}, ImportOptions{DryRun: true}).Action)
+	assert.Equal(t, ImportUpdate, apply(v, "changed", map[string]interface{}{"user": "new"}, ImportOptions{DryRun: true, Overwrite: true}).Action)
+	assert.Empty(t, v.writes, "dry runs never write")
+
+	// A secret created since it was read fails the check-and-set
+	v = newVault()
+	v.versions["teams/Prod/raced"] = 1
+	s = apply(v, "raced", map[string]interface{}{"k": "v"}, ImportOptions{})
+	assert.Equal(t, ImportCreate, s.Action)
+	assert.Contains(t, s.Error, "check-and-set")
+
+	report := &ImportReport{Accounts: []AccountImport{{Secrets: []ImportedSecret{s}}}}
+	assert.True(t, report.Failed())
+}
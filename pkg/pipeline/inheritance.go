@@ -34,7 +34,8 @@ func (c *Config) detectCycle(targetName string, visited, recursionStack map[stri
 	path = append(path, targetName)
 
 	if target, ok := c.Targets[targetName]; ok {
-		for _, imp := range target.Imports {
+		for _, spec := range target.Imports {
+			imp := importSourceName(spec)
 			if _, isTarget := c.Targets[imp]; isTarget {
 				// Self-reference is also a cycle
 				if imp == targetName {
@@ -88,19 +89,29 @@ func (c *Config) IsInheritedTarget(targetName string) bool {
 		return false
 	}
 	for _, imp := range target.Imports {
-		if _, isTarget := c.Targets[imp]; isTarget {
+		if _, isTarget := c.Targets[importSourceName(imp)]; isTarget {
 			return true
 		}
 	}
 	return false
 }
 
-// GetSourcePath returns the full path for a source or inherited target
+// GetSourcePath returns the full path for a source or inherited target.
+// Import selectors are kept as a suffix (e.g. "analytics:/db/*") so that a
+// different selection of the same source produces a different bundle ID.
+// Use ParseImport to get the source name for reading.
 func (c *Config) GetSourcePath(importName string) string {
+	if sel, err := ParseImport(importName); err == nil && sel.HasSelector() {
+		return c.GetSourcePath(sel.Source) + sel.Suffix()
+	}
+
 	if src, ok := c.Sources[importName]; ok {
 		if src.Vault != nil {
 			return src.Vault.Mount
 		}
+		if src.AWS != nil {
+			return awsSourcePath(src.AWS, c.AWS.Region)
+		}
 	}
 
 	if _, ok := c.Targets[importName]; ok {
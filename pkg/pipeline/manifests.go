@@ -0,0 +1,702 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kubernetes operators whose manifests MigrateManifests converts
+const (
+	ManifestsExternalSecrets      = "external-secrets"
+	ManifestsVaultSecretsOperator = "vault-secrets-operator"
+)
+
+// MigrationIssue is part of a manifest that could not be mapped to the
+// pipeline configuration, or was mapped with different behavior
+type MigrationIssue struct {
+	File     string `json:"file"`
+	Kind     string `json:"kind"`
+	Resource string `json:"resource"`        // namespace/name
+	Field    string `json:"field,omitempty"` // Manifest field, e.g. spec.data[0].remoteRef
+	Message  string `json:"message"`
+}
+
+// String formats the issue as file: Kind namespace/name field: message
+func (i MigrationIssue) String() string {
+	field := ""
+	if i.Field != "" {
+		field = " " + i.Field
+	}
+	return fmt.Sprintf("%s: %s %s%s: %s", i.File, i.Kind, i.Resource, field, i.Message)
+}
+
+// ManifestMigration is the result of MigrateManifests
+type ManifestMigration struct {
+	Sources map[string]Source
+	Targets map[string]Target
+	// VaultAddress and VaultNamespace are the Vault connection of the first
+	// mapped Vault source. The pipeline reads every Vault source through it.
+	VaultAddress   string
+	VaultNamespace string
+	// Converted is the number of ExternalSecret or VaultStaticSecret manifests mapped to targets
+	Converted int
+	Issues    []MigrationIssue
+}
+
+// manifest is a Kubernetes object with its spec left undecoded
+type manifest struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Spec yaml.Node `yaml:"spec"`
+
+	file string
+}
+
+// resource returns the object's namespace/name, or its name for cluster-scoped objects
+func (m *manifest) resource() string {
+	if m.Metadata.Namespace == "" {
+		return m.Metadata.Name
+	}
+	return m.Metadata.Namespace + "/" + m.Metadata.Name
+}
+
+// namespace returns the object's namespace, defaulting to "default"
+func (m *manifest) namespace() string {
+	if m.Metadata.Namespace == "" {
+		return "default"
+	}
+	return m.Metadata.Namespace
+}
+
+// manifestMigrator accumulates the mapped configuration and issues
+type manifestMigrator struct {
+	result *ManifestMigration
+	// vaultSources maps a Vault address, namespace and mount to its source name
+	vaultSources map[string]string
+}
+
+// MigrateManifests converts External Secrets Operator (ExternalSecret,
+// SecretStore, ClusterSecretStore) or Vault Secrets Operator
+// (VaultStaticSecret, VaultAuth, VaultConnection) manifests into pipeline
+// sources and targets. paths are YAML files or directories searched
+// recursively. Each ExternalSecret or VaultStaticSecret becomes a target
+// named namespace-secret, without an account_id. Everything that cannot be
+// mapped exactly is returned as an issue rather than dropped.
+func MigrateManifests(paths []string, from string) (*ManifestMigration, error) {
+	if from != ManifestsExternalSecrets && from != ManifestsVaultSecretsOperator {
+		return nil, fmt.Errorf("unsupported manifest source %q (must be %s or %s)", from, ManifestsExternalSecrets, ManifestsVaultSecretsOperator)
+	}
+	manifests, err := loadManifests(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &manifestMigrator{
+		result: &ManifestMigration{
+			Sources: make(map[string]Source),
+			Targets: make(map[string]Target),
+		},
+		vaultSources: make(map[string]string),
+	}
+	if from == ManifestsExternalSecrets {
+		m.migrateExternalSecrets(manifests)
+	} else {
+		m.migrateVaultStaticSecrets(manifests)
+	}
+	return m.result, nil
+}
+
+// loadManifests reads every YAML document in the given files and directories
+func loadManifests(paths []string) ([]*manifest, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		err = filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if ext := filepath.Ext(path); !d.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no YAML manifests found in %s", strings.Join(paths, ", "))
+	}
+
+	var manifests []*manifest
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		dec := yaml.NewDecoder(f)
+		for {
+			var obj manifest
+			err := dec.Decode(&obj)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+			if obj.Kind == "" {
+				continue
+			}
+			obj.file = file
+			manifests = append(manifests, &obj)
+		}
+		f.Close()
+	}
+	return manifests, nil
+}
+
+// issue records a migration issue for a manifest
+func (m *manifestMigrator) issue(obj *manifest, field, format string, args ...interface{}) {
+	m.result.Issues = append(m.result.Issues, MigrationIssue{
+		File:     obj.file,
+		Kind:     obj.Kind,
+		Resource: obj.resource(),
+		Field:    field,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// addVaultSource returns the source for a Vault KV2 mount, adding it on first use.
+// Vault sources are all read through one connection, so mounts on another
+// address or namespace than the first are reported.
+func (m *manifestMigrator) addVaultSource(obj *manifest, field, name, address, namespace, mount string) string {
+	mount = strings.Trim(mount, "/")
+	key := address + "|" + namespace + "|" + mount
+	if existing, ok := m.vaultSources[key]; ok {
+		return existing
+	}
+
+	if len(m.vaultSources) == 0 {
+		m.result.VaultAddress, m.result.VaultNamespace = address, namespace
+	} else if address != m.result.VaultAddress || namespace != m.result.VaultNamespace {
+		m.issue(obj, field, "Vault %s (namespace %q) differs from %s (namespace %q); the pipeline reads all Vault sources through vault.address",
+			address, namespace, m.result.VaultAddress, m.result.VaultNamespace)
+	}
+
+	name = m.uniqueSourceName(name)
+	m.vaultSources[key] = name
+	m.result.Sources[name] = Source{Vault: &VaultSource{Address: address, Namespace: namespace, Mount: mount}}
+	return name
+}
+
+// uniqueSourceName returns name, or name with a numeric suffix if a source already uses it
+func (m *manifestMigrator) uniqueSourceName(name string) string {
+	unique := name
+	for i := 2; ; i++ {
+		if _, taken := m.result.Sources[unique]; !taken {
+			return unique
+		}
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+// addTarget adds a target for a manifest unless its name is already used
+func (m *manifestMigrator) addTarget(obj *manifest, name string, target Target) {
+	if len(target.Imports) == 0 {
+		m.issue(obj, "", "no data could be mapped; no target was created")
+		return
+	}
+	if _, exists := m.result.Targets[name]; exists {
+		m.issue(obj, "", "target %s already exists for another manifest; this one was not converted", name)
+		return
+	}
+	m.result.Targets[name] = target
+	m.result.Converted++
+}
+
+// esoStoreSpec is the spec of an External Secrets Operator SecretStore or ClusterSecretStore
+type esoStoreSpec struct {
+	Provider map[string]yaml.Node `yaml:"provider"`
+}
+
+type esoVaultProvider struct {
+	Server    string `yaml:"server"`
+	Path      string `yaml:"path"`
+	Version   string `yaml:"version"`
+	Namespace string `yaml:"namespace"`
+}
+
+type esoAWSProvider struct {
+	Service string `yaml:"service"`
+	Region  string `yaml:"region"`
+	Role    string `yaml:"role"`
+}
+
+type esoStoreRef struct {
+	Name string `yaml:"name"`
+	Kind string `yaml:"kind"`
+}
+
+type esoRemoteRef struct {
+	Key                string `yaml:"key"`
+	Property           string `yaml:"property"`
+	Version            string `yaml:"version"`
+	DecodingStrategy   string `yaml:"decodingStrategy"`
+	ConversionStrategy string `yaml:"conversionStrategy"`
+	MetadataPolicy     string `yaml:"metadataPolicy"`
+}
+
+type esoSourceRef struct {
+	StoreRef     *esoStoreRef `yaml:"storeRef"`
+	GeneratorRef *yaml.Node   `yaml:"generatorRef"`
+}
+
+// esoExternalSecretSpec is the spec of an ExternalSecret
+type esoExternalSecretSpec struct {
+	SecretStoreRef esoStoreRef `yaml:"secretStoreRef"`
+	Target         struct {
+		Name     string     `yaml:"name"`
+		Template *yaml.Node `yaml:"template"`
+	} `yaml:"target"`
+	Data []struct {
+		SecretKey string        `yaml:"secretKey"`
+		RemoteRef esoRemoteRef  `yaml:"remoteRef"`
+		SourceRef *esoSourceRef `yaml:"sourceRef"`
+	} `yaml:"data"`
+	DataFrom []struct {
+		Extract *esoRemoteRef `yaml:"extract"`
+		Find    *struct {
+			Path *string `yaml:"path"`
+			Name *struct {
+				Regexp string `yaml:"regexp"`
+			} `yaml:"name"`
+			Tags map[string]string `yaml:"tags"`
+		} `yaml:"find"`
+		Rewrite   []yaml.Node   `yaml:"rewrite"`
+		SourceRef *esoSourceRef `yaml:"sourceRef"`
+	} `yaml:"dataFrom"`
+}
+
+// roleAccountPattern extracts the account ID of an IAM role ARN
+var roleAccountPattern = regexp.MustCompile(`^arn:aws[a-z-]*:iam::(\d{12}):role/`)
+
+// storeKey identifies a SecretStore (namespaced) or ClusterSecretStore
+func storeKey(kind, namespace, name string) string {
+	if kind == "ClusterSecretStore" {
+		return kind + "/" + name
+	}
+	return "SecretStore/" + namespace + "/" + name
+}
+
+// migrateExternalSecrets maps secret stores to sources and ExternalSecrets to targets
+func (m *manifestMigrator) migrateExternalSecrets(manifests []*manifest) {
+	// Store keys to source names; stores that cannot be mapped are absent
+	stores := make(map[string]string)
+	for _, obj := range manifests {
+		if obj.Kind != "SecretStore" && obj.Kind != "ClusterSecretStore" {
+			continue
+		}
+		if name := m.migrateSecretStore(obj); name != "" {
+			stores[storeKey(obj.Kind, obj.namespace(), obj.Metadata.Name)] = name
+		}
+	}
+
+	for _, obj := range manifests {
+		switch obj.Kind {
+		case "ExternalSecret":
+			m.migrateExternalSecret(obj, stores)
+		case "ClusterExternalSecret", "PushSecret":
+			m.issue(obj, "", "%s is not supported; convert the ExternalSecrets it creates instead", obj.Kind)
+		}
+	}
+}
+
+// migrateSecretStore maps a Vault KV2 or AWS Secrets Manager store to a
+// source and returns the source name, or "" if the store cannot be mapped
+func (m *manifestMigrator) migrateSecretStore(obj *manifest) string {
+	var spec esoStoreSpec
+	if err := obj.Spec.Decode(&spec); err != nil {
+		m.issue(obj, "spec", "failed to decode: %v", err)
+		return ""
+	}
+
+	name := obj.Metadata.Name
+	if obj.Kind == "SecretStore" {
+		name = obj.namespace() + "-" + name
+	}
+
+	if node, ok := spec.Provider["vault"]; ok {
+		var vp esoVaultProvider
+		if err := node.Decode(&vp); err != nil {
+			m.issue(obj, "spec.provider.vault", "failed to decode: %v", err)
+			return ""
+		}
+		if vp.Version == "v1" {
+			m.issue(obj, "spec.provider.vault.version", "KV version 1 mounts are not supported; only KV2 sources can be read")
+			return ""
+		}
+		if strings.Trim(vp.Path, "/") == "" {
+			m.issue(obj, "spec.provider.vault.path", "no mount path; keys that include their mount cannot be mapped to a source")
+			return ""
+		}
+		return m.addVaultSource(obj, "spec.provider.vault", name, vp.Server, vp.Namespace, vp.Path)
+	}
+
+	if node, ok := spec.Provider["aws"]; ok {
+		var ap esoAWSProvider
+		if err := node.Decode(&ap); err != nil {
+			m.issue(obj, "spec.provider.aws", "failed to decode: %v", err)
+			return ""
+		}
+		if ap.Service != "" && ap.Service != "SecretsManager" {
+			m.issue(obj, "spec.provider.aws.service", "%s is not supported; only Secrets Manager sources can be read", ap.Service)
+			return ""
+		}
+		src := &AWSSource{Region: ap.Region}
+		if match := roleAccountPattern.FindStringSubmatch(ap.Role); match != nil {
+			src.AccountID = match[1]
+		} else {
+			m.issue(obj, "spec.provider.aws", "no role with an account ID; set the source's account_id")
+		}
+		name = m.uniqueSourceName(name)
+		m.result.Sources[name] = Source{AWS: src}
+		return name
+	}
+
+	providers := make([]string, 0, len(spec.Provider))
+	for p := range spec.Provider {
+		providers = append(providers, p)
+	}
+	sort.Strings(providers)
+	m.issue(obj, "spec.provider", "provider %s is not supported; only vault and aws can be mapped to sources", strings.Join(providers, ", "))
+	return ""
+}
+
+// esoImport accumulates the keys selected from one remote secret
+type esoImport struct {
+	source string
+	key    string
+	keys   []string
+	whole  bool
+}
+
+// migrateExternalSecret maps an ExternalSecret to a target
+func (m *manifestMigrator) migrateExternalSecret(obj *manifest, stores map[string]string) {
+	var spec esoExternalSecretSpec
+	if err := obj.Spec.Decode(&spec); err != nil {
+		m.issue(obj, "spec", "failed to decode: %v", err)
+		return
+	}
+
+	// resolveStore returns the source of a store reference, or "" after reporting why not
+	resolveStore := func(field string, ref *esoSourceRef) string {
+		storeRef := spec.SecretStoreRef
+		if ref != nil {
+			if ref.GeneratorRef != nil {
+				m.issue(obj, field+".sourceRef.generatorRef", "generators are not supported")
+				return ""
+			}
+			if ref.StoreRef != nil {
+				storeRef = *ref.StoreRef
+			}
+		}
+		key := storeKey(storeRef.Kind, obj.namespace(), storeRef.Name)
+		source, ok := stores[key]
+		if !ok {
+			m.issue(obj, field, "store %s is missing or could not be mapped", strings.TrimPrefix(key, "SecretStore/"))
+		}
+		return source
+	}
+
+	var imports []*esoImport
+	importFor := func(source, key string) *esoImport {
+		for _, imp := range imports {
+			if imp.source == source && imp.key == key {
+				return imp
+			}
+		}
+		imp := &esoImport{source: source, key: key}
+		imports = append(imports, imp)
+		return imp
+	}
+	target := Target{}
+	renames := make(map[string]string) // property -> Kubernetes secret key
+
+	for i, d := range spec.Data {
+		field := fmt.Sprintf("spec.data[%d]", i)
+		source := resolveStore(field, d.SourceRef)
+		if source == "" || !m.checkRemoteRef(obj, field+".remoteRef", d.RemoteRef) {
+			continue
+		}
+		if d.RemoteRef.Property == "" {
+			m.issue(obj, field+".remoteRef", "the whole secret becomes the single key %q; the pipeline syncs secrets as JSON objects", d.SecretKey)
+			continue
+		}
+		if strings.ContainsAny(d.RemoteRef.Property, ",#") {
+			m.issue(obj, field+".remoteRef.property", "property %q cannot be used in an import selector", d.RemoteRef.Property)
+			continue
+		}
+		if prev, ok := renames[d.RemoteRef.Property]; ok && prev != d.SecretKey {
+			m.issue(obj, field, "property %q is already renamed to %q; renames apply to every secret of a target", d.RemoteRef.Property, prev)
+			continue
+		}
+		renames[d.RemoteRef.Property] = d.SecretKey
+
+		imp := importFor(source, strings.Trim(d.RemoteRef.Key, "/"))
+		imp.keys = append(imp.keys, d.RemoteRef.Property)
+	}
+
+	for i, d := range spec.DataFrom {
+		field := fmt.Sprintf("spec.dataFrom[%d]", i)
+		if len(d.Rewrite) > 0 {
+			m.issue(obj, field+".rewrite", "key rewrites are not supported")
+			continue
+		}
+		source := resolveStore(field, d.SourceRef)
+		if source == "" {
+			continue
+		}
+		switch {
+		case d.Extract != nil:
+			if !m.checkRemoteRef(obj, field+".extract", *d.Extract) {
+				continue
+			}
+			if d.Extract.Property != "" {
+				m.issue(obj, field+".extract.property", "extracting a nested object is not supported")
+				continue
+			}
+			importFor(source, strings.Trim(d.Extract.Key, "/")).whole = true
+		case d.Find != nil:
+			if d.Find.Name != nil || len(d.Find.Tags) > 0 {
+				m.issue(obj, field+".find", "finding secrets by name or tags is not supported; only find.path can be mapped")
+				continue
+			}
+			path := "**"
+			if d.Find.Path != nil && strings.Trim(*d.Find.Path, "/") != "" {
+				path = strings.Trim(*d.Find.Path, "/") + "/**"
+			}
+			importFor(source, path).whole = true
+		default:
+			m.issue(obj, field, "only extract and find can be mapped")
+		}
+	}
+
+	for _, imp := range imports {
+		if strings.ContainsAny(strings.TrimSuffix(strings.TrimSuffix(imp.key, "**"), "/"), "*?[") {
+			m.issue(obj, "", "key %q contains glob characters and cannot be selected exactly", imp.key)
+			continue
+		}
+		sel := ImportSelector{Source: imp.source, Path: imp.key}
+		if sel.Path == "**" {
+			sel.Path = ""
+		}
+		if !imp.whole {
+			sel.Keys = imp.keys
+		}
+		target.Imports = append(target.Imports, sel.String())
+	}
+
+	properties := make([]string, 0, len(renames))
+	for property := range renames {
+		properties = append(properties, property)
+	}
+	sort.Strings(properties)
+	for _, property := range properties {
+		if to := renames[property]; to != "" && to != property {
+			if target.Transforms == nil {
+				target.Transforms = &TargetTransforms{}
+			}
+			target.Transforms.Rename = append(target.Transforms.Rename, RenameTransform{From: property, To: to})
+		}
+	}
+
+	if spec.Target.Template != nil {
+		m.issue(obj, "spec.target.template", "templates are not converted; use transforms.template, which must render a JSON object")
+	}
+
+	name := spec.Target.Name
+	if name == "" {
+		name = obj.Metadata.Name
+	}
+	m.addTarget(obj, obj.namespace()+"-"+name, target)
+}
+
+// checkRemoteRef reports remote reference options the pipeline does not
+// support. Returns false if the reference cannot be mapped.
+func (m *manifestMigrator) checkRemoteRef(obj *manifest, field string, ref esoRemoteRef) bool {
+	if strings.Trim(ref.Key, "/") == "" {
+		m.issue(obj, field+".key", "key is required")
+		return false
+	}
+	if ref.DecodingStrategy != "" && ref.DecodingStrategy != "None" {
+		m.issue(obj, field+".decodingStrategy", "decoding strategy %s is not supported", ref.DecodingStrategy)
+		return false
+	}
+	if ref.ConversionStrategy != "" && ref.ConversionStrategy != "Default" {
+		m.issue(obj, field+".conversionStrategy", "conversion strategy %s is not supported", ref.ConversionStrategy)
+		return false
+	}
+	if ref.MetadataPolicy == "Fetch" {
+		m.issue(obj, field+".metadataPolicy", "fetching secret metadata is not supported")
+		return false
+	}
+	if ref.Version != "" {
+		m.issue(obj, field+".version", "pinned version %s is ignored; the pipeline reads the latest version", ref.Version)
+	}
+	return true
+}
+
+// vsoConnectionSpec is the spec of a Vault Secrets Operator VaultConnection
+type vsoConnectionSpec struct {
+	Address string `yaml:"address"`
+}
+
+type vsoAuthSpec struct {
+	VaultConnectionRef string `yaml:"vaultConnectionRef"`
+	Namespace          string `yaml:"namespace"`
+}
+
+// vsoStaticSecretSpec is the spec of a VaultStaticSecret
+type vsoStaticSecretSpec struct {
+	VaultAuthRef string `yaml:"vaultAuthRef"`
+	Namespace    string `yaml:"namespace"`
+	Mount        string `yaml:"mount"`
+	Type         string `yaml:"type"`
+	Path         string `yaml:"path"`
+	Version      int    `yaml:"version"`
+	Destination  struct {
+		Name           string `yaml:"name"`
+		Transformation struct {
+			Templates          map[string]yaml.Node `yaml:"templates"`
+			TransformationRefs []yaml.Node          `yaml:"transformationRefs"`
+			Includes           []string             `yaml:"includes"`
+			Excludes           []string             `yaml:"excludes"`
+		} `yaml:"transformation"`
+	} `yaml:"destination"`
+}
+
+// vsoRef resolves a VSO reference ("name" or "namespace/name") against a
+// default namespace. VSO falls back to the "default" object when the name is empty.
+func vsoRef(ref, namespace string) string {
+	if ref == "" {
+		return namespace + "/default"
+	}
+	if strings.Contains(ref, "/") {
+		return ref
+	}
+	return namespace + "/" + ref
+}
+
+// migrateVaultStaticSecrets maps VaultStaticSecrets to targets, reading the
+// Vault address and namespace from their VaultAuth and VaultConnection
+func (m *manifestMigrator) migrateVaultStaticSecrets(manifests []*manifest) {
+	connections := make(map[string]vsoConnectionSpec)
+	auths := make(map[string]vsoAuthSpec)
+	for _, obj := range manifests {
+		switch obj.Kind {
+		case "VaultConnection":
+			var spec vsoConnectionSpec
+			if err := obj.Spec.Decode(&spec); err != nil {
+				m.issue(obj, "spec", "failed to decode: %v", err)
+				continue
+			}
+			connections[obj.namespace()+"/"+obj.Metadata.Name] = spec
+		case "VaultAuth":
+			var spec vsoAuthSpec
+			if err := obj.Spec.Decode(&spec); err != nil {
+				m.issue(obj, "spec", "failed to decode: %v", err)
+				continue
+			}
+			auths[obj.namespace()+"/"+obj.Metadata.Name] = spec
+		}
+	}
+
+	for _, obj := range manifests {
+		switch obj.Kind {
+		case "VaultStaticSecret":
+			m.migrateVaultStaticSecret(obj, auths, connections)
+		case "VaultDynamicSecret", "VaultPKISecret", "HCPVaultSecretsApp":
+			m.issue(obj, "", "%s is not supported; only static KV secrets can be synced", obj.Kind)
+		}
+	}
+}
+
+// migrateVaultStaticSecret maps a VaultStaticSecret to a target importing one secret
+func (m *manifestMigrator) migrateVaultStaticSecret(obj *manifest, auths map[string]vsoAuthSpec, connections map[string]vsoConnectionSpec) {
+	var spec vsoStaticSecretSpec
+	if err := obj.Spec.Decode(&spec); err != nil {
+		m.issue(obj, "spec", "failed to decode: %v", err)
+		return
+	}
+	if spec.Type != "" && spec.Type != "kv-v2" {
+		m.issue(obj, "spec.type", "%s is not supported; only KV2 sources can be read", spec.Type)
+		return
+	}
+	mount, secretPath := strings.Trim(spec.Mount, "/"), strings.Trim(spec.Path, "/")
+	if mount == "" || secretPath == "" {
+		m.issue(obj, "spec", "mount and path are required")
+		return
+	}
+	if strings.ContainsAny(secretPath, "*?[#") {
+		m.issue(obj, "spec.path", "path %q cannot be selected exactly", secretPath)
+		return
+	}
+
+	var address string
+	namespace := spec.Namespace
+	authRef := vsoRef(spec.VaultAuthRef, obj.namespace())
+	if auth, ok := auths[authRef]; ok {
+		if namespace == "" {
+			namespace = auth.Namespace
+		}
+		authNamespace, _, _ := strings.Cut(authRef, "/")
+		if conn, ok := connections[vsoRef(auth.VaultConnectionRef, authNamespace)]; ok {
+			address = conn.Address
+		}
+	} else if spec.VaultAuthRef != "" {
+		m.issue(obj, "spec.vaultAuthRef", "VaultAuth %s not found; the source uses vault.address", authRef)
+	}
+
+	source := m.addVaultSource(obj, "spec", mount, address, namespace, mount)
+	target := Target{Imports: []string{ImportSelector{Source: source, Path: secretPath}.String()}}
+
+	if spec.Version != 0 {
+		m.issue(obj, "spec.version", "pinned version %d is ignored; the pipeline reads the latest version", spec.Version)
+	}
+	tr := spec.Destination.Transformation
+	if len(tr.Includes) > 0 || len(tr.Excludes) > 0 {
+		target.Transforms = &TargetTransforms{Include: tr.Includes, Exclude: tr.Excludes}
+	}
+	if len(tr.Templates) > 0 {
+		m.issue(obj, "spec.destination.transformation.templates", "templates are not converted; use transforms.template, which must render a JSON object")
+	}
+	if len(tr.TransformationRefs) > 0 {
+		m.issue(obj, "spec.destination.transformation.transformationRefs", "SecretTransformation references are not converted")
+	}
+
+	name := spec.Destination.Name
+	if name == "" {
+		name = obj.Metadata.Name
+	}
+	m.addTarget(obj, obj.namespace()+"-"+name, target)
+}
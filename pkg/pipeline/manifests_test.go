@@ -0,0 +1,259 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const esoManifests = `
+apiVersion: external-secrets.io/v1beta1
+kind: ClusterSecretStore
+metadata:
+  name: vault-backend
+spec:
+  provider:
+    vault:
+      server: https://vault.example.com
+      path: secret
+      version: v2
+      auth:
+        kubernetes: {role: eso}
+---
+apiVersion: external-secrets.io/v1beta1
+kind: SecretStore
+metadata:
+  name: aws
+  namespace: payments
+spec:
+  provider:
+    aws:
+      service: SecretsManager
+      region: eu-west-1
+      role: arn:aws:iam::123456789012:role/eso
+---
+apiVersion: external-secrets.io/v1beta1
+kind: SecretStore
+metadata:
+  name: gcp
+  namespace: payments
+spec:
+  provider:
+    gcpsm: {projectID: payments}
+---
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: api
+  namespace: payments
+spec:
+  secretStoreRef: {name: vault-backend, kind: ClusterSecretStore}
+  target: {name: api-credentials}
+  data:
+    - secretKey: DB_PASSWORD
+      remoteRef: {key: payments/db, property: password}
+    - secretKey: username
+      remoteRef: {key: payments/db, property: username}
+    - secretKey: STRIPE_KEY
+      remoteRef: {key: payments/stripe, property: key, version: "3"}
+    - secretKey: blob
+      remoteRef: {key: payments/blob}
+    - secretKey: cert
+      remoteRef: {key: payments/cert, property: pem, decodingStrategy: Base64}
+    - secretKey: region
+      sourceRef:
+        storeRef: {name: aws, kind: SecretStore}
+      remoteRef: {key: payments/config, property: region}
+  dataFrom:
+    - extract: {key: payments/shared}
+    - find:
+        path: payments/flags
+    - find:
+        name: {regexp: "^feature-"}
+    - extract: {key: payments/env}
+      rewrite:
+        - regexp: {source: "(.*)", target: "APP_$1"}
+---
+apiVersion: external-secrets.io/v1beta1
+kind: ExternalSecret
+metadata:
+  name: gcp
+  namespace: payments
+spec:
+  secretStoreRef: {name: gcp}
+  data:
+    - secretKey: token
+      remoteRef: {key: token}
+  target:
+    template:
+      data: {config: "{{ .token }}"}
+`
+
+const vsoManifests = `
+apiVersion: secrets.hashicorp.com/v1beta1
+kind: VaultConnection
+metadata:
+  name: default
+  namespace: vault-system
+spec:
+  address: https://vault.example.com
+---
+apiVersion: secrets.hashicorp.com/v1beta1
+kind: VaultAuth
+metadata:
+  name: default
+  namespace: apps
+spec:
+  method: kubernetes
+  namespace: team-a
+  vaultConnectionRef: vault-system/default
+---
+apiVersion: secrets.hashicorp.com/v1beta1
+kind: VaultStaticSecret
+metadata:
+  name: web
+  namespace: apps
+spec:
+  type: kv-v2
+  mount: kvv2
+  path: /web/config
+  version: 2
+  destination:
+    name: web-config
+    create: true
+    transformation:
+      includes: ["^DB_"]
+      excludes: ["_OLD$"]
+      templates:
+        url: {text: "postgres://{{ .Secrets.DB_HOST }}"}
+---
+apiVersion: secrets.hashicorp.com/v1beta1
+kind: VaultStaticSecret
+metadata:
+  name: legacy
+  namespace: apps
+spec:
+  type: kv-v1
+  mount: kv
+  path: legacy
+  destination: {name: legacy}
+---
+apiVersion: secrets.hashicorp.com/v1beta1
+kind: VaultDynamicSecret
+metadata:
+  name: db
+  namespace: apps
+spec:
+  mount: database
+  path: creds/app
+`
+
+func writeManifests(t *testing.T, content string) string {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nested", "secrets.yaml"), []byte(content), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a manifest"), 0644))
+	return dir
+}
+
+// issueFields returns the issues of a resource keyed by field
+func issueFields(issues []MigrationIssue, resource string) map[string]string {
+	fields := make(map[string]string)
+	for _, i := range issues {
+		if i.Resource == resource {
+			fields[i.Field] = i.Message
+		}
+	}
+	return fields
+}
+
+func TestMigrateManifests_ExternalSecrets(t *testing.T) {
+	dir := writeManifests(t, esoManifests)
+	m, err := MigrateManifests([]string{dir}, ManifestsExternalSecrets)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]Source{
+		"vault-backend": {Vault: &VaultSource{Address: "https://vault.example.com", Mount: "secret"}},
+		"payments-aws":  {AWS: &AWSSource{AccountID: "123456789012", Region: "eu-west-1"}},
+	}, m.Sources)
+	assert.Equal(t, "https://vault.example.com", m.VaultAddress)
+
+	require.Len(t, m.Targets, 1)
+	assert.Equal(t, 1, m.Converted)
+	target := m.Targets["payments-api-credentials"]
+	assert.Equal(t, []string{
+		"vault-backend:/payments/db#password,username",
+		"vault-backend:/payments/stripe#key",
+		"payments-aws:/payments/config#region",
+		"vault-backend:/payments/shared",
+		"vault-backend:/payments/flags/**",
+	}, target.Imports)
+	require.NotNil(t, target.Transforms)
+	assert.Equal(t, []RenameTransform{
+		{From: "key", To: "STRIPE_KEY"},
+		{From: "password", To: "DB_PASSWORD"},
+	}, target.Transforms.Rename)
+
+	api := issueFields(m.Issues, "payments/api")
+	assert.Contains(t, api["spec.data[2].remoteRef.version"], "pinned version 3 is ignored")
+	assert.Contains(t, api["spec.data[3].remoteRef"], "whole secret becomes the single key")
+	assert.Contains(t, api["spec.data[4].remoteRef.decodingStrategy"], "Base64")
+	assert.Contains(t, api["spec.dataFrom[2].find"], "by name or tags")
+	assert.Contains(t, api["spec.dataFrom[3].rewrite"], "rewrites")
+
+	assert.Contains(t, issueFields(m.Issues, "payments/gcp")["spec.provider"], "gcpsm is not supported")
+	gcp := issueFields(m.Issues, "payments/gcp")
+	assert.Contains(t, gcp["spec.data[0]"], "store payments/gcp is missing")
+	assert.Contains(t, gcp["spec.target.template"], "templates are not converted")
+	assert.Contains(t, gcp[""], "no target was created")
+	for _, i := range m.Issues {
+		assert.Equal(t, filepath.Join(dir, "nested", "secrets.yaml"), i.File)
+	}
+
+	// The converted targets form a valid configuration
+	cfg := &Config{Sources: m.Sources, Targets: m.Targets, MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}}}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestMigrateManifests_VaultSecretsOperator(t *testing.T) {
+	dir := writeManifests(t, vsoManifests)
+	m, err := MigrateManifests([]string{filepath.Join(dir, "nested", "secrets.yaml")}, ManifestsVaultSecretsOperator)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]Source{
+		"kvv2": {Vault: &VaultSource{Address: "https://vault.example.com", Namespace: "team-a", Mount: "kvv2"}},
+	}, m.Sources)
+	assert.Equal(t, "team-a", m.VaultNamespace)
+	assert.Equal(t, map[string]Target{
+		"apps-web-config": {
+			Imports:    []string{"kvv2:/web/config"},
+			Transforms: &TargetTransforms{Include: []string{"^DB_"}, Exclude: []string{"_OLD$"}},
+		},
+	}, m.Targets)
+
+	web := issueFields(m.Issues, "apps/web")
+	assert.Contains(t, web["spec.version"], "pinned version 2")
+	assert.Contains(t, web["spec.destination.transformation.templates"], "templates are not converted")
+	assert.Contains(t, issueFields(m.Issues, "apps/legacy")["spec.type"], "kv-v1 is not supported")
+	assert.Contains(t, issueFields(m.Issues, "apps/db")[""], "VaultDynamicSecret is not supported")
+	assert.Len(t, m.Issues, 4)
+
+	cfg := &Config{Sources: m.Sources, Targets: m.Targets}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestMigrateManifests_Errors(t *testing.T) {
+	_, err := MigrateManifests([]string{t.TempDir()}, ManifestsExternalSecrets)
+	assert.ErrorContains(t, err, "no YAML manifests")
+
+	_, err = MigrateManifests([]string{t.TempDir()}, "terraform")
+	assert.ErrorContains(t, err, "unsupported manifest source")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("kind: [unclosed"), 0644))
+	_, err = MigrateManifests([]string{dir}, ManifestsExternalSecrets)
+	assert.ErrorContains(t, err, "failed to parse")
+}
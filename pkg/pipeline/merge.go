@@ -3,10 +3,13 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"path"
+	"strings"
 	"time"
 
 	reqctx "github.com/jbcom/secretsync/pkg/context"
 	"github.com/jbcom/secretsync/pkg/client/vault"
+	"github.com/jbcom/secretsync/pkg/diff"
 	"github.com/jbcom/secretsync/pkg/utils"
 	log "github.com/sirupsen/logrus"
 )
@@ -73,78 +76,100 @@ func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bo
 		"sources":    sourcePaths,
 	}).Info("Starting merge")
 
-	// Initialize Vault client for reading sources
-	sourceClient := &vault.VaultClient{
-		Address:   p.config.Vault.Address,
-		Namespace: p.config.Vault.Namespace,
-	}
-	if err := sourceClient.Init(ctx); err != nil {
+	mergedSecrets, merger, failedSources, err := p.mergeSources(ctx, targetName, target, sourcePaths)
+	if err != nil {
 		return Result{
 			Target:   targetName,
 			Phase:    "merge",
 			Success:  false,
-			Error:    fmt.Errorf("failed to init source vault client: %w", err),
+			Error:    err,
 			Duration: time.Since(start),
 		}
 	}
 
-	// Merge all sources in sequence (later sources override earlier)
-	mergedSecrets := make(map[string]interface{})
-	var failedSources []string
+	// A bundle without the secrets of failed imports would be synced as if
+	// they had been removed (and deleted with delete_orphans), so it is not
+	// written and the previous bundle is kept
+	if len(failedSources) > 0 {
+		err := &SourceReadError{Target: targetName, Sources: failedSources}
+		l.WithError(err).Error("Merge incomplete, keeping the previous bundle")
+		return Result{
+			Target:    targetName,
+			Phase:     "merge",
+			Operation: string(OperationMerge),
+			Success:   false,
+			Error:     err,
+			Duration:  time.Since(start),
+			Details: ResultDetails{
+				SourcePaths:     sourcePaths,
+				DestinationPath: bundlePath,
+				FailedImports:   failedSources,
+			},
+		}
+	}
 
-	for i, sourcePath := range sourcePaths {
-		l.WithFields(log.Fields{
-			"source":   sourcePath,
-			"priority": i,
-		}).Debug("Processing source")
+	l.WithField("secretsCount", len(mergedSecrets)).Debug("Merge complete, writing to store")
 
-		// List all secrets in this source
-		secrets, err := sourceClient.ListSecrets(ctx, sourcePath)
-		if err != nil {
-			l.WithError(err).WithField("source", sourcePath).Warn("Failed to list secrets from source")
-			failedSources = append(failedSources, sourcePath)
-			continue
+	// Secrets failing their schemas never reach the merge store
+	if err := p.validateSecretSchemas(targetName, mergedSecrets); err != nil {
+		return Result{
+			Target:    targetName,
+			Phase:     "merge",
+			Operation: string(OperationMerge),
+			Success:   false,
+			Error:     err,
+			Duration:  time.Since(start),
+			Details: ResultDetails{
+				SecretsProcessed: len(mergedSecrets),
+				SourcePaths:      sourcePaths,
+				DestinationPath:  bundlePath,
+			},
 		}
+	}
 
-		// Read and merge each secret
-		for _, secretPath := range secrets {
-			secretData, err := sourceClient.GetKVSecretOnce(ctx, secretPath)
-			if err != nil {
-				l.WithError(err).WithField("secret", secretPath).Warn("Failed to read secret")
-				continue
-			}
-
-			// Relative path within this source
-			relPath := secretPath
-			if len(secretPath) > len(sourcePath) {
-				relPath = secretPath[len(sourcePath):]
-				if len(relPath) > 0 && relPath[0] == '/' {
-					relPath = relPath[1:]
-				}
-			}
+	provenance := newBundleProvenance(targetName, bundleID, sourcePaths, merger.provenance)
+	p.cacheProvenance(provenance)
 
-			// Deep merge into accumulated result (later sources win on conflict)
-			if existing, ok := mergedSecrets[relPath]; ok {
-				if existingMap, ok := existing.(map[string]interface{}); ok {
-					mergedSecrets[relPath] = utils.DeepMerge(existingMap, secretData)
-				} else {
-					// Not a map, just override
-					mergedSecrets[relPath] = secretData
-				}
-			} else {
-				mergedSecrets[relPath] = secretData
-			}
+	overrides, err := p.checkOverrides(targetName, merger.conflicts)
+	if err != nil {
+		return Result{
+			Target:    targetName,
+			Phase:     "merge",
+			Operation: string(OperationMerge),
+			Success:   false,
+			Error:     err,
+			Duration:  time.Since(start),
+			Details: ResultDetails{
+				SourcePaths:     sourcePaths,
+				DestinationPath: bundlePath,
+				Overrides:       overrides,
+			},
 		}
 	}
 
-	l.WithField("secretsCount", len(mergedSecrets)).Debug("Merge complete, writing to store")
+	if err := p.checkBundlePolicies(ctx, targetName, target, mergedSecrets); err != nil {
+		return Result{
+			Target:    targetName,
+			Phase:     "merge",
+			Operation: string(OperationMerge),
+			Success:   false,
+			Error:     err,
+			Duration:  time.Since(start),
+			Details: ResultDetails{
+				SecretsProcessed: len(mergedSecrets),
+				SourcePaths:      sourcePaths,
+				DestinationPath:  bundlePath,
+				Overrides:        overrides,
+			},
+		}
+	}
 
 	if dryRun {
 		l.WithFields(log.Fields{
 			"secretsCount": len(mergedSecrets),
 			"bundlePath":   bundlePath,
 		}).Info("[DRY-RUN] Would write merged bundle")
-		return Result{
+		result := Result{
 			Target:    targetName,
 			Phase:     "merge",
 			Operation: string(OperationMerge),
@@ -154,6 +179,34 @@ func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bo
 				SecretsProcessed: len(mergedSecrets),
 				SourcePaths:      sourcePaths,
 				DestinationPath:  bundlePath,
+				Overrides:        overrides,
+			},
+		}
+		if p.pipelineDiff != nil {
+			if targetDiff, err := p.computeMergeDiff(ctx, targetName, sourcePaths); err != nil {
+				l.WithError(err).Debug("Failed to compute merge diff")
+			} else {
+				result.Diff = targetDiff
+				p.addTargetDiff(*targetDiff)
+			}
+		}
+		return result
+	}
+
+	// When applying a plan, only the planned bundle is written
+	if err := p.checkPlannedMerge(ctx, targetName, bundlePath, mergedSecrets); err != nil {
+		return Result{
+			Target:    targetName,
+			Phase:     "merge",
+			Operation: string(OperationMerge),
+			Success:   false,
+			Error:     err,
+			Duration:  time.Since(start),
+			Details: ResultDetails{
+				SecretsProcessed: len(mergedSecrets),
+				SourcePaths:      sourcePaths,
+				DestinationPath:  bundlePath,
+				Overrides:        overrides,
 			},
 		}
 	}
@@ -176,32 +229,35 @@ func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bo
 		}
 	}
 
-	success := len(failedSources) == 0
-	var lastErr error
-	if !success {
-		lastErr = fmt.Errorf("failed to read from %d sources: %v", len(failedSources), failedSources)
+	// Record key-level provenance next to the bundle. The bundle is usable
+	// without it, so a failure here does not fail the merge.
+	var provErr error
+	if p.config.MergeStore.Vault != nil {
+		provErr = p.writeProvenanceToVault(ctx, TargetProvenancePath(p.config.MergeStore.Vault.Mount, targetName, sourcePaths), provenance)
+	} else if p.s3Store != nil {
+		provErr = p.s3Store.WriteProvenance(ctx, provenance)
+	}
+	if provErr != nil {
+		l.WithError(provErr).Warn("Failed to write bundle provenance")
 	}
 
 	l.WithFields(log.Fields{
-		"duration":      time.Since(start),
-		"success":       success,
-		"bundlePath":    bundlePath,
-		"secretsCount":  len(mergedSecrets),
-		"failedSources": failedSources,
+		"duration":     time.Since(start),
+		"bundlePath":   bundlePath,
+		"secretsCount": len(mergedSecrets),
 	}).Info("Merge completed")
 
 	result := Result{
 		Target:    targetName,
 		Phase:     "merge",
 		Operation: string(OperationMerge),
-		Success:   success,
-		Error:     lastErr,
+		Success:   true,
 		Duration:  time.Since(start),
 		Details: ResultDetails{
 			SecretsProcessed: len(mergedSecrets),
 			SourcePaths:      sourcePaths,
 			DestinationPath:  bundlePath,
-			FailedImports:    failedSources,
+			Overrides:        overrides,
 		},
 	}
 
@@ -219,6 +275,212 @@ func (p *Pipeline) mergeTarget(ctx context.Context, targetName string, dryRun bo
 	return result
 }
 
+// SourceReadError is returned when some of a target's imports could not be
+// read. The target's bundle is neither written nor synced.
+type SourceReadError struct {
+	Target  string
+	Sources []string
+}
+
+func (e *SourceReadError) Error() string {
+	return fmt.Sprintf("failed to read from %d sources: %v", len(e.Sources), e.Sources)
+}
+
+// mergeSources reads a target's imports in order and merges them in memory.
+// Imports that cannot be read are returned as failedSources; an error is only
+// returned when the merge cannot run at all.
+func (p *Pipeline) mergeSources(ctx context.Context, targetName string, target Target, sourcePaths []string) (map[string]interface{}, *bundleMerger, []string, error) {
+	return p.mergeSourcesWith(ctx, targetName, target, sourcePaths, nil)
+}
+
+// mergeSourcesWith is mergeSources with the bundles of inherited targets
+// taken from inherited instead of the merge store, for targets whose bundles
+// are not written yet (plans)
+func (p *Pipeline) mergeSourcesWith(ctx context.Context, targetName string, target Target, sourcePaths []string, inherited map[string]map[string]interface{}) (map[string]interface{}, *bundleMerger, []string, error) {
+	l := log.WithFields(log.Fields{
+		"action":     "mergeSources",
+		"target":     targetName,
+		"request_id": reqctx.GetRequestID(ctx),
+	})
+
+	merger, err := newBundleMerger(target)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Merge all sources in sequence (later sources override earlier)
+	mergedSecrets := make(map[string]interface{})
+	var failedSources []string
+
+	// Vault client for reading sources, initialized on first Vault import
+	var sourceClient *vault.VaultClient
+
+	for i, importSpec := range target.Imports {
+		sourcePath := sourcePaths[i]
+		l.WithFields(log.Fields{
+			"source":   sourcePath,
+			"priority": i,
+		}).Debug("Processing source")
+
+		selector, err := ParseImport(importSpec)
+		if err != nil {
+			l.WithError(err).Warn("Invalid import selector")
+			failedSources = append(failedSources, sourcePath)
+			continue
+		}
+
+		var secrets map[string]interface{}
+		var versions map[string]int
+		sourceBase := p.config.GetSourcePath(selector.Source)
+		if bundle, ok := inherited[selector.Source]; ok {
+			// Merging modifies secrets in place, so merge a copy
+			secrets = utils.DeepMerge(nil, bundle)
+		} else if src, ok := p.config.Sources[selector.Source]; ok && src.AWS != nil {
+			secrets, err = p.readAWSSource(ctx, selector.Source, src.AWS)
+		} else {
+			if sourceClient == nil {
+				sourceClient = &vault.VaultClient{
+					Address:   p.config.Vault.Address,
+					Namespace: p.config.Vault.Namespace,
+				}
+				if err := sourceClient.Init(ctx); err != nil {
+					return nil, nil, nil, fmt.Errorf("failed to init source vault client: %w", err)
+				}
+			}
+			secrets, versions, err = readVaultSource(ctx, sourceClient, sourceBase)
+		}
+		if err != nil {
+			l.WithError(err).WithField("source", sourcePath).Warn("Failed to read secrets from source")
+			failedSources = append(failedSources, sourcePath)
+			continue
+		}
+
+		// Deep merge into accumulated result (later sources win on conflict)
+		for relPath, secretData := range selector.apply(secrets) {
+			origin := ValueOrigin{
+				Source:  importSpec,
+				Path:    sourceBase + "/" + relPath,
+				Version: versions[relPath],
+			}
+			merger.merge(mergedSecrets, relPath, secretData, origin)
+		}
+	}
+
+	return mergedSecrets, merger, failedSources, nil
+}
+
+// readVaultSource reads all secrets under a Vault source path.
+// Returns secrets and their KV versions keyed by their path relative to the source path.
+func readVaultSource(ctx context.Context, client *vault.VaultClient, sourcePath string) (map[string]interface{}, map[string]int, error) {
+	l := log.WithFields(log.Fields{
+		"action": "readVaultSource",
+		"source": sourcePath,
+	})
+
+	secretPaths, err := client.ListSecrets(ctx, sourcePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secrets := make(map[string]interface{})
+	versions := make(map[string]int)
+	for _, secretPath := range secretPaths {
+		secretData, version, err := client.GetKVSecretVersionOnce(ctx, secretPath)
+		if err != nil {
+			l.WithError(err).WithField("secret", secretPath).Warn("Failed to read secret")
+			continue
+		}
+
+		// Relative path within this source
+		relPath := secretPath
+		if len(secretPath) > len(sourcePath) {
+			relPath = secretPath[len(sourcePath):]
+			if len(relPath) > 0 && relPath[0] == '/' {
+				relPath = relPath[1:]
+			}
+		}
+		secrets[relPath] = secretData
+		versions[relPath] = version
+	}
+
+	return secrets, versions, nil
+}
+
+// bundleMerger merges source secrets into a bundle using a target's merge strategies
+// and records the key-level provenance of the result
+type bundleMerger struct {
+	strategy   utils.MergeStrategy
+	overrides  []MergeStrategyOverride
+	provenance map[string]map[string]*KeyProvenance
+	conflicts  []diff.KeyOverride // Scalar values overridden by later imports
+	// secretSources is the import that last replaced each secret as a whole
+	secretSources map[string]string
+}
+
+// newBundleMerger returns a merger for a target's merge_strategy settings
+func newBundleMerger(target Target) (*bundleMerger, error) {
+	strategy, err := utils.ParseMergeStrategy(target.MergeStrategy)
+	if err != nil {
+		return nil, fmt.Errorf("merge_strategy: %w", err)
+	}
+	for i, o := range target.MergeStrategyOverrides {
+		if o.Path == "" {
+			return nil, fmt.Errorf("merge_strategy_overrides[%d]: path is required", i)
+		}
+		if _, err := path.Match(strings.Trim(o.Path, "/"), ""); err != nil {
+			return nil, fmt.Errorf("merge_strategy_overrides[%d]: invalid path pattern %q: %w", i, o.Path, err)
+		}
+		if _, err := utils.ParseMergeStrategy(o.Strategy); err != nil {
+			return nil, fmt.Errorf("merge_strategy_overrides[%d]: %w", i, err)
+		}
+	}
+	return &bundleMerger{
+		strategy:   strategy,
+		overrides:  target.MergeStrategyOverrides,
+		provenance:    make(map[string]map[string]*KeyProvenance),
+		secretSources: make(map[string]string),
+	}, nil
+}
+
+// strategyFor returns the merge strategy for a secret path
+func (m *bundleMerger) strategyFor(relPath string) utils.MergeStrategy {
+	for _, o := range m.overrides {
+		if matchPathGlob(o.Path, relPath) {
+			strategy, _ := utils.ParseMergeStrategy(o.Strategy)
+			return strategy
+		}
+	}
+	return m.strategy
+}
+
+// merge merges a secret into the accumulated result at relPath.
+// Map values are merged per the path's strategy; anything else is overridden
+// unless the strategy is first_wins. origin describes where secretData was read.
+func (m *bundleMerger) merge(merged map[string]interface{}, relPath string, secretData interface{}, origin ValueOrigin) {
+	strategy := m.strategyFor(relPath)
+	existing, exists := merged[relPath]
+	if !exists {
+		merged[relPath] = secretData
+		m.recordSecret(relPath, secretData, origin)
+		return
+	}
+
+	existingMap, existingIsMap := existing.(map[string]interface{})
+	dataMap, dataIsMap := secretData.(map[string]interface{})
+	if existingIsMap && dataIsMap {
+		before := describeSharedKeys(existingMap, dataMap)
+		result := utils.Merger{Strategy: strategy}.Merge(existingMap, dataMap)
+		merged[relPath] = result
+		m.recordMerge(relPath, before, dataMap, result, origin)
+		return
+	}
+	if strategy != utils.MergeStrategyFirstWins {
+		m.recordReplace(relPath, existing, secretData, origin)
+		merged[relPath] = secretData
+		m.recordSecret(relPath, secretData, origin)
+	}
+}
+
 // writeMergedBundleToVault writes the merged secrets to Vault, wiping existing data first
 func (p *Pipeline) writeMergedBundleToVault(ctx context.Context, bundlePath string, secrets map[string]interface{}) error {
 	l := log.WithFields(log.Fields{
@@ -0,0 +1,51 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/jbcom/secretsync/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleMerger(t *testing.T) {
+	merger, err := newBundleMerger(Target{
+		MergeStrategy: "list_replace",
+		MergeStrategyOverrides: []MergeStrategyOverride{
+			{Path: "network/*", Strategy: "list_union"},
+			{Path: "baseline/**", Strategy: "first_wins"},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, utils.MergeStrategyListReplace, merger.strategyFor("api/stripe"))
+	assert.Equal(t, utils.MergeStrategyListUnion, merger.strategyFor("network/vpc"))
+	assert.Equal(t, utils.MergeStrategyFirstWins, merger.strategyFor("baseline/tls/ca"))
+
+	merged := map[string]interface{}{}
+	merger.merge(merged, "network/vpc", map[string]interface{}{"cidrs": []interface{}{"10.0.0.0/8"}}, ValueOrigin{})
+	merger.merge(merged, "network/vpc", map[string]interface{}{"cidrs": []interface{}{"10.0.0.0/8", "172.16.0.0/12"}}, ValueOrigin{})
+	assert.Equal(t, []interface{}{"10.0.0.0/8", "172.16.0.0/12"}, merged["network/vpc"].(map[string]interface{})["cidrs"])
+
+	merger.merge(merged, "api/stripe", map[string]interface{}{"hosts": []interface{}{"a"}, "key": "old"}, ValueOrigin{})
+	merger.merge(merged, "api/stripe", map[string]interface{}{"hosts": []interface{}{"b"}, "key": "new"}, ValueOrigin{})
+	assert.Equal(t, map[string]interface{}{"hosts": []interface{}{"b"}, "key": "new"}, merged["api/stripe"])
+
+	merger.merge(merged, "baseline/tls", "first", ValueOrigin{})
+	merger.merge(merged, "baseline/tls", "second", ValueOrigin{})
+	assert.Equal(t, "first", merged["baseline/tls"])
+}
+
+func TestNewBundleMerger_Invalid(t *testing.T) {
+	_, err := newBundleMerger(Target{MergeStrategy: "append_all"})
+	assert.Error(t, err)
+
+	_, err = newBundleMerger(Target{MergeStrategyOverrides: []MergeStrategyOverride{{Path: "db/*", Strategy: "bogus"}}})
+	assert.Error(t, err)
+
+	_, err = newBundleMerger(Target{MergeStrategyOverrides: []MergeStrategyOverride{{Strategy: "list_union"}}})
+	assert.Error(t, err)
+
+	cfg := &Config{Targets: map[string]Target{"Stg": {MergeStrategy: "bogus"}}}
+	assert.Error(t, cfg.Validate())
+}
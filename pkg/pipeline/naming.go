@@ -0,0 +1,224 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+// defaultSecretNameTemplate keeps the bundle-relative path as the AWS secret name
+const defaultSecretNameTemplate = "{{.Path}}"
+
+// maxAWSSecretNameLength is the Secrets Manager limit on secret names
+const maxAWSSecretNameLength = 512
+
+// awsSecretNamePattern matches the characters Secrets Manager accepts in secret names
+var awsSecretNamePattern = regexp.MustCompile(`^[A-Za-z0-9/_+=.@-]+$`)
+
+// secretNameTemplates caches parsed templates by their source text
+var secretNameTemplates sync.Map
+
+// SecretNameData holds the variables available to secret_name_template.
+//
+// Example: "{{.Target}}/{{.Path}}" or "{{index .Segments 0}}/{{.Name}}"
+type SecretNameData struct {
+	Target    string   // Target name
+	AccountID string   // Target AWS account ID
+	Region    string   // Target AWS region
+	Path      string   // Secret path relative to the bundle
+	Segments  []string // Path split on "/"
+	Dir       string   // Path without its last segment ("" for top-level secrets)
+	Name      string   // Last path segment
+}
+
+// newSecretNameData builds template variables for a secret in a target
+func newSecretNameData(targetName, accountID, region, secretPath string) SecretNameData {
+	dir := path.Dir(secretPath)
+	if dir == "." {
+		dir = ""
+	}
+	return SecretNameData{
+		Target:    targetName,
+		AccountID: accountID,
+		Region:    region,
+		Path:      secretPath,
+		Segments:  strings.Split(secretPath, "/"),
+		Dir:       dir,
+		Name:      path.Base(secretPath),
+	}
+}
+
+// parseSecretNameTemplate parses and caches a secret name template
+func parseSecretNameTemplate(text string) (*template.Template, error) {
+	if cached, ok := secretNameTemplates.Load(text); ok {
+		return cached.(*template.Template), nil
+	}
+	tmpl, err := template.New("secret_name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret_name_template: %w", err)
+	}
+	secretNameTemplates.Store(text, tmpl)
+	return tmpl, nil
+}
+
+// renderSecretName renders the AWS secret name for a secret.
+// The prefix is prepended verbatim to the rendered template.
+func renderSecretName(prefix, nameTemplate string, data SecretNameData) (string, error) {
+	if nameTemplate == "" {
+		nameTemplate = defaultSecretNameTemplate
+	}
+	tmpl, err := parseSecretNameTemplate(nameTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render secret_name_template: %w", err)
+	}
+
+	name := prefix + buf.String()
+	if err := validateAWSSecretName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// validateAWSSecretName checks a name against Secrets Manager naming rules
+func validateAWSSecretName(name string) error {
+	if name == "" {
+		return fmt.Errorf("secret name is empty")
+	}
+	if len(name) > maxAWSSecretNameLength {
+		return fmt.Errorf("secret name %q exceeds %d characters", name, maxAWSSecretNameLength)
+	}
+	if !awsSecretNamePattern.MatchString(name) {
+		return fmt.Errorf("secret name %q contains characters not allowed by AWS (allowed: letters, digits, /_+=.@-)", name)
+	}
+	return nil
+}
+
+// validateSecretNaming checks a target's prefix and template without rendering
+// them: names depend on the secret paths, so every rendered name is checked
+// again by awsSecretNames before anything is written.
+func validateSecretNaming(prefix, nameTemplate string) error {
+	if prefix != "" {
+		if err := validateAWSSecretName(prefix); err != nil {
+			return fmt.Errorf("invalid secret_prefix: %w", err)
+		}
+	}
+	if nameTemplate == "" {
+		return nil
+	}
+	tmpl, err := parseSecretNameTemplate(nameTemplate)
+	if err != nil {
+		return err
+	}
+	// Literal text ends up in every name, so it can be checked now
+	var text strings.Builder
+	collectTemplateText(tmpl.Tree.Root, &text)
+	if text.Len() > 0 && !awsSecretNamePattern.MatchString(text.String()) {
+		return fmt.Errorf("secret_name_template %q contains characters not allowed by AWS (allowed: letters, digits, /_+=.@-)", nameTemplate)
+	}
+	return nil
+}
+
+// collectTemplateText appends the literal text of a template tree
+func collectTemplateText(node parse.Node, text *strings.Builder) {
+	switch n := node.(type) {
+	case *parse.TextNode:
+		text.Write(n.Text)
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectTemplateText(child, text)
+		}
+	case *parse.IfNode:
+		collectTemplateText(n.List, text)
+		collectTemplateText(n.ElseList, text)
+	case *parse.RangeNode:
+		collectTemplateText(n.List, text)
+		collectTemplateText(n.ElseList, text)
+	case *parse.WithNode:
+		collectTemplateText(n.List, text)
+		collectTemplateText(n.ElseList, text)
+	}
+}
+
+// awsSecretNames renders and validates the AWS secret name of every secret
+// in a target's bundle, so a name AWS would reject fails the target before
+// anything is written
+func (p *Pipeline) awsSecretNames(targetName string, secrets map[string]map[string]interface{}) (map[string]string, error) {
+	names := make(map[string]string, len(secrets))
+	var invalid []string
+	for secretPath := range secrets {
+		name, err := p.getAWSSecretName(targetName, secretPath)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("%s: %v", secretPath, err))
+			continue
+		}
+		names[secretPath] = name
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return nil, fmt.Errorf("%d secrets have invalid AWS names: %s", len(invalid), strings.Join(invalid, "; "))
+	}
+	return names, nil
+}
+
+// secretNamePrefix returns the part of a target's secret names that does not
+// depend on the secret path: secret_prefix plus the template output before the
+// first path variable. Templates that cannot be rendered without a real path
+// fall back to their leading literal text.
+func (p *Pipeline) secretNamePrefix(targetName string) string {
+	target := p.config.Targets[targetName]
+	nameTemplate := target.SecretNameTemplate
+	if nameTemplate == "" {
+		return target.SecretPrefix
+	}
+	tmpl, err := parseSecretNameTemplate(nameTemplate)
+	if err != nil {
+		return target.SecretPrefix
+	}
+
+	const marker = "\x00"
+	region := target.Region
+	if region == "" {
+		region = p.config.AWS.Region
+	}
+	data := SecretNameData{
+		Target:    targetName,
+		AccountID: target.AccountID,
+		Region:    region,
+		Path:      marker,
+		Segments:  []string{marker},
+		Dir:       marker,
+		Name:      marker,
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err == nil {
+		if i := strings.Index(buf.String(), marker); i >= 0 {
+			return target.SecretPrefix + buf.String()[:i]
+		}
+	}
+
+	var leading strings.Builder
+	if tmpl.Tree != nil {
+		for _, node := range tmpl.Tree.Root.Nodes {
+			text, ok := node.(*parse.TextNode)
+			if !ok {
+				break
+			}
+			leading.Write(text.Text)
+		}
+	}
+	return target.SecretPrefix + leading.String()
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderSecretName(t *testing.T) {
+	data := newSecretNameData("Serverless_Stg", "111111111111", "us-west-2", "api-keys/stripe")
+
+	tests := []struct {
+		name     string
+		prefix   string
+		template string
+		expected string
+		wantErr  bool
+	}{
+		{"Default keeps path", "", "", "api-keys/stripe", false},
+		{"Prefix only", "prod/", "", "prod/api-keys/stripe", false},
+		{"Target template", "", "{{.Target}}/{{.Path}}", "Serverless_Stg/api-keys/stripe", false},
+		{"Account and region", "", "{{.AccountID}}/{{.Region}}/{{.Name}}", "111111111111/us-west-2/stripe", false},
+		{"Path segments", "", "{{index .Segments 0}}-{{.Name}}", "api-keys-stripe", false},
+		{"Prefix and template", "org/", "{{.Target}}/{{.Path}}", "org/Serverless_Stg/api-keys/stripe", false},
+		{"Unknown variable", "", "{{.Missing}}", "", true},
+		{"Invalid characters", "", "{{.Target}} {{.Path}}", "", true},
+		{"Invalid template syntax", "", "{{.Path", "", true},
+		{"Empty result", "", "{{if false}}x{{end}}", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderSecretName(tt.prefix, tt.template, data)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+func TestNewSecretNameData(t *testing.T) {
+	data := newSecretNameData("Stg", "111111111111", "us-east-1", "database/postgres/main")
+	assert.Equal(t, []string{"database", "postgres", "main"}, data.Segments)
+	assert.Equal(t, "database/postgres", data.Dir)
+	assert.Equal(t, "main", data.Name)
+
+	top := newSecretNameData("Stg", "111111111111", "us-east-1", "token")
+	assert.Equal(t, "", top.Dir)
+	assert.Equal(t, "token", top.Name)
+}
+
+func TestValidateAWSSecretName(t *testing.T) {
+	assert.NoError(t, validateAWSSecretName("prod/api-keys/stripe_key+v1=@."))
+	assert.Error(t, validateAWSSecretName(""))
+	assert.Error(t, validateAWSSecretName("has space"))
+	assert.Error(t, validateAWSSecretName("bad#char"))
+	assert.Error(t, validateAWSSecretName(strings.Repeat("a", 513)))
+}
+
+func TestConfig_ValidateSecretNaming(t *testing.T) {
+	cfg := &Config{
+		Targets: map[string]Target{
+			"Stg": {
+				AccountID:          "111111111111",
+				SecretPrefix:       "stg/",
+				SecretNameTemplate: "{{.Target}}/{{.Path}}",
+			},
+		},
+	}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Targets["Stg"] = Target{AccountID: "111111111111", SecretNameTemplate: "{{.Target}}:{{.Path}}"}
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `target "Stg"`)
+
+	cfg.Targets["Stg"] = Target{AccountID: "111111111111", SecretPrefix: "bad prefix/"}
+	assert.Error(t, cfg.Validate())
+
+	cfg.Targets["Stg"] = Target{AccountID: "111111111111", SecretNameTemplate: "{{.Target}"}
+	assert.Error(t, cfg.Validate())
+
+	// Templates are only parsed: names depend on the real secret paths
+	cfg.Targets["Stg"] = Target{AccountID: "111111111111", SecretNameTemplate: "{{index .Segments 3}}/{{.Name}}"}
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestPipeline_AWSSecretNames(t *testing.T) {
+	p := &Pipeline{config: &Config{
+		AWS:     AWSConfig{Region: "us-east-1"},
+		Targets: map[string]Target{"Stg": {AccountID: "111111111111", SecretPrefix: "stg/"}},
+	}}
+
+	names, err := p.awsSecretNames("Stg", map[string]map[string]interface{}{
+		"api/stripe": {"KEY": "x"},
+		"db":         {"PASSWORD": "y"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"api/stripe": "stg/api/stripe", "db": "stg/db"}, names)
+
+	_, err = p.awsSecretNames("Stg", map[string]map[string]interface{}{
+		"api/stripe":  {"KEY": "x"},
+		"api/has #":   {"KEY": "y"},
+		"api/too big": {"KEY": "z"},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "2 secrets have invalid AWS names")
+	assert.Contains(t, err.Error(), "api/has #")
+}
+
+func TestPipeline_GetAWSSecretName(t *testing.T) {
+	p := &Pipeline{config: &Config{
+		AWS: AWSConfig{Region: "us-east-1"},
+		Targets: map[string]Target{
+			"Stg":  {AccountID: "111111111111", SecretPrefix: "stg/"},
+			"Prod": {AccountID: "222222222222", SecretNameTemplate: "{{.Target}}/{{.Region}}/{{.Path}}"},
+		},
+	}}
+
+	name, err := p.getAWSSecretName("Stg", "api-keys/stripe")
+	require.NoError(t, err)
+	assert.Equal(t, "stg/api-keys/stripe", name)
+
+	name, err = p.getAWSSecretName("Prod", "api-keys/stripe")
+	require.NoError(t, err)
+	assert.Equal(t, "Prod/us-east-1/api-keys/stripe", name)
+}
+
+func TestPipeline_SecretNamePrefix(t *testing.T) {
+	p := &Pipeline{config: &Config{
+		AWS: AWSConfig{Region: "us-east-1"},
+		Targets: map[string]Target{
+			"Default":  {},
+			"Prefixed": {SecretPrefix: "stg/"},
+			"Template": {SecretPrefix: "svc/", SecretNameTemplate: "{{.Target}}/{{.Region}}/{{.Path}}"},
+			"Segments": {SecretNameTemplate: "team/{{index .Segments 2}}/{{.Name}}"},
+		},
+	}}
+
+	assert.Equal(t, "", p.secretNamePrefix("Default"))
+	assert.Equal(t, "stg/", p.secretNamePrefix("Prefixed"))
+	assert.Equal(t, "svc/Template/us-east-1/", p.secretNamePrefix("Template"))
+	assert.Equal(t, "team/", p.secretNamePrefix("Segments"))
+}
@@ -0,0 +1,614 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/pkg/client/vault"
+	log "github.com/sirupsen/logrus"
+)
+
+// Notification delivery defaults
+const (
+	DefaultNotificationAttempts = 3
+	DefaultNotificationBackoff  = time.Second
+)
+
+// defaultNotificationTemplate renders the message, the diff summary and the
+// failed targets of a run
+const defaultNotificationTemplate = `{{.Message}}
+{{- with .Run}}{{with .DiffSummary}}
+Changes: +{{.Added}} ~{{.Modified}} -{{.Removed}} ({{.Unchanged}} unchanged){{end}}
+{{- range .Results}}{{if not .Success}}
+{{.Target}} [{{.Phase}}]: {{.Error}}{{end}}{{end}}{{end}}`
+
+// defaultEmailSubject is the subject of emails without one
+const defaultEmailSubject = `[secretsync] {{.Event}}: {{.Message}}`
+
+// NotificationData is the data of notification templates and the default
+// webhook body. Run is set for pipeline runs and Resource for the controller.
+type NotificationData struct {
+	Event    v1alpha1.NotificationEvent `json:"event"`
+	Message  string                     `json:"message"`
+	Run      *RunRecord                 `json:"run,omitempty"`
+	Resource *NotificationResource      `json:"resource,omitempty"`
+}
+
+// NotificationResource describes a SecretSync resource in notifications.
+// It never includes the spec, which holds notification credentials.
+type NotificationResource struct {
+	Name             string `json:"name"`
+	Namespace        string `json:"namespace"`
+	Status           string `json:"status"`
+	SyncDestinations int    `json:"syncDestinations"`
+	Error            string `json:"error,omitempty"`
+}
+
+// NotificationSecrets reads the Vault secrets referenced by notifications
+// (webhook headerSecret, Slack urlSecret)
+type NotificationSecrets interface {
+	GetKVSecretOnce(ctx context.Context, path string) (map[string]interface{}, error)
+}
+
+// vaultNotificationSecrets logs in to Vault on first use, so runs whose
+// notifications reference no secrets never authenticate
+type vaultNotificationSecrets struct {
+	client *vault.VaultClient
+	once   sync.Once
+	err    error
+}
+
+// NewVaultNotificationSecrets reads notification secrets with a Vault client
+// that is initialized when the first secret is read
+func NewVaultNotificationSecrets(client *vault.VaultClient) NotificationSecrets {
+	return &vaultNotificationSecrets{client: client}
+}
+
+func (s *vaultNotificationSecrets) GetKVSecretOnce(ctx context.Context, path string) (map[string]interface{}, error) {
+	s.once.Do(func() {
+		s.err = s.client.Init(ctx)
+	})
+	if s.err != nil {
+		return nil, fmt.Errorf("failed to init vault client: %w", s.err)
+	}
+	return s.client.GetKVSecretOnce(ctx, path)
+}
+
+// Notifier delivers notifications to webhook, Slack and email channels
+type Notifier struct {
+	Channels []*v1alpha1.NotificationSpec
+	// Template is the message body of channels without their own body
+	Template string
+	// Attempts is the number of delivery attempts per channel (default 3)
+	Attempts int
+	// Backoff is the delay before the first retry, doubled for each further retry (default 1s)
+	Backoff time.Duration
+	// Secrets reads headerSecret and urlSecret values
+	Secrets NotificationSecrets
+	// HTTPClient sends webhook and Slack requests (default: 30s timeout)
+	HTTPClient *http.Client
+}
+
+// NewNotifier creates the notifier of a pipeline configuration
+func NewNotifier(cfg *Config) (*Notifier, error) {
+	n := cfg.Notifications
+	backoff, err := n.backoff()
+	if err != nil {
+		return nil, err
+	}
+	return &Notifier{
+		Channels: n.Channels,
+		Template: n.Template,
+		Attempts: n.Attempts,
+		Backoff:  backoff,
+		Secrets: NewVaultNotificationSecrets(&vault.VaultClient{
+			Address:   cfg.Vault.Address,
+			Namespace: cfg.Vault.Namespace,
+		}),
+	}, nil
+}
+
+// enabled reports whether any notification channel is configured
+func (n NotificationsConfig) enabled() bool {
+	return len(n.Channels) > 0
+}
+
+// backoff parses the configured backoff
+func (n NotificationsConfig) backoff() (time.Duration, error) {
+	if n.Backoff == "" {
+		return DefaultNotificationBackoff, nil
+	}
+	d, err := time.ParseDuration(n.Backoff)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("notifications.backoff: invalid duration %q", n.Backoff)
+	}
+	return d, nil
+}
+
+// validate checks the notification configuration
+func (n NotificationsConfig) validate() error {
+	if n.Attempts < 0 {
+		return fmt.Errorf("notifications.attempts must not be negative")
+	}
+	if _, err := n.backoff(); err != nil {
+		return err
+	}
+	if n.Template != "" {
+		if _, err := template.New("template").Parse(n.Template); err != nil {
+			return fmt.Errorf("notifications.template: %w", err)
+		}
+	}
+	for i, ch := range n.Channels {
+		if err := ValidateNotification(ch); err != nil {
+			return fmt.Errorf("notifications.channels[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidateNotification checks that a notification channel is complete and
+// its templates parse
+func ValidateNotification(spec *v1alpha1.NotificationSpec) error {
+	if spec == nil || (spec.Webhook == nil && spec.Slack == nil && spec.Email == nil) {
+		return fmt.Errorf("one of webhook, slack or email is required")
+	}
+	if w := spec.Webhook; w != nil {
+		if w.URL == "" {
+			return fmt.Errorf("webhook.url is required")
+		}
+		if err := validateEvents(w.Events); err != nil {
+			return fmt.Errorf("webhook: %w", err)
+		}
+		if _, err := template.New("body").Parse(w.Body); err != nil {
+			return fmt.Errorf("webhook.body: %w", err)
+		}
+	}
+	if s := spec.Slack; s != nil {
+		if s.URL == nil && (s.URLSecret == nil || s.URLSecretKey == nil) {
+			return fmt.Errorf("slack.url or slack.urlSecret with slack.urlSecretKey is required")
+		}
+		if err := validateEvents(s.Events); err != nil {
+			return fmt.Errorf("slack: %w", err)
+		}
+		if _, err := template.New("body").Parse(s.Body); err != nil {
+			return fmt.Errorf("slack.body: %w", err)
+		}
+	}
+	if e := spec.Email; e != nil {
+		if e.To == "" || e.From == "" || e.Host == "" {
+			return fmt.Errorf("email.to, email.from and email.host are required")
+		}
+		if err := validateEvents(e.Events); err != nil {
+			return fmt.Errorf("email: %w", err)
+		}
+		if _, err := template.New("subject").Parse(e.Subject); err != nil {
+			return fmt.Errorf("email.subject: %w", err)
+		}
+		if _, err := template.New("body").Parse(e.Body); err != nil {
+			return fmt.Errorf("email.body: %w", err)
+		}
+	}
+	return nil
+}
+
+func validateEvents(events []v1alpha1.NotificationEvent) error {
+	for _, e := range events {
+		if e != v1alpha1.NotificationEventSyncSuccess && e != v1alpha1.NotificationEventSyncFailure {
+			return fmt.Errorf("event %q must be success or failure", e)
+		}
+	}
+	return nil
+}
+
+// wants reports whether a channel subscribes to an event (no events means all)
+func wants(events []v1alpha1.NotificationEvent, event v1alpha1.NotificationEvent) bool {
+	if len(events) == 0 {
+		return true
+	}
+	for _, e := range events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify delivers data to every channel subscribed to its event. Every channel
+// is attempted; the returned error joins the channels that failed. Errors
+// never contain secret values or secret URLs.
+func (n *Notifier) Notify(ctx context.Context, data NotificationData) error {
+	var errs []error
+	for i, ch := range n.Channels {
+		if ch == nil {
+			continue
+		}
+		if ch.Webhook != nil && wants(ch.Webhook.Events, data.Event) {
+			if err := n.deliver(ctx, func() error { return n.sendWebhook(ctx, ch.Webhook, data) }); err != nil {
+				errs = append(errs, fmt.Errorf("channel %d webhook: %w", i, err))
+			}
+		}
+		if ch.Slack != nil && wants(ch.Slack.Events, data.Event) {
+			if err := n.deliver(ctx, func() error { return n.sendSlack(ctx, ch.Slack, data) }); err != nil {
+				errs = append(errs, fmt.Errorf("channel %d slack: %w", i, err))
+			}
+		}
+		if ch.Email != nil && wants(ch.Email.Events, data.Event) {
+			if err := n.deliver(ctx, func() error { return n.sendEmail(ctx, ch.Email, data) }); err != nil {
+				errs = append(errs, fmt.Errorf("channel %d email: %w", i, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// permanentError is a delivery failure that retrying cannot fix
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// deliver calls send until it succeeds, fails permanently or runs out of
+// attempts, doubling the delay between attempts
+func (n *Notifier) deliver(ctx context.Context, send func() error) error {
+	attempts := n.Attempts
+	if attempts <= 0 {
+		attempts = DefaultNotificationAttempts
+	}
+	delay := n.Backoff
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = send(); err == nil {
+			return nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt == attempts {
+			break
+		}
+		log.WithError(err).WithField("attempt", attempt).Debug("Notification failed, retrying")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return err
+}
+
+// render executes a template with the notification data
+func render(name, text string, data NotificationData) (string, error) {
+	t, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", permanentError{fmt.Errorf("%s: %w", name, err)}
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", permanentError{fmt.Errorf("%s: %w", name, err)}
+	}
+	return buf.String(), nil
+}
+
+// body returns the channel body template, falling back to the notifier's
+func (n *Notifier) body(channelBody string) string {
+	if channelBody != "" {
+		return channelBody
+	}
+	if n.Template != "" {
+		return n.Template
+	}
+	return defaultNotificationTemplate
+}
+
+func (n *Notifier) httpClient() *http.Client {
+	if n.HTTPClient != nil {
+		return n.HTTPClient
+	}
+	return &http.Client{Timeout: 30 * time.Second}
+}
+
+// readSecret reads a notification secret from Vault
+func (n *Notifier) readSecret(ctx context.Context, path string) (map[string]interface{}, error) {
+	if n.Secrets == nil {
+		return nil, permanentError{fmt.Errorf("secret %s: no secret store configured", path)}
+	}
+	secret, err := n.Secrets.GetKVSecretOnce(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s: %w", path, err)
+	}
+	if secret == nil {
+		return nil, permanentError{fmt.Errorf("secret %s not found", path)}
+	}
+	return secret, nil
+}
+
+// sendWebhook sends the rendered body, or the JSON notification data when the
+// webhook has no body and no notifier template is set. Headers from
+// headerSecret override the static headers.
+func (n *Notifier) sendWebhook(ctx context.Context, w *v1alpha1.WebhookNotification, data NotificationData) error {
+	headers := make(map[string]string, len(w.Headers))
+	for k, v := range w.Headers {
+		headers[k] = v
+	}
+	if w.HeaderSecret != nil && *w.HeaderSecret != "" {
+		secret, err := n.readSecret(ctx, *w.HeaderSecret)
+		if err != nil {
+			return err
+		}
+		for k, v := range secret {
+			s, ok := v.(string)
+			if !ok {
+				return permanentError{fmt.Errorf("header %s in secret %s is not a string", k, *w.HeaderSecret)}
+			}
+			headers[k] = s
+		}
+	}
+
+	var body []byte
+	if !w.ExcludeBody {
+		if w.Body == "" && n.Template == "" {
+			var err error
+			if body, err = json.Marshal(data); err != nil {
+				return permanentError{err}
+			}
+			if _, ok := headers["Content-Type"]; !ok {
+				headers["Content-Type"] = "application/json"
+			}
+		} else {
+			rendered, err := render("body", n.body(w.Body), data)
+			if err != nil {
+				return err
+			}
+			body = []byte(rendered)
+		}
+	}
+
+	method := w.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	return n.post(ctx, method, w.URL, headers, body)
+}
+
+// sendSlack posts the rendered body to a Slack incoming webhook. A body that
+// renders to a JSON object is sent as the payload (for blocks); anything else
+// is sent as the message text.
+func (n *Notifier) sendSlack(ctx context.Context, s *v1alpha1.SlackNotification, data NotificationData) error {
+	var hookURL string
+	if s.URL != nil {
+		hookURL = *s.URL
+	} else {
+		secret, err := n.readSecret(ctx, *s.URLSecret)
+		if err != nil {
+			return err
+		}
+		v, ok := secret[*s.URLSecretKey].(string)
+		if !ok || v == "" {
+			return permanentError{fmt.Errorf("key %s not found in secret %s", *s.URLSecretKey, *s.URLSecret)}
+		}
+		hookURL = v
+	}
+
+	text, err := render("body", n.body(s.Body), data)
+	if err != nil {
+		return err
+	}
+	payload := []byte(strings.TrimSpace(text))
+	if !json.Valid(payload) || !bytes.HasPrefix(payload, []byte("{")) {
+		if payload, err = json.Marshal(map[string]string{"text": text}); err != nil {
+			return permanentError{err}
+		}
+	}
+	return n.post(ctx, http.MethodPost, hookURL, map[string]string{"Content-Type": "application/json"}, payload)
+}
+
+// post sends an HTTP request. 4xx responses other than 408 and 429 are
+// permanent failures. Transport errors are reported without the URL, which
+// may embed a token.
+func (n *Notifier) post(ctx context.Context, method, target string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{fmt.Errorf("invalid request: %w", unwrapURLError(err))}
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.httpClient().Do(req)
+	if err != nil {
+		return unwrapURLError(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return permanentError{fmt.Errorf("%s returned %s", method, resp.Status)}
+	default:
+		return fmt.Errorf("%s returned %s", method, resp.Status)
+	}
+}
+
+func unwrapURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// sendEmail sends a plain text email over SMTP, using STARTTLS when the
+// server offers it
+func (n *Notifier) sendEmail(ctx context.Context, e *v1alpha1.EmailNotification, data NotificationData) error {
+	subjectTemplate := e.Subject
+	if subjectTemplate == "" {
+		subjectTemplate = defaultEmailSubject
+	}
+	subject, err := render("subject", subjectTemplate, data)
+	if err != nil {
+		return err
+	}
+	body, err := render("body", n.body(e.Body), data)
+	if err != nil {
+		return err
+	}
+
+	var recipients []string
+	for _, to := range strings.Split(e.To, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			recipients = append(recipients, to)
+		}
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", strings.NewReplacer("\r", " ", "\n", " ").Replace(subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	port := e.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(e.Host, strconv.Itoa(port))
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+	conn.SetDeadline(deadline)
+
+	c, err := smtp.NewClient(conn, e.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: e.Host, InsecureSkipVerify: e.InsecureSkipVerify}); err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if e.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", e.Username, e.Password, e.Host)); err != nil {
+			return permanentError{fmt.Errorf("auth: %w", err)}
+		}
+	}
+	if err := c.Mail(e.From); err != nil {
+		return err
+	}
+	for _, to := range recipients {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// RunNotification returns the notification data of a pipeline run
+func RunNotification(rec *RunRecord) NotificationData {
+	data := NotificationData{Event: v1alpha1.NotificationEventSyncSuccess, Run: rec}
+
+	failed := 0
+	for _, r := range rec.Results {
+		if !r.Success {
+			failed++
+		}
+	}
+	mode := string(rec.Operation)
+	if rec.DryRun {
+		mode += " (dry run)"
+	}
+	switch {
+	case rec.Success:
+		data.Message = fmt.Sprintf("secretsync %s run %s succeeded for %d targets", mode, rec.ID, len(rec.Targets))
+	case failed > 0:
+		data.Event = v1alpha1.NotificationEventSyncFailure
+		data.Message = fmt.Sprintf("secretsync %s run %s failed: %d of %d results failed", mode, rec.ID, failed, len(rec.Results))
+	default:
+		data.Event = v1alpha1.NotificationEventSyncFailure
+		data.Message = fmt.Sprintf("secretsync %s run %s failed: %s", mode, rec.ID, rec.Error)
+	}
+	return data
+}
+
+// SecretSyncNotification returns the notification data of a reconciled
+// SecretSync resource and its sync error, if any
+func SecretSyncNotification(ss *v1alpha1.SecretSync, syncErr error) NotificationData {
+	name := ss.Namespace + "/" + ss.Name
+	data := NotificationData{
+		Event:   v1alpha1.NotificationEventSyncSuccess,
+		Message: fmt.Sprintf("SecretSync %s synced to %d destinations", name, ss.Status.SyncDestinations),
+		Resource: &NotificationResource{
+			Name:             ss.Name,
+			Namespace:        ss.Namespace,
+			Status:           ss.Status.Status,
+			SyncDestinations: ss.Status.SyncDestinations,
+		},
+	}
+	if syncErr != nil {
+		data.Event = v1alpha1.NotificationEventSyncFailure
+		data.Message = fmt.Sprintf("SecretSync %s failed: %v", name, syncErr)
+		data.Resource.Error = syncErr.Error()
+	}
+	return data
+}
+
+// notifyRun sends the notifications of a finished run.
+// Notifications are best effort: failures are logged and never fail the run.
+func (p *Pipeline) notifyRun(ctx context.Context, rec *RunRecord) {
+	l := log.WithFields(log.Fields{
+		"action":     "notifyRun",
+		"request_id": rec.ID,
+	})
+
+	if p.notifier == nil {
+		n, err := NewNotifier(p.config)
+		if err != nil {
+			l.WithError(err).Warn("Failed to initialize notifications")
+			return
+		}
+		p.notifier = n
+	}
+
+	if err := p.notifier.Notify(ctx, RunNotification(rec)); err != nil {
+		l.WithError(err).Warn("Failed to send notifications")
+	}
+}
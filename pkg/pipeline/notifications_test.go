@@ -0,0 +1,333 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jbcom/secretsync/api/v1alpha1"
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeNotificationSecrets serves Vault secrets from memory
+type fakeNotificationSecrets map[string]map[string]interface{}
+
+func (f fakeNotificationSecrets) GetKVSecretOnce(ctx context.Context, path string) (map[string]interface{}, error) {
+	return f[path], nil
+}
+
+func strPtr(s string) *string { return &s }
+
+func testRunRecord(success bool) *RunRecord {
+	rec := &RunRecord{
+		ID:          "run-1",
+		Operation:   OperationPipeline,
+		Targets:     []string{"Staging", "Production"},
+		Success:     success,
+		DiffSummary: &diff.ChangeSummary{Added: 2, Modified: 1},
+		Results: []RecordResult{
+			{Target: "Staging", Phase: "sync", Success: true},
+			{Target: "Production", Phase: "sync", Success: success},
+		},
+	}
+	if !success {
+		rec.Results[1].Error = "access denied"
+	}
+	return rec
+}
+
+func TestNotifier_Webhook(t *testing.T) {
+	var calls atomic.Int32
+	var got NotificationData
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		auth = r.Header.Get("Authorization")
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "static", r.Header.Get("X-Static"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer server.Close()
+
+	n := &Notifier{
+		Channels: []*v1alpha1.NotificationSpec{{Webhook: &v1alpha1.WebhookNotification{
+			URL:          server.URL,
+			Headers:      map[string]string{"X-Static": "static", "Authorization": "overridden"},
+			HeaderSecret: strPtr("kv/notify/webhook"),
+		}}},
+		Backoff: time.Millisecond,
+		Secrets: fakeNotificationSecrets{"kv/notify/webhook": {"Authorization": "Bearer hook-token"}},
+	}
+
+	require.NoError(t, n.Notify(context.Background(), RunNotification(testRunRecord(true))))
+	assert.Equal(t, int32(2), calls.Load(), "5xx is retried")
+	assert.Equal(t, "Bearer hook-token", auth)
+	assert.Equal(t, v1alpha1.NotificationEventSyncSuccess, got.Event)
+	assert.Equal(t, "run-1", got.Run.ID)
+	assert.Equal(t, 2, got.Run.DiffSummary.Added)
+}
+
+func TestNotifier_WebhookResourceBody(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	slackURL := "https://hooks.slack.com/services/T000/B000/slack-token"
+	ss := &v1alpha1.SecretSync{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "apps", Name: "api"},
+		Spec: v1alpha1.SecretSyncSpec{Notifications: []*v1alpha1.NotificationSpec{
+			{Webhook: &v1alpha1.WebhookNotification{URL: server.URL + "/hook?token=url-token", Headers: map[string]string{"Authorization": "Bearer header-token"}}},
+			{Slack: &v1alpha1.SlackNotification{URL: &slackURL, Events: []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncFailure}}},
+			{Email: &v1alpha1.EmailNotification{To: "ops@example.com", Host: "smtp.example.com", Password: "smtp-password", Events: []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncFailure}}},
+		}},
+		Status: v1alpha1.SecretSyncStatus{Status: "Synced", SyncDestinations: 2},
+	}
+
+	n := &Notifier{Channels: ss.Spec.Notifications[:1], Backoff: time.Millisecond}
+	require.NoError(t, n.Notify(context.Background(), SecretSyncNotification(ss, nil)))
+
+	var got NotificationData
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+	assert.Equal(t, &NotificationResource{Name: "api", Namespace: "apps", Status: "Synced", SyncDestinations: 2}, got.Resource)
+	for _, secret := range []string{"url-token", "header-token", "slack-token", "smtp-password"} {
+		assert.NotContains(t, body, secret)
+	}
+	for _, field := range []string{`"password"`, `"url"`, `"headers"`} {
+		assert.NotContains(t, body, field)
+	}
+}
+
+func TestNotifier_WebhookPermanentFailure(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	n := &Notifier{
+		Channels: []*v1alpha1.NotificationSpec{{Webhook: &v1alpha1.WebhookNotification{URL: server.URL + "/hook?token=s3cret"}}},
+		Backoff:  time.Millisecond,
+	}
+	err := n.Notify(context.Background(), RunNotification(testRunRecord(true)))
+	require.Error(t, err)
+	assert.Equal(t, int32(1), calls.Load(), "4xx is not retried")
+	assert.NotContains(t, err.Error(), "s3cret")
+}
+
+func TestNotifier_WebhookRetriesExhausted(t *testing.T) {
+	n := &Notifier{
+		Channels: []*v1alpha1.NotificationSpec{{Webhook: &v1alpha1.WebhookNotification{URL: "http://127.0.0.1:1/hook?token=s3cret"}}},
+		Attempts: 2,
+		Backoff:  time.Millisecond,
+	}
+	err := n.Notify(context.Background(), RunNotification(testRunRecord(true)))
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "s3cret")
+}
+
+func TestNotifier_SlackEventsAndTemplate(t *testing.T) {
+	var payloads []map[string]interface{}
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+		mu.Lock()
+		payloads = append(payloads, p)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	n := &Notifier{
+		Channels: []*v1alpha1.NotificationSpec{{Slack: &v1alpha1.SlackNotification{
+			Events:       []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncFailure},
+			URLSecret:    strPtr("kv/notify/slack"),
+			URLSecretKey: strPtr("url"),
+		}}},
+		Secrets: fakeNotificationSecrets{"kv/notify/slack": {"url": server.URL}},
+	}
+
+	// Successful runs are not sent to a failure-only channel
+	require.NoError(t, n.Notify(context.Background(), RunNotification(testRunRecord(true))))
+	assert.Empty(t, payloads)
+
+	require.NoError(t, n.Notify(context.Background(), RunNotification(testRunRecord(false))))
+	require.Len(t, payloads, 1)
+	text := payloads[0]["text"].(string)
+	assert.Contains(t, text, "run run-1 failed: 1 of 2 results failed")
+	assert.Contains(t, text, "Changes: +2 ~1 -0")
+	assert.Contains(t, text, "Production [sync]: access denied")
+	assert.NotContains(t, text, "Staging [sync]")
+
+	// A body rendering to a JSON object is sent as the payload
+	n.Channels[0].Slack.Body = `{"blocks": [{"type": "section", "text": {"type": "mrkdwn", "text": "{{.Event}}"}}]}`
+	require.NoError(t, n.Notify(context.Background(), RunNotification(testRunRecord(false))))
+	require.Len(t, payloads, 2)
+	assert.Contains(t, payloads[1], "blocks")
+}
+
+// smtpStub accepts one SMTP session and records the message
+type smtpStub struct {
+	listener net.Listener
+	from     string
+	rcpts    []string
+	data     string
+	done     chan struct{}
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &smtpStub{listener: ln, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *smtpStub) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+	reply("220 localhost ESMTP stub")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+		switch upper := strings.ToUpper(cmd); {
+		case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(upper, "MAIL FROM:"):
+			s.from = strings.Trim(cmd[len("MAIL FROM:"):], "<>")
+			reply("250 OK")
+		case strings.HasPrefix(upper, "RCPT TO:"):
+			s.rcpts = append(s.rcpts, strings.Trim(cmd[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case upper == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case upper == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestNotifier_Email(t *testing.T) {
+	stub := newSMTPStub(t)
+	host, port, err := net.SplitHostPort(stub.listener.Addr().String())
+	require.NoError(t, err)
+	var portNum int
+	fmt.Sscan(port, &portNum)
+
+	n := &Notifier{Channels: []*v1alpha1.NotificationSpec{{Email: &v1alpha1.EmailNotification{
+		To:   "ops@example.com, oncall@example.com",
+		From: "secretsync@example.com",
+		Host: host,
+		Port: portNum,
+	}}}}
+
+	require.NoError(t, n.Notify(context.Background(), RunNotification(testRunRecord(false))))
+	<-stub.done
+
+	assert.Equal(t, "secretsync@example.com", stub.from)
+	assert.Equal(t, []string{"ops@example.com", "oncall@example.com"}, stub.rcpts)
+	assert.Contains(t, stub.data, "Subject: [secretsync] failure: secretsync pipeline run run-1 failed")
+	assert.Contains(t, stub.data, "Production [sync]: access denied")
+}
+
+func TestNotificationsConfig_Validate(t *testing.T) {
+	valid := NotificationsConfig{Channels: []*v1alpha1.NotificationSpec{
+		{Webhook: &v1alpha1.WebhookNotification{URL: "https://example.com/hook"}},
+	}}
+	assert.NoError(t, valid.validate())
+
+	tests := []struct {
+		name string
+		cfg  NotificationsConfig
+		want string
+	}{
+		{"empty channel", NotificationsConfig{Channels: []*v1alpha1.NotificationSpec{{}}}, "one of webhook, slack or email"},
+		{"webhook url", NotificationsConfig{Channels: []*v1alpha1.NotificationSpec{{Webhook: &v1alpha1.WebhookNotification{}}}}, "webhook.url"},
+		{"slack url", NotificationsConfig{Channels: []*v1alpha1.NotificationSpec{{Slack: &v1alpha1.SlackNotification{URLSecret: strPtr("kv/slack")}}}}, "slack.url"},
+		{"email fields", NotificationsConfig{Channels: []*v1alpha1.NotificationSpec{{Email: &v1alpha1.EmailNotification{To: "a@example.com"}}}}, "email.to"},
+		{"event", NotificationsConfig{Channels: []*v1alpha1.NotificationSpec{{Webhook: &v1alpha1.WebhookNotification{URL: "https://x", Events: []v1alpha1.NotificationEvent{"done"}}}}}, `event "done"`},
+		{"template", NotificationsConfig{Template: "{{.Message"}, "notifications.template"},
+		{"backoff", NotificationsConfig{Backoff: "soon"}, "notifications.backoff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.validate()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestNotificationsConfig_YAML(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+notifications:
+  attempts: 5
+  backoff: 2s
+  channels:
+    - slack:
+        events: [failure]
+        urlSecret: kv/notify/slack
+        urlSecretKey: url
+    - webhook:
+        url: https://example.com/hook
+        headerSecret: kv/notify/webhook
+`), &cfg))
+	require.Len(t, cfg.Notifications.Channels, 2)
+	assert.Equal(t, []v1alpha1.NotificationEvent{v1alpha1.NotificationEventSyncFailure}, cfg.Notifications.Channels[0].Slack.Events)
+	assert.Equal(t, "kv/notify/webhook", *cfg.Notifications.Channels[1].Webhook.HeaderSecret)
+	assert.NoError(t, cfg.Notifications.validate())
+
+	n, err := NewNotifier(&cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 5, n.Attempts)
+	assert.Equal(t, 2*time.Second, n.Backoff)
+}
@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	log "github.com/sirupsen/logrus"
+)
+
+// Actions for merge.on_protected_override
+const (
+	// ProtectedOverrideWarn logs protected overrides and reports them in the diff
+	ProtectedOverrideWarn = "warn"
+	// ProtectedOverrideFail fails the target's merge before its bundle is written
+	ProtectedOverrideFail = "fail"
+)
+
+// protectedKeyRule is a ProtectedKeyRule with its patterns compiled
+type protectedKeyRule struct {
+	source string
+	paths  *compiledFilter
+	keys   *compiledFilter
+}
+
+// compileProtectedKeys validates and compiles the merge settings' protected keys
+func compileProtectedKeys(settings MergeSettings) ([]protectedKeyRule, error) {
+	switch settings.OnProtectedOverride {
+	case "", ProtectedOverrideWarn, ProtectedOverrideFail:
+	default:
+		return nil, fmt.Errorf("on_protected_override %q must be %s or %s", settings.OnProtectedOverride, ProtectedOverrideWarn, ProtectedOverrideFail)
+	}
+
+	rules := make([]protectedKeyRule, 0, len(settings.ProtectedKeys))
+	for i, r := range settings.ProtectedKeys {
+		if r.Source == "" {
+			return nil, fmt.Errorf("protected_keys[%d]: source is required", i)
+		}
+		paths, err := compilePathFilter(&PatternFilter{Include: r.Paths})
+		if err != nil {
+			return nil, fmt.Errorf("protected_keys[%d].paths: %w", i, err)
+		}
+		keys, err := compileRegexFilter(&PatternFilter{Include: r.Keys})
+		if err != nil {
+			return nil, fmt.Errorf("protected_keys[%d].keys: %w", i, err)
+		}
+		rules = append(rules, protectedKeyRule{source: r.Source, paths: paths, keys: keys})
+	}
+	return rules, nil
+}
+
+// protects reports whether the rule covers an override
+func (r protectedKeyRule) protects(o diff.KeyOverride) bool {
+	if r.source != importSourceName(o.Overridden) && r.source != o.Origin {
+		return false
+	}
+	return r.paths.matches(o.Path) && r.keys.matches(o.Key)
+}
+
+// checkOverrides traces overridden values back through inherited targets and
+// marks protected keys. Returns the sorted overrides, and an error when
+// protected keys were overridden and on_protected_override is fail.
+func (p *Pipeline) checkOverrides(targetName string, overrides []diff.KeyOverride) ([]diff.KeyOverride, error) {
+	l := log.WithFields(log.Fields{
+		"action": "checkOverrides",
+		"target": targetName,
+	})
+
+	settings := p.config.Pipeline.Merge
+	rules, err := compileProtectedKeys(settings)
+	if err != nil {
+		return overrides, err
+	}
+
+	var protected int
+	for i := range overrides {
+		o := &overrides[i]
+		if origin := p.originSource(o.Overridden, o.Path, o.Key); origin != importSourceName(o.Overridden) {
+			o.Origin = origin
+		}
+		for _, rule := range rules {
+			if rule.protects(*o) {
+				o.Protected = true
+				protected++
+				l.WithFields(log.Fields{
+					"path":       o.Path,
+					"key":        o.Key,
+					"source":     o.Source,
+					"overridden": o.Overridden,
+				}).Warn("Protected key overridden")
+				break
+			}
+		}
+	}
+
+	sort.Slice(overrides, func(i, j int) bool {
+		if overrides[i].Path != overrides[j].Path {
+			return overrides[i].Path < overrides[j].Path
+		}
+		return overrides[i].Key < overrides[j].Key
+	})
+
+	if protected > 0 && settings.OnProtectedOverride == ProtectedOverrideFail {
+		return overrides, fmt.Errorf("%d protected keys overridden", protected)
+	}
+	return overrides, nil
+}
+
+// originSource returns the source that originally supplied a key, following
+// inherited targets through the provenance of bundles merged in this process
+func (p *Pipeline) originSource(importSpec, secretPath, key string) string {
+	name := importSourceName(importSpec)
+	for depth := 0; depth <= len(p.config.Targets); depth++ {
+		if _, isTarget := p.config.Targets[name]; !isTarget {
+			return name
+		}
+		prov := p.cachedProvenance(name)
+		if prov == nil {
+			return name
+		}
+		kp, ok := prov.Lookup(secretPath, key)
+		if !ok {
+			// Nested keys (db.password) are traced by their top-level key
+			top, _, nested := strings.Cut(key, ".")
+			if kp, ok = prov.Lookup(secretPath, top); !nested || !ok {
+				return name
+			}
+		}
+		name = importSourceName(kp.Winner.Source)
+	}
+	return name
+}
+
+// cacheProvenance keeps a target's bundle provenance for later targets in the run
+func (p *Pipeline) cacheProvenance(prov *BundleProvenance) {
+	p.provenanceMu.Lock()
+	defer p.provenanceMu.Unlock()
+	if p.provenance == nil {
+		p.provenance = make(map[string]*BundleProvenance)
+	}
+	p.provenance[prov.Target] = prov
+}
+
+// cachedProvenance returns the provenance of a target merged in this process
+func (p *Pipeline) cachedProvenance(targetName string) *BundleProvenance {
+	p.provenanceMu.Lock()
+	defer p.provenanceMu.Unlock()
+	return p.provenance[targetName]
+}
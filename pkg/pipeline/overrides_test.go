@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleMerger_Conflicts(t *testing.T) {
+	merger, err := newBundleMerger(Target{})
+	require.NoError(t, err)
+
+	merged := map[string]interface{}{}
+	merger.merge(merged, "app", map[string]interface{}{
+		"TLS_MIN": "1.2",
+		"SAME":    "x",
+		"HOSTS":   []interface{}{"a"},
+		"LOGGING": map[string]interface{}{"level": "info"},
+	}, ValueOrigin{Source: "security-baseline"})
+	merger.merge(merged, "app", map[string]interface{}{
+		"TLS_MIN": "1.0",
+		"SAME":    "x",
+		"HOSTS":   []interface{}{"b"},
+		"LOGGING": map[string]interface{}{"level": "debug"},
+	}, ValueOrigin{Source: "analytics"})
+
+	// Only scalars whose value changed are overrides, including scalars nested
+	// in merged maps; lists are combined
+	assert.Equal(t, []diff.KeyOverride{
+		{Path: "app", Key: "LOGGING.level", Source: "analytics", Overridden: "security-baseline"},
+		{Path: "app", Key: "TLS_MIN", Source: "analytics", Overridden: "security-baseline"},
+	}, merger.conflicts)
+
+	firstWins, err := newBundleMerger(Target{MergeStrategy: "first_wins"})
+	require.NoError(t, err)
+	merged = map[string]interface{}{}
+	firstWins.merge(merged, "app", map[string]interface{}{"TLS_MIN": "1.2"}, ValueOrigin{Source: "security-baseline"})
+	firstWins.merge(merged, "app", map[string]interface{}{"TLS_MIN": "1.0"}, ValueOrigin{Source: "analytics"})
+	assert.Empty(t, firstWins.conflicts)
+}
+
+func TestBundleMerger_ReplaceConflicts(t *testing.T) {
+	merger, err := newBundleMerger(Target{})
+	require.NoError(t, err)
+
+	merged := map[string]interface{}{}
+	merger.merge(merged, "token", "abc", ValueOrigin{Source: "security-baseline"})
+	merger.merge(merged, "token", "abc", ValueOrigin{Source: "analytics"})
+	merger.merge(merged, "token", "xyz", ValueOrigin{Source: "payments"})
+	merger.merge(merged, "db", map[string]interface{}{
+		"conn": map[string]interface{}{"password": "s3cret"},
+	}, ValueOrigin{Source: "security-baseline"})
+	merger.merge(merged, "db", "postgres://db", ValueOrigin{Source: "analytics"})
+
+	// Identical values are not overrides; replaced maps report each scalar
+	assert.Equal(t, []diff.KeyOverride{
+		{Path: "token", Source: "payments", Overridden: "analytics"},
+		{Path: "db", Key: "conn.password", Source: "analytics", Overridden: "security-baseline"},
+	}, merger.conflicts)
+}
+
+func TestCheckOverrides_NestedProtectedKey(t *testing.T) {
+	cfg := &Config{
+		Sources: map[string]Source{
+			"security-baseline": {Vault: &VaultSource{Mount: "baseline"}},
+			"analytics":         {Vault: &VaultSource{Mount: "analytics"}},
+		},
+		Targets: map[string]Target{"Prod": {Imports: []string{"security-baseline", "analytics"}}},
+		Pipeline: PipelineSettings{Merge: MergeSettings{
+			OnProtectedOverride: ProtectedOverrideFail,
+			ProtectedKeys:       []ProtectedKeyRule{{Source: "security-baseline", Keys: []string{`^db\.password$`}}},
+		}},
+	}
+	p := &Pipeline{config: cfg}
+
+	merger, err := newBundleMerger(cfg.Targets["Prod"])
+	require.NoError(t, err)
+	merged := map[string]interface{}{}
+	merger.merge(merged, "app", map[string]interface{}{
+		"db": map[string]interface{}{"user": "app", "password": "baseline"},
+	}, ValueOrigin{Source: "security-baseline"})
+	merger.merge(merged, "app", map[string]interface{}{
+		"db": map[string]interface{}{"password": "override"},
+	}, ValueOrigin{Source: "analytics"})
+
+	overrides, err := p.checkOverrides("Prod", merger.conflicts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 protected keys overridden")
+	require.Len(t, overrides, 1)
+	assert.Equal(t, "db.password", overrides[0].Key)
+	assert.True(t, overrides[0].Protected)
+}
+
+func TestCompileProtectedKeys(t *testing.T) {
+	_, err := compileProtectedKeys(MergeSettings{OnProtectedOverride: "ignore"})
+	assert.Error(t, err)
+
+	_, err = compileProtectedKeys(MergeSettings{ProtectedKeys: []ProtectedKeyRule{{Keys: []string{"^TLS_"}}}})
+	assert.Error(t, err)
+
+	_, err = compileProtectedKeys(MergeSettings{ProtectedKeys: []ProtectedKeyRule{{Source: "baseline", Keys: []string{"("}}}})
+	assert.Error(t, err)
+
+	rules, err := compileProtectedKeys(MergeSettings{
+		OnProtectedOverride: ProtectedOverrideFail,
+		ProtectedKeys:       []ProtectedKeyRule{{Source: "security-baseline", Paths: []string{"app/**"}, Keys: []string{"^TLS_"}}},
+	})
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.True(t, rules[0].protects(diff.KeyOverride{Path: "app/web", Key: "TLS_MIN", Overridden: "security-baseline"}))
+	assert.True(t, rules[0].protects(diff.KeyOverride{Path: "app/web", Key: "TLS_MIN", Overridden: "Stg", Origin: "security-baseline"}))
+	assert.False(t, rules[0].protects(diff.KeyOverride{Path: "db", Key: "TLS_MIN", Overridden: "security-baseline"}))
+	assert.False(t, rules[0].protects(diff.KeyOverride{Path: "app/web", Key: "API_KEY", Overridden: "security-baseline"}))
+	assert.False(t, rules[0].protects(diff.KeyOverride{Path: "app/web", Key: "TLS_MIN", Overridden: "analytics"}))
+}
+
+func TestCheckOverrides(t *testing.T) {
+	cfg := &Config{
+		Targets: map[string]Target{
+			"Stg":  {Imports: []string{"security-baseline"}},
+			"Prod": {Imports: []string{"Stg", "analytics"}},
+		},
+		Pipeline: PipelineSettings{Merge: MergeSettings{
+			ProtectedKeys: []ProtectedKeyRule{{Source: "security-baseline"}},
+		}},
+	}
+	p := &Pipeline{config: cfg}
+	p.cacheProvenance(newBundleProvenance("Stg", "id", nil, map[string]map[string]*KeyProvenance{
+		"app": {"TLS_MIN": {Winner: ValueOrigin{Source: "security-baseline"}}},
+	}))
+
+	overrides := []diff.KeyOverride{
+		{Path: "app", Key: "TLS_MIN", Source: "analytics", Overridden: "Stg"},
+		{Path: "app", Key: "DEBUG", Source: "analytics", Overridden: "Stg"},
+	}
+
+	checked, err := p.checkOverrides("Prod", overrides)
+	require.NoError(t, err)
+	require.Len(t, checked, 2)
+	assert.Equal(t, "DEBUG", checked[0].Key)
+	assert.False(t, checked[0].Protected)
+	assert.Equal(t, "TLS_MIN", checked[1].Key)
+	assert.Equal(t, "security-baseline", checked[1].Origin)
+	assert.True(t, checked[1].Protected)
+
+	cfg.Pipeline.Merge.OnProtectedOverride = ProtectedOverrideFail
+	_, err = p.checkOverrides("Prod", overrides)
+	assert.Error(t, err)
+}
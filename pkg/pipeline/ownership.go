@@ -0,0 +1,50 @@
+package pipeline
+
+import "sort"
+
+// Ownership tags written by sync on every secret it manages.
+// Orphan deletion only ever touches secrets carrying these tags for the
+// target being synced, so secrets created by hand are never removed.
+const (
+	TagManagedBy   = "secretsync:managed-by"
+	TagTarget      = "secretsync:target"
+	TagBundleID    = "secretsync:bundle-id"
+	ManagedByValue = "secretsync"
+)
+
+// ownershipTags returns the tags identifying a secret as managed for a target
+func ownershipTags(targetName, bundleID string) map[string]string {
+	return map[string]string{
+		TagManagedBy: ManagedByValue,
+		TagTarget:    targetName,
+		TagBundleID:  bundleID,
+	}
+}
+
+// isManagedBy reports whether tags mark a secret as managed for the given target
+func isManagedBy(tags map[string]string, targetName string) bool {
+	return tags[TagManagedBy] == ManagedByValue && tags[TagTarget] == targetName
+}
+
+// findOrphans returns managed secrets for a target that are not in the desired set.
+// secretTags maps secret names to their tags as listed from AWS.
+func findOrphans(secretTags map[string]map[string]string, desired map[string]bool, targetName string) []string {
+	var orphans []string
+	for name, tags := range secretTags {
+		if desired[name] || !isManagedBy(tags, targetName) {
+			continue
+		}
+		orphans = append(orphans, name)
+	}
+	sort.Strings(orphans)
+	return orphans
+}
+
+// getTargetBundleID returns the bundle ID for a target's current import sequence
+func (p *Pipeline) getTargetBundleID(target Target) string {
+	var sourcePaths []string
+	for _, importName := range target.Imports {
+		sourcePaths = append(sourcePaths, p.config.GetSourcePath(importName))
+	}
+	return BundleID(sourcePaths)
+}
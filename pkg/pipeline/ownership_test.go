@@ -0,0 +1,54 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOwnershipTags(t *testing.T) {
+	tags := ownershipTags("Serverless_Stg", "abc123")
+
+	assert.Equal(t, ManagedByValue, tags[TagManagedBy])
+	assert.Equal(t, "Serverless_Stg", tags[TagTarget])
+	assert.Equal(t, "abc123", tags[TagBundleID])
+	assert.True(t, isManagedBy(tags, "Serverless_Stg"))
+	assert.False(t, isManagedBy(tags, "Serverless_Prod"))
+	assert.False(t, isManagedBy(nil, "Serverless_Stg"))
+}
+
+func TestFindOrphans(t *testing.T) {
+	secretTags := map[string]map[string]string{
+		// Still in the bundle
+		"api-keys/stripe": ownershipTags("Stg", "abc"),
+		// Managed by this target, removed from bundle
+		"api-keys/old": ownershipTags("Stg", "abc"),
+		// Managed under a previous bundle ID
+		"legacy/config": ownershipTags("Stg", "old-bundle"),
+		// Managed by another target in the same account
+		"api-keys/prod": ownershipTags("Prod", "def"),
+		// Created by hand
+		"manual/secret": {"team": "platform"},
+		"untagged":      nil,
+	}
+	desired := map[string]bool{"api-keys/stripe": true}
+
+	orphans := findOrphans(secretTags, desired, "Stg")
+
+	assert.Equal(t, []string{"api-keys/old", "legacy/config"}, orphans)
+}
+
+func TestGetTargetBundleID(t *testing.T) {
+	cfg := &Config{
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Mount: "analytics"}},
+		},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged-secrets"}},
+		Targets: map[string]Target{
+			"Stg": {AccountID: "111111111111", Imports: []string{"analytics"}},
+		},
+	}
+	p := &Pipeline{config: cfg}
+
+	assert.Equal(t, BundleID([]string{"analytics"}), p.getTargetBundleID(cfg.Targets["Stg"]))
+}
@@ -35,13 +35,16 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	reqctx "github.com/jbcom/secretsync/pkg/context"
 	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/jbcom/secretsync/pkg/observability"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Operation defines what the pipeline should do
@@ -71,6 +74,24 @@ type Pipeline struct {
 
 	pipelineDiff *diff.PipelineDiff
 	diffMu       sync.Mutex
+
+	// Provenance of bundles merged in this process, used to trace
+	// overrides through inherited targets
+	provenance   map[string]*BundleProvenance
+	provenanceMu sync.Mutex
+
+	historySinks []HistorySink
+	caller       string // Cached caller identity for run records
+	notifier     *Notifier
+	schemas      *secretSchemas
+
+	// Policy engine, account tags and findings of the current run, and the
+	// targets whose bundle was checked against the key rules in it
+	policy        *policyEngine
+	policyTags    *accountTagCache
+	policyReport  *PolicyReport
+	policyChecked map[string]bool
+	policyMu      sync.Mutex
 }
 
 // Options configures pipeline execution
@@ -82,8 +103,19 @@ type Options struct {
 	Parallelism     int
 	ComputeDiff     bool
 	OutputFormat    diff.OutputFormat
+
+	// DiffValues sets how much of changed values the diff reveals.
+	// Values are hidden by default.
+	DiffValues diff.ValueMode
+
+	// Stop, when closed, stops the run from starting further targets.
+	// Targets already running finish; the rest fail with ErrStopped.
+	Stop <-chan struct{}
 }
 
+// ErrStopped is the error of targets not started because Options.Stop was closed
+var ErrStopped = errors.New("pipeline stopped before target started")
+
 // DefaultOptions returns sensible default options
 func DefaultOptions() Options {
 	return Options{
@@ -118,6 +150,8 @@ type ResultDetails struct {
 	DestinationPath  string   `json:"destination_path,omitempty"`
 	RoleARN          string   `json:"role_arn,omitempty"`
 	FailedImports    []string `json:"failed_imports,omitempty"`
+
+	Overrides []diff.KeyOverride `json:"overrides,omitempty"` // Scalar keys overridden during merge
 }
 
 // New creates a new Pipeline from configuration
@@ -131,9 +165,15 @@ func New(cfg *Config) (*Pipeline, error) {
 		return nil, fmt.Errorf("failed to build dependency graph: %w", err)
 	}
 
+	schemas, err := compileSecretSchemas(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	return &Pipeline{
-		config: cfg,
-		graph:  graph,
+		config:  cfg,
+		graph:   graph,
+		schemas: schemas,
 	}, nil
 }
 
@@ -167,18 +207,18 @@ func NewWithContext(ctx context.Context, cfg *Config) (*Pipeline, error) {
 	return p, nil
 }
 
-// NewFromFile creates a Pipeline from a configuration file
-func NewFromFile(path string) (*Pipeline, error) {
-	cfg, err := LoadConfig(path)
+// NewFromFile creates a Pipeline from a configuration file and overlays
+func NewFromFile(path string, overlays ...string) (*Pipeline, error) {
+	cfg, err := LoadConfig(path, overlays...)
 	if err != nil {
 		return nil, err
 	}
 	return New(cfg)
 }
 
-// NewFromFileWithContext creates a Pipeline from a configuration file with context
-func NewFromFileWithContext(ctx context.Context, path string) (*Pipeline, error) {
-	cfg, err := LoadConfig(path)
+// NewFromFileWithContext creates a Pipeline from a configuration file and overlays with context
+func NewFromFileWithContext(ctx context.Context, path string, overlays ...string) (*Pipeline, error) {
+	cfg, err := LoadConfig(path, overlays...)
 	if err != nil {
 		return nil, err
 	}
@@ -188,9 +228,12 @@ func NewFromFileWithContext(ctx context.Context, path string) (*Pipeline, error)
 // Run executes the pipeline with the given options.
 // Each operation (merge, sync) is distinct and idempotent.
 func (p *Pipeline) Run(ctx context.Context, opts Options) ([]Result, error) {
-	// Generate request ID and add to context
-	reqCtx := reqctx.NewRequestContext()
-	ctx = reqctx.WithRequestContext(ctx, reqCtx)
+	// Generate request ID and add to context, unless the caller supplied one
+	reqCtx := reqctx.FromContext(ctx)
+	if reqCtx == nil {
+		reqCtx = reqctx.NewRequestContext()
+		ctx = reqctx.WithRequestContext(ctx, reqCtx)
+	}
 
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -207,7 +250,7 @@ func (p *Pipeline) Run(ctx context.Context, opts Options) ([]Result, error) {
 	p.resultsMu.Unlock()
 
 	if opts.DryRun || opts.ComputeDiff {
-		p.initDiff(opts.DryRun, "")
+		p.initDiff(opts.DryRun, "", opts.DiffValues)
 	}
 
 	targets := p.resolveTargets(opts.Targets)
@@ -222,18 +265,43 @@ func (p *Pipeline) Run(ctx context.Context, opts Options) ([]Result, error) {
 
 	p.initialized = true
 
+	ctx, span := observability.StartSpan(ctx, "pipeline.run",
+		attribute.String("secretsync.operation", string(opts.Operation)),
+		attribute.Bool("secretsync.dry_run", opts.DryRun),
+		attribute.Int("secretsync.targets", len(targets)),
+	)
+
 	var results []Result
 	var err error
+	defer func() { observability.EndSpan(span, err) }()
 
 	switch opts.Operation {
-	case OperationMerge:
-		results, err = p.runMerge(ctx, targets, opts)
-	case OperationSync:
-		results, err = p.runSync(ctx, targets, opts)
-	case OperationPipeline:
-		results, err = p.runPipeline(ctx, targets, opts)
+	case OperationMerge, OperationSync, OperationPipeline:
 	default:
-		return nil, fmt.Errorf("unknown operation: %s", opts.Operation)
+		err = fmt.Errorf("unknown operation: %s", opts.Operation)
+		return nil, err
+	}
+
+	// Policy rules are checked before anything is read or written
+	if err = p.enforcePolicies(ctx, targets); err == nil {
+		switch opts.Operation {
+		case OperationMerge:
+			results, err = p.runMerge(ctx, targets, opts)
+		case OperationSync:
+			results, err = p.runSync(ctx, targets, opts)
+		case OperationPipeline:
+			results, err = p.runPipeline(ctx, targets, opts)
+		}
+	}
+
+	if p.config.History.enabled() || p.config.Notifications.enabled() {
+		rec := p.newRunRecord(ctx, opts, targets, results, err)
+		if p.config.History.enabled() {
+			p.recordRun(ctx, rec)
+		}
+		if p.config.Notifications.enabled() {
+			p.notifyRun(ctx, rec)
+		}
 	}
 
 	if err != nil {
@@ -528,3 +528,52 @@ func TestS3Config(t *testing.T) {
 	assert.Equal(t, "key-123", config.KMSKeyID)
 }
 
+
+func TestExecuteParallel_Stop(t *testing.T) {
+	p := &Pipeline{}
+	stop := make(chan struct{})
+
+	var ran []string
+	results := p.executeParallel(context.Background(), []string{"a", "b", "c"}, 1, stop, func(target string) Result {
+		ran = append(ran, target)
+		if target == "a" {
+			close(stop) // the running target finishes, the rest are not started
+		}
+		return Result{Target: target, Success: true}
+	})
+
+	assert.Equal(t, []string{"a"}, ran)
+	assert.True(t, results[0].Success)
+	assert.ErrorIs(t, results[1].Error, ErrStopped)
+	assert.ErrorIs(t, results[2].Error, ErrStopped)
+}
+
+func TestPipeline_FailedSourceHoldsBackSync(t *testing.T) {
+	// Nothing listens on the Vault address, so the source cannot be read
+	t.Setenv("VAULT_TOKEN", "test")
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+	cfg := &Config{
+		Vault:      VaultConfig{Address: "http://127.0.0.1:1"},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Sources:    map[string]Source{"analytics": {Vault: &VaultSource{Mount: "analytics"}}},
+		Targets: map[string]Target{
+			"Production": {AccountID: "123456789012", Imports: []string{"analytics"}},
+		},
+		Pipeline: PipelineSettings{Sync: SyncSettings{DeleteOrphans: true}},
+	}
+	p, err := New(cfg)
+	require.NoError(t, err)
+
+	opts := DefaultOptions()
+	opts.ContinueOnError = true
+	results, _ := p.Run(context.Background(), opts)
+
+	// The partial bundle is not written, and the previous one is not synced
+	// (which would delete the failed import's secrets as orphans)
+	require.Len(t, results, 1)
+	assert.Equal(t, "merge", results[0].Phase)
+	assert.False(t, results[0].Success)
+	var sourceErr *SourceReadError
+	require.ErrorAs(t, results[0].Error, &sourceErr)
+	assert.Equal(t, []string{"analytics"}, results[0].Details.FailedImports)
+}
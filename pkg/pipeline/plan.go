@@ -0,0 +1,430 @@
+package pipeline
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/jbcom/secretsync/pkg/diff"
+	log "github.com/sirupsen/logrus"
+)
+
+// PlanFormatVersion is the version of the plan file format
+const PlanFormatVersion = 1
+
+// Plan is a saved dry run that Apply executes later.
+// It records each target's bundle ID and content hashes of what the run
+// reads and writes, so Apply can refuse to run if anything changed since.
+// Plans contain hashes and the redacted diff, never secret values.
+type Plan struct {
+	Version    int                `json:"version"`
+	CreatedAt  time.Time          `json:"created_at"`
+	ConfigHash string             `json:"config_hash"`
+	Operation  Operation          `json:"operation"`
+	Targets    []PlanTarget       `json:"targets"`
+	Diff       *diff.PipelineDiff `json:"diff,omitempty"`
+}
+
+// PlanTarget records the state of one target when the plan was made
+type PlanTarget struct {
+	Target          string `json:"target"`
+	BundleID        string `json:"bundle_id"`
+	SourceHash      string `json:"source_hash"`                // Merged imports (merge) or stored bundle (sync)
+	MergeStoreHash  string `json:"merge_store_hash,omitempty"` // Bundle currently in the merge store (merge)
+	DestinationHash string `json:"destination_hash,omitempty"` // AWS secrets the sync would touch (sync)
+}
+
+// PlanDrift is a difference between a plan and the current state
+type PlanDrift struct {
+	Target  string `json:"target,omitempty"`
+	Kind    string `json:"kind"` // config, bundle_id, source, merge_store, destination
+	Planned string `json:"planned"`
+	Current string `json:"current"`
+}
+
+// PlanDriftError is returned by Apply when the plan no longer matches reality
+type PlanDriftError struct {
+	Drift []PlanDrift
+}
+
+func (e *PlanDriftError) Error() string {
+	parts := make([]string, 0, len(e.Drift))
+	for _, d := range e.Drift {
+		if d.Target == "" {
+			parts = append(parts, fmt.Sprintf("%s changed", d.Kind))
+		} else {
+			parts = append(parts, fmt.Sprintf("target %s: %s changed", d.Target, d.Kind))
+		}
+	}
+	return fmt.Sprintf("plan is stale, re-run plan: %s", strings.Join(parts, "; "))
+}
+
+// Plan performs a dry run and records what Apply must find unchanged.
+// The returned plan includes the diff of the dry run.
+func (p *Pipeline) Plan(ctx context.Context, opts Options) (*Plan, error) {
+	l := log.WithFields(log.Fields{
+		"action":    "Pipeline.Plan",
+		"operation": opts.Operation,
+	})
+
+	opts.DryRun = true
+	opts.ComputeDiff = true
+	// Plan files never contain values, not even masked
+	opts.DiffValues = diff.ValuesHidden
+	results, err := p.Run(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("dry run failed: %w", err)
+	}
+	for _, r := range results {
+		if !r.Success {
+			return nil, fmt.Errorf("dry run failed for target %s: %w", r.Target, r.Error)
+		}
+	}
+
+	plan := &Plan{
+		Version:    PlanFormatVersion,
+		CreatedAt:  time.Now().UTC(),
+		ConfigHash: p.config.Hash(),
+		Operation:  opts.Operation,
+		Diff:       p.Diff(),
+	}
+	// Targets are in dependency order, so inherited targets are hashed
+	// against the bundles their parents will be merged to
+	planned := make(map[string]map[string]interface{})
+	for _, targetName := range p.resolveTargets(opts.Targets) {
+		state, err := p.targetState(ctx, targetName, opts.Operation, planned)
+		if err != nil {
+			return nil, fmt.Errorf("target %s: %w", targetName, err)
+		}
+		plan.Targets = append(plan.Targets, state)
+	}
+
+	l.WithField("targets", len(plan.Targets)).Info("Plan created")
+	return plan, nil
+}
+
+// VerifyPlan checks that the configuration, sources and destinations of a
+// plan are unchanged. Returns a *PlanDriftError listing every difference.
+func (p *Pipeline) VerifyPlan(ctx context.Context, plan *Plan) error {
+	if plan.Version != PlanFormatVersion {
+		return fmt.Errorf("unsupported plan version %d (expected %d)", plan.Version, PlanFormatVersion)
+	}
+
+	if err := p.checkPlanOrder(plan); err != nil {
+		return err
+	}
+
+	var drift []PlanDrift
+	if current := p.config.Hash(); current != plan.ConfigHash {
+		drift = append(drift, PlanDrift{Kind: "config", Planned: plan.ConfigHash, Current: current})
+	}
+
+	merged := make(map[string]map[string]interface{})
+	for _, planned := range plan.Targets {
+		current, err := p.targetState(ctx, planned.Target, plan.Operation, merged)
+		if err != nil {
+			return fmt.Errorf("target %s: %w", planned.Target, err)
+		}
+		check := func(kind, plannedHash, currentHash string) {
+			if plannedHash != currentHash {
+				drift = append(drift, PlanDrift{Target: planned.Target, Kind: kind, Planned: plannedHash, Current: currentHash})
+			}
+		}
+		check("bundle_id", planned.BundleID, current.BundleID)
+		check("source", planned.SourceHash, current.SourceHash)
+		check("merge_store", planned.MergeStoreHash, current.MergeStoreHash)
+		check("destination", planned.DestinationHash, current.DestinationHash)
+	}
+
+	if len(drift) > 0 {
+		return &PlanDriftError{Drift: drift}
+	}
+	return nil
+}
+
+// Apply verifies a plan and runs exactly that plan. The operation and targets
+// come from the plan; DryRun in opts is ignored. Nothing is written if any
+// drift is found. The run carries the plan, and each target checks its
+// planned hashes again right before it writes: a merge refuses to write a
+// bundle that differs from the planned one or to replace a merge store bundle
+// that changed, and a sync refuses to write when the bundle it read or the
+// AWS secrets it would touch changed. A refused target fails with a
+// *PlanDriftError and writes nothing.
+func (p *Pipeline) Apply(ctx context.Context, plan *Plan, opts Options) ([]Result, error) {
+	if err := p.VerifyPlan(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	opts.Operation = plan.Operation
+	opts.DryRun = false
+	opts.Targets = nil
+	for _, t := range plan.Targets {
+		opts.Targets = append(opts.Targets, t.Target)
+	}
+	return p.Run(withAppliedPlan(ctx, plan), opts)
+}
+
+// appliedPlanKey is the context key of the plan being applied
+type appliedPlanKey struct{}
+
+// withAppliedPlan returns a context carrying the plan Apply runs
+func withAppliedPlan(ctx context.Context, plan *Plan) context.Context {
+	return context.WithValue(ctx, appliedPlanKey{}, plan)
+}
+
+// appliedPlanTarget returns the planned state of a target when ctx belongs to
+// an Apply, along with the plan's operation
+func appliedPlanTarget(ctx context.Context, targetName string) (PlanTarget, Operation, bool) {
+	plan, ok := ctx.Value(appliedPlanKey{}).(*Plan)
+	if !ok || plan == nil {
+		return PlanTarget{}, "", false
+	}
+	for _, t := range plan.Targets {
+		if t.Target == targetName {
+			return t, plan.Operation, true
+		}
+	}
+	return PlanTarget{}, "", false
+}
+
+// checkPlannedMerge is called by a merge right before it writes the bundle.
+// When applying a plan, the merged bundle and the bundle it replaces must
+// still hash to the planned values.
+func (p *Pipeline) checkPlannedMerge(ctx context.Context, targetName, bundlePath string, merged map[string]interface{}) error {
+	planned, _, ok := appliedPlanTarget(ctx, targetName)
+	if !ok {
+		return nil
+	}
+	stored, err := p.readStoredBundle(ctx, targetName, bundlePath)
+	if err != nil {
+		return err
+	}
+	return planDrift(targetName,
+		PlanDrift{Kind: "source", Planned: planned.SourceHash, Current: contentHash(merged)},
+		PlanDrift{Kind: "merge_store", Planned: planned.MergeStoreHash, Current: contentHash(stored)},
+	)
+}
+
+// checkPlannedSync is called by a sync right before it writes to AWS. When
+// applying a plan, the AWS secrets the sync would touch must still hash to the
+// planned value, and for sync-only plans so must the bundle it read (stored,
+// before filters and transforms). desired maps AWS names to the values written.
+func (p *Pipeline) checkPlannedSync(ctx context.Context, targetName string, target Target, stored map[string]map[string]interface{}, desired map[string]interface{}) error {
+	planned, op, ok := appliedPlanTarget(ctx, targetName)
+	if !ok {
+		return nil
+	}
+	var drift []PlanDrift
+	if op == OperationSync {
+		drift = append(drift, PlanDrift{Kind: "source", Planned: planned.SourceHash, Current: contentHash(stored)})
+	}
+	destination, err := p.destinationHash(ctx, targetName, target, desired)
+	if err != nil {
+		return err
+	}
+	drift = append(drift, PlanDrift{Kind: "destination", Planned: planned.DestinationHash, Current: destination})
+	return planDrift(targetName, drift...)
+}
+
+// planDrift returns a *PlanDriftError for the checks whose hashes differ
+func planDrift(targetName string, checks ...PlanDrift) error {
+	var drift []PlanDrift
+	for _, d := range checks {
+		if d.Planned != d.Current {
+			d.Target = targetName
+			drift = append(drift, d)
+		}
+	}
+	if len(drift) > 0 {
+		return &PlanDriftError{Drift: drift}
+	}
+	return nil
+}
+
+// checkPlanOrder refuses plans that merge a target without the targets it
+// inherits from, or before them: the target would be merged against a parent
+// bundle that was never planned
+func (p *Pipeline) checkPlanOrder(plan *Plan) error {
+	if plan.Operation == OperationSync {
+		return nil
+	}
+	seen := make(map[string]bool, len(plan.Targets))
+	for _, t := range plan.Targets {
+		target, ok := p.config.Targets[t.Target]
+		if !ok {
+			return fmt.Errorf("target %s: target not found", t.Target)
+		}
+		for _, importSpec := range target.Imports {
+			parent := importSourceName(importSpec)
+			if _, isTarget := p.config.Targets[parent]; isTarget && !seen[parent] {
+				return fmt.Errorf("target %s inherits from %s, which the plan does not merge before it; re-run plan", t.Target, parent)
+			}
+		}
+		seen[t.Target] = true
+	}
+	return nil
+}
+
+// targetState hashes what a run of the operation would read and overwrite for a target.
+// For merges, inherited targets are read from planned, and the target's merged
+// bundle is added to it for the targets that inherit from it.
+func (p *Pipeline) targetState(ctx context.Context, targetName string, op Operation, planned map[string]map[string]interface{}) (PlanTarget, error) {
+	target, ok := p.config.Targets[targetName]
+	if !ok {
+		return PlanTarget{}, fmt.Errorf("target not found")
+	}
+
+	var sourcePaths []string
+	for _, importName := range target.Imports {
+		sourcePaths = append(sourcePaths, p.config.GetSourcePath(importName))
+	}
+	state := PlanTarget{Target: targetName, BundleID: BundleID(sourcePaths)}
+
+	bundlePath, err := p.GetBundlePath(targetName)
+	if err != nil {
+		return state, err
+	}
+	stored, err := p.readStoredBundle(ctx, targetName, bundlePath)
+	if err != nil {
+		return state, err
+	}
+
+	// The bundle sync would read: stored for sync, freshly merged otherwise
+	bundle := stored
+	if op == OperationSync {
+		state.SourceHash = contentHash(stored)
+	} else {
+		merged, _, _, err := p.mergeSourcesWith(ctx, targetName, target, sourcePaths, planned)
+		if err != nil {
+			return state, err
+		}
+		planned[targetName] = merged
+		state.SourceHash = contentHash(merged)
+		state.MergeStoreHash = contentHash(stored)
+
+		bundle = make(map[string]map[string]interface{}, len(merged))
+		for secretPath, data := range merged {
+			if m, ok := data.(map[string]interface{}); ok {
+				bundle[secretPath] = m
+			}
+		}
+	}
+
+	if op == OperationMerge {
+		return state, nil
+	}
+
+	transformer, err := newSecretTransformer(target)
+	if err == nil {
+		bundle, err = transformer.apply(bundle)
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to apply filters and transforms: %w", err)
+	}
+	names, err := p.awsSecretNames(targetName, bundle)
+	if err != nil {
+		return state, err
+	}
+	desired := make(map[string]interface{}, len(bundle))
+	for secretPath, data := range bundle {
+		desired[names[secretPath]] = data
+	}
+
+	state.DestinationHash, err = p.destinationHash(ctx, targetName, target, desired)
+	return state, err
+}
+
+// readStoredBundle reads a target's bundle from the merge store. A bundle
+// that was never written is empty.
+func (p *Pipeline) readStoredBundle(ctx context.Context, targetName, bundlePath string) (map[string]map[string]interface{}, error) {
+	stored, err := p.readBundleSecrets(ctx, targetName, bundlePath)
+	if err != nil {
+		var notFound *s3types.NoSuchKey
+		if !errors.As(err, &notFound) {
+			return nil, fmt.Errorf("failed to read merge store: %w", err)
+		}
+		stored = make(map[string]map[string]interface{})
+	}
+	return stored, nil
+}
+
+// destinationHash hashes the AWS secrets a sync of desired (keyed by AWS
+// name) would overwrite or, with delete_orphans, delete
+func (p *Pipeline) destinationHash(ctx context.Context, targetName string, target Target, desired map[string]interface{}) (string, error) {
+	region := target.Region
+	if region == "" {
+		region = p.config.AWS.Region
+	}
+	accountSecrets, accountTags, err := p.fetchAWSSecretsWithTags(ctx, p.getRoleARNForTarget(target), region, p.currentStateScope(targetName, desired))
+	if err != nil {
+		return "", fmt.Errorf("failed to read AWS secrets: %w", err)
+	}
+	return contentHash(p.selectCurrentState(targetName, accountSecrets, accountTags, desired)), nil
+}
+
+// contentHash returns a SHA-256 of a value's canonical JSON encoding
+// (encoding/json sorts map keys)
+func contentHash(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// Hash returns a hash of the configuration that decides what a run does.
+// Vault credentials are excluded so a plan can be applied by another job.
+func (c *Config) Hash() string {
+	cfg := *c
+	cfg.Vault.Auth = VaultAuthConfig{}
+	return contentHash(cfg)
+}
+
+// WritePlan writes a plan as gzip-compressed JSON
+func WritePlan(path string, plan *Plan) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(plan); err != nil {
+		return fmt.Errorf("failed to encode plan: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress plan: %w", err)
+	}
+	return os.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// ReadPlan reads a plan written by WritePlan
+func ReadPlan(path string) (*Plan, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open plan: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan (not a plan file?): %w", err)
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan: %w", err)
+	}
+	var plan Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	return &plan, nil
+}
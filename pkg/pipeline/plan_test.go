@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/jbcom/secretsync/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanRoundTrip(t *testing.T) {
+	plan := &Plan{
+		Version:    PlanFormatVersion,
+		ConfigHash: "cfg",
+		Operation:  OperationPipeline,
+		Targets: []PlanTarget{
+			{Target: "Prod", BundleID: "abc", SourceHash: "src", MergeStoreHash: "ms", DestinationHash: "dst"},
+		},
+		Diff: &diff.PipelineDiff{DryRun: true},
+	}
+
+	path := filepath.Join(t.TempDir(), "plan.bin")
+	require.NoError(t, WritePlan(path, plan))
+
+	read, err := ReadPlan(path)
+	require.NoError(t, err)
+	assert.Equal(t, plan.Targets, read.Targets)
+	assert.Equal(t, OperationPipeline, read.Operation)
+	assert.True(t, read.Diff.DryRun)
+
+	_, err = ReadPlan(filepath.Join(t.TempDir(), "missing.bin"))
+	assert.Error(t, err)
+}
+
+func TestConfigHash(t *testing.T) {
+	cfg := &Config{Targets: map[string]Target{"Prod": {AccountID: "111111111111", Imports: []string{"analytics"}}}}
+	hash := cfg.Hash()
+	assert.NotEmpty(t, hash)
+
+	// Credentials do not change the hash
+	cfg.Vault.Auth.Token = &TokenAuth{Token: "s.secret"}
+	assert.Equal(t, hash, cfg.Hash())
+	assert.NotNil(t, cfg.Vault.Auth.Token, "Hash must not modify the config")
+
+	cfg.Targets["Prod"] = Target{AccountID: "111111111111", Imports: []string{"analytics", "common"}}
+	assert.NotEqual(t, hash, cfg.Hash())
+}
+
+func TestContentHash(t *testing.T) {
+	a := map[string]interface{}{"x": "1", "y": map[string]interface{}{"b": 2.0, "a": 1.0}}
+	b := map[string]interface{}{"y": map[string]interface{}{"a": 1.0, "b": 2.0}, "x": "1"}
+	assert.Equal(t, contentHash(a), contentHash(b))
+	assert.NotEqual(t, contentHash(a), contentHash(map[string]interface{}{"x": "2"}))
+}
+
+func TestVerifyPlan_ConfigDrift(t *testing.T) {
+	cfg := &Config{Targets: map[string]Target{"Prod": {Imports: []string{"analytics"}}}}
+	p := &Pipeline{config: cfg}
+
+	plan := &Plan{Version: PlanFormatVersion, ConfigHash: cfg.Hash(), Operation: OperationMerge}
+	assert.NoError(t, p.VerifyPlan(context.Background(), plan))
+
+	plan.ConfigHash = "stale"
+	err := p.VerifyPlan(context.Background(), plan)
+	var driftErr *PlanDriftError
+	require.True(t, errors.As(err, &driftErr))
+	require.Len(t, driftErr.Drift, 1)
+	assert.Equal(t, "config", driftErr.Drift[0].Kind)
+	assert.Contains(t, err.Error(), "config changed")
+
+	_, err = p.Apply(context.Background(), plan, Options{})
+	assert.True(t, errors.As(err, &driftErr))
+
+	plan.Version = 99
+	assert.Error(t, p.VerifyPlan(context.Background(), plan))
+}
+
+func TestVerifyPlan_InheritedOrder(t *testing.T) {
+	cfg := &Config{Targets: map[string]Target{
+		"Stg":  {Imports: []string{"analytics"}},
+		"Prod": {Imports: []string{"Stg"}},
+	}}
+	p := &Pipeline{config: cfg}
+
+	plan := &Plan{Version: PlanFormatVersion, ConfigHash: cfg.Hash(), Operation: OperationPipeline,
+		Targets: []PlanTarget{{Target: "Prod"}}}
+	err := p.VerifyPlan(context.Background(), plan)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "target Prod inherits from Stg, which the plan does not merge before it")
+
+	plan.Targets = []PlanTarget{{Target: "Prod"}, {Target: "Stg"}}
+	assert.ErrorContains(t, p.VerifyPlan(context.Background(), plan), "inherits from Stg")
+
+	// Sync-only plans read the stored bundle of every target
+	plan.Operation = OperationSync
+	plan.Targets = []PlanTarget{{Target: "Prod"}}
+	assert.NoError(t, p.checkPlanOrder(plan))
+}
+
+func TestMergeSourcesWith_PlannedParent(t *testing.T) {
+	cfg := &Config{
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{
+			"Stg":  {Imports: []string{"analytics"}},
+			"Prod": {Imports: []string{"Stg"}},
+		},
+	}
+	p := &Pipeline{config: cfg}
+	planned := map[string]map[string]interface{}{
+		"Stg": {"app": map[string]interface{}{"HOSTS": []interface{}{"a"}}},
+	}
+
+	target := cfg.Targets["Prod"]
+	merged, _, failed, err := p.mergeSourcesWith(context.Background(), "Prod", target, []string{cfg.GetSourcePath("Stg")}, planned)
+	require.NoError(t, err)
+	assert.Empty(t, failed)
+	assert.Equal(t, planned["Stg"], merged)
+
+	// The planned parent bundle is not modified by merging
+	merged["app"].(map[string]interface{})["HOSTS"] = []interface{}{"b"}
+	assert.Equal(t, []interface{}{"a"}, planned["Stg"]["app"].(map[string]interface{})["HOSTS"])
+}
+
+func TestPlanDriftError(t *testing.T) {
+	err := &PlanDriftError{Drift: []PlanDrift{
+		{Target: "Prod", Kind: "source"},
+		{Target: "Prod", Kind: "destination"},
+	}}
+	assert.Equal(t, "plan is stale, re-run plan: target Prod: source changed; target Prod: destination changed", err.Error())
+}
+
+func TestCheckPlannedMerge(t *testing.T) {
+	p := &Pipeline{config: &Config{Targets: map[string]Target{"Stg": {Imports: []string{"analytics"}}}}}
+	merged := map[string]interface{}{"app": map[string]interface{}{"KEY": "planned"}}
+
+	// Outside Apply nothing is checked
+	assert.NoError(t, p.checkPlannedMerge(context.Background(), "Stg", "merged/Stg", merged))
+
+	plan := &Plan{Operation: OperationPipeline, Targets: []PlanTarget{{
+		Target:         "Stg",
+		SourceHash:     contentHash(merged),
+		MergeStoreHash: contentHash(map[string]map[string]interface{}{}),
+	}}}
+	ctx := withAppliedPlan(context.Background(), plan)
+	planned, op, ok := appliedPlanTarget(ctx, "Stg")
+	require.True(t, ok)
+	assert.Equal(t, OperationPipeline, op)
+	assert.Equal(t, "Stg", planned.Target)
+	_, _, ok = appliedPlanTarget(ctx, "Prod")
+	assert.False(t, ok)
+
+	assert.NoError(t, p.checkPlannedMerge(ctx, "Stg", "merged/Stg", merged))
+
+	// A source that changed after VerifyPlan is refused before the write
+	changed := map[string]interface{}{"app": map[string]interface{}{"KEY": "changed"}}
+	err := p.checkPlannedMerge(ctx, "Stg", "merged/Stg", changed)
+	var drift *PlanDriftError
+	require.ErrorAs(t, err, &drift)
+	require.Len(t, drift.Drift, 1)
+	assert.Equal(t, "Stg", drift.Drift[0].Target)
+	assert.Equal(t, "source", drift.Drift[0].Kind)
+}
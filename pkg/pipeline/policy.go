@@ -0,0 +1,629 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Policy severities, from most to least severe
+const (
+	PolicySeverityError   = "error"
+	PolicySeverityWarning = "warning"
+	PolicySeverityNote    = "note"
+
+	// PolicyFailOnNever reports findings without ever blocking a run
+	PolicyFailOnNever = "never"
+)
+
+// policySeverityRank orders severities for policies.fail_on
+var policySeverityRank = map[string]int{
+	PolicySeverityNote:    1,
+	PolicySeverityWarning: 2,
+	PolicySeverityError:   3,
+}
+
+// PolicyFinding is a policy rule violation. Findings name targets, secret
+// paths and keys, never secret values.
+type PolicyFinding struct {
+	Rule     string `json:"rule"`
+	Severity string `json:"severity"`
+	Target   string `json:"target,omitempty"`
+	Path     string `json:"path,omitempty"` // Secret path in the target's bundle
+	Key      string `json:"key,omitempty"`  // Key within the secret, dotted for nested keys
+	Message  string `json:"message"`
+	Blocking bool   `json:"blocking"`
+}
+
+// PolicyReport is the result of checking the policy rules
+type PolicyReport struct {
+	FailOn   string          `json:"fail_on"`
+	Findings []PolicyFinding `json:"findings"`
+
+	rules []PolicyRule
+}
+
+// Blocking returns the findings that block the run
+func (r *PolicyReport) Blocking() []PolicyFinding {
+	var blocking []PolicyFinding
+	for _, f := range r.Findings {
+		if f.Blocking {
+			blocking = append(blocking, f)
+		}
+	}
+	return blocking
+}
+
+// Err returns a PolicyViolationError if any finding blocks the run
+func (r *PolicyReport) Err() error {
+	if blocking := r.Blocking(); len(blocking) > 0 {
+		return &PolicyViolationError{Findings: blocking}
+	}
+	return nil
+}
+
+// PolicyViolationError is returned when policy findings block a run
+type PolicyViolationError struct {
+	Findings []PolicyFinding
+}
+
+func (e *PolicyViolationError) Error() string {
+	parts := make([]string, 0, len(e.Findings))
+	for _, f := range e.Findings {
+		parts = append(parts, f.String())
+	}
+	return fmt.Sprintf("policy check failed with %d blocking findings: %s", len(e.Findings), strings.Join(parts, "; "))
+}
+
+// String formats the finding as "rule: target: message"
+func (f PolicyFinding) String() string {
+	if f.Target == "" {
+		return fmt.Sprintf("%s: %s", f.Rule, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", f.Rule, f.Target, f.Message)
+}
+
+// AccountTagsFunc returns the tags of an AWS account, for rules selecting
+// targets by account tag
+type AccountTagsFunc func(ctx context.Context, accountID string) (map[string]string, error)
+
+// policyEngine holds the compiled policy rules
+type policyEngine struct {
+	failOn string
+	rules  []*compiledPolicyRule
+	config []PolicyRule
+}
+
+// compiledPolicyRule is a PolicyRule with its patterns compiled
+type compiledPolicyRule struct {
+	name        string
+	severity    string
+	names       []string
+	accountTags map[string]string
+	denyImports []string
+	maxImports  int
+
+	keyPaths  *compiledFilter
+	keys      *compiledFilter
+	deny      bool
+	minLength int
+}
+
+// validate checks the policy rules
+func (c PoliciesConfig) validate() error {
+	_, err := compilePolicies(c)
+	return err
+}
+
+// compilePolicies validates and compiles the policy rules
+func compilePolicies(cfg PoliciesConfig) (*policyEngine, error) {
+	e := &policyEngine{failOn: cfg.FailOn, config: cfg.Rules}
+	if e.failOn == "" {
+		e.failOn = PolicySeverityError
+	}
+	if _, ok := policySeverityRank[e.failOn]; !ok && e.failOn != PolicyFailOnNever {
+		return nil, fmt.Errorf("policies.fail_on %q must be error, warning, note or never", cfg.FailOn)
+	}
+
+	seen := make(map[string]bool)
+	for i, r := range cfg.Rules {
+		field := fmt.Sprintf("policies.rules[%d]", i)
+		if r.Name == "" {
+			return nil, fmt.Errorf("%s: name is required", field)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("%s: duplicate rule name %q", field, r.Name)
+		}
+		seen[r.Name] = true
+
+		rule := &compiledPolicyRule{
+			name:        r.Name,
+			severity:    r.Severity,
+			denyImports: r.DenyImports,
+			maxImports:  r.MaxImports,
+		}
+		if rule.severity == "" {
+			rule.severity = PolicySeverityError
+		}
+		if _, ok := policySeverityRank[rule.severity]; !ok {
+			return nil, fmt.Errorf("%s: severity %q must be error, warning or note", field, r.Severity)
+		}
+		if r.Targets != nil {
+			rule.names = r.Targets.Names
+			rule.accountTags = r.Targets.AccountTags
+		}
+		for _, pattern := range append(append([]string{}, rule.names...), rule.denyImports...) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: invalid pattern %q: %w", field, pattern, err)
+			}
+		}
+		if r.MaxImports < 0 {
+			return nil, fmt.Errorf("%s: max_imports must not be negative", field)
+		}
+		if r.Keys != nil {
+			if len(r.Keys.Keys) == 0 {
+				return nil, fmt.Errorf("%s.keys: keys is required", field)
+			}
+			if r.Keys.Deny == (r.Keys.MinLength > 0) {
+				return nil, fmt.Errorf("%s.keys: set exactly one of deny or min_length", field)
+			}
+			paths, err := compilePathFilter(&PatternFilter{Include: r.Keys.Paths})
+			if err != nil {
+				return nil, fmt.Errorf("%s.keys.paths: %w", field, err)
+			}
+			keys, err := compileRegexFilter(&PatternFilter{Include: r.Keys.Keys})
+			if err != nil {
+				return nil, fmt.Errorf("%s.keys.keys: %w", field, err)
+			}
+			rule.keyPaths, rule.keys = paths, keys
+			rule.deny, rule.minLength = r.Keys.Deny, r.Keys.MinLength
+		}
+		if len(rule.denyImports) == 0 && rule.maxImports == 0 && rule.keys == nil {
+			return nil, fmt.Errorf("%s: at least one of deny_imports, max_imports or keys is required", field)
+		}
+		e.rules = append(e.rules, rule)
+	}
+	return e, nil
+}
+
+// blocks reports whether a finding of the given severity blocks the run
+func (e *policyEngine) blocks(severity string) bool {
+	if e.failOn == PolicyFailOnNever {
+		return false
+	}
+	return policySeverityRank[severity] >= policySeverityRank[e.failOn]
+}
+
+// finding creates a finding of a rule
+func (e *policyEngine) finding(r *compiledPolicyRule, target, message string) PolicyFinding {
+	return PolicyFinding{
+		Rule:     r.name,
+		Severity: r.severity,
+		Target:   target,
+		Message:  message,
+		Blocking: e.blocks(r.severity),
+	}
+}
+
+// accountTagCache looks up each account's tags once per run
+type accountTagCache struct {
+	lookup AccountTagsFunc
+	mu     sync.Mutex
+	tags   map[string]map[string]string
+	errs   map[string]error
+}
+
+func newAccountTagCache(lookup AccountTagsFunc) *accountTagCache {
+	return &accountTagCache{
+		lookup: lookup,
+		tags:   make(map[string]map[string]string),
+		errs:   make(map[string]error),
+	}
+}
+
+// get returns the tags of an account
+func (c *accountTagCache) get(ctx context.Context, accountID string) (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tags, ok := c.tags[accountID]; ok {
+		return tags, nil
+	}
+	if err, ok := c.errs[accountID]; ok {
+		return nil, err
+	}
+	tags, err := c.lookup(ctx, accountID)
+	if err != nil {
+		c.errs[accountID] = err
+		return nil, err
+	}
+	c.tags[accountID] = tags
+	return tags, nil
+}
+
+// selects reports whether the rule applies to a target. A non-empty note
+// explains why a rule selecting by account tag could not be evaluated.
+func (r *compiledPolicyRule) selects(ctx context.Context, targetName string, target Target, tags *accountTagCache) (bool, string) {
+	if len(r.names) > 0 {
+		matched := false
+		for _, pattern := range r.names {
+			if ok, _ := path.Match(pattern, targetName); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, ""
+		}
+	}
+	if len(r.accountTags) == 0 {
+		return true, ""
+	}
+	if target.AccountID == "" {
+		return false, "target has no account_id; account tags not checked"
+	}
+	if tags == nil || tags.lookup == nil {
+		return false, "account tags unavailable without AWS access; rule not evaluated"
+	}
+	accountTags, err := tags.get(ctx, target.AccountID)
+	if err != nil {
+		return false, fmt.Sprintf("failed to read account tags; rule not evaluated: %v", err)
+	}
+	for k, v := range r.accountTags {
+		if accountTags[k] != v {
+			return false, ""
+		}
+	}
+	return true, ""
+}
+
+// checkConfig evaluates the import rules against the named targets
+func (e *policyEngine) checkConfig(ctx context.Context, cfg *Config, targets []string, tags *accountTagCache) []PolicyFinding {
+	targets = append([]string{}, targets...)
+	sort.Strings(targets)
+
+	var findings []PolicyFinding
+	for _, r := range e.rules {
+		for _, name := range targets {
+			target, ok := cfg.Targets[name]
+			if !ok {
+				continue
+			}
+			selected, note := r.selects(ctx, name, target, tags)
+			if note != "" {
+				f := e.finding(r, name, note)
+				f.Severity, f.Blocking = PolicySeverityNote, false
+				findings = append(findings, f)
+			}
+			if !selected {
+				continue
+			}
+
+			if r.maxImports > 0 && len(target.Imports) > r.maxImports {
+				findings = append(findings, e.finding(r, name,
+					fmt.Sprintf("imports %d sources, more than the maximum of %d", len(target.Imports), r.maxImports)))
+			}
+			for _, src := range cfg.importedSources(name) {
+				for _, pattern := range r.denyImports {
+					if ok, _ := path.Match(pattern, src.name); !ok {
+						continue
+					}
+					msg := fmt.Sprintf("imports denied source %q", src.name)
+					if len(src.via) > 0 {
+						msg += " via " + strings.Join(src.via, " -> ")
+					}
+					findings = append(findings, e.finding(r, name, msg))
+					break
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// checkBundle evaluates the key rules against a target's merged bundle
+func (e *policyEngine) checkBundle(ctx context.Context, targetName string, target Target, secrets map[string]interface{}, tags *accountTagCache) []PolicyFinding {
+	paths := make([]string, 0, len(secrets))
+	for p := range secrets {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var findings []PolicyFinding
+	for _, r := range e.rules {
+		if r.keys == nil {
+			continue
+		}
+		// Notes about unevaluated rules were reported by checkConfig
+		if selected, _ := r.selects(ctx, targetName, target, tags); !selected {
+			continue
+		}
+		for _, secretPath := range paths {
+			if !r.keyPaths.matches(secretPath) {
+				continue
+			}
+			data, ok := secrets[secretPath].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			walkPolicyKeys(data, "", func(key, leaf string, value interface{}) {
+				if !r.keys.matches(leaf) {
+					return
+				}
+				var msg string
+				if r.deny {
+					msg = fmt.Sprintf("secret %s contains denied key %s", secretPath, key)
+				} else if s, ok := value.(string); ok && len([]rune(s)) < r.minLength {
+					msg = fmt.Sprintf("value of key %s in secret %s is shorter than %d characters", key, secretPath, r.minLength)
+				} else {
+					return
+				}
+				f := e.finding(r, targetName, msg)
+				f.Path, f.Key = secretPath, key
+				findings = append(findings, f)
+			})
+		}
+	}
+	return findings
+}
+
+// walkPolicyKeys calls fn for every key of a secret in sorted order,
+// descending into nested objects. key is the dotted path, leaf the key name.
+func walkPolicyKeys(data map[string]interface{}, prefix string, fn func(key, leaf string, value interface{})) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		fn(key, k, data[k])
+		if nested, ok := data[k].(map[string]interface{}); ok {
+			walkPolicyKeys(nested, key, fn)
+		}
+	}
+}
+
+// importedSource is a source a target imports, directly or through the
+// inherited targets in via
+type importedSource struct {
+	name string
+	via  []string
+}
+
+// importedSources returns the sources a target imports, following inherited targets
+func (c *Config) importedSources(targetName string) []importedSource {
+	var sources []importedSource
+	seen := make(map[string]bool)
+	var walk func(name string, via []string)
+	walk = func(name string, via []string) {
+		target, ok := c.Targets[name]
+		if !ok {
+			return
+		}
+		for _, spec := range target.Imports {
+			imp := importSourceName(spec)
+			if seen[imp] {
+				continue
+			}
+			seen[imp] = true
+			if _, isTarget := c.Targets[imp]; isTarget {
+				walk(imp, append(append([]string{}, via...), imp))
+				continue
+			}
+			sources = append(sources, importedSource{name: imp, via: via})
+		}
+	}
+	walk(targetName, nil)
+	return sources
+}
+
+// CheckPolicies evaluates the import rules against the named targets, or
+// every target when none are named. Key rules need merged bundles and are
+// checked by Run. accountTags may be nil, in which case rules selecting by
+// account tag are reported as not evaluated.
+func (c *Config) CheckPolicies(ctx context.Context, targets []string, accountTags AccountTagsFunc) (*PolicyReport, error) {
+	engine, err := compilePolicies(c.Policies)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		for name := range c.Targets {
+			targets = append(targets, name)
+		}
+	}
+	report := &PolicyReport{FailOn: engine.failOn, rules: engine.config}
+	report.Findings = engine.checkConfig(ctx, c, targets, newAccountTagCache(accountTags))
+	return report, nil
+}
+
+// accountTagsFunc returns the account tag lookup of the AWS execution context, if any
+func (p *Pipeline) accountTagsFunc() AccountTagsFunc {
+	if p.awsCtx == nil {
+		return nil
+	}
+	return p.awsCtx.GetAccountTags
+}
+
+// enforcePolicies checks the import rules at the start of a run and prepares
+// the bundle checks of the merge and sync phases
+func (p *Pipeline) enforcePolicies(ctx context.Context, targets []string) error {
+	p.policyMu.Lock()
+	p.policy, p.policyReport, p.policyTags = nil, nil, nil
+	p.policyChecked = make(map[string]bool)
+	p.policyMu.Unlock()
+
+	if len(p.config.Policies.Rules) == 0 {
+		return nil
+	}
+	engine, err := compilePolicies(p.config.Policies)
+	if err != nil {
+		return err
+	}
+	tags := newAccountTagCache(p.accountTagsFunc())
+	findings := engine.checkConfig(ctx, p.config, targets, tags)
+
+	p.policyMu.Lock()
+	p.policy, p.policyTags = engine, tags
+	p.policyReport = &PolicyReport{FailOn: engine.failOn, rules: engine.config}
+	p.policyMu.Unlock()
+
+	return p.addPolicyFindings(findings)
+}
+
+// checkBundlePolicies checks a target's merged bundle against the key rules.
+// Returns a PolicyViolationError if a finding blocks the target.
+func (p *Pipeline) checkBundlePolicies(ctx context.Context, targetName string, target Target, secrets map[string]interface{}) error {
+	p.policyMu.Lock()
+	engine, tags := p.policy, p.policyTags
+	if p.policyChecked != nil {
+		p.policyChecked[targetName] = true
+	}
+	p.policyMu.Unlock()
+	if engine == nil {
+		return nil
+	}
+	return p.addPolicyFindings(engine.checkBundle(ctx, targetName, target, secrets, tags))
+}
+
+// checkSyncedBundlePolicies checks the bundle a sync read from the merge store
+// against the key rules, so sync-only runs are blocked like merges. Targets
+// whose merged bundle was already checked in this run are not checked twice.
+func (p *Pipeline) checkSyncedBundlePolicies(ctx context.Context, targetName string, target Target, secrets map[string]interface{}) error {
+	p.policyMu.Lock()
+	checked := p.policyChecked[targetName]
+	p.policyMu.Unlock()
+	if checked {
+		return nil
+	}
+	return p.checkBundlePolicies(ctx, targetName, target, secrets)
+}
+
+// addPolicyFindings logs findings and adds them to the run's report
+func (p *Pipeline) addPolicyFindings(findings []PolicyFinding) error {
+	for _, f := range findings {
+		l := log.WithFields(log.Fields{
+			"action":   "policy",
+			"rule":     f.Rule,
+			"severity": f.Severity,
+			"target":   f.Target,
+			"blocking": f.Blocking,
+		})
+		if f.Severity == PolicySeverityNote {
+			l.Info(f.Message)
+		} else {
+			l.Warn(f.Message)
+		}
+	}
+
+	p.policyMu.Lock()
+	p.policyReport.Findings = append(p.policyReport.Findings, findings...)
+	p.policyMu.Unlock()
+
+	return (&PolicyReport{Findings: findings}).Err()
+}
+
+// PolicyReport returns the policy findings of the last Run, or nil if no
+// policy rules are configured
+func (p *Pipeline) PolicyReport() *PolicyReport {
+	p.policyMu.Lock()
+	defer p.policyMu.Unlock()
+	if p.policyReport == nil {
+		return nil
+	}
+	report := *p.policyReport
+	report.Findings = append([]PolicyFinding{}, p.policyReport.Findings...)
+	return &report
+}
+
+// JSON returns the report as indented JSON
+func (r *PolicyReport) JSON() ([]byte, error) {
+	out := *r
+	if out.Findings == nil {
+		out.Findings = []PolicyFinding{}
+	}
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// SARIF returns the report as a SARIF 2.1.0 log. Findings are located in
+// configPath, with the target, secret path and key as logical locations.
+func (r *PolicyReport) SARIF(configPath, version string) ([]byte, error) {
+	type message struct {
+		Text string `json:"text"`
+	}
+	type rule struct {
+		ID                   string            `json:"id"`
+		ShortDescription     message           `json:"shortDescription"`
+		DefaultConfiguration map[string]string `json:"defaultConfiguration"`
+	}
+	type logicalLocation struct {
+		Name               string `json:"name"`
+		FullyQualifiedName string `json:"fullyQualifiedName"`
+		Kind               string `json:"kind"`
+	}
+	type location struct {
+		PhysicalLocation map[string]interface{} `json:"physicalLocation,omitempty"`
+		LogicalLocations []logicalLocation      `json:"logicalLocations,omitempty"`
+	}
+	type result struct {
+		RuleID    string     `json:"ruleId"`
+		Level     string     `json:"level"`
+		Message   message    `json:"message"`
+		Locations []location `json:"locations"`
+	}
+
+	rules := make([]rule, 0, len(r.rules))
+	for _, pr := range r.rules {
+		desc := pr.Description
+		if desc == "" {
+			desc = pr.Name
+		}
+		level := pr.Severity
+		if level == "" {
+			level = PolicySeverityError
+		}
+		rules = append(rules, rule{ID: pr.Name, ShortDescription: message{Text: desc}, DefaultConfiguration: map[string]string{"level": level}})
+	}
+
+	results := make([]result, 0, len(r.Findings))
+	for _, f := range r.Findings {
+		loc := location{}
+		if configPath != "" {
+			loc.PhysicalLocation = map[string]interface{}{"artifactLocation": map[string]string{"uri": configPath}}
+		}
+		if f.Target != "" {
+			name := f.Target
+			if f.Path != "" {
+				name += "/" + strings.Trim(f.Path, "/")
+			}
+			if f.Key != "" {
+				name += "#" + f.Key
+			}
+			loc.LogicalLocations = []logicalLocation{{Name: f.Target, FullyQualifiedName: name, Kind: "target"}}
+		}
+		results = append(results, result{RuleID: f.Rule, Level: f.Severity, Message: message{Text: f.Message}, Locations: []location{loc}})
+	}
+
+	sarif := map[string]interface{}{
+		"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+		"version": "2.1.0",
+		"runs": []interface{}{map[string]interface{}{
+			"tool": map[string]interface{}{"driver": map[string]interface{}{
+				"name":           "secretsync",
+				"version":        version,
+				"informationUri": "https://github.com/jbcom/secretsync",
+				"rules":          rules,
+			}},
+			"results": results,
+		}},
+	}
+	return json.MarshalIndent(sarif, "", "  ")
+}
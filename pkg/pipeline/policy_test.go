@@ -0,0 +1,294 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func policyTestConfig(rules ...PolicyRule) *Config {
+	return &Config{
+		Sources: map[string]Source{
+			"analytics":     {Vault: &VaultSource{Mount: "analytics"}},
+			"analytics-dev": {Vault: &VaultSource{Mount: "analytics-dev"}},
+			"shared":        {Vault: &VaultSource{Mount: "shared"}},
+		},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{
+			"Dev":        {AccountID: "111111111111", Imports: []string{"analytics-dev", "shared"}},
+			"Staging":    {AccountID: "222222222222", Imports: []string{"Dev"}},
+			"Production": {AccountID: "333333333333", Imports: []string{"analytics", "shared"}},
+		},
+		Policies: PoliciesConfig{Rules: rules},
+	}
+}
+
+func staticAccountTags(tags map[string]map[string]string) AccountTagsFunc {
+	return func(ctx context.Context, accountID string) (map[string]string, error) {
+		if t, ok := tags[accountID]; ok {
+			return t, nil
+		}
+		return nil, errors.New("account not found")
+	}
+}
+
+func TestCompilePolicies(t *testing.T) {
+	_, err := compilePolicies(PoliciesConfig{Rules: []PolicyRule{{Name: "max", MaxImports: 3}}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		cfg  PoliciesConfig
+		want string
+	}{
+		{"fail_on", PoliciesConfig{FailOn: "critical"}, "policies.fail_on"},
+		{"name", PoliciesConfig{Rules: []PolicyRule{{MaxImports: 1}}}, "name is required"},
+		{"duplicate", PoliciesConfig{Rules: []PolicyRule{{Name: "a", MaxImports: 1}, {Name: "a", MaxImports: 2}}}, "duplicate rule name"},
+		{"severity", PoliciesConfig{Rules: []PolicyRule{{Name: "a", Severity: "fatal", MaxImports: 1}}}, `severity "fatal"`},
+		{"no checks", PoliciesConfig{Rules: []PolicyRule{{Name: "a"}}}, "at least one of"},
+		{"pattern", PoliciesConfig{Rules: []PolicyRule{{Name: "a", DenyImports: []string{"[dev"}}}}, "invalid pattern"},
+		{"keys", PoliciesConfig{Rules: []PolicyRule{{Name: "a", Keys: &PolicyKeyRule{MinLength: 16}}}}, "keys is required"},
+		{"keys check", PoliciesConfig{Rules: []PolicyRule{{Name: "a", Keys: &PolicyKeyRule{Keys: []string{"password"}}}}}, "exactly one of deny or min_length"},
+		{"keys regex", PoliciesConfig{Rules: []PolicyRule{{Name: "a", Keys: &PolicyKeyRule{Keys: []string{"("}, Deny: true}}}}, "keys.keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := compilePolicies(tt.cfg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestConfig_CheckPolicies(t *testing.T) {
+	cfg := policyTestConfig(
+		PolicyRule{
+			Name:        "no-dev-in-prod",
+			Targets:     &PolicyTargetSelector{AccountTags: map[string]string{"env": "prod"}},
+			DenyImports: []string{"*-dev"},
+		},
+		PolicyRule{Name: "max-imports", Severity: PolicySeverityWarning, MaxImports: 1},
+	)
+	tags := staticAccountTags(map[string]map[string]string{
+		"111111111111": {"env": "dev"},
+		"222222222222": {"env": "prod"},
+		"333333333333": {"env": "prod"},
+	})
+
+	report, err := cfg.CheckPolicies(context.Background(), nil, tags)
+	require.NoError(t, err)
+
+	require.Len(t, report.Findings, 3)
+	assert.Equal(t, PolicyFinding{
+		Rule:     "no-dev-in-prod",
+		Severity: PolicySeverityError,
+		Target:   "Staging",
+		Message:  `imports denied source "analytics-dev" via Dev`,
+		Blocking: true,
+	}, report.Findings[0])
+	assert.Equal(t, "max-imports", report.Findings[1].Rule)
+	assert.Equal(t, "Dev", report.Findings[1].Target)
+	assert.False(t, report.Findings[1].Blocking, "warnings do not block by default")
+	assert.Equal(t, "Production", report.Findings[2].Target)
+
+	var violation *PolicyViolationError
+	require.ErrorAs(t, report.Err(), &violation)
+	assert.Len(t, violation.Findings, 1)
+
+	// Without account tags the rule is reported as not evaluated
+	report, err = cfg.CheckPolicies(context.Background(), []string{"Staging"}, nil)
+	require.NoError(t, err)
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, PolicySeverityNote, report.Findings[0].Severity)
+	assert.Contains(t, report.Findings[0].Message, "not evaluated")
+	assert.NoError(t, report.Err())
+}
+
+func TestConfig_CheckPoliciesFailOn(t *testing.T) {
+	cfg := policyTestConfig(PolicyRule{Name: "max-imports", Severity: PolicySeverityWarning, MaxImports: 1})
+
+	cfg.Policies.FailOn = PolicySeverityWarning
+	report, err := cfg.CheckPolicies(context.Background(), nil, nil)
+	require.NoError(t, err)
+	assert.Error(t, report.Err())
+
+	cfg.Policies.FailOn = PolicyFailOnNever
+	report, err = cfg.CheckPolicies(context.Background(), nil, nil)
+	require.NoError(t, err)
+	assert.Len(t, report.Findings, 2)
+	assert.NoError(t, report.Err())
+}
+
+func TestPolicyEngine_CheckBundle(t *testing.T) {
+	engine, err := compilePolicies(PoliciesConfig{Rules: []PolicyRule{
+		{Name: "strong-passwords", Keys: &PolicyKeyRule{Keys: []string{"(?i)password"}, MinLength: 16}},
+		{Name: "no-root-keys", Severity: PolicySeverityWarning, Keys: &PolicyKeyRule{Paths: []string{"aws/**"}, Keys: []string{"^root_"}, Deny: true}},
+	}})
+	require.NoError(t, err)
+
+	secrets := map[string]interface{}{
+		"db/main": map[string]interface{}{
+			"DB_PASSWORD": "hunter2",
+			"admin":       map[string]interface{}{"password": "correct-horse-battery-staple"},
+		},
+		"aws/creds": map[string]interface{}{"root_access_key": "AKIA", "password": 12345},
+	}
+
+	findings := engine.checkBundle(context.Background(), "Production", Target{}, secrets, newAccountTagCache(nil))
+	require.Len(t, findings, 2)
+
+	assert.Equal(t, "strong-passwords", findings[0].Rule)
+	assert.Equal(t, "db/main", findings[0].Path)
+	assert.Equal(t, "DB_PASSWORD", findings[0].Key)
+	assert.True(t, findings[0].Blocking)
+
+	assert.Equal(t, "no-root-keys", findings[1].Rule)
+	assert.Equal(t, "aws/creds", findings[1].Path)
+	assert.Equal(t, "root_access_key", findings[1].Key)
+	assert.False(t, findings[1].Blocking)
+
+	for _, f := range findings {
+		assert.NotContains(t, f.Message, "hunter2")
+		assert.NotContains(t, f.Message, "AKIA")
+	}
+}
+
+func TestPipeline_RunBlockedByPolicy(t *testing.T) {
+	cfg := policyTestConfig(PolicyRule{Name: "no-dev", DenyImports: []string{"*-dev"}})
+	p, err := New(cfg)
+	require.NoError(t, err)
+
+	opts := DefaultOptions()
+	opts.DryRun = true
+	results, err := p.Run(context.Background(), opts)
+
+	var violation *PolicyViolationError
+	require.ErrorAs(t, err, &violation)
+	assert.Empty(t, results, "no target runs when a policy blocks")
+	require.Len(t, violation.Findings, 2)
+	assert.Equal(t, "Dev", violation.Findings[0].Target)
+	assert.Equal(t, "Staging", violation.Findings[1].Target)
+
+	report := p.PolicyReport()
+	require.NotNil(t, report)
+	assert.Len(t, report.Findings, 2)
+}
+
+func TestPipeline_CheckBundlePolicies(t *testing.T) {
+	cfg := policyTestConfig(PolicyRule{Name: "strong-passwords", Keys: &PolicyKeyRule{Keys: []string{"(?i)password"}, MinLength: 16}})
+	p, err := New(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, p.enforcePolicies(context.Background(), []string{"Production"}))
+	err = p.checkBundlePolicies(context.Background(), "Production", cfg.Targets["Production"], map[string]interface{}{
+		"db": map[string]interface{}{"password": "short"},
+	})
+	var violation *PolicyViolationError
+	require.ErrorAs(t, err, &violation)
+	assert.Equal(t, "db", violation.Findings[0].Path)
+	assert.Len(t, p.PolicyReport().Findings, 1)
+}
+
+func TestPipeline_CheckSyncedBundlePolicies(t *testing.T) {
+	cfg := policyTestConfig(PolicyRule{Name: "strong-passwords", Keys: &PolicyKeyRule{Keys: []string{"(?i)password"}, MinLength: 16}})
+	p, err := New(cfg)
+	require.NoError(t, err)
+	weak := map[string]interface{}{"db": map[string]interface{}{"password": "short"}}
+
+	// A sync-only run checks the stored bundle
+	require.NoError(t, p.enforcePolicies(context.Background(), []string{"Production"}))
+	err = p.checkSyncedBundlePolicies(context.Background(), "Production", cfg.Targets["Production"], weak)
+	var violation *PolicyViolationError
+	require.ErrorAs(t, err, &violation)
+	assert.Len(t, p.PolicyReport().Findings, 1)
+
+	// A bundle checked by this run's merge phase is not reported twice
+	require.NoError(t, p.enforcePolicies(context.Background(), []string{"Production"}))
+	require.Error(t, p.checkBundlePolicies(context.Background(), "Production", cfg.Targets["Production"], weak))
+	assert.NoError(t, p.checkSyncedBundlePolicies(context.Background(), "Production", cfg.Targets["Production"], weak))
+	assert.Len(t, p.PolicyReport().Findings, 1)
+}
+
+func TestPolicyReport_Formats(t *testing.T) {
+	cfg := policyTestConfig(PolicyRule{Name: "no-dev", Description: "No dev sources", DenyImports: []string{"*-dev"}})
+	report, err := cfg.CheckPolicies(context.Background(), []string{"Dev"}, nil)
+	require.NoError(t, err)
+	report.Findings[0].Path, report.Findings[0].Key = "db", "password"
+
+	out, err := report.JSON()
+	require.NoError(t, err)
+	var decoded PolicyReport
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	assert.Equal(t, report.Findings, decoded.Findings)
+	assert.Equal(t, PolicySeverityError, decoded.FailOn)
+
+	out, err = report.SARIF("config.yaml", "1.2.3")
+	require.NoError(t, err)
+	var sarif struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string `json:"name"`
+					Rules []struct {
+						ID               string `json:"id"`
+						ShortDescription struct {
+							Text string `json:"text"`
+						} `json:"shortDescription"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+					LogicalLocations []struct {
+						FullyQualifiedName string `json:"fullyQualifiedName"`
+					} `json:"logicalLocations"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal(out, &sarif))
+	assert.Equal(t, "2.1.0", sarif.Version)
+	require.Len(t, sarif.Runs, 1)
+	assert.Equal(t, "No dev sources", sarif.Runs[0].Tool.Driver.Rules[0].ShortDescription.Text)
+	require.Len(t, sarif.Runs[0].Results, 1)
+	result := sarif.Runs[0].Results[0]
+	assert.Equal(t, "no-dev", result.RuleID)
+	assert.Equal(t, "error", result.Level)
+	assert.Equal(t, "config.yaml", result.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, "Dev/db#password", result.Locations[0].LogicalLocations[0].FullyQualifiedName)
+}
+
+func TestPoliciesConfig_YAML(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+policies:
+  fail_on: warning
+  rules:
+    - name: no-dev-in-prod
+      targets:
+        account_tags: {env: prod}
+      deny_imports: ["*-dev"]
+    - name: strong-passwords
+      severity: warning
+      keys:
+        keys: ["(?i)password"]
+        min_length: 16
+`), &cfg))
+	require.Len(t, cfg.Policies.Rules, 2)
+	assert.Equal(t, map[string]string{"env": "prod"}, cfg.Policies.Rules[0].Targets.AccountTags)
+	assert.Equal(t, 16, cfg.Policies.Rules[1].Keys.MinLength)
+	assert.NoError(t, cfg.Policies.validate())
+}
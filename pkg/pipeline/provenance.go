@@ -0,0 +1,382 @@
+package pipeline
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jbcom/secretsync/pkg/client/vault"
+	"github.com/jbcom/secretsync/pkg/diff"
+	log "github.com/sirupsen/logrus"
+)
+
+// ValueOrigin identifies where a value in a merged bundle came from.
+// Values are never stored, only a redacted description of them.
+type ValueOrigin struct {
+	Source  string `json:"source"`            // Import entry (source or inherited target)
+	Path    string `json:"path"`              // Full path of the secret in the source
+	Version int    `json:"version,omitempty"` // Vault KV version, when known
+	Value   string `json:"value,omitempty"`   // Redacted value (type and size)
+}
+
+// KeyProvenance records which import supplied a key of a merged secret
+type KeyProvenance struct {
+	Winner     ValueOrigin   `json:"winner"`               // Import whose value is in the bundle
+	Merged     []ValueOrigin `json:"merged,omitempty"`     // Earlier values combined into the winner (maps, lists)
+	Overridden []ValueOrigin `json:"overridden,omitempty"` // Values that lost, in import order
+}
+
+// BundleProvenance is the key-level provenance of a merged bundle.
+// It is stored next to the bundle in the merge store.
+type BundleProvenance struct {
+	Target    string                               `json:"target"`
+	BundleID  string                               `json:"bundle_id"`
+	Sources   []string                             `json:"sources"`
+	CreatedAt time.Time                            `json:"created_at"`
+	Secrets   map[string]map[string]*KeyProvenance `json:"secrets"` // secret path → key → provenance
+}
+
+// Lookup returns the provenance of a key in a bundle secret
+func (b *BundleProvenance) Lookup(secretPath, key string) (*KeyProvenance, bool) {
+	keys, ok := b.Secrets[secretPath]
+	if !ok {
+		return nil, false
+	}
+	kp, ok := keys[key]
+	return kp, ok
+}
+
+// withValue returns a copy of the origin describing a specific value
+func (o ValueOrigin) withValue(value interface{}) ValueOrigin {
+	o.Value = redactValue(value)
+	return o
+}
+
+// redactValue describes a value without revealing it, e.g. "string(24)".
+// No hash of the value is included: provenance is stored and printed, and a
+// hash of a short secret can be brute-forced offline.
+func redactValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string(%d)", len(v))
+	case bool:
+		return "bool"
+	case float64, json.Number, int, int64:
+		return "number"
+	case map[string]interface{}:
+		return fmt.Sprintf("object(%d)", len(v))
+	case []interface{}:
+		return fmt.Sprintf("list(%d)", len(v))
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// valueFingerprint returns a short hash of a value's JSON encoding, for
+// comparing values during a merge. It is never stored or printed.
+func valueFingerprint(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "unknown"
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:4])
+}
+
+// recordSecret resets the provenance of a secret that was replaced as a whole
+func (m *bundleMerger) recordSecret(relPath string, secretData interface{}, origin ValueOrigin) {
+	m.secretSources[relPath] = origin.Source
+	dataMap, ok := secretData.(map[string]interface{})
+	if !ok {
+		delete(m.provenance, relPath)
+		return
+	}
+	keys := make(map[string]*KeyProvenance, len(dataMap))
+	for key, value := range dataMap {
+		keys[key] = &KeyProvenance{Winner: origin.withValue(value)}
+	}
+	m.provenance[relPath] = keys
+}
+
+// priorValue describes a value before a later import was merged into it
+type priorValue struct {
+	keys        []string // Key path from the top of the secret
+	fingerprint string
+	scalar      bool
+}
+
+// recordMerge updates the provenance of a secret after a later import was merged into it,
+// and records scalar values the import overrode, including scalars nested in
+// maps. before describes the values both imports set, taken before merging.
+func (m *bundleMerger) recordMerge(relPath string, before map[string]priorValue, data, result map[string]interface{}, origin ValueOrigin) {
+	keys, ok := m.provenance[relPath]
+	if !ok {
+		keys = make(map[string]*KeyProvenance, len(data))
+		m.provenance[relPath] = keys
+	}
+
+	// Conflicts are recorded before the provenance below names the new winners
+	paths := make([]string, 0, len(before))
+	for keyPath := range before {
+		paths = append(paths, keyPath)
+	}
+	sort.Strings(paths)
+	for _, keyPath := range paths {
+		prior := before[keyPath]
+		if !prior.scalar {
+			continue
+		}
+		resultFingerprint := valueFingerprint(valueAt(result, prior.keys))
+		if resultFingerprint == prior.fingerprint || resultFingerprint != valueFingerprint(valueAt(data, prior.keys)) {
+			continue
+		}
+		m.conflicts = append(m.conflicts, diff.KeyOverride{
+			Path:       relPath,
+			Key:        keyPath,
+			Source:     origin.Source,
+			Overridden: m.keySource(relPath, prior.keys[0]),
+		})
+	}
+
+	// Keys dropped by the merge (map_replace) have no provenance left
+	for key := range keys {
+		if _, ok := result[key]; !ok {
+			delete(keys, key)
+		}
+	}
+
+	for key, value := range data {
+		incoming := origin.withValue(value)
+		kp, ok := keys[key]
+		if !ok {
+			keys[key] = &KeyProvenance{Winner: incoming}
+			continue
+		}
+
+		resultFingerprint := valueFingerprint(result[key])
+		switch {
+		case resultFingerprint == valueFingerprint(value):
+			// The later value replaced everything before it
+			kp.Overridden = append(kp.Overridden, kp.Merged...)
+			kp.Overridden = append(kp.Overridden, kp.Winner)
+			kp.Merged = nil
+			kp.Winner = incoming
+		case resultFingerprint == before[key].fingerprint:
+			// The earlier value was kept (first_wins)
+			kp.Overridden = append(kp.Overridden, incoming)
+		default:
+			// Both values were combined (maps merged, lists appended)
+			kp.Merged = append(kp.Merged, kp.Winner)
+			kp.Winner = incoming
+		}
+	}
+}
+
+// recordReplace records the values a later import overrode by replacing a
+// secret as a whole: every scalar of a map secret, or the secret itself
+// (with an empty key) when it is not a map
+func (m *bundleMerger) recordReplace(relPath string, existing, secretData interface{}, origin ValueOrigin) {
+	if valueFingerprint(existing) == valueFingerprint(secretData) {
+		return
+	}
+	existingMap, ok := existing.(map[string]interface{})
+	if !ok {
+		m.conflicts = append(m.conflicts, diff.KeyOverride{
+			Path:       relPath,
+			Source:     origin.Source,
+			Overridden: m.secretSources[relPath],
+		})
+		return
+	}
+
+	scalars := make(map[string]priorValue)
+	describeScalars(scalars, nil, existingMap)
+	paths := make([]string, 0, len(scalars))
+	for keyPath := range scalars {
+		paths = append(paths, keyPath)
+	}
+	sort.Strings(paths)
+	for _, keyPath := range paths {
+		m.conflicts = append(m.conflicts, diff.KeyOverride{
+			Path:       relPath,
+			Key:        keyPath,
+			Source:     origin.Source,
+			Overridden: m.keySource(relPath, scalars[keyPath].keys[0]),
+		})
+	}
+}
+
+// keySource returns the import that supplied the current value of a key
+func (m *bundleMerger) keySource(relPath, key string) string {
+	if kp, ok := m.provenance[relPath][key]; ok {
+		return kp.Winner.Source
+	}
+	return m.secretSources[relPath]
+}
+
+// describeSharedKeys describes the existing values of keys set by both maps,
+// recursing into maps both set. Nested keys are named by their dotted path.
+func describeSharedKeys(existing, data map[string]interface{}) map[string]priorValue {
+	prior := make(map[string]priorValue)
+	describeShared(prior, nil, existing, data)
+	return prior
+}
+
+func describeShared(prior map[string]priorValue, parent []string, existing, data map[string]interface{}) {
+	for key, value := range data {
+		old, ok := existing[key]
+		if !ok {
+			continue
+		}
+		keys := append(append([]string(nil), parent...), key)
+		prior[strings.Join(keys, ".")] = priorValue{keys: keys, fingerprint: valueFingerprint(old), scalar: isScalar(old)}
+
+		oldMap, oldIsMap := old.(map[string]interface{})
+		valueMap, valueIsMap := value.(map[string]interface{})
+		if oldIsMap && valueIsMap {
+			describeShared(prior, keys, oldMap, valueMap)
+		}
+	}
+}
+
+// describeScalars describes every scalar of a map, recursing into nested maps
+func describeScalars(scalars map[string]priorValue, parent []string, data map[string]interface{}) {
+	for key, value := range data {
+		keys := append(append([]string(nil), parent...), key)
+		if nested, ok := value.(map[string]interface{}); ok {
+			describeScalars(scalars, keys, nested)
+			continue
+		}
+		if isScalar(value) {
+			scalars[strings.Join(keys, ".")] = priorValue{keys: keys, fingerprint: valueFingerprint(value), scalar: true}
+		}
+	}
+}
+
+// valueAt returns the value at a key path, or nil
+func valueAt(data map[string]interface{}, keys []string) interface{} {
+	var value interface{} = data
+	for _, key := range keys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// isScalar reports whether a JSON value is neither an object nor a list
+func isScalar(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// newBundleProvenance builds the provenance document for a merged bundle
+func newBundleProvenance(targetName, bundleID string, sourcePaths []string, secrets map[string]map[string]*KeyProvenance) *BundleProvenance {
+	return &BundleProvenance{
+		Target:    targetName,
+		BundleID:  bundleID,
+		Sources:   sourcePaths,
+		CreatedAt: time.Now().UTC(),
+		Secrets:   secrets,
+	}
+}
+
+// provenanceToMap converts provenance to the generic form stored in Vault KV
+func provenanceToMap(prov *BundleProvenance) (map[string]interface{}, error) {
+	data, err := json.Marshal(prov)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal provenance: %w", err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("failed to convert provenance: %w", err)
+	}
+	return out, nil
+}
+
+// provenanceFromMap converts provenance read from Vault KV
+func provenanceFromMap(raw map[string]interface{}) (*BundleProvenance, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal provenance: %w", err)
+	}
+	var prov BundleProvenance
+	if err := json.Unmarshal(data, &prov); err != nil {
+		return nil, fmt.Errorf("failed to parse provenance: %w", err)
+	}
+	return &prov, nil
+}
+
+// writeProvenanceToVault writes bundle provenance to the Vault merge store
+func (p *Pipeline) writeProvenanceToVault(ctx context.Context, provenancePath string, prov *BundleProvenance) error {
+	data, err := provenanceToMap(prov)
+	if err != nil {
+		return err
+	}
+
+	mergeClient := &vault.VaultClient{
+		Address:   p.config.Vault.Address,
+		Namespace: p.config.Vault.Namespace,
+	}
+	if err := mergeClient.Init(ctx); err != nil {
+		return fmt.Errorf("failed to init merge vault client: %w", err)
+	}
+
+	if _, err := mergeClient.WriteSecretOnce(ctx, provenancePath, data, nil); err != nil {
+		return fmt.Errorf("failed to write provenance %s: %w", provenancePath, err)
+	}
+	return nil
+}
+
+// ReadProvenance reads the key-level provenance of a target's current bundle
+// from the merge store. It is written by the merge phase.
+func (p *Pipeline) ReadProvenance(ctx context.Context, targetName string) (*BundleProvenance, error) {
+	l := log.WithFields(log.Fields{
+		"action": "ReadProvenance",
+		"target": targetName,
+	})
+
+	target, ok := p.config.Targets[targetName]
+	if !ok {
+		return nil, fmt.Errorf("target not found: %s", targetName)
+	}
+
+	var sourcePaths []string
+	for _, importName := range target.Imports {
+		sourcePaths = append(sourcePaths, p.config.GetSourcePath(importName))
+	}
+
+	if p.config.MergeStore.Vault != nil {
+		provenancePath := TargetProvenancePath(p.config.MergeStore.Vault.Mount, targetName, sourcePaths)
+		l.WithField("path", provenancePath).Debug("Reading provenance from Vault")
+
+		mergeClient := &vault.VaultClient{
+			Address:   p.config.Vault.Address,
+			Namespace: p.config.Vault.Namespace,
+		}
+		if err := mergeClient.Init(ctx); err != nil {
+			return nil, fmt.Errorf("failed to init merge vault client: %w", err)
+		}
+		raw, err := mergeClient.GetKVSecretOnce(ctx, provenancePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read provenance (has the merge phase run?): %w", err)
+		}
+		return provenanceFromMap(raw)
+	} else if p.s3Store != nil {
+		return p.s3Store.ReadProvenance(ctx, targetName, BundleID(sourcePaths))
+	}
+
+	return nil, fmt.Errorf("no merge store configured")
+}
@@ -0,0 +1,101 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBundleMerger_Provenance(t *testing.T) {
+	merger, err := newBundleMerger(Target{
+		MergeStrategyOverrides: []MergeStrategyOverride{{Path: "baseline/*", Strategy: "first_wins"}},
+	})
+	require.NoError(t, err)
+
+	baseline := ValueOrigin{Source: "security-baseline", Path: "baseline/app/config", Version: 3}
+	analytics := ValueOrigin{Source: "analytics", Path: "analytics/app/config", Version: 7}
+
+	merged := map[string]interface{}{}
+	merger.merge(merged, "app/config", map[string]interface{}{
+		"DB_PASSWORD": "baseline-secret",
+		"HOSTS":       []interface{}{"a"},
+	}, baseline)
+	merger.merge(merged, "app/config", map[string]interface{}{
+		"DB_PASSWORD": "analytics-secret",
+		"HOSTS":       []interface{}{"b"},
+		"API_KEY":     "k",
+	}, analytics)
+
+	kp := merger.provenance["app/config"]["DB_PASSWORD"]
+	require.NotNil(t, kp)
+	assert.Equal(t, "analytics", kp.Winner.Source)
+	assert.Equal(t, 7, kp.Winner.Version)
+	require.Len(t, kp.Overridden, 1)
+	assert.Equal(t, "security-baseline", kp.Overridden[0].Source)
+	assert.Equal(t, 3, kp.Overridden[0].Version)
+	assert.NotEqual(t, kp.Winner.Value, kp.Overridden[0].Value)
+
+	// Lists are appended: both imports contributed
+	hosts := merger.provenance["app/config"]["HOSTS"]
+	assert.Equal(t, "analytics", hosts.Winner.Source)
+	require.Len(t, hosts.Merged, 1)
+	assert.Equal(t, "security-baseline", hosts.Merged[0].Source)
+	assert.Empty(t, hosts.Overridden)
+
+	assert.Equal(t, "analytics", merger.provenance["app/config"]["API_KEY"].Winner.Source)
+
+	// first_wins keeps the earlier value; the later one is recorded as overridden
+	merger.merge(merged, "baseline/tls", map[string]interface{}{"CA": "first"}, baseline)
+	merger.merge(merged, "baseline/tls", map[string]interface{}{"CA": "second"}, analytics)
+	ca := merger.provenance["baseline/tls"]["CA"]
+	assert.Equal(t, "security-baseline", ca.Winner.Source)
+	require.Len(t, ca.Overridden, 1)
+	assert.Equal(t, "analytics", ca.Overridden[0].Source)
+}
+
+func TestBundleMerger_ProvenanceMapReplace(t *testing.T) {
+	merger, err := newBundleMerger(Target{MergeStrategy: "map_replace"})
+	require.NoError(t, err)
+
+	merged := map[string]interface{}{}
+	merger.merge(merged, "app", map[string]interface{}{"a": "1", "b": "2"}, ValueOrigin{Source: "first"})
+	merger.merge(merged, "app", map[string]interface{}{"a": "3"}, ValueOrigin{Source: "second"})
+
+	keys := merger.provenance["app"]
+	assert.Len(t, keys, 1)
+	assert.Equal(t, "second", keys["a"].Winner.Source)
+}
+
+func TestRedactValue(t *testing.T) {
+	assert.Equal(t, "string(18)", redactValue("super-secret-value"))
+	assert.Equal(t, "string(4)", redactValue("1234"), "no fingerprint of short secrets")
+	assert.Equal(t, "null", redactValue(nil))
+	assert.Equal(t, "number", redactValue(1234.0))
+	assert.Equal(t, "object(1)", redactValue(map[string]interface{}{"a": 1.0}))
+	assert.Equal(t, "list(2)", redactValue([]interface{}{"a", "b"}))
+}
+
+func TestProvenanceRoundTrip(t *testing.T) {
+	prov := newBundleProvenance("Prod", "abc", []string{"analytics"}, map[string]map[string]*KeyProvenance{
+		"app/config": {"KEY": {Winner: ValueOrigin{Source: "analytics", Path: "analytics/app/config", Version: 2}}},
+	})
+
+	raw, err := provenanceToMap(prov)
+	require.NoError(t, err)
+	decoded, err := provenanceFromMap(raw)
+	require.NoError(t, err)
+
+	kp, ok := decoded.Lookup("app/config", "KEY")
+	require.True(t, ok)
+	assert.Equal(t, 2, kp.Winner.Version)
+	_, ok = decoded.Lookup("app/config", "MISSING")
+	assert.False(t, ok)
+}
+
+func TestTargetProvenancePath(t *testing.T) {
+	sources := []string{"analytics"}
+	path := TargetProvenancePath("merged-secrets", "Prod", sources)
+	assert.Equal(t, "merged-secrets/provenance/Prod/"+BundleID(sources), path)
+	assert.NotEqual(t, TargetBundlePath("merged-secrets", "Prod", sources), path)
+}
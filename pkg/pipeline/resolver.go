@@ -407,7 +407,13 @@ func AutoResolveConfig(ctx context.Context, cfg *Config, awsCtx *AWSExecutionCon
 
 	// Resolve target imports
 	for targetName, target := range cfg.Targets {
-		for i, imp := range target.Imports {
+		for i, spec := range target.Imports {
+			sel, err := ParseImport(spec)
+			if err != nil {
+				continue // Reported by Validate
+			}
+			imp := sel.Source
+
 			// Check if import is already a known source or target
 			if _, ok := cfg.Sources[imp]; ok {
 				continue // Already a known source
@@ -427,8 +433,9 @@ func AutoResolveConfig(ctx context.Context, cfg *Config, awsCtx *AWSExecutionCon
 					"confidence":   resolved.MatchConfidence,
 				}).Info("Auto-resolved import to AWS account")
 
-				// Update the import to use the resolved name
-				target.Imports[i] = resolved.ResolvedName
+				// Update the import to use the resolved name, keeping any selector
+				sel.Source = resolved.ResolvedName
+				target.Imports[i] = sel.String()
 
 				// Add as a source if not exists
 				if _, ok := cfg.Sources[resolved.ResolvedName]; !ok {
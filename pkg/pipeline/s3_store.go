@@ -12,6 +12,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/jbcom/secretsync/pkg/observability"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -36,7 +37,7 @@ func NewS3MergeStore(ctx context.Context, cfg *MergeStoreS3, region string) (*S3
 	})
 	l.Debug("Creating S3 merge store")
 
-	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	awsCfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region), config.WithAPIOptions(observability.AWSAPIOptions))
 	if err != nil {
 		return nil, fmt.Errorf("failed to load AWS config: %w", err)
 	}
@@ -230,6 +231,15 @@ func (s *S3MergeStore) bundleKey(targetName, bundleID string) string {
 	return fmt.Sprintf("%sbundles/%s/%s.json", prefix, targetName, bundleID)
 }
 
+// provenanceKey returns the S3 key for a bundle's provenance
+func (s *S3MergeStore) provenanceKey(targetName, bundleID string) string {
+	prefix := s.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return fmt.Sprintf("%sprovenance/%s/%s.json", prefix, targetName, bundleID)
+}
+
 // WriteMergedBundle writes a complete merged bundle to S3 as a single JSON blob
 func (s *S3MergeStore) WriteMergedBundle(ctx context.Context, targetName, bundleID string, secrets map[string]interface{}) error {
 	l := log.WithFields(log.Fields{
@@ -316,6 +326,63 @@ func (s *S3MergeStore) ReadMergedBundle(ctx context.Context, targetName, bundleI
 	return result, nil
 }
 
+// WriteProvenance writes the key-level provenance of a bundle to S3
+func (s *S3MergeStore) WriteProvenance(ctx context.Context, prov *BundleProvenance) error {
+	l := log.WithFields(log.Fields{
+		"action":   "S3MergeStore.WriteProvenance",
+		"bucket":   s.Bucket,
+		"target":   prov.Target,
+		"bundleID": prov.BundleID,
+	})
+	l.Debug("Writing bundle provenance to S3")
+
+	jsonData, err := json.Marshal(prov)
+	if err != nil {
+		return fmt.Errorf("failed to marshal provenance: %w", err)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.provenanceKey(prov.Target, prov.BundleID)),
+		Body:        bytes.NewReader(jsonData),
+		ContentType: aws.String("application/json"),
+	}
+	if s.KMSKeyID != "" {
+		input.ServerSideEncryption = "aws:kms"
+		input.SSEKMSKeyId = aws.String(s.KMSKeyID)
+	} else {
+		input.ServerSideEncryption = "AES256"
+	}
+
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+	return nil
+}
+
+// ReadProvenance reads the key-level provenance of a bundle from S3
+func (s *S3MergeStore) ReadProvenance(ctx context.Context, targetName, bundleID string) (*BundleProvenance, error) {
+	output, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.provenanceKey(targetName, bundleID)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object (has the merge phase run?): %w", err)
+	}
+	defer output.Body.Close()
+
+	body, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	var prov BundleProvenance
+	if err := json.Unmarshal(body, &prov); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal provenance: %w", err)
+	}
+	return &prov, nil
+}
+
 // DeleteBundle deletes a bundle from S3
 func (s *S3MergeStore) DeleteBundle(ctx context.Context, targetName, bundleID string) error {
 	l := log.WithFields(log.Fields{
@@ -0,0 +1,281 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaFailure is a secret value that failed JSON Schema validation.
+// It names where the value is, never the value itself.
+type SchemaFailure struct {
+	Path    string `json:"path"`    // Secret path in the bundle
+	Pointer string `json:"pointer"` // JSON pointer of the failing value within the secret
+	Schema  string `json:"schema"`  // Owner of the schema, e.g. "source analytics"
+	Message string `json:"message"`
+}
+
+func (f SchemaFailure) String() string {
+	return fmt.Sprintf("%s#%s (%s): %s", f.Path, f.Pointer, f.Schema, f.Message)
+}
+
+// SchemaValidationError is returned when merged secrets fail their schemas
+type SchemaValidationError struct {
+	Target   string
+	Failures []SchemaFailure
+}
+
+func (e *SchemaValidationError) Error() string {
+	parts := make([]string, 0, len(e.Failures))
+	for _, f := range e.Failures {
+		parts = append(parts, f.String())
+	}
+	return fmt.Sprintf("%d schema violations: %s", len(e.Failures), strings.Join(parts, "; "))
+}
+
+// compiledSecretSchema is a SecretSchema ready for validation
+type compiledSecretSchema struct {
+	owner  string
+	path   string
+	schema *jsonschema.Schema
+}
+
+// secretSchemas holds the compiled schemas of sources and targets
+type secretSchemas struct {
+	sources map[string][]compiledSecretSchema
+	targets map[string][]compiledSecretSchema
+}
+
+// compileSecretSchemas loads and compiles the schemas of all sources and targets.
+// Returns nil if none are configured.
+func compileSecretSchemas(cfg *Config) (*secretSchemas, error) {
+	set := &secretSchemas{
+		sources: make(map[string][]compiledSecretSchema),
+		targets: make(map[string][]compiledSecretSchema),
+	}
+	compiler := jsonschema.NewCompiler()
+	found := false
+
+	compileAll := func(kind, name string, schemas []SecretSchema) ([]compiledSecretSchema, error) {
+		var compiled []compiledSecretSchema
+		for i, s := range schemas {
+			found = true
+			field := fmt.Sprintf("%s %q: schemas[%d]", kind, name, i)
+			if s.Path == "" {
+				return nil, fmt.Errorf("%s: path is required", field)
+			}
+			pattern := strings.Trim(s.Path, "/")
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("%s: invalid path pattern %q: %w", field, s.Path, err)
+			}
+			if (s.Schema == nil) == (s.File == "") {
+				return nil, fmt.Errorf("%s: set exactly one of schema or file", field)
+			}
+
+			url := fmt.Sprintf("secretsync:///%s/%s/schemas/%d.json", kind, name, i)
+			var doc interface{}
+			var err error
+			if s.File != "" {
+				if url, err = filepath.Abs(s.File); err != nil {
+					return nil, fmt.Errorf("%s: %w", field, err)
+				}
+				doc, err = loadSchemaFile(s.File)
+			} else {
+				doc, err = normalizeJSON(s.Schema)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field, err)
+			}
+			if err := compiler.AddResource(url, doc); err != nil {
+				return nil, fmt.Errorf("%s: %w", field, err)
+			}
+			schema, err := compiler.Compile(url)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid schema: %w", field, err)
+			}
+			compiled = append(compiled, compiledSecretSchema{owner: kind + " " + name, path: pattern, schema: schema})
+		}
+		return compiled, nil
+	}
+
+	for name, src := range cfg.Sources {
+		compiled, err := compileAll("source", name, src.Schemas)
+		if err != nil {
+			return nil, err
+		}
+		set.sources[name] = compiled
+	}
+	for name, target := range cfg.Targets {
+		compiled, err := compileAll("target", name, target.Schemas)
+		if err != nil {
+			return nil, err
+		}
+		set.targets[name] = compiled
+	}
+	if !found {
+		return nil, nil
+	}
+	return set, nil
+}
+
+// loadSchemaFile reads a JSON or YAML schema file
+func loadSchemaFile(file string) (interface{}, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse schema file: %w", err)
+		}
+		return normalizeJSON(doc)
+	default:
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse schema file: %w", err)
+		}
+		return doc, nil
+	}
+}
+
+// normalizeJSON converts a decoded YAML or JSON value to the form the
+// validator expects (json.Number for numbers)
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return jsonschema.UnmarshalJSON(bytes.NewReader(data))
+}
+
+// forTarget returns the schemas that apply to a target's bundle: its own
+// followed by those of the sources it imports, directly or through inherited targets
+func (s *secretSchemas) forTarget(cfg *Config, targetName string) []compiledSecretSchema {
+	if s == nil {
+		return nil
+	}
+	schemas := append([]compiledSecretSchema{}, s.targets[targetName]...)
+	for _, src := range cfg.importedSources(targetName) {
+		schemas = append(schemas, s.sources[src.name]...)
+	}
+	return schemas
+}
+
+// validateSecretSchemas validates a target's merged secrets against the
+// schemas that apply to it. Returns a SchemaValidationError listing every
+// failure, or nil.
+func (p *Pipeline) validateSecretSchemas(targetName string, secrets map[string]interface{}) error {
+	schemas := p.schemas.forTarget(p.config, targetName)
+	if len(schemas) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(secrets))
+	for secretPath := range secrets {
+		paths = append(paths, secretPath)
+	}
+	sort.Strings(paths)
+
+	var failures []SchemaFailure
+	for _, secretPath := range paths {
+		var value interface{}
+		for _, s := range schemas {
+			if !matchPathGlob(s.path, secretPath) {
+				continue
+			}
+			if value == nil {
+				normalized, err := normalizeJSON(secrets[secretPath])
+				if err != nil {
+					failures = append(failures, SchemaFailure{Path: secretPath, Schema: s.owner, Message: "secret is not valid JSON"})
+					break
+				}
+				value = normalized
+			}
+			err := s.schema.Validate(value)
+			var verr *jsonschema.ValidationError
+			if errors.As(err, &verr) {
+				failures = append(failures, schemaFailures(secretPath, s.owner, verr)...)
+			} else if err != nil {
+				failures = append(failures, SchemaFailure{Path: secretPath, Schema: s.owner, Message: err.Error()})
+			}
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+	return &SchemaValidationError{Target: targetName, Failures: failures}
+}
+
+// schemaPrinter formats validation messages
+var schemaPrinter = message.NewPrinter(language.English)
+
+// schemaFailures flattens a validation error into one failure per failing keyword
+func schemaFailures(secretPath, owner string, verr *jsonschema.ValidationError) []SchemaFailure {
+	if len(verr.Causes) > 0 {
+		var failures []SchemaFailure
+		for _, cause := range verr.Causes {
+			failures = append(failures, schemaFailures(secretPath, owner, cause)...)
+		}
+		return failures
+	}
+	return []SchemaFailure{{
+		Path:    secretPath,
+		Pointer: jsonPointer(verr.InstanceLocation),
+		Schema:  owner,
+		Message: schemaMessage(verr.ErrorKind),
+	}}
+}
+
+// schemaMessage describes a failed keyword without the failing value.
+// The validator's own messages include the value for these keywords.
+func schemaMessage(k jsonschema.ErrorKind) string {
+	rat := func(r interface{ Float64() (float64, bool) }) float64 {
+		f, _ := r.Float64()
+		return f
+	}
+	switch k := k.(type) {
+	case *kind.Pattern:
+		return fmt.Sprintf("does not match pattern %q", k.Want)
+	case *kind.Format:
+		return fmt.Sprintf("is not a valid %s", k.Want)
+	case *kind.Minimum:
+		return fmt.Sprintf("minimum: want >= %v", rat(k.Want))
+	case *kind.Maximum:
+		return fmt.Sprintf("maximum: want <= %v", rat(k.Want))
+	case *kind.ExclusiveMinimum:
+		return fmt.Sprintf("exclusiveMinimum: want > %v", rat(k.Want))
+	case *kind.ExclusiveMaximum:
+		return fmt.Sprintf("exclusiveMaximum: want < %v", rat(k.Want))
+	case *kind.MultipleOf:
+		return fmt.Sprintf("multipleOf: want a multiple of %v", rat(k.Want))
+	case *kind.ContentEncoding:
+		return fmt.Sprintf("value is not %s encoded", k.Want)
+	case *kind.ContentMediaType:
+		return fmt.Sprintf("value is not of media type %s", k.Want)
+	}
+	return k.LocalizedString(schemaPrinter)
+}
+
+// jsonPointer formats an instance location as a JSON pointer (RFC 6901)
+func jsonPointer(tokens []string) string {
+	var sb strings.Builder
+	for _, tok := range tokens {
+		sb.WriteByte('/')
+		sb.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(tok))
+	}
+	return sb.String()
+}
@@ -0,0 +1,175 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const dbSchemaYAML = `
+type: object
+required: [host, port]
+properties:
+  host: {type: string}
+  port: {type: integer, minimum: 1}
+  user: {type: string, pattern: "^[a-z]+$"}
+`
+
+func schemaTestConfig(t *testing.T) *Config {
+	var schema map[string]interface{}
+	require.NoError(t, yaml.Unmarshal([]byte(dbSchemaYAML), &schema))
+	return &Config{
+		Sources: map[string]Source{
+			"analytics": {
+				Vault:   &VaultSource{Mount: "analytics"},
+				Schemas: []SecretSchema{{Path: "db/*", Schema: schema}},
+			},
+			"shared": {Vault: &VaultSource{Mount: "shared"}},
+		},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{
+			"Staging": {Imports: []string{"analytics", "shared"}},
+			"Production": {
+				Imports: []string{"Staging"},
+				Schemas: []SecretSchema{{Path: "api/**", Schema: map[string]interface{}{"required": []interface{}{"token"}}}},
+			},
+		},
+	}
+}
+
+func TestValidateSecretSchemas(t *testing.T) {
+	p, err := New(schemaTestConfig(t))
+	require.NoError(t, err)
+
+	valid := map[string]interface{}{
+		"db/main":    map[string]interface{}{"host": "db.example.com", "port": 5432},
+		"api/stripe": map[string]interface{}{"token": "sk_live"},
+		"other":      map[string]interface{}{"port": "not checked"},
+	}
+	assert.NoError(t, p.validateSecretSchemas("Production", valid))
+
+	invalid := map[string]interface{}{
+		"db/main":    map[string]interface{}{"host": "db.example.com", "port": "5432", "user": "Admin-S3cret"},
+		"db/replica": map[string]interface{}{"host": "replica", "port": -1.0},
+		"api/stripe": map[string]interface{}{"key": "sk_live_hidden"},
+	}
+	err = p.validateSecretSchemas("Production", invalid)
+	var schemaErr *SchemaValidationError
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Equal(t, "Production", schemaErr.Target)
+
+	require.Len(t, schemaErr.Failures, 4)
+	assert.Equal(t, SchemaFailure{Path: "api/stripe", Pointer: "", Schema: "target Production", Message: `missing property 'token'`}, schemaErr.Failures[0])
+
+	byPointer := map[string]SchemaFailure{}
+	for _, f := range schemaErr.Failures[1:] {
+		byPointer[f.Path+"#"+f.Pointer] = f
+	}
+	assert.Equal(t, "source analytics", byPointer["db/main#/port"].Schema)
+	assert.Contains(t, byPointer["db/main#/port"].Message, "want integer")
+	assert.Equal(t, `does not match pattern "^[a-z]+$"`, byPointer["db/main#/user"].Message)
+	assert.Equal(t, "minimum: want >= 1", byPointer["db/replica#/port"].Message)
+
+	// Values never appear in errors
+	for _, secret := range []string{"5432", "Admin-S3cret", "-1", "sk_live_hidden"} {
+		assert.NotContains(t, err.Error(), secret)
+	}
+
+	// Source schemas apply to targets importing the source directly
+	assert.Error(t, p.validateSecretSchemas("Staging", invalid))
+	assert.NoError(t, p.validateSecretSchemas("Staging", map[string]interface{}{"api/stripe": map[string]interface{}{}}))
+}
+
+func TestCompileSecretSchemas(t *testing.T) {
+	schemas, err := compileSecretSchemas(&Config{Targets: map[string]Target{"Production": {}}})
+	require.NoError(t, err)
+	assert.Nil(t, schemas, "no schemas configured")
+
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "db.yaml")
+	require.NoError(t, os.WriteFile(yamlFile, []byte(dbSchemaYAML), 0644))
+	jsonFile := filepath.Join(dir, "api.json")
+	require.NoError(t, os.WriteFile(jsonFile, []byte(`{"type": "object", "required": ["token"]}`), 0644))
+
+	cfg := &Config{Targets: map[string]Target{"Production": {Schemas: []SecretSchema{
+		{Path: "db/*", File: yamlFile},
+		{Path: "api/*", File: jsonFile},
+	}}}}
+	schemas, err = compileSecretSchemas(cfg)
+	require.NoError(t, err)
+	require.Len(t, schemas.targets["Production"], 2)
+
+	p := &Pipeline{config: cfg, schemas: schemas}
+	err = p.validateSecretSchemas("Production", map[string]interface{}{
+		"db/main":  map[string]interface{}{"host": "db"},
+		"api/mail": map[string]interface{}{},
+	})
+	var schemaErr *SchemaValidationError
+	require.ErrorAs(t, err, &schemaErr)
+	assert.Len(t, schemaErr.Failures, 2)
+
+	tests := []struct {
+		name    string
+		schemas []SecretSchema
+		want    string
+	}{
+		{"path", []SecretSchema{{Schema: map[string]interface{}{}}}, "path is required"},
+		{"pattern", []SecretSchema{{Path: "[db", Schema: map[string]interface{}{}}}, "invalid path pattern"},
+		{"neither", []SecretSchema{{Path: "db/*"}}, "exactly one of schema or file"},
+		{"both", []SecretSchema{{Path: "db/*", File: yamlFile, Schema: map[string]interface{}{}}}, "exactly one of schema or file"},
+		{"missing file", []SecretSchema{{Path: "db/*", File: filepath.Join(dir, "missing.json")}}, "failed to read schema file"},
+		{"invalid schema", []SecretSchema{{Path: "db/*", Schema: map[string]interface{}{"type": 5}}}, "invalid schema"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Targets: map[string]Target{"Production": {Schemas: tt.schemas}}}
+			_, err := compileSecretSchemas(cfg)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), `target "Production": schemas[0]`)
+			assert.Contains(t, err.Error(), tt.want)
+			assert.Error(t, cfg.Validate())
+		})
+	}
+}
+
+func TestHeldBackTargets(t *testing.T) {
+	results := []Result{
+		{Target: "Staging", Success: true},
+		{Target: "Production", Error: &SchemaValidationError{Target: "Production"}},
+		{Target: "Dev", Error: errors.New("access denied")},
+		{Target: "Analytics", Error: fmt.Errorf("merge: %w", &SourceReadError{Target: "Analytics", Sources: []string{"analytics"}})},
+	}
+	assert.Equal(t, map[string]bool{"Production": true, "Analytics": true}, heldBackTargets(results))
+	assert.Nil(t, heldBackTargets(results[:1]))
+}
+
+func TestSecretSchema_YAML(t *testing.T) {
+	var cfg Config
+	require.NoError(t, yaml.Unmarshal([]byte(`
+sources:
+  analytics:
+    vault: {mount: analytics}
+    schemas:
+      - path: db/*
+        schema:
+          type: object
+          required: [port]
+          properties:
+            port: {type: integer}
+targets:
+  Production:
+    imports: [analytics]
+    schemas:
+      - path: "**"
+        file: schemas/all.json
+`), &cfg))
+	require.Len(t, cfg.Sources["analytics"].Schemas, 1)
+	assert.Equal(t, "object", cfg.Sources["analytics"].Schemas[0].Schema["type"])
+	assert.Equal(t, "schemas/all.json", cfg.Targets["Production"].Schemas[0].File)
+}
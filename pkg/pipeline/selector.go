@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ImportSelector is a parsed entry of Target.Imports.
+//
+// Syntax: name[:/path-glob][#key1,key2]
+//
+//	analytics                whole source
+//	analytics:/db/*          only secrets matching the path glob
+//	analytics:/db/**         every secret under db
+//	analytics:/api#token,url only the "token" and "url" keys of the api secret
+//	analytics#token          only the "token" key, from every secret
+type ImportSelector struct {
+	Source string   // Source or target name
+	Path   string   // Path glob relative to the source, without leading "/"
+	Keys   []string // Keys to keep; empty keeps all keys
+}
+
+// ParseImport parses an import entry into its source name and selector
+func ParseImport(spec string) (ImportSelector, error) {
+	var sel ImportSelector
+
+	rest := spec
+	if idx := strings.Index(rest, "#"); idx >= 0 {
+		for _, key := range strings.Split(rest[idx+1:], ",") {
+			if key = strings.TrimSpace(key); key != "" {
+				sel.Keys = append(sel.Keys, key)
+			}
+		}
+		if len(sel.Keys) == 0 {
+			return sel, fmt.Errorf("import %q: empty key list after '#'", spec)
+		}
+		rest = rest[:idx]
+	}
+
+	if idx := strings.Index(rest, ":"); idx >= 0 {
+		sel.Path = strings.Trim(rest[idx+1:], "/")
+		if sel.Path == "" {
+			return sel, fmt.Errorf("import %q: empty path after ':'", spec)
+		}
+		if _, err := path.Match(sel.Path, ""); err != nil {
+			return sel, fmt.Errorf("import %q: invalid path pattern: %w", spec, err)
+		}
+		rest = rest[:idx]
+	}
+
+	sel.Source = strings.TrimSpace(rest)
+	if sel.Source == "" {
+		return sel, fmt.Errorf("import %q: missing source name", spec)
+	}
+	return sel, nil
+}
+
+// importSourceName returns the source or target name of an import entry.
+// Invalid selectors are reported by Config.Validate; here the raw entry is returned.
+func importSourceName(spec string) string {
+	sel, err := ParseImport(spec)
+	if err != nil {
+		return spec
+	}
+	return sel.Source
+}
+
+// HasSelector reports whether the import narrows its source
+func (s ImportSelector) HasSelector() bool {
+	return s.Path != "" || len(s.Keys) > 0
+}
+
+// Suffix returns the canonical selector text (e.g. ":/db/*#token,url").
+// It is appended to source paths so a different selection produces a different bundle.
+func (s ImportSelector) Suffix() string {
+	var sb strings.Builder
+	if s.Path != "" {
+		sb.WriteString(":/")
+		sb.WriteString(s.Path)
+	}
+	if len(s.Keys) > 0 {
+		sb.WriteString("#")
+		sb.WriteString(strings.Join(s.Keys, ","))
+	}
+	return sb.String()
+}
+
+// String returns the canonical import entry
+func (s ImportSelector) String() string {
+	return s.Source + s.Suffix()
+}
+
+// apply keeps only the secrets and keys selected by the import.
+// Secrets keyed by source-relative path; non-map secrets are kept whole when
+// no keys are selected and dropped otherwise.
+func (s ImportSelector) apply(secrets map[string]interface{}) map[string]interface{} {
+	if !s.HasSelector() {
+		return secrets
+	}
+
+	selected := make(map[string]interface{}, len(secrets))
+	for relPath, data := range secrets {
+		if s.Path != "" && !matchPathGlob(s.Path, relPath) {
+			continue
+		}
+		if len(s.Keys) == 0 {
+			selected[relPath] = data
+			continue
+		}
+		dataMap, ok := data.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kept := make(map[string]interface{}, len(s.Keys))
+		for _, key := range s.Keys {
+			if value, ok := dataMap[key]; ok {
+				kept[key] = value
+			}
+		}
+		if len(kept) > 0 {
+			selected[relPath] = kept
+		}
+	}
+	return selected
+}
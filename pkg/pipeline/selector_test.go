@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImport(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected ImportSelector
+		wantErr  bool
+	}{
+		{spec: "analytics", expected: ImportSelector{Source: "analytics"}},
+		{spec: "analytics:/db/*", expected: ImportSelector{Source: "analytics", Path: "db/*"}},
+		{spec: "analytics:/api#token,url", expected: ImportSelector{Source: "analytics", Path: "api", Keys: []string{"token", "url"}}},
+		{spec: "analytics#token", expected: ImportSelector{Source: "analytics", Keys: []string{"token"}}},
+		{spec: "analytics:db/**", expected: ImportSelector{Source: "analytics", Path: "db/**"}},
+		{spec: "analytics:/", wantErr: true},
+		{spec: "analytics#", wantErr: true},
+		{spec: ":/db/*", wantErr: true},
+		{spec: "analytics:/db/[", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			sel, err := ParseImport(tt.spec)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sel)
+		})
+	}
+}
+
+func TestImportSelector_String(t *testing.T) {
+	sel, err := ParseImport("analytics:db/*#token, url")
+	require.NoError(t, err)
+	assert.Equal(t, "analytics:/db/*#token,url", sel.String())
+	assert.Equal(t, "analytics", importSourceName("analytics:/db/*"))
+}
+
+func TestImportSelector_Apply(t *testing.T) {
+	secrets := map[string]interface{}{
+		"api":         map[string]interface{}{"token": "t", "url": "u", "admin": "a"},
+		"db/postgres": map[string]interface{}{"password": "p"},
+		"db/mysql":    map[string]interface{}{"password": "m"},
+		"raw":         "not-a-map",
+	}
+
+	byPath, _ := ParseImport("analytics:/db/*")
+	assert.Equal(t, map[string]interface{}{
+		"db/postgres": map[string]interface{}{"password": "p"},
+		"db/mysql":    map[string]interface{}{"password": "m"},
+	}, byPath.apply(secrets))
+
+	byKeys, _ := ParseImport("analytics:/api#token,url")
+	assert.Equal(t, map[string]interface{}{
+		"api": map[string]interface{}{"token": "t", "url": "u"},
+	}, byKeys.apply(secrets))
+
+	whole, _ := ParseImport("analytics")
+	assert.Equal(t, secrets, whole.apply(secrets))
+}
+
+func TestSelectorsChangeBundleID(t *testing.T) {
+	cfg := &Config{
+		Sources: map[string]Source{
+			"analytics": {Vault: &VaultSource{Mount: "kv/analytics"}},
+		},
+		MergeStore: MergeStoreConfig{Vault: &MergeStoreVault{Mount: "merged"}},
+		Targets: map[string]Target{
+			"Stg":    {Imports: []string{"analytics"}},
+			"Vendor": {Imports: []string{"analytics:/api#token"}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	assert.Equal(t, "kv/analytics:/api#token", cfg.GetSourcePath("analytics:/api#token"))
+
+	p := &Pipeline{config: cfg}
+	assert.NotEqual(t, p.getTargetBundleID(cfg.Targets["Stg"]), p.getTargetBundleID(cfg.Targets["Vendor"]))
+
+	graph, err := BuildGraph(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"analytics"}, graph.Nodes["Vendor"].Deps)
+
+	cfg.Targets["Bad"] = Target{Imports: []string{"analytics#"}}
+	assert.Error(t, cfg.Validate())
+}
@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultServeInterval is the schedule of the serve daemon when none is configured
+const DefaultServeInterval = time.Hour
+
+// Schedule is a set of targets the serve daemon runs on an interval
+type Schedule struct {
+	Name      string // "default", or the target name of an override
+	Interval  time.Duration
+	Jitter    time.Duration
+	Operation Operation
+	Targets   []string // nil means every target without its own schedule
+	Exclude   []string // targets with their own schedule (default schedule only)
+}
+
+// Schedules returns the default schedule followed by one schedule per
+// target override, sorted by target name
+func (s ServeConfig) Schedules() ([]Schedule, error) {
+	interval, err := parseServeDuration("serve.interval", s.Interval)
+	if err != nil {
+		return nil, err
+	}
+	if interval == 0 {
+		interval = DefaultServeInterval
+	}
+	jitter, err := parseServeDuration("serve.jitter", s.Jitter)
+	if err != nil {
+		return nil, err
+	}
+	op := s.Operation
+	if op == "" {
+		op = OperationPipeline
+	}
+
+	overrides := make([]string, 0, len(s.Targets))
+	for name := range s.Targets {
+		overrides = append(overrides, name)
+	}
+	sort.Strings(overrides)
+
+	schedules := []Schedule{{Name: "default", Interval: interval, Jitter: jitter, Operation: op, Exclude: overrides}}
+	for _, name := range overrides {
+		field := fmt.Sprintf("serve.targets.%s", name)
+		targetInterval, err := parseServeDuration(field, s.Targets[name])
+		if err != nil {
+			return nil, err
+		}
+		if targetInterval == 0 {
+			return nil, fmt.Errorf("%s: interval is required", field)
+		}
+		schedules = append(schedules, Schedule{
+			Name:      name,
+			Interval:  targetInterval,
+			Jitter:    jitter,
+			Operation: op,
+			Targets:   []string{name},
+		})
+	}
+	return schedules, nil
+}
+
+// DriftCheckInterval returns the interval of drift checks, or 0 if they are disabled
+func (s ServeConfig) DriftCheckInterval() (time.Duration, error) {
+	return parseServeDuration("serve.drift_interval", s.DriftInterval)
+}
+
+// validate checks the serve configuration against the configured targets
+func (s ServeConfig) validate(targets map[string]Target) error {
+	switch s.Operation {
+	case "", OperationMerge, OperationSync, OperationPipeline:
+	default:
+		return fmt.Errorf("serve.operation %q must be merge, sync or pipeline", s.Operation)
+	}
+	for name := range s.Targets {
+		if _, ok := targets[name]; !ok {
+			return fmt.Errorf("serve.targets: unknown target %q", name)
+		}
+	}
+	if _, err := s.DriftCheckInterval(); err != nil {
+		return err
+	}
+	_, err := s.Schedules()
+	return err
+}
+
+// parseServeDuration parses a duration; empty is zero
+func parseServeDuration(field, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", field, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s: must not be negative", field)
+	}
+	return d, nil
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeConfigSchedules(t *testing.T) {
+	schedules, err := ServeConfig{}.Schedules()
+	require.NoError(t, err)
+	require.Len(t, schedules, 1)
+	assert.Equal(t, DefaultServeInterval, schedules[0].Interval)
+	assert.Equal(t, OperationPipeline, schedules[0].Operation)
+	assert.Nil(t, schedules[0].Targets)
+
+	schedules, err = ServeConfig{
+		Interval:  "15m",
+		Jitter:    "30s",
+		Operation: OperationSync,
+		Targets:   map[string]string{"Prod": "5m", "Dev": "1h"},
+	}.Schedules()
+	require.NoError(t, err)
+	require.Len(t, schedules, 3)
+	assert.Equal(t, []string{"Dev", "Prod"}, schedules[0].Exclude)
+	assert.Equal(t, "Dev", schedules[1].Name)
+	assert.Equal(t, 5*time.Minute, schedules[2].Interval)
+	assert.Equal(t, 30*time.Second, schedules[2].Jitter)
+	assert.Equal(t, OperationSync, schedules[2].Operation)
+}
+
+func TestServeConfigValidate(t *testing.T) {
+	targets := map[string]Target{"Prod": {}}
+
+	assert.NoError(t, ServeConfig{Targets: map[string]string{"Prod": "5m"}}.validate(targets))
+	assert.ErrorContains(t, ServeConfig{Targets: map[string]string{"Missing": "5m"}}.validate(targets), "unknown target")
+	assert.ErrorContains(t, ServeConfig{Targets: map[string]string{"Prod": ""}}.validate(targets), "interval is required")
+	assert.ErrorContains(t, ServeConfig{Interval: "-1m"}.validate(targets), "serve.interval")
+	assert.ErrorContains(t, ServeConfig{Jitter: "soon"}.validate(targets), "serve.jitter")
+	assert.ErrorContains(t, ServeConfig{Operation: "delete"}.validate(targets), "serve.operation")
+	assert.ErrorContains(t, ServeConfig{DriftInterval: "hourly"}.validate(targets), "serve.drift_interval")
+	assert.NoError(t, ServeConfig{DriftInterval: "30m"}.validate(targets))
+}
@@ -72,9 +72,72 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 
 	l.WithField("secretsCount", len(secretsData)).Debug("Retrieved secrets from bundle")
 
+	// Bundles written before a schema was added are checked again here
+	bundle := make(map[string]interface{}, len(secretsData))
+	for secretPath, data := range secretsData {
+		bundle[secretPath] = data
+	}
+	if err := p.validateSecretSchemas(targetName, bundle); err != nil {
+		return Result{
+			Target:   targetName,
+			Phase:    "sync",
+			Success:  false,
+			Error:    err,
+			Duration: time.Since(start),
+		}
+	}
+	if err := p.checkSyncedBundlePolicies(ctx, targetName, target, bundle); err != nil {
+		return Result{
+			Target:   targetName,
+			Phase:    "sync",
+			Success:  false,
+			Error:    err,
+			Duration: time.Since(start),
+		}
+	}
+
+	// Apply target filters and transforms before anything is written
+	stored := secretsData
+	transformer, err := newSecretTransformer(target)
+	if err == nil {
+		secretsData, err = transformer.apply(secretsData)
+	}
+	if err != nil {
+		return Result{
+			Target:   targetName,
+			Phase:    "sync",
+			Success:  false,
+			Error:    fmt.Errorf("failed to apply filters and transforms: %w", err),
+			Duration: time.Since(start),
+		}
+	}
+	if transformer != nil {
+		l.WithField("secretsCount", len(secretsData)).Debug("Applied filters and transforms")
+	}
+
+	// Every name is checked before the first write, so a template that
+	// renders an invalid name for some paths cannot leave a partial sync
+	secretNames, err := p.awsSecretNames(targetName, secretsData)
+	if err != nil {
+		return Result{
+			Target:   targetName,
+			Phase:    "sync",
+			Success:  false,
+			Error:    err,
+			Duration: time.Since(start),
+		}
+	}
+
+	// Get role ARN and region for this target
+	roleARN := p.getRoleARNForTarget(target)
+	region := target.Region
+	if region == "" {
+		region = p.config.AWS.Region
+	}
+
 	if dryRun {
 		l.WithField("secretsCount", len(secretsData)).Info("[DRY-RUN] Would sync secrets to AWS")
-		return Result{
+		result := Result{
 			Target:    targetName,
 			Phase:     "sync",
 			Operation: string(OperationSync),
@@ -86,17 +149,38 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 				DestinationPath:  fmt.Sprintf("aws://%s", target.AccountID),
 			},
 		}
+
+		// Diff shows what would be written and, with delete_orphans, what would be removed
+		if p.pipelineDiff != nil {
+			targetDiff, err := p.computeSyncDiff(ctx, targetName, roleARN, region, secretsData)
+			if err != nil {
+				l.WithError(err).Debug("Failed to compute sync diff")
+			} else {
+				result.Diff = targetDiff
+				result.Details.SecretsRemoved = targetDiff.Summary.Removed
+				p.addTargetDiff(*targetDiff)
+			}
+		}
+		return result
 	}
 
-	// Get role ARN and region for this target
-	roleARN := p.getRoleARNForTarget(target)
-	region := target.Region
-	if region == "" {
-		region = p.config.AWS.Region
+	// When applying a plan, only the planned bundle is synced over the planned AWS state
+	desired := make(map[string]interface{}, len(secretsData))
+	for secretPath, data := range secretsData {
+		desired[secretNames[secretPath]] = data
+	}
+	if err := p.checkPlannedSync(ctx, targetName, target, stored, desired); err != nil {
+		return Result{
+			Target:   targetName,
+			Phase:    "sync",
+			Success:  false,
+			Error:    err,
+			Duration: time.Since(start),
+		}
 	}
 
 	// Initialize AWS client for target account
-	awsClient, err := p.getAWSClientForTarget(ctx, target)
+	awsClient, err := p.getAWSClientForTarget(ctx, targetName, target)
 	if err != nil {
 		return Result{
 			Target:   targetName,
@@ -110,10 +194,11 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 	// Sync each secret to AWS
 	var syncErrors []string
 	successCount := 0
+	desiredNames := make(map[string]bool, len(secretsData))
 
 	for secretPath, data := range secretsData {
-		// Determine AWS secret name
-		awsSecretName := p.getAWSSecretName(targetName, secretPath)
+		awsSecretName := secretNames[secretPath]
+		desiredNames[awsSecretName] = true
 
 		// Convert data to JSON bytes for AWS
 		secretBytes, err := json.Marshal(data)
@@ -145,6 +230,25 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 		successCount++
 	}
 
+	// Delete secrets this target previously managed that are no longer in the bundle
+	removedCount := 0
+	if p.config.Pipeline.Sync.DeleteOrphans {
+		orphans, err := p.findTargetOrphans(ctx, awsClient, targetName, desiredNames)
+		if err != nil {
+			l.WithError(err).Error("Failed to list secrets for orphan detection")
+			syncErrors = append(syncErrors, "orphan-detection")
+		}
+		for _, orphan := range orphans {
+			if err := awsClient.DeleteSecret(ctx, orphan); err != nil {
+				l.WithError(err).WithField("awsSecret", orphan).Error("Failed to delete orphaned secret")
+				syncErrors = append(syncErrors, orphan)
+				continue
+			}
+			l.WithField("awsSecret", orphan).Info("Deleted orphaned secret")
+			removedCount++
+		}
+	}
+
 	success := len(syncErrors) == 0
 	var lastErr error
 	if !success {
@@ -155,6 +259,7 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 		"duration": time.Since(start),
 		"success":  success,
 		"synced":   successCount,
+		"removed":  removedCount,
 		"failed":   len(syncErrors),
 	}).Info("Sync completed")
 
@@ -167,6 +272,7 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 		Duration:  time.Since(start),
 		Details: ResultDetails{
 			SecretsProcessed: successCount,
+			SecretsRemoved:   removedCount,
 			SourcePaths:      []string{bundlePath},
 			DestinationPath:  fmt.Sprintf("aws://%s", target.AccountID),
 			RoleARN:          roleARN,
@@ -175,7 +281,7 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 
 	// Compute diff if tracking is enabled
 	if p.pipelineDiff != nil {
-		targetDiff, err := p.computeSyncDiff(ctx, targetName, roleARN, region)
+		targetDiff, err := p.computeSyncDiff(ctx, targetName, roleARN, region, secretsData)
 		if err != nil {
 			l.WithError(err).Debug("Failed to compute sync diff")
 		} else {
@@ -187,7 +293,8 @@ func (p *Pipeline) syncTarget(ctx context.Context, targetName string, dryRun boo
 	return result
 }
 
-// readBundleSecrets reads all secrets from the merge store bundle
+// readBundleSecrets reads all secrets from the merge store bundle. It fails
+// if any secret cannot be read, since callers treat missing secrets as removed.
 func (p *Pipeline) readBundleSecrets(ctx context.Context, targetName, bundlePath string) (map[string]map[string]interface{}, error) {
 	secretsData := make(map[string]map[string]interface{})
 
@@ -205,10 +312,14 @@ func (p *Pipeline) readBundleSecrets(ctx context.Context, targetName, bundlePath
 			return nil, fmt.Errorf("failed to list secrets from bundle: %w", err)
 		}
 
+		// A secret missing from the result would be treated as removed, so
+		// the whole read fails if any secret cannot be read
+		var unreadable []string
 		for _, secretPath := range secrets {
 			data, err := mergeClient.GetKVSecretOnce(ctx, secretPath)
 			if err != nil {
 				log.WithError(err).WithField("secret", secretPath).Warn("Failed to read secret from bundle")
+				unreadable = append(unreadable, secretPath)
 				continue
 			}
 			// Use relative path within bundle
@@ -221,6 +332,9 @@ func (p *Pipeline) readBundleSecrets(ctx context.Context, targetName, bundlePath
 			}
 			secretsData[relPath] = data
 		}
+		if len(unreadable) > 0 {
+			return nil, fmt.Errorf("failed to read %d secrets from bundle: %v", len(unreadable), unreadable)
+		}
 	} else if p.s3Store != nil {
 		target, ok := p.config.Targets[targetName]
 		if !ok {
@@ -246,14 +360,17 @@ func (p *Pipeline) readBundleSecrets(ctx context.Context, targetName, bundlePath
 
 // getAWSClientForTarget returns an AWS client configured for the target account.
 // It handles cross-account role assumption via Control Tower or custom patterns.
-func (p *Pipeline) getAWSClientForTarget(ctx context.Context, target Target) (*aws.AwsClient, error) {
+// Secrets written through the client carry the target's ownership tags.
+func (p *Pipeline) getAWSClientForTarget(ctx context.Context, targetName string, target Target) (*aws.AwsClient, error) {
 	region := target.Region
 	if region == "" {
 		region = p.config.AWS.Region
 	}
 
 	client := &aws.AwsClient{
+		Name:   targetName,
 		Region: region,
+		Tags:   ownershipTags(targetName, p.getTargetBundleID(target)),
 	}
 
 	// If we have an AWS execution context with role assumption
@@ -269,15 +386,35 @@ func (p *Pipeline) getAWSClientForTarget(ctx context.Context, target Target) (*a
 	return client, nil
 }
 
+// findTargetOrphans lists the target account and returns secrets managed for
+// this target that are not in the desired set
+func (p *Pipeline) findTargetOrphans(ctx context.Context, awsClient *aws.AwsClient, targetName string, desired map[string]bool) ([]string, error) {
+	names, err := awsClient.ListSecrets(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	secretTags := make(map[string]map[string]string, len(names))
+	for _, name := range names {
+		secretTags[name] = awsClient.GetSecretTags(name)
+	}
+	return findOrphans(secretTags, desired, targetName), nil
+}
+
 // getRoleARNForTarget returns the role ARN for assuming into the target account
 func (p *Pipeline) getRoleARNForTarget(target Target) string {
-	if target.AccountID == "" {
+	return p.getRoleARNForAccount(target.AccountID)
+}
+
+// getRoleARNForAccount returns the role ARN for assuming into an account.
+// Shared by sync targets and AWS Secrets Manager sources.
+func (p *Pipeline) getRoleARNForAccount(accountID string) string {
+	if accountID == "" {
 		return ""
 	}
 
 	// Use custom role pattern if provided
 	if p.awsCtx != nil && p.config.AWS.ExecutionContext.CustomRolePattern != "" {
-		return fmt.Sprintf(p.config.AWS.ExecutionContext.CustomRolePattern, target.AccountID)
+		return fmt.Sprintf(p.config.AWS.ExecutionContext.CustomRolePattern, accountID)
 	}
 
 	// Use Control Tower execution role if enabled
@@ -286,15 +423,21 @@ func (p *Pipeline) getRoleARNForTarget(target Target) string {
 		if roleName == "" {
 			roleName = "AWSControlTowerExecution"
 		}
-		return fmt.Sprintf("arn:aws:iam::%s:role/%s", target.AccountID, roleName)
+		return fmt.Sprintf("arn:aws:iam::%s:role/%s", accountID, roleName)
 	}
 
 	return ""
 }
 
 // getAWSSecretName determines the AWS Secrets Manager secret name for a given path.
-func (p *Pipeline) getAWSSecretName(targetName, secretPath string) string {
-	// Default: use the secret path as-is
-	// Could be customized via target config or naming patterns
-	return secretPath
+// The target's secret_name_template is rendered (default: the path as-is) and
+// secret_prefix is prepended. Names AWS would reject are returned as errors.
+func (p *Pipeline) getAWSSecretName(targetName, secretPath string) (string, error) {
+	target := p.config.Targets[targetName]
+	region := target.Region
+	if region == "" {
+		region = p.config.AWS.Region
+	}
+	data := newSecretNameData(targetName, target.AccountID, region, secretPath)
+	return renderSecretName(target.SecretPrefix, target.SecretNameTemplate, data)
 }
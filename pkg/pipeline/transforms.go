@@ -0,0 +1,240 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// compiledFilter is a PatternFilter with its patterns prepared for matching
+type compiledFilter struct {
+	include []func(string) bool
+	exclude []func(string) bool
+}
+
+// matches reports whether a value passes the filter
+func (f *compiledFilter) matches(value string) bool {
+	if f == nil {
+		return true
+	}
+	for _, m := range f.exclude {
+		if m(value) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, m := range f.include {
+		if m(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileRegexFilter compiles a PatternFilter of regular expressions
+func compileRegexFilter(f *PatternFilter) (*compiledFilter, error) {
+	if f == nil {
+		return nil, nil
+	}
+	compile := func(patterns []string) ([]func(string) bool, error) {
+		var matchers []func(string) bool
+		for _, pattern := range patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+			}
+			matchers = append(matchers, re.MatchString)
+		}
+		return matchers, nil
+	}
+	include, err := compile(f.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compile(f.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &compiledFilter{include: include, exclude: exclude}, nil
+}
+
+// compilePathFilter compiles a PatternFilter of path globs
+func compilePathFilter(f *PatternFilter) (*compiledFilter, error) {
+	if f == nil {
+		return nil, nil
+	}
+	compile := func(patterns []string) ([]func(string) bool, error) {
+		var matchers []func(string) bool
+		for _, pattern := range patterns {
+			pattern := strings.Trim(pattern, "/")
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+			}
+			matchers = append(matchers, func(p string) bool { return matchPathGlob(pattern, p) })
+		}
+		return matchers, nil
+	}
+	include, err := compile(f.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compile(f.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	return &compiledFilter{include: include, exclude: exclude}, nil
+}
+
+// matchPathGlob matches a secret path against a glob pattern segment by segment.
+// "*" matches within a single segment; a trailing "**" matches any remaining segments.
+func matchPathGlob(pattern, secretPath string) bool {
+	patternSegs := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegs := strings.Split(strings.Trim(secretPath, "/"), "/")
+
+	if patternSegs[len(patternSegs)-1] == "**" {
+		patternSegs = patternSegs[:len(patternSegs)-1]
+		if len(pathSegs) < len(patternSegs) {
+			return false
+		}
+		pathSegs = pathSegs[:len(patternSegs)]
+	} else if len(patternSegs) != len(pathSegs) {
+		return false
+	}
+
+	for i, seg := range patternSegs {
+		if ok, _ := path.Match(seg, pathSegs[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// secretTransformer applies a target's filters and transforms to bundle secrets
+type secretTransformer struct {
+	regexFilter *compiledFilter
+	pathFilter  *compiledFilter
+	keyFilter   *compiledFilter
+	renames     []RenameTransform
+	template    *template.Template
+}
+
+// newSecretTransformer compiles a target's filters and transforms.
+// Returns nil if the target has neither configured.
+func newSecretTransformer(target Target) (*secretTransformer, error) {
+	if target.Filters == nil && target.Transforms == nil {
+		return nil, nil
+	}
+
+	t := &secretTransformer{}
+	var err error
+	if target.Filters != nil {
+		if t.regexFilter, err = compileRegexFilter(target.Filters.Regex); err != nil {
+			return nil, fmt.Errorf("filters.regex: %w", err)
+		}
+		if t.pathFilter, err = compilePathFilter(target.Filters.Path); err != nil {
+			return nil, fmt.Errorf("filters.path: %w", err)
+		}
+	}
+	if tr := target.Transforms; tr != nil {
+		if len(tr.Include) > 0 || len(tr.Exclude) > 0 {
+			if t.keyFilter, err = compileRegexFilter(&PatternFilter{Include: tr.Include, Exclude: tr.Exclude}); err != nil {
+				return nil, fmt.Errorf("transforms: %w", err)
+			}
+		}
+		for i, r := range tr.Rename {
+			if r.From == "" || r.To == "" {
+				return nil, fmt.Errorf("transforms.rename[%d]: from and to are required", i)
+			}
+		}
+		t.renames = tr.Rename
+		if tr.Template != "" {
+			if t.template, err = template.New("transform").Option("missingkey=error").Funcs(transformFuncs).Parse(tr.Template); err != nil {
+				return nil, fmt.Errorf("transforms.template: %w", err)
+			}
+		}
+	}
+	return t, nil
+}
+
+// ApplyTransforms filters secrets by path and transforms their keys the way
+// sync does for a target with these filters and transforms
+func ApplyTransforms(filters *TargetFilters, transforms *TargetTransforms, secrets map[string]map[string]interface{}) (map[string]map[string]interface{}, error) {
+	t, err := newSecretTransformer(Target{Filters: filters, Transforms: transforms})
+	if err != nil {
+		return nil, err
+	}
+	return t.apply(secrets)
+}
+
+// apply filters secrets by path and transforms their keys.
+// Secrets left without keys are dropped so empty secrets are never written.
+func (t *secretTransformer) apply(secrets map[string]map[string]interface{}) (map[string]map[string]interface{}, error) {
+	if t == nil {
+		return secrets, nil
+	}
+
+	result := make(map[string]map[string]interface{}, len(secrets))
+	for secretPath, data := range secrets {
+		if !t.regexFilter.matches(secretPath) || !t.pathFilter.matches(secretPath) {
+			continue
+		}
+
+		transformed, err := t.transformKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %w", secretPath, err)
+		}
+		if len(transformed) == 0 {
+			continue
+		}
+		result[secretPath] = transformed
+	}
+	return result, nil
+}
+
+// transformFuncs are the functions available to transforms.template.
+// json encodes a value as JSON, so values with quotes, backslashes or
+// newlines render as valid JSON strings.
+var transformFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// transformKeys applies key include/exclude, renames and the template to one secret
+func (t *secretTransformer) transformKeys(data map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if t.keyFilter.matches(key) {
+			out[key] = value
+		}
+	}
+
+	for _, r := range t.renames {
+		if value, ok := out[r.From]; ok {
+			delete(out, r.From)
+			out[r.To] = value
+		}
+	}
+
+	if t.template == nil {
+		return out, nil
+	}
+
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, out); err != nil {
+		return nil, fmt.Errorf("failed to render transforms.template: %w", err)
+	}
+	var rendered map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &rendered); err != nil {
+		// Never include the rendered output: it contains secret values
+		return nil, fmt.Errorf("transforms.template did not produce a JSON object")
+	}
+	return rendered, nil
+}
@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMatchPathGlob(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{"db/*", "db/postgres", true},
+		{"db/*", "db/postgres/main", false},
+		{"db/**", "db/postgres/main", true},
+		{"db/**", "db", true},
+		{"db/**", "api/token", false},
+		{"/api/*/token", "api/stripe/token", true},
+		{"api/str*", "api/stripe", true},
+		{"api", "api/stripe", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"_"+tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchPathGlob(tt.pattern, tt.path))
+		})
+	}
+}
+
+func TestSecretTransformer_Filters(t *testing.T) {
+	secrets := map[string]map[string]interface{}{
+		"api/stripe":      {"key": "a"},
+		"api/datadog":     {"key": "b"},
+		"db/postgres":     {"password": "c"},
+		"internal/config": {"debug": true},
+	}
+
+	transformer, err := newSecretTransformer(Target{
+		Filters: &TargetFilters{
+			Path:  &PatternFilter{Include: []string{"api/*", "db/**"}},
+			Regex: &PatternFilter{Exclude: []string{"datadog$"}},
+		},
+	})
+	require.NoError(t, err)
+
+	result, err := transformer.apply(secrets)
+	require.NoError(t, err)
+
+	assert.Len(t, result, 2)
+	assert.Contains(t, result, "api/stripe")
+	assert.Contains(t, result, "db/postgres")
+}
+
+func TestSecretTransformer_Keys(t *testing.T) {
+	secrets := map[string]map[string]interface{}{
+		"db/postgres": {
+			"DB_HOST":     "db.example.com",
+			"DB_PASSWORD": "hunter2",
+			"DB_INTERNAL": "x",
+			"OTHER":       "y",
+		},
+		"api/token": {"OTHER": "z"},
+	}
+
+	transformer, err := newSecretTransformer(Target{
+		Transforms: &TargetTransforms{
+			Include: []string{"^DB_"},
+			Exclude: []string{"INTERNAL"},
+			Rename:  []RenameTransform{{From: "DB_HOST", To: "HOST"}},
+		},
+	})
+	require.NoError(t, err)
+
+	result, err := transformer.apply(secrets)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"HOST":        "db.example.com",
+		"DB_PASSWORD": "hunter2",
+	}, result["db/postgres"])
+
+	// Secrets with no remaining keys are dropped
+	assert.NotContains(t, result, "api/token")
+}
+
+func TestSecretTransformer_Template(t *testing.T) {
+	transformer, err := newSecretTransformer(Target{
+		Transforms: &TargetTransforms{
+			Template: `{"url": "postgres://{{.user}}@{{.host}}"}`,
+		},
+	})
+	require.NoError(t, err)
+
+	result, err := transformer.apply(map[string]map[string]interface{}{
+		"db": {"user": "app", "host": "db.example.com"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"url": "postgres://app@db.example.com"}, result["db"])
+
+	// Missing keys and non-object output are errors
+	_, err = transformer.apply(map[string]map[string]interface{}{"db": {"user": "app"}})
+	assert.Error(t, err)
+
+	notJSON, err := newSecretTransformer(Target{Transforms: &TargetTransforms{Template: "{{.user}}"}})
+	require.NoError(t, err)
+	_, err = notJSON.apply(map[string]map[string]interface{}{"db": {"user": "s3cr3t"}})
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "s3cr3t")
+}
+
+func TestSecretTransformer_TemplateJSON(t *testing.T) {
+	transformer, err := newSecretTransformer(Target{
+		Transforms: &TargetTransforms{
+			Template: `{"password": {{json .password}}, "dsn": {{json (printf "postgres://%s@%s" .user .host)}}}`,
+		},
+	})
+	require.NoError(t, err)
+
+	password := "p\"a\\ss\nword"
+	result, err := transformer.apply(map[string]map[string]interface{}{
+		"db": {"user": `o"brien`, "host": "db.example.com", "password": password},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"password": password,
+		"dsn":      `postgres://o"brien@db.example.com`,
+	}, result["db"])
+}
+
+func TestNewSecretTransformer_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+	}{
+		{"Bad filter regex", Target{Filters: &TargetFilters{Regex: &PatternFilter{Include: []string{"("}}}}},
+		{"Bad path glob", Target{Filters: &TargetFilters{Path: &PatternFilter{Include: []string{"db/["}}}}},
+		{"Bad key regex", Target{Transforms: &TargetTransforms{Exclude: []string{"["}}}},
+		{"Empty rename", Target{Transforms: &TargetTransforms{Rename: []RenameTransform{{From: "a"}}}}},
+		{"Bad template", Target{Transforms: &TargetTransforms{Template: "{{.x"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSecretTransformer(tt.target)
+			assert.Error(t, err)
+		})
+	}
+
+	// No filters or transforms is a no-op
+	transformer, err := newSecretTransformer(Target{})
+	require.NoError(t, err)
+	assert.Nil(t, transformer)
+}
+
+func TestTarget_UnmarshalFiltersAndTransforms(t *testing.T) {
+	content := `
+account_id: "111111111111"
+imports: [analytics]
+filters:
+  path:
+    include: ["api/**"]
+transforms:
+  exclude: ["^INTERNAL_"]
+  rename:
+    - from: API_KEY
+      to: VENDOR_KEY
+`
+	var target Target
+	require.NoError(t, yaml.Unmarshal([]byte(content), &target))
+
+	require.NotNil(t, target.Filters)
+	assert.Equal(t, []string{"api/**"}, target.Filters.Path.Include)
+	require.NotNil(t, target.Transforms)
+	assert.Equal(t, []string{"^INTERNAL_"}, target.Transforms.Exclude)
+	assert.Equal(t, []RenameTransform{{From: "API_KEY", To: "VENDOR_KEY"}}, target.Transforms.Rename)
+}
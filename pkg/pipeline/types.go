@@ -1,8 +1,14 @@
 // Package pipeline provides unified configuration and orchestration for secrets syncing pipelines.
 package pipeline
 
+import "github.com/jbcom/secretsync/api/v1alpha1"
+
 // Config represents the unified pipeline configuration
 type Config struct {
+	// Include lists files and globs, relative to this file, whose sources,
+	// targets and dynamic_targets are merged into this configuration
+	Include []string `mapstructure:"include" yaml:"include,omitempty"`
+
 	Log            LogConfig                `mapstructure:"log" yaml:"log"`
 	Vault          VaultConfig              `mapstructure:"vault" yaml:"vault"`
 	AWS            AWSConfig                `mapstructure:"aws" yaml:"aws"`
@@ -11,6 +17,16 @@ type Config struct {
 	Targets        map[string]Target        `mapstructure:"targets" yaml:"targets"`
 	DynamicTargets map[string]DynamicTarget `mapstructure:"dynamic_targets" yaml:"dynamic_targets"`
 	Pipeline       PipelineSettings         `mapstructure:"pipeline" yaml:"pipeline"`
+	History        HistoryConfig            `mapstructure:"history" yaml:"history,omitempty"`
+	Serve          ServeConfig              `mapstructure:"serve" yaml:"serve,omitempty"`
+	Notifications  NotificationsConfig      `mapstructure:"notifications" yaml:"notifications,omitempty"`
+	Policies       PoliciesConfig           `mapstructure:"policies" yaml:"policies,omitempty"`
+
+	// Origins records the files the configuration was loaded from. Set by LoadConfig.
+	Origins ConfigOrigins `mapstructure:"-" yaml:"-" json:"-"`
+
+	// refs records the values resolved from references, by field path
+	refs map[string]configRef
 }
 
 // LogConfig controls logging behavior
@@ -137,6 +153,20 @@ type IdentityCenterConfig struct {
 type Source struct {
 	Vault *VaultSource `mapstructure:"vault" yaml:"vault"`
 	AWS   *AWSSource   `mapstructure:"aws" yaml:"aws"`
+
+	// Schemas validate the merged secrets of every target importing this source
+	Schemas []SecretSchema `mapstructure:"schemas" yaml:"schemas,omitempty"`
+}
+
+// SecretSchema attaches a JSON Schema to the secrets whose path matches a glob.
+// Set exactly one of Schema and File.
+type SecretSchema struct {
+	// Path is a secret path glob: "*" matches within a segment, a trailing "/**" a subtree
+	Path string `mapstructure:"path" yaml:"path"`
+	// Schema is an inline JSON Schema
+	Schema map[string]interface{} `mapstructure:"schema" yaml:"schema,omitempty"`
+	// File is a JSON or YAML schema file, relative to the working directory
+	File string `mapstructure:"file" yaml:"file,omitempty"`
 }
 
 // VaultSource imports secrets from a Vault KV2 mount
@@ -189,6 +219,66 @@ type Target struct {
 	Region       string   `mapstructure:"region" yaml:"region"`
 	SecretPrefix string   `mapstructure:"secret_prefix" yaml:"secret_prefix"`
 	RoleARN      string   `mapstructure:"role_arn" yaml:"role_arn"`
+
+	// SecretNameTemplate is a Go template for AWS secret names (default: "{{.Path}}").
+	// See SecretNameData for available variables. SecretPrefix is prepended to the result.
+	SecretNameTemplate string `mapstructure:"secret_name_template" yaml:"secret_name_template"`
+
+	// Filters select which bundle secrets are synced (by secret path)
+	Filters *TargetFilters `mapstructure:"filters" yaml:"filters,omitempty"`
+	// Transforms reshape the JSON keys inside each synced secret
+	Transforms *TargetTransforms `mapstructure:"transforms" yaml:"transforms,omitempty"`
+
+	// MergeStrategy controls how imports are merged: deep (default), list_replace,
+	// list_union, map_replace or first_wins
+	MergeStrategy string `mapstructure:"merge_strategy" yaml:"merge_strategy,omitempty"`
+	// MergeStrategyOverrides sets the strategy for secret paths matching a glob.
+	// The first matching override wins.
+	MergeStrategyOverrides []MergeStrategyOverride `mapstructure:"merge_strategy_overrides" yaml:"merge_strategy_overrides,omitempty"`
+
+	// Schemas validate the target's merged secrets before the bundle is written
+	Schemas []SecretSchema `mapstructure:"schemas" yaml:"schemas,omitempty"`
+}
+
+// MergeStrategyOverride sets the merge strategy for secret paths matching a glob
+type MergeStrategyOverride struct {
+	Path     string `mapstructure:"path" yaml:"path"`
+	Strategy string `mapstructure:"strategy" yaml:"strategy"`
+}
+
+// TargetFilters selects bundle secrets by path before sync.
+// A secret is synced if it passes both the regex and path filters.
+type TargetFilters struct {
+	Regex *PatternFilter `mapstructure:"regex" yaml:"regex,omitempty"`
+	Path  *PatternFilter `mapstructure:"path" yaml:"path,omitempty"`
+}
+
+// PatternFilter includes and excludes values by pattern.
+// Regex filters use Go regular expressions; path filters use glob patterns
+// where "*" matches within a segment and a trailing "/**" matches a subtree.
+// An empty include list includes everything; exclude always wins.
+type PatternFilter struct {
+	Include []string `mapstructure:"include" yaml:"include,omitempty"`
+	Exclude []string `mapstructure:"exclude" yaml:"exclude,omitempty"`
+}
+
+// TargetTransforms reshapes the JSON keys of each secret before sync.
+// Steps run in order: include, exclude, rename, template.
+type TargetTransforms struct {
+	// Include keeps only keys matching any of these regexes
+	Include []string `mapstructure:"include" yaml:"include,omitempty"`
+	// Exclude drops keys matching any of these regexes
+	Exclude []string `mapstructure:"exclude" yaml:"exclude,omitempty"`
+	// Rename renames keys (exact match)
+	Rename []RenameTransform `mapstructure:"rename" yaml:"rename,omitempty"`
+	// Template is a Go template rendered with the secret's keys that must produce a JSON object
+	Template string `mapstructure:"template" yaml:"template,omitempty"`
+}
+
+// RenameTransform renames a single JSON key
+type RenameTransform struct {
+	From string `mapstructure:"from" yaml:"from"`
+	To   string `mapstructure:"to" yaml:"to"`
 }
 
 // UnmarshalYAML implements custom YAML unmarshaling to support shorthand format.
@@ -219,9 +309,10 @@ type DynamicTarget struct {
 	// AccountNamePatterns maps discovered accounts to specific targets using regex
 	AccountNamePatterns []AccountNamePattern `mapstructure:"account_name_patterns" yaml:"account_name_patterns"`
 
-	Region       string `mapstructure:"region" yaml:"region"`
-	SecretPrefix string `mapstructure:"secret_prefix" yaml:"secret_prefix"`
-	RoleARN      string `mapstructure:"role_arn" yaml:"role_arn"`
+	Region             string `mapstructure:"region" yaml:"region"`
+	SecretPrefix       string `mapstructure:"secret_prefix" yaml:"secret_prefix"`
+	SecretNameTemplate string `mapstructure:"secret_name_template" yaml:"secret_name_template"`
+	RoleARN            string `mapstructure:"role_arn" yaml:"role_arn"`
 }
 
 // DiscoveryConfig defines how to discover dynamic targets
@@ -290,9 +381,121 @@ type PipelineSettings struct {
 	ContinueOnError bool          `mapstructure:"continue_on_error" yaml:"continue_on_error"`
 }
 
+// HistoryConfig configures where run history and audit records are written.
+// Every configured sink receives every record.
+type HistoryConfig struct {
+	JSONL *HistoryJSONL `mapstructure:"jsonl" yaml:"jsonl,omitempty"`
+	S3    *HistoryS3    `mapstructure:"s3" yaml:"s3,omitempty"`
+	Vault *HistoryVault `mapstructure:"vault" yaml:"vault,omitempty"`
+}
+
+// HistoryJSONL appends records to a local JSON Lines file
+type HistoryJSONL struct {
+	Path string `mapstructure:"path" yaml:"path"`
+}
+
+// HistoryS3 writes one object per record to S3
+type HistoryS3 struct {
+	Bucket   string `mapstructure:"bucket" yaml:"bucket"`
+	Prefix   string `mapstructure:"prefix" yaml:"prefix"`
+	Region   string `mapstructure:"region" yaml:"region"` // defaults to aws.region
+	KMSKeyID string `mapstructure:"kms_key_id" yaml:"kms_key_id"`
+}
+
+// HistoryVault writes one KV2 secret per record to Vault
+type HistoryVault struct {
+	Mount string `mapstructure:"mount" yaml:"mount"`
+	Path  string `mapstructure:"path" yaml:"path"` // defaults to secretsync/history
+}
+
+// ServeConfig configures the schedule of the serve daemon.
+// Durations use Go syntax (30s, 15m, 1h).
+type ServeConfig struct {
+	Interval  string            `mapstructure:"interval" yaml:"interval,omitempty"`   // default schedule (default 1h)
+	Jitter    string            `mapstructure:"jitter" yaml:"jitter,omitempty"`       // random delay of up to this added to each run
+	Operation Operation         `mapstructure:"operation" yaml:"operation,omitempty"` // default pipeline
+	Targets   map[string]string `mapstructure:"targets" yaml:"targets,omitempty"`     // per-target interval overrides
+
+	// DriftInterval enables drift checks of every target on this interval
+	DriftInterval string `mapstructure:"drift_interval" yaml:"drift_interval,omitempty"`
+}
+
+// NotificationsConfig configures the notifications sent after every run.
+// Channels use the notification types of the SecretSync CRD.
+type NotificationsConfig struct {
+	Channels []*v1alpha1.NotificationSpec `mapstructure:"channels" yaml:"channels,omitempty"`
+	// Template is the message body of channels without their own body
+	Template string `mapstructure:"template" yaml:"template,omitempty"`
+	// Attempts is the number of delivery attempts per channel (default 3)
+	Attempts int `mapstructure:"attempts" yaml:"attempts,omitempty"`
+	// Backoff is the delay before the first retry, doubled for each further retry (default 1s)
+	Backoff string `mapstructure:"backoff" yaml:"backoff,omitempty"`
+}
+
+// PoliciesConfig configures the policy rules checked by validate and at the
+// start of every run. Bundle rules are checked on each merged bundle before
+// it is written.
+type PoliciesConfig struct {
+	// FailOn is the lowest severity that blocks the run: error (default), warning, note or never
+	FailOn string       `mapstructure:"fail_on" yaml:"fail_on,omitempty"`
+	Rules  []PolicyRule `mapstructure:"rules" yaml:"rules,omitempty"`
+}
+
+// PolicyRule is a single policy check. Every check set on the rule applies
+// to every target the rule selects.
+type PolicyRule struct {
+	Name        string `mapstructure:"name" yaml:"name"`
+	Description string `mapstructure:"description" yaml:"description,omitempty"`
+	// Severity is error (default), warning or note
+	Severity string `mapstructure:"severity" yaml:"severity,omitempty"`
+	// Targets selects the targets the rule applies to; nil selects all targets
+	Targets *PolicyTargetSelector `mapstructure:"targets" yaml:"targets,omitempty"`
+
+	// DenyImports are globs of source names a target may not import,
+	// directly or through inherited targets
+	DenyImports []string `mapstructure:"deny_imports" yaml:"deny_imports,omitempty"`
+	// MaxImports limits the number of a target's direct imports (0 = no limit)
+	MaxImports int `mapstructure:"max_imports" yaml:"max_imports,omitempty"`
+	// Keys checks the keys of the secrets in the target's merged bundle
+	Keys *PolicyKeyRule `mapstructure:"keys" yaml:"keys,omitempty"`
+}
+
+// PolicyTargetSelector selects targets by name and AWS account tags.
+// A target is selected when it matches both.
+type PolicyTargetSelector struct {
+	// Names are target name globs; empty matches all
+	Names []string `mapstructure:"names" yaml:"names,omitempty"`
+	// AccountTags must all be set on the target's AWS account with these values
+	AccountTags map[string]string `mapstructure:"account_tags" yaml:"account_tags,omitempty"`
+}
+
+// PolicyKeyRule flags bundle keys matching Keys in secrets matching Paths.
+// With Deny the keys must not be present at all; otherwise string values
+// must be at least MinLength characters long.
+type PolicyKeyRule struct {
+	Paths     []string `mapstructure:"paths" yaml:"paths,omitempty"` // Secret path globs; empty matches all
+	Keys      []string `mapstructure:"keys" yaml:"keys"`             // Key regexes
+	Deny      bool     `mapstructure:"deny" yaml:"deny,omitempty"`
+	MinLength int      `mapstructure:"min_length" yaml:"min_length,omitempty"`
+}
+
 // MergeSettings configures the merge phase
 type MergeSettings struct {
 	Parallel int `mapstructure:"parallel" yaml:"parallel"`
+
+	// ProtectedKeys marks keys that later imports must not override
+	ProtectedKeys []ProtectedKeyRule `mapstructure:"protected_keys" yaml:"protected_keys,omitempty"`
+	// OnProtectedOverride is "warn" (default) or "fail"; fail aborts the target's merge
+	OnProtectedOverride string `mapstructure:"on_protected_override" yaml:"on_protected_override,omitempty"`
+}
+
+// ProtectedKeyRule marks keys supplied by a source as protected.
+// A key is protected when its overridden value came from Source (directly or
+// through an inherited target) and it matches Paths and Keys.
+type ProtectedKeyRule struct {
+	Source string   `mapstructure:"source" yaml:"source"`
+	Paths  []string `mapstructure:"paths" yaml:"paths,omitempty"` // Secret path globs; empty matches all
+	Keys   []string `mapstructure:"keys" yaml:"keys,omitempty"`   // Key regexes; empty matches all
 }
 
 // SyncSettings configures the sync phase
@@ -17,63 +17,10 @@ import (
 //	[(list, ["append"]), (dict, ["merge"]), (set, ["union"])],
 //	["override"], ["override"]
 //
-// The function modifies dst in place and returns the merged result.
+// The function modifies dst in place and returns the merged result. It is
+// Merger with MergeStrategyDeep, which holds the merge implementation.
 func DeepMerge(dst, src map[string]interface{}) map[string]interface{} {
-	if dst == nil {
-		dst = make(map[string]interface{})
-	}
-	if src == nil {
-		return dst
-	}
-
-	for key, srcVal := range src {
-		dstVal, exists := dst[key]
-
-		if !exists {
-			// Key doesn't exist in dst, just set it
-			dst[key] = deepCopyValue(srcVal)
-			continue
-		}
-
-		// Both exist, merge based on types
-		dst[key] = mergeValues(dstVal, srcVal)
-	}
-
-	return dst
-}
-
-// mergeValues merges two values according to deepmerge semantics
-func mergeValues(dst, src interface{}) interface{} {
-	// Handle nil cases
-	if src == nil {
-		return dst
-	}
-	if dst == nil {
-		return deepCopyValue(src)
-	}
-
-	// Type-based merging
-	switch srcTyped := src.(type) {
-	case map[string]interface{}:
-		// Dict merge (recursive)
-		if dstMap, ok := dst.(map[string]interface{}); ok {
-			return DeepMerge(dstMap, srcTyped)
-		}
-		// Type conflict: override
-		return deepCopyValue(src)
-
-	case []interface{}:
-		// List append
-		if dstSlice, ok := dst.([]interface{}); ok {
-			return appendSlices(dstSlice, srcTyped)
-		}
-		// Type conflict: override
-		return deepCopyValue(src)
-
-	default:
-		// Scalar or unknown type: override
-		return deepCopyValue(src)
-	}
+	return Merger{Strategy: MergeStrategyDeep}.Merge(dst, src)
 }
 
 // appendSlices appends src slice to dst slice (list append strategy)
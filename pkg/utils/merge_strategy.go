@@ -0,0 +1,144 @@
+package utils
+
+import "fmt"
+
+// MergeStrategy selects how values from a later source combine with earlier ones.
+type MergeStrategy string
+
+const (
+	// MergeStrategyDeep is the DeepMerge behavior: lists append, maps merge, scalars override
+	MergeStrategyDeep MergeStrategy = "deep"
+	// MergeStrategyListReplace merges maps but replaces lists instead of appending
+	MergeStrategyListReplace MergeStrategy = "list_replace"
+	// MergeStrategyListUnion merges maps and unions lists, dropping duplicate items
+	MergeStrategyListUnion MergeStrategy = "list_union"
+	// MergeStrategyMapReplace replaces the whole value with the later source (no key merging)
+	MergeStrategyMapReplace MergeStrategy = "map_replace"
+	// MergeStrategyFirstWins keeps values from the first source that set them; later sources only add missing keys
+	MergeStrategyFirstWins MergeStrategy = "first_wins"
+)
+
+// MergeStrategies lists all supported merge strategies
+var MergeStrategies = []MergeStrategy{
+	MergeStrategyDeep,
+	MergeStrategyListReplace,
+	MergeStrategyListUnion,
+	MergeStrategyMapReplace,
+	MergeStrategyFirstWins,
+}
+
+// ParseMergeStrategy validates a strategy name. An empty name is the deep strategy.
+func ParseMergeStrategy(name string) (MergeStrategy, error) {
+	if name == "" {
+		return MergeStrategyDeep, nil
+	}
+	for _, s := range MergeStrategies {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown merge strategy %q (must be one of %v)", name, MergeStrategies)
+}
+
+// Merger merges maps according to a MergeStrategy. It is the only merge
+// implementation: DeepMerge is a Merger with MergeStrategyDeep, which is also
+// what the zero value uses.
+type Merger struct {
+	Strategy MergeStrategy
+}
+
+// Merge merges src into dst and returns the result. dst may be modified in place.
+func (m Merger) Merge(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{})
+	}
+	if src == nil {
+		return dst
+	}
+
+	if m.Strategy == MergeStrategyMapReplace {
+		return deepCopyValue(src).(map[string]interface{})
+	}
+
+	for key, srcVal := range src {
+		dstVal, exists := dst[key]
+		if !exists {
+			dst[key] = deepCopyValue(srcVal)
+			continue
+		}
+		dst[key] = m.mergeValue(dstVal, srcVal)
+	}
+	return dst
+}
+
+// mergeValue merges two values according to the strategy
+func (m Merger) mergeValue(dst, src interface{}) interface{} {
+	if src == nil {
+		return dst
+	}
+	if dst == nil {
+		return deepCopyValue(src)
+	}
+
+	switch m.Strategy {
+	case MergeStrategyMapReplace:
+		return deepCopyValue(src)
+	case MergeStrategyFirstWins:
+		dstMap, dstIsMap := dst.(map[string]interface{})
+		srcMap, srcIsMap := src.(map[string]interface{})
+		if dstIsMap && srcIsMap {
+			return m.Merge(dstMap, srcMap)
+		}
+		return dst
+	}
+
+	switch srcTyped := src.(type) {
+	case map[string]interface{}:
+		if dstMap, ok := dst.(map[string]interface{}); ok {
+			return m.Merge(dstMap, srcTyped)
+		}
+		return deepCopyValue(src)
+
+	case []interface{}:
+		dstSlice, ok := dst.([]interface{})
+		if !ok {
+			return deepCopyValue(src)
+		}
+		switch m.Strategy {
+		case MergeStrategyListReplace:
+			return deepCopyValue(src)
+		case MergeStrategyListUnion:
+			return unionSlices(dstSlice, srcTyped)
+		default:
+			return appendSlices(dstSlice, srcTyped)
+		}
+
+	default:
+		return deepCopyValue(src)
+	}
+}
+
+// unionSlices appends src items not already present in dst (list union strategy)
+func unionSlices(dst, src []interface{}) []interface{} {
+	result := make([]interface{}, 0, len(dst)+len(src))
+	contains := func(v interface{}) bool {
+		for _, existing := range result {
+			if DeepEqual(existing, v) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, v := range dst {
+		if !contains(v) {
+			result = append(result, deepCopyValue(v))
+		}
+	}
+	for _, v := range src {
+		if !contains(v) {
+			result = append(result, deepCopyValue(v))
+		}
+	}
+	return result
+}
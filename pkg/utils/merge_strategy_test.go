@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMergeStrategy(t *testing.T) {
+	if s, err := ParseMergeStrategy(""); err != nil || s != MergeStrategyDeep {
+		t.Errorf("expected empty name to be deep, got %q, %v", s, err)
+	}
+	if s, err := ParseMergeStrategy("list_union"); err != nil || s != MergeStrategyListUnion {
+		t.Errorf("expected list_union, got %q, %v", s, err)
+	}
+	if _, err := ParseMergeStrategy("append_everything"); err == nil {
+		t.Error("expected error for unknown strategy")
+	}
+}
+
+func TestMerger_Strategies(t *testing.T) {
+	newDst := func() map[string]interface{} {
+		return map[string]interface{}{
+			"cidrs":  []interface{}{"10.0.0.0/8", "192.168.0.0/16"},
+			"db":     map[string]interface{}{"host": "a", "port": 5432},
+			"region": "us-east-1",
+		}
+	}
+	src := map[string]interface{}{
+		"cidrs":  []interface{}{"10.0.0.0/8", "172.16.0.0/12"},
+		"db":     map[string]interface{}{"host": "b"},
+		"region": "eu-west-1",
+		"new":    "value",
+	}
+
+	tests := []struct {
+		strategy MergeStrategy
+		expected map[string]interface{}
+	}{
+		{
+			strategy: MergeStrategyDeep,
+			expected: map[string]interface{}{
+				"cidrs":  []interface{}{"10.0.0.0/8", "192.168.0.0/16", "10.0.0.0/8", "172.16.0.0/12"},
+				"db":     map[string]interface{}{"host": "b", "port": 5432},
+				"region": "eu-west-1",
+				"new":    "value",
+			},
+		},
+		{
+			strategy: MergeStrategyListReplace,
+			expected: map[string]interface{}{
+				"cidrs":  []interface{}{"10.0.0.0/8", "172.16.0.0/12"},
+				"db":     map[string]interface{}{"host": "b", "port": 5432},
+				"region": "eu-west-1",
+				"new":    "value",
+			},
+		},
+		{
+			strategy: MergeStrategyListUnion,
+			expected: map[string]interface{}{
+				"cidrs":  []interface{}{"10.0.0.0/8", "192.168.0.0/16", "172.16.0.0/12"},
+				"db":     map[string]interface{}{"host": "b", "port": 5432},
+				"region": "eu-west-1",
+				"new":    "value",
+			},
+		},
+		{
+			strategy: MergeStrategyMapReplace,
+			expected: map[string]interface{}{
+				"cidrs":  []interface{}{"10.0.0.0/8", "172.16.0.0/12"},
+				"db":     map[string]interface{}{"host": "b"},
+				"region": "eu-west-1",
+				"new":    "value",
+			},
+		},
+		{
+			strategy: MergeStrategyFirstWins,
+			expected: map[string]interface{}{
+				"cidrs":  []interface{}{"10.0.0.0/8", "192.168.0.0/16"},
+				"db":     map[string]interface{}{"host": "a", "port": 5432},
+				"region": "us-east-1",
+				"new":    "value",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.strategy), func(t *testing.T) {
+			result := Merger{Strategy: tt.strategy}.Merge(newDst(), src)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMerger_ZeroValueMatchesDeepMerge(t *testing.T) {
+	dst := func() map[string]interface{} {
+		return map[string]interface{}{"tags": []interface{}{"prod"}, "a": map[string]interface{}{"x": 1}}
+	}
+	src := map[string]interface{}{"tags": []interface{}{"v2"}, "a": map[string]interface{}{"y": 2}}
+
+	if !reflect.DeepEqual(Merger{}.Merge(dst(), src), DeepMerge(dst(), src)) {
+		t.Error("expected zero-value Merger to match DeepMerge")
+	}
+}